@@ -3,21 +3,35 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	pathpkg "path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"text/tabwriter"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/ryanoboyle/bb-stream/internal/api"
 	"github.com/ryanoboyle/bb-stream/internal/b2"
 	"github.com/ryanoboyle/bb-stream/internal/config"
 	"github.com/ryanoboyle/bb-stream/internal/sync"
 	"github.com/ryanoboyle/bb-stream/internal/watch"
+	"github.com/ryanoboyle/bb-stream/pkg/apiclient"
+	"github.com/ryanoboyle/bb-stream/pkg/checksum"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+	"github.com/ryanoboyle/bb-stream/pkg/logging"
 	"github.com/ryanoboyle/bb-stream/pkg/progress"
+	"github.com/ryanoboyle/bb-stream/pkg/retry"
+	"github.com/ryanoboyle/bb-stream/pkg/uri"
 	"github.com/spf13/cobra"
 )
 
@@ -30,6 +44,8 @@ const (
 var rootCmd = &cobra.Command{
 	Use:   "bb-stream",
 	Short: "Backblaze B2 streaming file manager",
+	// main prints the full error detail itself
+	SilenceErrors: true,
 	Long: `bb-stream is a CLI tool for streaming files to and from Backblaze B2 cloud storage.
 
 Features:
@@ -39,20 +55,212 @@ Features:
   - HTTP API for programmatic access
   - Live Read support for reading files while they upload`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		// Skip config init for config commands
-		if cmd.Name() == "init" || cmd.Name() == "show" || cmd.Parent().Name() == "config" {
+		if format, _ := cmd.Flags().GetString("output"); format != "table" && format != "json" {
+			return fmt.Errorf("invalid --output %q: must be table or json", format)
+		}
+		if err := configureLogging(cmd); err != nil {
+			return err
+		}
+		if err := checkServerSupport(cmd); err != nil {
+			return err
+		}
+		if profile, _ := cmd.Flags().GetString("profile"); profile != "" {
+			config.SetProfile(profile)
+		}
+		if path, _ := cmd.Flags().GetString("config"); path != "" {
+			config.SetConfigPath(path)
+		}
+
+		// Config commands and doctor load the config themselves, so they
+		// still run when it doesn't validate
+		if cmd.Name() == "init" || cmd.Name() == "show" || cmd.Name() == "doctor" || cmd.Parent().Name() == "config" {
 			return nil
 		}
 		return config.Init()
 	},
 }
 
+// serverAnnotation marks commands that can run against an API server with --server
+const serverAnnotation = "server"
+
+// localAnnotation marks commands that never reach storage, such as config,
+// so --server and BB_SERVER don't apply to them. Subcommands inherit it.
+const localAnnotation = "local"
+
+// checkServerSupport rejects a command that reaches storage but can't do so
+// through the API server named by --server or BB_SERVER
+func checkServerSupport(cmd *cobra.Command) error {
+	if serverURL(cmd) == "" || cmd.Annotations[serverAnnotation] != "" || localOnly(cmd) {
+		return nil
+	}
+	return fmt.Errorf("%s is not supported with --server: the API server has no endpoint for it", cmd.CommandPath())
+}
+
+// localOnly reports whether cmd or a parent is annotated local, or it's one
+// of cobra's help and shell completion commands
+func localOnly(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Annotations[localAnnotation] != "" {
+			return true
+		}
+	}
+	switch cmd.Name() {
+	case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return true
+	}
+	return cmd.HasParent() && cmd.Parent().Name() == "completion"
+}
+
+// serverURL returns the --server flag, or BB_SERVER when the flag isn't given
+func serverURL(cmd *cobra.Command) string {
+	if server, _ := cmd.Flags().GetString("server"); server != "" {
+		return server
+	}
+	return os.Getenv("BB_SERVER")
+}
+
+// serverAPIKey returns the --api-key flag, or the configured api_key (BB_API_KEY)
+func serverAPIKey(cmd *cobra.Command) string {
+	if key, _ := cmd.Flags().GetString("api-key"); key != "" {
+		return key
+	}
+	return config.Get().APIKey
+}
+
+// newStorage returns the API server named by --server, or a B2 client using
+// the configured credentials when no server is given
+func newStorage(ctx context.Context, cmd *cobra.Command) (b2.Storage, error) {
+	if server := serverURL(cmd); server != "" {
+		return apiclient.New(server, serverAPIKey(cmd))
+	}
+	return b2.NewFromConfig(ctx)
+}
+
+// directClient returns client as a *b2.Client for features the API server
+// doesn't expose, or an error naming the feature when running with --server
+func directClient(client b2.Storage, feature string) (*b2.Client, error) {
+	c, ok := client.(*b2.Client)
+	if !ok {
+		return nil, fmt.Errorf("%s is not supported with --server", feature)
+	}
+	return c, nil
+}
+
+// configureLogging applies --log-level (or BB_LOG_LEVEL) and --log-format
+func configureLogging(cmd *cobra.Command) error {
+	levelName, _ := cmd.Flags().GetString("log-level")
+	if !cmd.Flags().Changed("log-level") {
+		if env := os.Getenv("BB_LOG_LEVEL"); env != "" {
+			levelName = env
+		}
+	}
+	level, err := logging.ParseLevel(levelName)
+	if err != nil {
+		return err
+	}
+	format, _ := cmd.Flags().GetString("log-format")
+	return logging.Configure(level, format)
+}
+
+// Doctor command
+var doctorCmd = &cobra.Command{
+	Use:          "doctor",
+	Short:        "Check configuration, credentials and bucket access",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		failed := 0
+
+		check := func(name string, err error, detail, hint string) bool {
+			if err != nil {
+				failed++
+				fmt.Printf("FAIL  %s: %s\n", name, errors.Sanitize(err))
+				if verbose {
+					fmt.Printf("      error: %s\n", errors.Detail(err))
+				}
+				fmt.Printf("      hint: %s\n", hint)
+				return false
+			}
+			fmt.Printf("PASS  %s: %s\n", name, detail)
+			return true
+		}
+
+		err := config.Load()
+		detail := config.GetConfigPath()
+		if _, statErr := os.Stat(detail); os.IsNotExist(statErr) {
+			detail += " (not found; using environment variables)"
+		}
+		hint := "Fix the reported field in " + config.GetConfigPath() + " or re-run 'bb-stream config init'"
+		if !check("Configuration", err, detail, hint) {
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+
+		// An invalid field is reported but doesn't stop the remaining checks
+		check("Validation", config.Get().Validate(), "all fields valid", hint)
+
+		profile := config.ActiveProfileName()
+		if !config.IsConfigured() {
+			err = fmt.Errorf("no credentials for profile %q", profile)
+		}
+		if !check("Credentials", err, "profile "+profile,
+			"Run 'bb-stream config init' or set BB_KEY_ID and BB_APP_KEY") {
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		client, err := b2.NewFromConfig(ctx)
+		if !check("Authentication", err, "authorized with B2",
+			"Check the key ID and application key are correct and the key hasn't been deleted") {
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+
+		buckets, err := client.ListBucketInfo(ctx)
+		if !check("Bucket listing", err, fmt.Sprintf("%d bucket(s) visible", len(buckets)),
+			"Check network access to api.backblazeb2.com and that the key has listBuckets capability") {
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+
+		if bucket := config.Get().DefaultBucket; bucket == "" {
+			fmt.Println("SKIP  Default bucket: not set")
+		} else {
+			var found *b2.BucketInfo
+			for i := range buckets {
+				if buckets[i].Name == bucket {
+					found = &buckets[i]
+				}
+			}
+			err, detail = nil, ""
+			if found == nil {
+				err = fmt.Errorf("bucket %q: %w", bucket, errors.ErrBucketNotFound)
+			} else {
+				detail = fmt.Sprintf("%s (%s)", found.Name, found.Type)
+			}
+			check("Default bucket", err, detail,
+				"Run 'bb-stream config set default_bucket <name>' with a bucket this key can access")
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+		return nil
+	},
+}
+
 // Version command
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("bb-stream version %s (API version %d)\n", Version, APIVersion)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		info := struct {
+			Version    string `json:"version"`
+			APIVersion int    `json:"api_version"`
+		}{Version, APIVersion}
+
+		return render(cmd, info, func() {
+			fmt.Printf("bb-stream version %s (API version %d)\n", Version, APIVersion)
+		})
 	},
 }
 
@@ -66,7 +274,7 @@ var configInitCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize configuration interactively",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := config.Init(); err != nil {
+		if err := config.Load(); err != nil {
 			return err
 		}
 
@@ -84,7 +292,7 @@ var configInitCmd = &cobra.Command{
 		bucket, _ := reader.ReadString('\n')
 		bucket = strings.TrimSpace(bucket)
 
-		config.SetCredentials(keyID, appKey)
+		config.SetProfileCredentials(config.ActiveProfileName(), keyID, appKey)
 		if bucket != "" {
 			config.SetDefaultBucket(bucket)
 		}
@@ -102,7 +310,7 @@ var configShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show current configuration",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := config.Init(); err != nil {
+		if err := config.Load(); err != nil {
 			return err
 		}
 
@@ -112,6 +320,62 @@ var configShowCmd = &cobra.Command{
 		fmt.Printf("Application Key: %s\n", maskKey(cfg.ApplicationKey))
 		fmt.Printf("Default Bucket: %s\n", cfg.DefaultBucket)
 		fmt.Printf("API Port: %d\n", cfg.APIPort)
+
+		fmt.Println("Profiles:")
+		active := config.ActiveProfileName()
+		for _, name := range config.ProfileNames() {
+			marker := " "
+			if name == active {
+				marker = "*"
+			}
+			creds, _ := config.GetProfile(name)
+			fmt.Printf("  %s %s (Key ID: %s)\n", marker, name, maskKey(creds.KeyID))
+		}
+		return nil
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print a configuration value",
+	Long:  "Print a configuration value. Valid keys: " + strings.Join(config.Keys(), ", "),
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.Load(); err != nil {
+			return err
+		}
+
+		value, err := config.GetValue(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set and save a configuration value",
+	Long:  "Set and save a configuration value. Valid keys: " + strings.Join(config.Keys(), ", "),
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.Load(); err != nil {
+			return err
+		}
+
+		if err := config.SetValue(args[0], args[1]); err != nil {
+			return err
+		}
+		if err := config.Save(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+
+		// Keys are often set one at a time, so a config that's still invalid
+		// is saved and reported rather than refused
+		if err := config.Get().Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Saved, but %s is still invalid: %v\n", config.GetConfigPath(), err)
+		}
 		return nil
 	},
 }
@@ -122,7 +386,7 @@ var lsCmd = &cobra.Command{
 	Short: "List buckets or files",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
-		client, err := b2.NewFromConfig(ctx)
+		client, err := newStorage(ctx, cmd)
 		if err != nil {
 			return err
 		}
@@ -134,12 +398,14 @@ var lsCmd = &cobra.Command{
 				return err
 			}
 
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tTYPE")
-			for _, b := range buckets {
-				fmt.Fprintf(w, "%s\t%s\n", b.Name, b.Type)
-			}
-			w.Flush()
+			return render(cmd, buckets, func() {
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "NAME\tTYPE")
+				for _, b := range buckets {
+					fmt.Fprintf(w, "%s\t%s\n", b.Name, b.Type)
+				}
+				w.Flush()
+			})
 		} else {
 			// List files in bucket
 			bucket := args[0]
@@ -148,23 +414,394 @@ var lsCmd = &cobra.Command{
 				prefix = args[1]
 			}
 
-			objects, err := client.ListObjects(ctx, bucket, prefix)
+			minTime, maxTime, err := getModTimeRange(cmd)
 			if err != nil {
 				return err
 			}
 
+			// B2 only filters by prefix, so the pattern is applied after listing
+			pattern, _ := cmd.Flags().GetString("pattern")
+			if _, err := b2.FilterObjects(nil, pattern); err != nil {
+				return err
+			}
+
+			var objects []b2.ObjectInfo
+			if tag, _ := cmd.Flags().GetString("tag"); tag != "" {
+				// Tags live in each object's metadata, so this reads every object under the prefix
+				direct, err := directClient(client, "--tag")
+				if err != nil {
+					return err
+				}
+				key, value, _ := strings.Cut(tag, "=")
+				if objects, err = direct.ListByTag(ctx, bucket, prefix, key, value); err != nil {
+					return err
+				}
+			} else if objects, err = client.ListObjects(ctx, bucket, prefix); err != nil {
+				return err
+			}
+			if objects, err = b2.FilterObjects(objects, pattern); err != nil {
+				return err
+			}
+
+			matched := make([]b2.ObjectInfo, 0, len(objects))
+			for _, obj := range objects {
+				if (minTime > 0 && obj.Timestamp < minTime) || (maxTime > 0 && obj.Timestamp > maxTime) {
+					continue
+				}
+				matched = append(matched, obj)
+			}
+
+			return render(cmd, matched, func() {
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "NAME\tSIZE\tMODIFIED")
+				for _, obj := range matched {
+					fmt.Fprintf(w, "%s\t%s\t%s\n",
+						obj.Name,
+						formatSize(obj.Size),
+						time.Unix(obj.Timestamp, 0).Format(time.RFC3339))
+				}
+				w.Flush()
+			})
+		}
+	},
+}
+
+// Search command
+var searchCmd = &cobra.Command{
+	Use:   "search <bucket> <query>",
+	Short: "Find files whose names contain a string",
+	Long: `Find files whose names contain query, ignoring case.
+
+B2 has no search API, so this scans the bucket's listing and stops once
+--limit matches are found. Narrow the scan with --prefix on large buckets,
+and pass the printed cursor as --cursor to see the next page.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bucket, query := args[0], args[1]
+		prefix, _ := cmd.Flags().GetString("prefix")
+		cursor, _ := cmd.Flags().GetString("cursor")
+		limit, _ := cmd.Flags().GetInt("limit")
+		if limit < 1 || limit > b2.MaxSearchLimit {
+			return fmt.Errorf("--limit must be between 1 and %d", b2.MaxSearchLimit)
+		}
+
+		ctx := context.Background()
+		client, err := newStorage(ctx, cmd)
+		if err != nil {
+			return err
+		}
+
+		result, err := b2.SearchObjects(ctx, client, bucket, prefix, query, cursor, limit)
+		if err != nil {
+			return err
+		}
+
+		return render(cmd, result, func() {
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 			fmt.Fprintln(w, "NAME\tSIZE\tMODIFIED")
-			for _, obj := range objects {
+			for _, obj := range result.Objects {
 				fmt.Fprintf(w, "%s\t%s\t%s\n",
 					obj.Name,
 					formatSize(obj.Size),
 					time.Unix(obj.Timestamp, 0).Format(time.RFC3339))
 			}
 			w.Flush()
+			if result.Cursor != "" {
+				fmt.Printf("\nMore matches: rerun with --cursor %q\n", result.Cursor)
+			}
+		})
+	},
+}
+
+// Tree command
+var treeCmd = &cobra.Command{
+	Use:   "tree <bucket> [prefix]",
+	Short: "Show bucket contents as a directory tree",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bucket := args[0]
+		prefix := ""
+		if len(args) > 1 {
+			prefix = args[1]
+		}
+		depth, _ := cmd.Flags().GetInt("depth")
+		if depth < 0 {
+			return fmt.Errorf("--depth must be zero or more")
 		}
 
-		return nil
+		ctx := context.Background()
+		client, err := b2.NewFromConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		root := newTree(bucket, prefix, depth)
+		err = client.WalkObjects(ctx, bucket, prefix, func(obj b2.ObjectInfo) error {
+			root.addObject(obj)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		root.sort()
+
+		return render(cmd, root, func() {
+			fmt.Printf("%s (%s)\n", root.Name, root.summary())
+			root.print("")
+		})
+	},
+}
+
+// treeNode is a file or directory in the tree command's output.
+// Directories aggregate the count and size of every file beneath them.
+type treeNode struct {
+	Name     string      `json:"name"`
+	Dir      bool        `json:"dir"`
+	Files    int         `json:"files,omitempty"`
+	Size     int64       `json:"size"`
+	Children []*treeNode `json:"children,omitempty"`
+
+	index    map[string]*treeNode
+	base     string // Prefix object names are shown relative to; root only
+	maxDepth int    // Levels kept below the root (0 = unlimited); root only
+}
+
+// newTree returns the root of a tree of bucket's objects under prefix, kept
+// maxDepth levels deep (0 = unlimited). Names are shown relative to the
+// directory the prefix names, so a partial prefix like "photos/20" still
+// nests under "photos/".
+func newTree(bucket, prefix string, maxDepth int) *treeNode {
+	base := prefix[:strings.LastIndex(prefix, "/")+1]
+	return &treeNode{Name: bucket + "/" + base, Dir: true, base: base, maxDepth: maxDepth}
+}
+
+// addObject records an object listed under the root's prefix
+func (n *treeNode) addObject(obj b2.ObjectInfo) {
+	rel := strings.TrimPrefix(obj.Name, n.base)
+	if rel == "" || strings.HasSuffix(rel, "/") {
+		return // Folder placeholders carry no data
+	}
+	n.add(strings.Split(rel, "/"), obj.Size, n.maxDepth)
+}
+
+// add records a file at the path given by parts, at most maxDepth levels
+// below n (0 = unlimited). Directories at the last level are summaries: their
+// contents are counted but not kept, so memory is bounded by what is shown.
+func (n *treeNode) add(parts []string, size int64, maxDepth int) {
+	n.Files++
+	n.Size += size
+
+	if len(parts) == 1 {
+		n.Children = append(n.Children, &treeNode{Name: parts[0], Size: size})
+		return
+	}
+
+	child := n.index[parts[0]]
+	if child == nil {
+		if n.index == nil {
+			n.index = make(map[string]*treeNode)
+		}
+		child = &treeNode{Name: parts[0] + "/", Dir: true}
+		n.index[parts[0]] = child
+		n.Children = append(n.Children, child)
+	}
+	if maxDepth == 1 {
+		child.Files++
+		child.Size += size
+		return
+	}
+	if maxDepth > 0 {
+		maxDepth--
+	}
+	child.add(parts[1:], size, maxDepth)
+}
+
+// sort orders children by name, recursively
+func (n *treeNode) sort() {
+	sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Name < n.Children[j].Name })
+	for _, c := range n.Children {
+		c.sort()
+	}
+	n.index = nil
+}
+
+// summary describes a directory's file count and total size
+func (n *treeNode) summary() string {
+	noun := "files"
+	if n.Files == 1 {
+		noun = "file"
+	}
+	return fmt.Sprintf("%d %s, %s", n.Files, noun, formatSize(n.Size))
+}
+
+// print writes the node's children with box-drawing indentation
+func (n *treeNode) print(indent string) {
+	for i, c := range n.Children {
+		branch, next := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			branch, next = "└── ", "    "
+		}
+		if c.Dir {
+			fmt.Printf("%s%s%s (%s)\n", indent, branch, c.Name, c.summary())
+			c.print(indent + next)
+		} else {
+			fmt.Printf("%s%s%s (%s)\n", indent, branch, c.Name, formatSize(c.Size))
+		}
+	}
+}
+
+// Du command
+var duCmd = &cobra.Command{
+	Use:   "du <bucket> [prefix]",
+	Short: "Show storage used per prefix",
+	Long: `Show the total size and object count of each top-level sub-prefix,
+largest first. With --all every directory level is reported.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bucket := args[0]
+		prefix := ""
+		if len(args) > 1 {
+			prefix = args[1]
+		}
+		all, _ := cmd.Flags().GetBool("all")
+
+		ctx := context.Background()
+		client, err := b2.NewFromConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		usage := newDiskUsage(prefix, all)
+		err = client.WalkObjects(ctx, bucket, prefix, func(obj b2.ObjectInfo) error {
+			usage.add(obj)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		entries, total := usage.entries(), usage.total
+		out := struct {
+			Entries []*duEntry `json:"entries"`
+			Total   duEntry    `json:"total"`
+		}{entries, total}
+		return render(cmd, out, func() {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SIZE\tFILES\tPREFIX")
+			for _, e := range entries {
+				fmt.Fprintf(w, "%s\t%d\t%s\n", formatSize(e.Size), e.Files, e.Prefix)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\n", formatSize(total.Size), total.Files, "total")
+			w.Flush()
+		})
+	},
+}
+
+// duEntry is the storage used under one prefix
+type duEntry struct {
+	Prefix string `json:"prefix"`
+	Files  int    `json:"files"`
+	Size   int64  `json:"size"`
+}
+
+// diskUsage totals the du command's objects per sub-prefix
+type diskUsage struct {
+	base  string // Directory the prefix names; entries are grouped below it
+	all   bool   // Count every directory level, not just the top one
+	usage map[string]*duEntry
+	total duEntry
+}
+
+// newDiskUsage groups objects relative to the directory prefix names, as
+// tree does
+func newDiskUsage(prefix string, all bool) *diskUsage {
+	base := prefix[:strings.LastIndex(prefix, "/")+1]
+	return &diskUsage{base: base, all: all, usage: make(map[string]*duEntry), total: duEntry{Prefix: base}}
+}
+
+// add counts an object listed under the prefix
+func (d *diskUsage) add(obj b2.ObjectInfo) {
+	rel := strings.TrimPrefix(obj.Name, d.base)
+	if rel == "" || strings.HasSuffix(rel, "/") {
+		return
+	}
+	d.total.Files++
+	d.total.Size += obj.Size
+
+	i := strings.Index(rel, "/")
+	if i < 0 {
+		// Files at the top level are reported on their own
+		d.count(rel, obj.Size)
+		return
+	}
+	if !d.all {
+		d.count(rel[:i+1], obj.Size)
+		return
+	}
+	for ; i < len(rel); i++ {
+		if rel[i] == '/' {
+			d.count(rel[:i+1], obj.Size)
+		}
+	}
+}
+
+func (d *diskUsage) count(key string, size int64) {
+	e := d.usage[key]
+	if e == nil {
+		e = &duEntry{Prefix: d.base + key}
+		d.usage[key] = e
+	}
+	e.Files++
+	e.Size += size
+}
+
+// entries returns the per-prefix totals, largest first
+func (d *diskUsage) entries() []*duEntry {
+	entries := make([]*duEntry, 0, len(d.usage))
+	for _, e := range d.usage {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Size != entries[j].Size {
+			return entries[i].Size > entries[j].Size
+		}
+		return entries[i].Prefix < entries[j].Prefix
+	})
+	return entries
+}
+
+// Stat command
+var statCmd = &cobra.Command{
+	Use:   "stat <bucket/path>",
+	Short: "Show information about a file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loc, err := uri.ParseObject(args[0])
+		if err != nil {
+			return err
+		}
+		bucket, path := loc.Bucket, loc.Key
+
+		ctx := context.Background()
+		client, err := b2.NewFromConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		info, err := client.GetObjectInfo(ctx, bucket, path)
+		if err != nil {
+			return err
+		}
+
+		return render(cmd, info, func() {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "Name:\t%s\n", info.Name)
+			fmt.Fprintf(w, "Size:\t%s (%d bytes)\n", formatSize(info.Size), info.Size)
+			fmt.Fprintf(w, "Content-Type:\t%s\n", info.ContentType)
+			fmt.Fprintf(w, "Modified:\t%s\n", time.Unix(info.Timestamp, 0).Format(time.RFC3339))
+			fmt.Fprintf(w, "SHA1:\t%s\n", info.SHA1)
+			w.Flush()
+		})
 	},
 }
 
@@ -177,12 +814,23 @@ var uploadCmd = &cobra.Command{
 		localFile := args[0]
 		remotePath := args[1]
 
-		// Parse bucket/path
-		parts := strings.SplitN(remotePath, "/", 2)
-		if len(parts) < 2 {
-			return fmt.Errorf("remote path must be in format: bucket/path")
+		loc, err := uri.ParseObject(remotePath)
+		if err != nil {
+			return err
+		}
+		bucket, path := loc.Bucket, loc.Key
+
+		// Parse custom metadata
+		metaPairs, _ := cmd.Flags().GetStringArray("meta")
+		info, err := b2.ParseFileInfo(metaPairs)
+		if err != nil {
+			return err
+		}
+
+		limitRate, err := getLimitRate(cmd)
+		if err != nil {
+			return err
 		}
-		bucket, path := parts[0], parts[1]
 
 		// Open file
 		f, err := os.Open(localFile)
@@ -191,28 +839,81 @@ var uploadCmd = &cobra.Command{
 		}
 		defer f.Close()
 
-		info, err := f.Stat()
+		stat, err := f.Stat()
 		if err != nil {
 			return fmt.Errorf("failed to stat file: %w", err)
 		}
 
-		ctx := context.Background()
-		client, err := b2.NewFromConfig(ctx)
+		ctx, _, stop := transferContext()
+		defer stop()
+
+		client, err := newStorage(ctx, cmd)
 		if err != nil {
 			return err
 		}
 
-		// Progress callback using progress.Callback type
-		var progressCb progress.Callback = func(transferred, total int64) {
-			percent := float64(transferred) / float64(total) * 100
-			fmt.Printf("\rUploading: %s / %s (%.1f%%)", formatSize(transferred), formatSize(total), percent)
+		progressCb := newProgressPrinter("Uploading", stat.Size())
+
+		opts := b2.DefaultUploadOptions()
+		opts.Info = info
+		opts.MaxBytesPerSec = limitRate
+		opts.ProgressCallback = progressCb
+		opts.Compress, _ = cmd.Flags().GetBool("gzip")
+		opts.Encrypt, _ = cmd.Flags().GetBool("encrypt")
+		opts.Passphrase = os.Getenv(b2.PassphraseEnv)
+		if opts.ChecksumAlgorithm, err = getChecksumAlgorithm(cmd); err != nil {
+			return err
+		}
+		if suffix, _ := cmd.Flags().GetBool("gz-suffix"); suffix && opts.Compress {
+			path += ".gz"
 		}
 
 		fmt.Printf("Uploading %s to %s/%s\n", localFile, bucket, path)
-		err = client.UploadWithProgress(ctx, bucket, path, f, info.Size(), progressCb)
+		var result *b2.UploadResult
+		ifChanged, _ := cmd.Flags().GetBool("if-changed")
+		if resumable, _ := cmd.Flags().GetBool("resumable"); resumable {
+			if ifChanged {
+				return fmt.Errorf("--resumable and --if-changed cannot be combined")
+			}
+			var direct *b2.Client
+			if direct, err = directClient(client, "--resumable"); err != nil {
+				return err
+			}
+			manifest := b2.ManifestPath(filepath.Dir(config.GetConfigPath()), localFile, bucket, path)
+			resumableOpts := &b2.ResumableOptions{UploadOptions: *opts, Manifest: manifest}
+			if v, _ := cmd.Flags().GetString("part-size"); v != "" {
+				if resumableOpts.PartSize, err = parseSize(v); err != nil {
+					return fmt.Errorf("invalid --part-size: %w", err)
+				}
+			}
+			result, err = direct.UploadFileResumable(ctx, localFile, bucket, path, resumableOpts)
+			if err != nil && ctx.Err() == nil && !stderrors.Is(err, errors.ErrSourceChanged) {
+				err = fmt.Errorf("%w (run the same command again to resume)", err)
+			}
+		} else if ifChanged {
+			var direct *b2.Client
+			if direct, err = directClient(client, "--if-changed"); err != nil {
+				return err
+			}
+			useChecksum, _ := cmd.Flags().GetBool("checksum")
+			result, err = direct.UploadIfChanged(ctx, bucket, path, f, stat.Size(), useChecksum, opts)
+		} else {
+			err = client.Upload(ctx, bucket, path, f, stat.Size(), opts)
+		}
+		if ctx.Err() != nil {
+			fmt.Println()
+			if resumable, _ := cmd.Flags().GetBool("resumable"); resumable {
+				return fmt.Errorf("upload interrupted; run the same command again to resume")
+			}
+			return fmt.Errorf("upload interrupted")
+		}
 		if err != nil {
 			return err
 		}
+		if result != nil && result.Skipped {
+			fmt.Println("Skipped: remote file is unchanged")
+			return nil
+		}
 
 		fmt.Println("\nUpload complete!")
 		return nil
@@ -228,15 +929,21 @@ var downloadCmd = &cobra.Command{
 		remotePath := args[0]
 		localFile := args[1]
 
-		// Parse bucket/path
-		parts := strings.SplitN(remotePath, "/", 2)
-		if len(parts) < 2 {
-			return fmt.Errorf("remote path must be in format: bucket/path")
+		loc, err := uri.ParseObject(remotePath)
+		if err != nil {
+			return err
 		}
-		bucket, path := parts[0], parts[1]
+		bucket, path := loc.Bucket, loc.Key
 
-		ctx := context.Background()
-		client, err := b2.NewFromConfig(ctx)
+		limitRate, err := getLimitRate(cmd)
+		if err != nil {
+			return err
+		}
+
+		ctx, _, stop := transferContext()
+		defer stop()
+
+		client, err := newStorage(ctx, cmd)
 		if err != nil {
 			return err
 		}
@@ -247,21 +954,23 @@ var downloadCmd = &cobra.Command{
 			return fmt.Errorf("failed to get object info: %w", err)
 		}
 
-		// Create local file
-		f, err := os.Create(localFile)
-		if err != nil {
-			return fmt.Errorf("failed to create file: %w", err)
-		}
-		defer f.Close()
+		progressCb := newProgressPrinter("Downloading", objInfo.Size)
 
-		// Progress callback using progress.Callback type
-		var progressCb progress.Callback = func(transferred, total int64) {
-			percent := float64(transferred) / float64(total) * 100
-			fmt.Printf("\rDownloading: %s / %s (%.1f%%)", formatSize(transferred), formatSize(total), percent)
-		}
+		opts := b2.DefaultDownloadOptions()
+		opts.MaxBytesPerSec = limitRate
+		opts.ProgressCallback = progressCb
+		raw, _ := cmd.Flags().GetBool("raw")
+		opts.Decompress = !raw
+		opts.Decrypt, _ = cmd.Flags().GetBool("decrypt")
+		opts.Passphrase = os.Getenv(b2.PassphraseEnv)
 
 		fmt.Printf("Downloading %s/%s to %s\n", bucket, path, localFile)
-		err = client.DownloadWithProgress(ctx, bucket, path, f, progressCb)
+		// The file only appears once the download completes
+		_, err = client.DownloadToFile(ctx, bucket, path, localFile, opts)
+		if ctx.Err() != nil {
+			fmt.Println()
+			return fmt.Errorf("download interrupted")
+		}
 		if err != nil {
 			return err
 		}
@@ -279,15 +988,14 @@ var rmCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		remotePath := args[0]
 
-		// Parse bucket/path
-		parts := strings.SplitN(remotePath, "/", 2)
-		if len(parts) < 2 {
-			return fmt.Errorf("remote path must be in format: bucket/path")
+		loc, err := uri.ParseObject(remotePath)
+		if err != nil {
+			return err
 		}
-		bucket, path := parts[0], parts[1]
+		bucket, path := loc.Bucket, loc.Key
 
 		ctx := context.Background()
-		client, err := b2.NewFromConfig(ctx)
+		client, err := newStorage(ctx, cmd)
 		if err != nil {
 			return err
 		}
@@ -312,6 +1020,482 @@ var rmCmd = &cobra.Command{
 	},
 }
 
+// Cleanup command
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup <bucket>",
+	Short: "Cancel unfinished large file uploads",
+	Long: `Cancel large file uploads that were started but never finished, such as
+interrupted --resumable uploads. B2 keeps and bills for their parts until
+they're cancelled.
+
+Only uploads older than --older-than are cancelled, so uploads still in
+progress are left alone. A --resumable upload whose parts are cancelled
+starts over the next time it runs.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bucket := args[0]
+
+		olderThanFlag, _ := cmd.Flags().GetString("older-than")
+		olderThan, err := b2.ParseAge(olderThanFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --older-than: %w", err)
+		}
+
+		ctx := context.Background()
+		client, err := b2.NewFromConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		var uploads []b2.UnfinishedUpload
+		verb := "Cancelled"
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			verb = "Would cancel"
+			all, err := client.ListUnfinishedUploads(ctx, bucket)
+			if err != nil {
+				return err
+			}
+			uploads = b2.StartedBefore(all, olderThan)
+		} else if uploads, err = client.CleanupUnfinished(ctx, bucket, olderThan); err != nil {
+			return err
+		}
+
+		return render(cmd, uploads, func() {
+			if len(uploads) == 0 {
+				fmt.Printf("No unfinished uploads older than %s\n", olderThanFlag)
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tSTARTED\tFILE ID")
+			for _, u := range uploads {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", u.Name, time.Unix(u.Timestamp, 0).Format(time.RFC3339), u.FileID)
+			}
+			w.Flush()
+			fmt.Printf("\n%s %d unfinished upload(s)\n", verb, len(uploads))
+		})
+	},
+}
+
+// Move command
+var mvCmd = &cobra.Command{
+	Use:   "mv <bucket/src> <bucket/dst>",
+	Short: "Move a file within B2 using a server-side copy",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		src, err := uri.ParseObject(args[0])
+		if err != nil {
+			return err
+		}
+		dst, err := uri.ParseObject(args[1])
+		if err != nil {
+			return err
+		}
+		srcBucket, srcPath := src.Bucket, src.Key
+		dstBucket, dstPath := dst.Bucket, dst.Key
+
+		opts := b2.DefaultCopyOptions()
+		opts.DestBucket = dstBucket
+
+		directive, _ := cmd.Flags().GetString("metadata-directive")
+		switch strings.ToLower(directive) {
+		case "copy":
+			opts.Directive = b2.MetadataCopy
+		case "replace":
+			opts.Directive = b2.MetadataReplace
+		default:
+			return fmt.Errorf("--metadata-directive must be copy or replace")
+		}
+
+		opts.ContentType, _ = cmd.Flags().GetString("content-type")
+		metaPairs, _ := cmd.Flags().GetStringArray("meta")
+		info, err := b2.ParseFileInfo(metaPairs)
+		if err != nil {
+			return err
+		}
+		opts.Info = info
+
+		ctx := context.Background()
+		client, err := b2.NewFromConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := client.Copy(ctx, srcBucket, srcPath, dstPath, opts); err != nil {
+			return err
+		}
+
+		// Only remove the source once the copy exists
+		if err := client.DeleteObject(ctx, srcBucket, srcPath); err != nil {
+			return fmt.Errorf("copied to %s/%s but failed to delete source: %w", dstBucket, dstPath, err)
+		}
+
+		fmt.Printf("Moved %s/%s to %s/%s\n", srcBucket, srcPath, dstBucket, dstPath)
+		return nil
+	},
+}
+
+// Tag command
+var tagCmd = &cobra.Command{
+	Use:   "tag <bucket/path> [key=value...]",
+	Short: "Show or add tags on a file",
+	Long: `Show a file's tags, or add and update tags given as key=value.
+
+Tags are stored in the file's B2 metadata. B2 metadata can't be changed in
+place, so tagging makes a server-side copy of the file onto itself: a new
+version with the same content. The previous version remains (and is billed)
+until it's deleted or hidden by a lifecycle rule. Files over 5GB can't be
+tagged. Use 'ls <bucket> --tag key=value' to find tagged files.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loc, err := uri.ParseObject(args[0])
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		client, err := b2.NewFromConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		tags, err := client.GetObjectTags(ctx, loc.Bucket, loc.Key)
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 1 {
+			return render(cmd, tags, func() {
+				keys := make([]string, 0, len(tags))
+				for k := range tags {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					fmt.Printf("%s=%s\n", k, tags[k])
+				}
+			})
+		}
+
+		for _, pair := range args[1:] {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid tag %q: must be in format key=value", pair)
+			}
+			tags[strings.ToLower(key)] = value
+		}
+		if err := client.SetObjectTags(ctx, loc.Bucket, loc.Key, tags); err != nil {
+			return err
+		}
+		fmt.Printf("Tagged %s/%s\n", loc.Bucket, loc.Key)
+		return nil
+	},
+}
+
+// Untag command
+var untagCmd = &cobra.Command{
+	Use:   "untag <bucket/path> <key...>",
+	Short: "Remove tags from a file",
+	Long: `Remove the named tags from a file. Like tag, this writes a new version of
+the file with the updated metadata.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loc, err := uri.ParseObject(args[0])
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		client, err := b2.NewFromConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		tags, err := client.GetObjectTags(ctx, loc.Bucket, loc.Key)
+		if err != nil {
+			return err
+		}
+
+		removed := 0
+		for _, key := range args[1:] {
+			key = strings.ToLower(key)
+			if _, ok := tags[key]; ok {
+				delete(tags, key)
+				removed++
+			}
+		}
+		if removed == 0 {
+			fmt.Println("No matching tags; nothing changed")
+			return nil
+		}
+
+		if err := client.SetObjectTags(ctx, loc.Bucket, loc.Key, tags); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d tags from %s/%s\n", removed, loc.Bucket, loc.Key)
+		return nil
+	},
+}
+
+// Copy command
+var cpCmd = &cobra.Command{
+	Use:   "cp <src> <dst>",
+	Short: "Copy files between local paths and B2",
+	Long: `Copy a file between a local path and B2, or between two B2 locations.
+
+Remote locations are written as b2://bucket/path; anything else is a local
+path. Copies within B2 are done server-side. A destination ending in "/"
+(or an existing local directory) keeps the source's file name.
+
+With -r, directories are copied recursively: local to B2 and B2 to local
+work like push and pull, and B2 to B2 copies every object under the prefix.
+
+Examples:
+  bb-stream cp ./report.pdf b2://mybucket/docs/
+  bb-stream cp b2://mybucket/docs/report.pdf .
+  bb-stream cp b2://mybucket/a.txt b2://archive/a.txt
+  bb-stream cp -r ./photos b2://mybucket/photos`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		src, err := uri.Parse(args[0])
+		if err != nil {
+			return err
+		}
+		dst, err := uri.Parse(args[1])
+		if err != nil {
+			return err
+		}
+		if !src.Remote && !dst.Remote {
+			return fmt.Errorf("at least one side must be a b2://bucket/path location")
+		}
+		recursive, _ := cmd.Flags().GetBool("recursive")
+
+		limitRate, err := getLimitRate(cmd)
+		if err != nil {
+			return err
+		}
+
+		ctx, stopping, stop := transferContext()
+		defer stop()
+
+		client, err := b2.NewFromConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case recursive && src.Remote && dst.Remote:
+			return copyPrefix(ctx, stopping, client, src, dst)
+		case recursive:
+			opts := sync.DefaultSyncOptions()
+			opts.MaxBytesPerSec = limitRate
+			opts.Stop = stopping.Done()
+			local, remote := src.Path, dst
+			opts.Direction = sync.ToRemote
+			if src.Remote {
+				local, remote = dst.Path, src
+				opts.Direction = sync.ToLocal
+			}
+			result, err := sync.NewSyncer(client, opts).Sync(ctx, local, remote.Bucket, remote.Key)
+			if result != nil {
+				printSyncResult(cmd, result, false)
+			}
+			if stopping.Err() != nil {
+				return fmt.Errorf("copy interrupted before all files were copied")
+			}
+			return err
+		case src.Remote && dst.Remote:
+			dstKey := dst.Key
+			if dstKey == "" || strings.HasSuffix(dstKey, "/") {
+				dstKey += pathpkg.Base(src.Key)
+			}
+			opts := b2.DefaultCopyOptions()
+			opts.DestBucket = dst.Bucket
+			if err := client.Copy(ctx, src.Bucket, src.Key, dstKey, opts); err != nil {
+				return err
+			}
+			fmt.Printf("Copied %s to b2://%s/%s\n", src, dst.Bucket, dstKey)
+			return nil
+		case dst.Remote:
+			return copyUp(ctx, client, src.Path, dst, limitRate)
+		default:
+			return copyDown(ctx, client, src, dst.Path, limitRate)
+		}
+	},
+}
+
+// copyUp uploads a local file to a B2 location
+func copyUp(ctx context.Context, client *b2.Client, localFile string, dst uri.Location, limitRate int64) error {
+	f, err := os.Open(localFile)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if stat.IsDir() {
+		return fmt.Errorf("%s is a directory (use -r to copy directories)", localFile)
+	}
+
+	key := dst.Key
+	if key == "" || strings.HasSuffix(key, "/") {
+		key += filepath.Base(localFile)
+	}
+
+	opts := b2.DefaultUploadOptions()
+	opts.MaxBytesPerSec = limitRate
+	opts.ProgressCallback = newProgressPrinter("Uploading", stat.Size())
+
+	fmt.Printf("Uploading %s to b2://%s/%s\n", localFile, dst.Bucket, key)
+	err = client.Upload(ctx, dst.Bucket, key, f, stat.Size(), opts)
+	if ctx.Err() != nil {
+		fmt.Println()
+		return fmt.Errorf("upload interrupted")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("\nUpload complete!")
+	return nil
+}
+
+// copyDown downloads a B2 object to a local path
+func copyDown(ctx context.Context, client *b2.Client, src uri.Location, localPath string, limitRate int64) error {
+	if src.Key == "" || strings.HasSuffix(src.Key, "/") {
+		return fmt.Errorf("%s is a prefix (use -r to copy directories)", src)
+	}
+
+	if info, err := os.Stat(localPath); (err == nil && info.IsDir()) || strings.HasSuffix(localPath, string(filepath.Separator)) {
+		localPath = filepath.Join(localPath, pathpkg.Base(src.Key))
+	}
+
+	objInfo, err := client.GetObjectInfo(ctx, src.Bucket, src.Key)
+	if err != nil {
+		return fmt.Errorf("failed to get object info: %w", err)
+	}
+
+	opts := b2.DefaultDownloadOptions()
+	opts.MaxBytesPerSec = limitRate
+	opts.ProgressCallback = newProgressPrinter("Downloading", objInfo.Size)
+	opts.Decompress = true
+
+	fmt.Printf("Downloading %s to %s\n", src, localPath)
+	_, err = client.DownloadToFile(ctx, src.Bucket, src.Key, localPath, opts)
+	if ctx.Err() != nil {
+		fmt.Println()
+		return fmt.Errorf("download interrupted")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nDownload complete! (%s)\n", formatSize(objInfo.Size))
+	return nil
+}
+
+// copyPrefix copies every object under src to the same relative names under
+// dst, server-side. No further copies start once stopping is cancelled.
+func copyPrefix(ctx, stopping context.Context, client *b2.Client, src, dst uri.Location) error {
+	srcPrefix, dstPrefix := src.Key, dst.Key
+	if srcPrefix != "" && !strings.HasSuffix(srcPrefix, "/") {
+		srcPrefix += "/"
+	}
+	if dstPrefix != "" && !strings.HasSuffix(dstPrefix, "/") {
+		dstPrefix += "/"
+	}
+
+	objects, err := client.ListObjects(ctx, src.Bucket, srcPrefix)
+	if err != nil {
+		return err
+	}
+
+	opts := b2.DefaultCopyOptions()
+	opts.DestBucket = dst.Bucket
+	copied := 0
+	for _, obj := range objects {
+		if stopping.Err() != nil {
+			return fmt.Errorf("copy interrupted after %d of %d files", copied, len(objects))
+		}
+		dstKey := dstPrefix + strings.TrimPrefix(obj.Name, srcPrefix)
+		if err := client.Copy(ctx, src.Bucket, obj.Name, dstKey, opts); err != nil {
+			return fmt.Errorf("copied %d of %d files: %w", copied, len(objects), err)
+		}
+		copied++
+	}
+	fmt.Printf("Copied %d files to b2://%s/%s\n", copied, dst.Bucket, dstPrefix)
+	return nil
+}
+
+// Versions command
+var versionsCmd = &cobra.Command{
+	Use:   "versions <bucket/path>",
+	Short: "List or delete stored versions of a file",
+	Long: `List every stored version of a file in B2.
+
+Deleting the newest version with --delete restores the previous one,
+which undoes an accidental overwrite.
+
+Examples:
+  bb-stream versions mybucket/report.pdf
+  bb-stream versions mybucket/report.pdf --delete <file-id>`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		remotePath := args[0]
+
+		loc, err := uri.ParseObject(remotePath)
+		if err != nil {
+			return err
+		}
+		bucket, path := loc.Bucket, loc.Key
+
+		ctx := context.Background()
+		client, err := b2.NewFromConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		fileID, _ := cmd.Flags().GetString("delete")
+		if fileID != "" {
+			force, _ := cmd.Flags().GetBool("force")
+			if !force {
+				fmt.Printf("Delete version %s of %s/%s? [y/N]: ", fileID, bucket, path)
+				reader := bufio.NewReader(os.Stdin)
+				response, _ := reader.ReadString('\n')
+				if strings.ToLower(strings.TrimSpace(response)) != "y" {
+					fmt.Println("Aborted")
+					return nil
+				}
+			}
+
+			if err := client.DeleteVersion(ctx, bucket, path, fileID); err != nil {
+				return err
+			}
+
+			fmt.Printf("Deleted version %s of %s/%s\n", fileID, bucket, path)
+			return nil
+		}
+
+		versions, err := client.ListObjectVersions(ctx, bucket, path)
+		if err != nil {
+			return err
+		}
+
+		return render(cmd, versions, func() {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "FILE ID\tSIZE\tUPLOADED\tACTION")
+			for _, v := range versions {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+					v.FileID,
+					formatSize(v.Size),
+					time.Unix(v.Timestamp, 0).Format(time.RFC3339),
+					v.Action)
+			}
+			w.Flush()
+		})
+	},
+}
+
 // Stream upload command
 var streamUpCmd = &cobra.Command{
 	Use:   "stream-up <bucket/path>",
@@ -320,12 +1504,11 @@ var streamUpCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		remotePath := args[0]
 
-		// Parse bucket/path
-		parts := strings.SplitN(remotePath, "/", 2)
-		if len(parts) < 2 {
-			return fmt.Errorf("remote path must be in format: bucket/path")
+		loc, err := uri.ParseObject(remotePath)
+		if err != nil {
+			return err
 		}
-		bucket, path := parts[0], parts[1]
+		bucket, path := loc.Bucket, loc.Key
 
 		ctx := context.Background()
 		client, err := b2.NewFromConfig(ctx)
@@ -334,12 +1517,14 @@ var streamUpCmd = &cobra.Command{
 		}
 
 		fmt.Fprintf(os.Stderr, "Streaming stdin to %s/%s...\n", bucket, path)
-		if err := client.StreamUpload(ctx, bucket, path, os.Stdin, nil); err != nil {
+		result, err := client.StreamUploadWithResult(ctx, bucket, path, os.Stdin, nil)
+		if err != nil {
 			return err
 		}
 
-		fmt.Fprintln(os.Stderr, "Stream upload complete!")
-		return nil
+		return render(cmd, result, func() {
+			fmt.Fprintf(os.Stderr, "Stream upload complete! %s, SHA1 %s\n", formatSize(result.Size), result.SHA1)
+		})
 	},
 }
 
@@ -351,12 +1536,11 @@ var streamDownCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		remotePath := args[0]
 
-		// Parse bucket/path
-		parts := strings.SplitN(remotePath, "/", 2)
-		if len(parts) < 2 {
-			return fmt.Errorf("remote path must be in format: bucket/path")
+		loc, err := uri.ParseObject(remotePath)
+		if err != nil {
+			return err
 		}
-		bucket, path := parts[0], parts[1]
+		bucket, path := loc.Bucket, loc.Key
 
 		ctx := context.Background()
 		client, err := b2.NewFromConfig(ctx)
@@ -374,75 +1558,358 @@ var syncCmd = &cobra.Command{
 	Short: "Sync files between local and B2",
 	Long: `Sync files between a local directory and a B2 bucket.
 
+The remote side may be written as bucket/path or b2://bucket/path. With the
+b2:// form the direction is inferred, so --to-remote and --to-local can be
+left out.
+
+With --bidirectional, changes flow both ways and files changed on both sides
+are resolved by --conflict: newer (the default), local, remote or skip.
+
 Examples:
   bb-stream sync ./local-folder mybucket/backup --to-remote
-  bb-stream sync mybucket/backup ./local-folder --to-local`,
+  bb-stream sync mybucket/backup ./local-folder --to-local
+  bb-stream sync ./local-folder b2://mybucket/backup
+  bb-stream sync ./local-folder mybucket/backup --bidirectional --conflict local`,
 	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		source := args[0]
-		dest := args[1]
-
 		toRemote, _ := cmd.Flags().GetBool("to-remote")
 		toLocal, _ := cmd.Flags().GetBool("to-local")
+
+		if bidirectional, _ := cmd.Flags().GetBool("bidirectional"); bidirectional {
+			if toRemote || toLocal {
+				return fmt.Errorf("--bidirectional cannot be combined with --to-remote or --to-local")
+			}
+			local, remote := args[0], args[1]
+			if uri.IsRemote(local) && !uri.IsRemote(remote) {
+				local, remote = remote, local
+			}
+			return runSync(cmd, sync.Bidirectional, local, remote)
+		}
+
+		switch {
+		case toRemote:
+			return runSync(cmd, sync.ToRemote, args[0], args[1])
+		case toLocal:
+			return runSync(cmd, sync.ToLocal, args[1], args[0])
+		}
+
+		switch srcRemote, dstRemote := uri.IsRemote(args[0]), uri.IsRemote(args[1]); {
+		case srcRemote && dstRemote:
+			return fmt.Errorf("cannot sync between two B2 locations")
+		case dstRemote:
+			return runSync(cmd, sync.ToRemote, args[0], args[1])
+		case srcRemote:
+			return runSync(cmd, sync.ToLocal, args[1], args[0])
+		}
+		return fmt.Errorf("must specify --to-remote or --to-local")
+	},
+}
+
+// Push command
+var pushCmd = &cobra.Command{
+	Use:   "push <local-dir> <bucket/prefix>",
+	Short: "Upload a local directory's changes to B2",
+	Long: `Upload new and changed files from a local directory to a B2 prefix.
+Equivalent to 'sync <local-dir> <bucket/prefix> --to-remote'.
+
+Examples:
+  bb-stream push ./photos mybucket/photos
+  bb-stream push ./site mybucket/www --delete --dry-run`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSync(cmd, sync.ToRemote, args[0], args[1])
+	},
+}
+
+// Pull command
+var pullCmd = &cobra.Command{
+	Use:   "pull <bucket/prefix> <local-dir>",
+	Short: "Download a B2 prefix's changes to a local directory",
+	Long: `Download new and changed files from a B2 prefix to a local directory.
+Equivalent to 'sync <bucket/prefix> <local-dir> --to-local'.
+
+Examples:
+  bb-stream pull mybucket/photos ./photos
+  bb-stream pull mybucket/backup ./restore --concurrent 8`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSync(cmd, sync.ToLocal, args[1], args[0])
+	},
+}
+
+// Get command
+var getCmd = &cobra.Command{
+	Use:   "get <bucket/prefix> <local-dir>",
+	Short: "Download every file under a B2 prefix",
+	Long: `Download every object under a B2 prefix into a local directory, whether or
+not a local copy already exists. Subdirectories below the prefix are recreated
+locally; with --flatten every file goes directly into local-dir, and names that
+collide get a numeric suffix (photo.jpg, photo-1.jpg, ...).
+
+Examples:
+  bb-stream get mybucket/photos ./photos
+  bb-stream get b2://mybucket/reports/2024 ./inbox --flatten`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loc, err := uri.ParseRemote(args[0])
+		if err != nil {
+			return err
+		}
+		localDir := args[1]
+
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
-		delete, _ := cmd.Flags().GetBool("delete")
+		flatten, _ := cmd.Flags().GetBool("flatten")
+
+		limitRate, err := getLimitRate(cmd)
+		if err != nil {
+			return err
+		}
+
+		ctx, stopping, stop := transferContext()
+		defer stop()
 
-		ctx := context.Background()
 		client, err := b2.NewFromConfig(ctx)
 		if err != nil {
 			return err
 		}
 
 		opts := sync.DefaultSyncOptions()
+		opts.Direction = sync.ToLocal
 		opts.DryRun = dryRun
-		opts.Delete = delete
-		opts.ProgressCallback = func(status sync.SyncStatus) {
-			fmt.Printf("\r%s: %s", status.Phase, status.CurrentFile)
+		opts.Concurrent, _ = cmd.Flags().GetInt("concurrent")
+		if opts.Concurrent < 1 {
+			return fmt.Errorf("--concurrent must be at least 1")
+		}
+		opts.MaxBytesPerSec = limitRate
+		opts.FailFast, _ = cmd.Flags().GetBool("fail-fast")
+		opts.MaxErrors, _ = cmd.Flags().GetInt("max-errors")
+		noPreserve, _ := cmd.Flags().GetBool("no-preserve-mtime")
+		opts.PreserveModTime = !noPreserve
+		if opts.Order, err = getOrder(cmd); err != nil {
+			return err
+		}
+		opts.ProgressCallback = syncProgressPrinter(cmd)
+		opts.Stop = stopping.Done()
+
+		result, err := sync.NewSyncer(client, opts).Get(ctx, loc.Bucket, loc.Key, localDir, flatten)
+		if result == nil {
+			return err
+		}
+
+		// Show what was done even when the download was cut short
+		if renderErr := render(cmd, result, func() { printSyncResult(cmd, result, dryRun) }); renderErr != nil {
+			return renderErr
+		}
+		if stopping.Err() != nil {
+			return fmt.Errorf("get interrupted before all files were downloaded")
 		}
+		return err
+	},
+}
 
-		var localPath, bucketName, remotePath string
+// runSync syncs localPath with remote ("bucket/prefix") in the given direction
+// using the flags registered by addSyncFlags
+func runSync(cmd *cobra.Command, direction sync.Direction, localPath, remote string) error {
+	loc, err := uri.ParseRemote(remote)
+	if err != nil {
+		return err
+	}
 
-		if toRemote {
-			opts.Direction = sync.ToRemote
-			localPath = source
-			parts := strings.SplitN(dest, "/", 2)
-			bucketName = parts[0]
-			if len(parts) > 1 {
-				remotePath = parts[1]
-			}
-		} else if toLocal {
-			opts.Direction = sync.ToLocal
-			parts := strings.SplitN(source, "/", 2)
-			bucketName = parts[0]
-			if len(parts) > 1 {
-				remotePath = parts[1]
-			}
-			localPath = dest
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	limitRate, err := getLimitRate(cmd)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Flags().Changed("conflict") && direction != sync.Bidirectional {
+		return fmt.Errorf("--conflict only applies to --bidirectional syncs")
+	}
+	conflict, err := getConflictPolicy(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, stopping, stop := transferContext()
+	defer stop()
+
+	client, err := newStorage(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	opts := sync.DefaultSyncOptions()
+	opts.Direction = direction
+	opts.DryRun = dryRun
+	opts.Delete, _ = cmd.Flags().GetBool("delete")
+	opts.Mirror, _ = cmd.Flags().GetBool("mirror")
+	opts.Concurrent, _ = cmd.Flags().GetInt("concurrent")
+	if opts.Concurrent < 1 {
+		return fmt.Errorf("--concurrent must be at least 1")
+	}
+	opts.Checksum, _ = cmd.Flags().GetBool("checksum")
+	if cmd.Flags().Changed("ignore") {
+		opts.IgnorePatterns, _ = cmd.Flags().GetStringArray("ignore")
+	}
+	opts.MaxBytesPerSec = limitRate
+	opts.NoIgnoreFile, _ = cmd.Flags().GetBool("no-ignore-file")
+	opts.MaxErrors, _ = cmd.Flags().GetInt("max-errors")
+	opts.AbortOnSystemic, _ = cmd.Flags().GetBool("abort-on-repeated-errors")
+	opts.FailFast, _ = cmd.Flags().GetBool("fail-fast")
+	noPreserve, _ := cmd.Flags().GetBool("no-preserve-mtime")
+	opts.PreserveModTime = !noPreserve
+	if opts.ChecksumAlgorithm, err = getChecksumAlgorithm(cmd); err != nil {
+		return err
+	}
+	opts.MinModTime, opts.MaxModTime, err = getModTimeRange(cmd)
+	if err != nil {
+		return err
+	}
+	if opts.Order, err = getOrder(cmd); err != nil {
+		return err
+	}
+	opts.ConflictPolicy = conflict
+	opts.ProgressCallback = syncProgressPrinter(cmd)
+	opts.Stop = stopping.Done()
+
+	result, err := sync.NewSyncer(client, opts).Sync(ctx, localPath, loc.Bucket, loc.Key)
+	if result == nil {
+		return err
+	}
+
+	// Show what was done even when the sync was cut short
+	if renderErr := render(cmd, result, func() { printSyncResult(cmd, result, dryRun) }); renderErr != nil {
+		return renderErr
+	}
+	if stopping.Err() != nil {
+		return fmt.Errorf("sync interrupted before all files were synced")
+	}
+	return err
+}
+
+// syncProgressPrinter returns a sync progress callback that rewrites one
+// status line, or stays quiet for JSON output
+func syncProgressPrinter(cmd *cobra.Command) func(sync.SyncStatus) {
+	return func(status sync.SyncStatus) {
+		if jsonOutput(cmd) {
+			return
+		}
+		if status.FilesTotal > 0 {
+			fmt.Printf("\r[%d/%d] %s: %s", status.FilesCompleted, status.FilesTotal, status.Phase, status.CurrentFile)
 		} else {
-			return fmt.Errorf("must specify --to-remote or --to-local")
+			fmt.Printf("\r%s: %s", status.Phase, status.CurrentFile)
+		}
+	}
+}
+
+// addSyncFlags registers the options shared by sync, push and pull
+func addSyncFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("dry-run", false, "Show what would be synced without making changes")
+	cmd.Flags().BoolP("verbose", "v", false, "With --dry-run, list each file that would change")
+	cmd.Flags().Bool("delete", false, "Delete files in destination that don't exist in source")
+	cmd.Flags().Bool("mirror", false, "Make the destination an exact copy of the source (implies --delete)")
+	cmd.Flags().Int("concurrent", sync.DefaultSyncOptions().Concurrent, "Number of files to transfer at once")
+	cmd.Flags().Bool("checksum", false, "Compare file contents by checksum instead of size and modification time")
+	cmd.Flags().StringArray("ignore", nil, "Ignore files matching this pattern, replacing the defaults (.git, node_modules, ...) (repeatable)")
+	cmd.Flags().String("limit-rate", "", "Limit total transfer rate (e.g. 500KB, 2MB)")
+	cmd.Flags().Bool("no-ignore-file", false, "Don't read .bbignore files")
+	cmd.Flags().Bool("no-preserve-mtime", false, "Give downloaded files the current time instead of the object's upload time")
+	cmd.Flags().Bool("fail-fast", false, "Stop at the first file that fails and exit non-zero")
+	cmd.Flags().Int("max-errors", sync.DefaultMaxErrors, "Maximum number of errors to report before truncating")
+	cmd.Flags().Bool("abort-on-repeated-errors", false, "Stop once --max-errors is reached if every error has the same cause")
+	cmd.Flags().String("checksum-algorithm", "sha1", "Hash for checksum comparisons: sha1 or sha256 (sha256 is also stored on upload)")
+	cmd.Flags().String("newer-than", "", "Only sync files modified within this age (e.g. 7d, 12h)")
+	cmd.Flags().String("older-than", "", "Only sync files modified before this age (e.g. 30d)")
+	cmd.Flags().String("order", "path", "Order to transfer files in: path, smallest (first) or largest (first)")
+}
+
+// printSyncResult prints the human-readable sync summary
+func printSyncResult(cmd *cobra.Command, result *sync.SyncResult, dryRun bool) {
+	fmt.Println()
+	if dryRun {
+		fmt.Println("Dry run - no changes made")
+		if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+			printPaths("Would upload", result.WouldUpload)
+			printPaths("Would download", result.WouldDownload)
+			printPaths("Would delete", result.WouldDelete)
+		}
+	}
+	fmt.Printf("Uploaded: %d, Downloaded: %d, Deleted: %d, Skipped: %d\n",
+		result.Uploaded, result.Downloaded, result.Deleted, result.Skipped)
+	fmt.Printf("Duration: %s\n", result.Duration)
+	if transferred := result.BytesUploaded + result.BytesDownloaded; transferred > 0 {
+		fmt.Printf("Transferred: %s in %s (%s/s)\n",
+			formatSize(transferred), result.Duration.Round(time.Millisecond), formatSize(int64(result.Throughput)))
+	}
+
+	if len(result.Conflicts) > 0 {
+		fmt.Printf("Conflicts: %d\n", len(result.Conflicts))
+		for _, c := range result.Conflicts {
+			fmt.Printf("  - %s (%s)\n", c.Path, c.Resolution)
+		}
+	}
+
+	if len(result.Errors) > 0 {
+		fmt.Printf("Errors: %d\n", len(result.Errors)+result.TruncatedErrorCount)
+		for _, err := range result.Errors {
+			fmt.Printf("  - %s\n", errors.Detail(err))
 		}
+		if result.TruncatedErrorCount > 0 {
+			fmt.Printf("  ... %d more errors (truncated)\n", result.TruncatedErrorCount)
+		}
+	}
+}
 
-		syncer := sync.NewSyncer(client, opts)
-		result, err := syncer.Sync(ctx, localPath, bucketName, remotePath)
+// Verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify <local-path> <bucket/prefix>",
+	Short: "Check that a local directory matches B2 by checksum",
+	Long: `Compare a local directory with a B2 prefix by size and SHA1 without
+transferring anything. Reports files that are missing remotely, extra
+remotely, or different, and exits non-zero if any are found.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		localPath := args[0]
+		loc, err := uri.ParseRemote(args[1])
 		if err != nil {
 			return err
 		}
+		bucketName, remotePath := loc.Bucket, loc.Key
 
-		fmt.Println()
-		if dryRun {
-			fmt.Println("Dry run - no changes made")
+		ctx, stop := interruptContext()
+		defer stop()
+
+		client, err := b2.NewFromConfig(ctx)
+		if err != nil {
+			return err
 		}
-		fmt.Printf("Uploaded: %d, Downloaded: %d, Deleted: %d, Skipped: %d\n",
-			result.Uploaded, result.Downloaded, result.Deleted, result.Skipped)
-		fmt.Printf("Duration: %s\n", result.Duration)
 
-		if len(result.Errors) > 0 {
-			fmt.Printf("Errors: %d\n", len(result.Errors))
-			for _, err := range result.Errors {
-				fmt.Printf("  - %v\n", err)
-			}
+		opts := sync.DefaultSyncOptions()
+		opts.NoIgnoreFile, _ = cmd.Flags().GetBool("no-ignore-file")
+		if opts.ChecksumAlgorithm, err = getChecksumAlgorithm(cmd); err != nil {
+			return err
+		}
+
+		result, err := sync.NewSyncer(client, opts).Verify(ctx, localPath, bucketName, remotePath)
+		if err != nil {
+			return err
+		}
+
+		if err := render(cmd, result, func() {
+			printPaths("Missing remotely", result.Missing)
+			printPaths("Only in B2", result.Extra)
+			printPaths("Different", result.Mismatched)
+			printPaths("Unverified (no SHA1 in B2, size matches)", result.Unverified)
+			printPaths("Errors", result.Errors)
+			fmt.Printf("Matched: %d, Missing: %d, Extra: %d, Different: %d, Unverified: %d\n",
+				result.Matched, len(result.Missing), len(result.Extra), len(result.Mismatched), len(result.Unverified))
+		}); err != nil {
+			return err
 		}
 
+		if !result.OK() {
+			return fmt.Errorf("verification failed: %s does not match %s", localPath, args[1])
+		}
 		return nil
 	},
 }
@@ -456,12 +1923,11 @@ var watchCmd = &cobra.Command{
 		localPath := args[0]
 		remotePath := args[1]
 
-		// Parse bucket/path
-		parts := strings.SplitN(remotePath, "/", 2)
-		if len(parts) < 2 {
-			return fmt.Errorf("remote path must be in format: bucket/path")
+		loc, err := uri.ParseRemote(remotePath)
+		if err != nil {
+			return err
 		}
-		bucket, path := parts[0], parts[1]
+		bucket, path := loc.Bucket, loc.Key
 
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
@@ -471,23 +1937,63 @@ var watchCmd = &cobra.Command{
 			return err
 		}
 
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
 		absPath, _ := filepath.Abs(localPath)
 		fmt.Printf("Watching %s for changes...\n", absPath)
-		fmt.Printf("Auto-uploading to %s/%s\n", bucket, path)
+		if dryRun {
+			fmt.Printf("Dry run: showing what would be uploaded to %s/%s\n", bucket, path)
+		} else {
+			fmt.Printf("Auto-uploading to %s/%s\n", bucket, path)
+		}
 		fmt.Println("Press Ctrl+C to stop")
 
-		autoUploader, err := watch.NewAutoUploader(client, localPath, bucket, path, nil)
+		include, _ := cmd.Flags().GetStringArray("include")
+		exclude, _ := cmd.Flags().GetStringArray("exclude")
+		watchOpts := watch.DefaultWatcherOptions().WithPatterns(include, exclude)
+		watchOpts.MirrorDeletes, _ = cmd.Flags().GetBool("mirror-deletes")
+		watchOpts.NoIgnoreFile, _ = cmd.Flags().GetBool("no-ignore-file")
+		watchOpts.BatchDelay, _ = cmd.Flags().GetDuration("batch")
+		watchOpts.MaxUploadAttempts, _ = cmd.Flags().GetInt("max-attempts")
+		if watchOpts.MaxUploadAttempts < 1 {
+			return fmt.Errorf("--max-attempts must be at least 1")
+		}
+		watchOpts.InitialScan, _ = cmd.Flags().GetBool("initial-scan")
+		watchOpts.PathTemplate, _ = cmd.Flags().GetString("path-template")
+		watchOpts.RetryJournal = watch.JournalPath(filepath.Dir(config.GetConfigPath()), localPath, bucket, path)
+		watchOpts.DryRun = dryRun
+
+		autoUploader, err := watch.NewAutoUploader(client, localPath, bucket, path, watchOpts)
 		if err != nil {
 			return err
 		}
 
 		autoUploader.OnUpload = func(path string, err error) {
-			if err != nil {
+			switch {
+			case err != nil:
 				fmt.Printf("[ERROR] %s: %v\n", path, err)
-			} else {
+			case autoUploader.DryRun():
+				fmt.Printf("[WOULD UPLOAD] %s\n", path)
+			default:
 				fmt.Printf("[UPLOADED] %s\n", path)
 			}
 		}
+		autoUploader.OnScan = func(queued int) {
+			fmt.Printf("Initial scan: %d new or changed files to upload\n", queued)
+		}
+		autoUploader.OnRetry = func(path string, attempt int, wait time.Duration) {
+			fmt.Printf("[RETRY] %s: attempt %d failed, retrying in %s\n", path, attempt, wait)
+		}
+		autoUploader.OnDelete = func(path string, err error) {
+			switch {
+			case err != nil:
+				fmt.Printf("[ERROR] delete %s: %v\n", path, err)
+			case autoUploader.DryRun():
+				fmt.Printf("[WOULD DELETE] %s\n", path)
+			default:
+				fmt.Printf("[DELETED] %s\n", path)
+			}
+		}
 
 		if err := autoUploader.Start(ctx); err != nil {
 			return err
@@ -499,7 +2005,16 @@ var watchCmd = &cobra.Command{
 		<-sigCh
 
 		fmt.Println("\nStopping watcher...")
+		if n := autoUploader.QueueDepth(); n > 0 {
+			fmt.Printf("Finishing %d queued uploads\n", n)
+		}
 		autoUploader.Stop()
+		if n := autoUploader.RetryDepth(); n > 0 && !dryRun {
+			fmt.Printf("%d failed uploads will be retried next time this directory is watched\n", n)
+		}
+		if n := autoUploader.FailedPermanently(); n > 0 {
+			fmt.Printf("%d files could not be uploaded after %d attempts\n", n, watchOpts.MaxUploadAttempts)
+		}
 		return nil
 	},
 }
@@ -511,6 +2026,15 @@ var serveCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		port, _ := cmd.Flags().GetInt("port")
 
+		var flushSize int64
+		if v, _ := cmd.Flags().GetString("flush-size"); v != "" {
+			n, err := parseSize(v)
+			if err != nil {
+				return fmt.Errorf("invalid --flush-size: %w", err)
+			}
+			flushSize = n
+		}
+
 		ctx := context.Background()
 		client, err := b2.NewFromConfig(ctx)
 		if err != nil {
@@ -518,6 +2042,7 @@ var serveCmd = &cobra.Command{
 		}
 
 		server := api.NewServer(client, port)
+		server.SetFlushThreshold(int(flushSize))
 
 		fmt.Printf("Starting API server on http://localhost:%d\n", port)
 		fmt.Println("Press Ctrl+C to stop")
@@ -536,23 +2061,219 @@ var serveCmd = &cobra.Command{
 	},
 }
 
+// Events command
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Print live events from a running API server",
+	Long: `Connect to a running API server's WebSocket feed and print each event as
+one line. The connection is re-established with backoff if it drops.
+
+Examples:
+  bb-stream events --server http://localhost:8080
+  bb-stream events --topics sync_progress,file_deleted -o json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server := serverURL(cmd)
+		if server == "" {
+			server = "http://localhost:8080"
+		}
+		wsURL, err := eventsURL(server)
+		if err != nil {
+			return err
+		}
+
+		header := http.Header{}
+		if apiKey := serverAPIKey(cmd); apiKey != "" {
+			header.Set("X-API-Key", apiKey)
+		}
+		topics, _ := cmd.Flags().GetString("topics")
+
+		ctx, stop := interruptContext()
+		defer stop()
+
+		// Backoff applies to reconnecting; it starts over after each successful connection
+		backoff := &retry.Config{
+			MaxAttempts: 10,
+			InitialWait: 500 * time.Millisecond,
+			MaxWait:     30 * time.Second,
+			Multiplier:  2.0,
+			OnRetry: func(attempt int, err error, wait time.Duration) {
+				fmt.Fprintf(os.Stderr, "Connection failed (%v); retrying in %s\n", err, wait.Round(time.Millisecond))
+			},
+		}
+
+		for {
+			conn, err := retry.DoWithResultCtx(ctx, backoff, nil, func(ctx context.Context) (*websocket.Conn, error) {
+				conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, header)
+				return conn, err
+			})
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to connect to %s: %w", wsURL, err)
+			}
+			fmt.Fprintf(os.Stderr, "Connected to %s\n", wsURL)
+
+			err = readEvents(ctx, cmd, conn, topics)
+			if ctx.Err() != nil {
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "Connection lost (%v); reconnecting\n", err)
+		}
+	},
+}
+
+// eventsURL turns an API server URL into its WebSocket endpoint
+func eventsURL(server string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid server URL %q (expected e.g. http://localhost:8080)", server)
+	}
+	switch u.Scheme {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("invalid server URL %q: scheme must be http or https", server)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/ws"
+	return u.String(), nil
+}
+
+// readEvents subscribes to topics, if any, and prints events from conn until
+// the connection fails or ctx is cancelled
+func readEvents(ctx context.Context, cmd *cobra.Command, conn *websocket.Conn, topics string) error {
+	defer conn.Close()
+
+	// Closing the connection unblocks ReadMessage on interrupt
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if topics != "" {
+		if err := conn.WriteJSON(map[string]string{"type": "subscribe", "data": topics}); err != nil {
+			return err
+		}
+	}
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if jsonOutput(cmd) {
+			fmt.Println(string(message))
+			continue
+		}
+
+		var event struct {
+			Type      string          `json:"type"`
+			Data      json.RawMessage `json:"data"`
+			Timestamp time.Time       `json:"timestamp"`
+		}
+		if err := json.Unmarshal(message, &event); err != nil {
+			fmt.Println(string(message))
+			continue
+		}
+		line := event.Timestamp.Local().Format(time.TimeOnly) + " " + event.Type
+		if len(event.Data) > 0 {
+			line += " " + string(event.Data)
+		}
+		fmt.Println(line)
+	}
+}
+
 func init() {
+	rootCmd.PersistentFlags().StringP("output", "o", "table", "Output format: table or json")
+	rootCmd.PersistentFlags().String("log-level", "info", "Log level: debug, info, warn or error (env: BB_LOG_LEVEL)")
+	rootCmd.PersistentFlags().String("log-format", "json", "Log format: json or text")
+	rootCmd.PersistentFlags().String("config", "", "Config file to use (default: ~/.config/bb-stream/config.yaml; env: BB_CONFIG_FILE)")
+	rootCmd.PersistentFlags().String("profile", "", "Credentials profile to use (default: the configured active profile)")
+	rootCmd.PersistentFlags().String("server", "", "Run through the bb-stream API server at this URL instead of B2 directly (env: BB_SERVER)")
+	rootCmd.PersistentFlags().String("api-key", "", "API key for --server (default: the configured api_key)")
+
+	// Doctor command
+	doctorCmd.Flags().BoolP("verbose", "v", false, "Show the underlying error for failed checks")
+	rootCmd.AddCommand(doctorCmd)
+
 	// Version command
 	rootCmd.AddCommand(versionCmd)
 
 	// Config commands
 	configCmd.AddCommand(configInitCmd)
 	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
 	rootCmd.AddCommand(configCmd)
 
 	// File commands
+	lsCmd.Flags().String("newer-than", "", "Only list files modified within this age (e.g. 7d, 12h)")
+	lsCmd.Flags().String("older-than", "", "Only list files modified before this age (e.g. 30d)")
+	lsCmd.Flags().String("tag", "", "Only list files tagged key=value, or with tag key at all (reads every file's metadata)")
+	lsCmd.Flags().String("pattern", "", "Only list files whose base name matches this glob (e.g. \"*.jpg\"); filtered after listing")
 	rootCmd.AddCommand(lsCmd)
+
+	searchCmd.Flags().String("prefix", "", "Only search files under this prefix")
+	searchCmd.Flags().Int("limit", b2.DefaultSearchLimit, "Maximum number of matches to show")
+	searchCmd.Flags().String("cursor", "", "Continue a previous search after this file name")
+	rootCmd.AddCommand(searchCmd)
+
+	// Stat command
+	rootCmd.AddCommand(statCmd)
+	treeCmd.Flags().Int("depth", 0, "Collapse directories below this depth into summaries (0 = unlimited)")
+	rootCmd.AddCommand(treeCmd)
+	duCmd.Flags().BoolP("all", "a", false, "Report every directory level, not just the top level")
+	rootCmd.AddCommand(duCmd)
+	uploadCmd.Flags().StringArray("meta", nil, "Custom metadata as key=value (repeatable)")
+	uploadCmd.Flags().String("limit-rate", "", "Limit transfer rate (e.g. 500KB, 2MB)")
+	uploadCmd.Flags().Bool("gzip", false, "Compress with gzip and store with Content-Encoding: gzip")
+	uploadCmd.Flags().Bool("gz-suffix", false, "With --gzip, append .gz to the object name")
+	uploadCmd.Flags().String("checksum-algorithm", "sha1", "Checksum to record: sha1 (computed by B2) or sha256 (stored as metadata)")
+	uploadCmd.Flags().Bool("encrypt", false, "Encrypt client-side with AES-256-GCM (passphrase from "+b2.PassphraseEnv+")")
+	uploadCmd.Flags().Bool("if-changed", false, "Skip the upload if the remote file already has the same size")
+	uploadCmd.Flags().Bool("checksum", false, "With --if-changed, also compare checksums (reads the file once more)")
+	uploadCmd.Flags().Bool("resumable", false, "Upload in parts, recording progress so an interrupted upload resumes where it stopped")
+	uploadCmd.Flags().String("part-size", "", "With --resumable, the size of each part (e.g. 200MB; default is B2's recommendation)")
 	rootCmd.AddCommand(uploadCmd)
+
+	downloadCmd.Flags().String("limit-rate", "", "Limit transfer rate (e.g. 500KB, 2MB)")
+	downloadCmd.Flags().Bool("raw", false, "Save gzip-encoded files as stored instead of decompressing them")
+	downloadCmd.Flags().Bool("decrypt", false, "Decrypt client-side encrypted files (passphrase from "+b2.PassphraseEnv+")")
 	rootCmd.AddCommand(downloadCmd)
 
 	rmCmd.Flags().BoolP("force", "f", false, "Skip confirmation")
 	rootCmd.AddCommand(rmCmd)
 
+	cleanupCmd.Flags().String("older-than", "24h", "Only cancel uploads started at least this long ago (e.g. 24h, 7d; 0s for all)")
+	cleanupCmd.Flags().Bool("dry-run", false, "List the uploads that would be cancelled without cancelling them")
+	rootCmd.AddCommand(cleanupCmd)
+
+	mvCmd.Flags().String("metadata-directive", "copy", "Metadata handling: copy (keep source, apply overrides) or replace")
+	mvCmd.Flags().String("content-type", "", "Content type for the destination")
+	mvCmd.Flags().StringArray("meta", nil, "Custom metadata as key=value (repeatable)")
+	rootCmd.AddCommand(mvCmd)
+
+	// Copy command
+	cpCmd.Flags().BoolP("recursive", "r", false, "Copy directories and prefixes recursively")
+	cpCmd.Flags().String("limit-rate", "", "Limit transfer rate (e.g. 500KB, 2MB)")
+	rootCmd.AddCommand(cpCmd)
+
+	// Tag commands
+	rootCmd.AddCommand(tagCmd)
+	rootCmd.AddCommand(untagCmd)
+
+	versionsCmd.Flags().String("delete", "", "Delete the version with this file ID")
+	versionsCmd.Flags().BoolP("force", "f", false, "Skip confirmation")
+	rootCmd.AddCommand(versionsCmd)
+
 	// Stream commands
 	rootCmd.AddCommand(streamUpCmd)
 	rootCmd.AddCommand(streamDownCmd)
@@ -560,21 +2281,69 @@ func init() {
 	// Sync command
 	syncCmd.Flags().Bool("to-remote", false, "Sync local to B2")
 	syncCmd.Flags().Bool("to-local", false, "Sync B2 to local")
-	syncCmd.Flags().Bool("dry-run", false, "Show what would be synced without making changes")
-	syncCmd.Flags().Bool("delete", false, "Delete files in destination that don't exist in source")
+	syncCmd.Flags().Bool("bidirectional", false, "Sync changes both ways between the local and remote arguments")
+	syncCmd.Flags().String("conflict", "newer", "With --bidirectional, how to resolve files changed on both sides: newer, local, remote or skip")
+	addSyncFlags(syncCmd)
 	rootCmd.AddCommand(syncCmd)
 
+	// Push and pull commands
+	addSyncFlags(pushCmd)
+	rootCmd.AddCommand(pushCmd)
+	addSyncFlags(pullCmd)
+	rootCmd.AddCommand(pullCmd)
+
+	// Get command
+	getCmd.Flags().Bool("flatten", false, "Put every file directly in local-dir, suffixing names that collide")
+	getCmd.Flags().Bool("dry-run", false, "Show what would be downloaded without downloading")
+	getCmd.Flags().BoolP("verbose", "v", false, "With --dry-run, list each file that would be downloaded")
+	getCmd.Flags().Int("concurrent", sync.DefaultSyncOptions().Concurrent, "Number of files to download at once")
+	getCmd.Flags().String("limit-rate", "", "Limit total transfer rate (e.g. 500KB, 2MB)")
+	getCmd.Flags().Bool("no-preserve-mtime", false, "Give downloaded files the current time instead of the object's upload time")
+	getCmd.Flags().Bool("fail-fast", false, "Stop at the first file that fails and exit non-zero")
+	getCmd.Flags().Int("max-errors", sync.DefaultMaxErrors, "Maximum number of errors to report before truncating")
+	getCmd.Flags().String("order", "path", "Order to download files in: path, smallest (first) or largest (first)")
+	rootCmd.AddCommand(getCmd)
+
+	// Verify command
+	verifyCmd.Flags().Bool("no-ignore-file", false, "Don't read .bbignore files")
+	verifyCmd.Flags().String("checksum-algorithm", "sha1", "Hash to compare: sha1, or sha256 for files uploaded with --checksum-algorithm sha256")
+	rootCmd.AddCommand(verifyCmd)
+
 	// Watch command
+	watchCmd.Flags().StringArray("include", nil, "Only upload files matching this glob, e.g. '*.mp4' (repeatable)")
+	watchCmd.Flags().StringArray("exclude", nil, "Ignore files matching this pattern, in addition to defaults (repeatable)")
+	watchCmd.Flags().Bool("mirror-deletes", false, "Delete remote files when local files are removed or renamed")
+	watchCmd.Flags().Bool("no-ignore-file", false, "Don't read .bbignore files")
+	watchCmd.Flags().Duration("batch", 0, "Collect bursts of changes until none occur for this long, then upload them together (e.g. 2s)")
+	watchCmd.Flags().Bool("initial-scan", false, "Before watching, upload files that are new or changed compared with the remote prefix")
+	watchCmd.Flags().String("path-template", "", `Template for object names under the remote path, e.g. '{{.Date}}/{{.RelPath}}' (fields: Date, Time, RelPath, Dir, Basename, Ext)`)
+	watchCmd.Flags().Bool("dry-run", false, "Show what would be uploaded or deleted without changing the bucket")
+	watchCmd.Flags().Int("max-attempts", watch.DefaultWatcherOptions().MaxUploadAttempts, "Attempts at a failing upload, retried with backoff, before giving up on it")
 	rootCmd.AddCommand(watchCmd)
 
 	// Serve command
 	serveCmd.Flags().IntP("port", "p", 8080, "Port to listen on")
+	serveCmd.Flags().String("flush-size", "", "Bytes streamed downloads buffer before flushing (e.g. 16KB; default 64KB)")
 	rootCmd.AddCommand(serveCmd)
+
+	// Events command
+	eventsCmd.Flags().String("topics", "", "Comma-separated event types to receive (default: all)")
+	rootCmd.AddCommand(eventsCmd)
+
+	// Commands that can run through an API server with --server
+	for _, c := range []*cobra.Command{lsCmd, searchCmd, uploadCmd, downloadCmd, rmCmd, syncCmd, pushCmd, pullCmd, eventsCmd} {
+		c.Annotations = map[string]string{serverAnnotation: "true"}
+	}
+
+	// Commands that only touch local configuration or check B2 directly
+	for _, c := range []*cobra.Command{versionCmd, configCmd, doctorCmd} {
+		c.Annotations = map[string]string{localAnnotation: "true"}
+	}
 }
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "Error:", errors.Detail(err))
 		os.Exit(1)
 	}
 }
@@ -601,3 +2370,191 @@ func formatSize(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
+// parseSize parses a human-readable size like "2MB" or "512K" into bytes
+func parseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(str, "B")
+
+	multiplier := int64(1)
+	if str != "" {
+		if idx := strings.IndexByte("KMGT", str[len(str)-1]); idx >= 0 {
+			for i := 0; i <= idx; i++ {
+				multiplier *= 1024
+			}
+			str = str[:len(str)-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// interruptContext returns a context cancelled by the first Ctrl+C or SIGTERM,
+// so a command can stop cleanly. Once it fires, default signal handling is
+// restored and a second Ctrl+C exits immediately.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// transferContext is interruptContext for commands that transfer files. The
+// first Ctrl+C or SIGTERM cancels stopping, after which the command starts
+// no new transfers; those in progress run under ctx, which a second Ctrl+C
+// cancels. Default signal handling is then restored, so a third exits immediately.
+func transferContext() (ctx, stopping context.Context, stop context.CancelFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopping, stopNew := context.WithCancel(ctx)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case <-signals:
+			stopNew()
+		case <-ctx.Done():
+			return
+		}
+		fmt.Fprintln(os.Stderr, "\nInterrupted: finishing transfers in progress (press Ctrl+C again to cancel them)")
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, stopping, cancel
+}
+
+// getLimitRate reads the --limit-rate flag, returning 0 when unset
+func getLimitRate(cmd *cobra.Command) (int64, error) {
+	limit, _ := cmd.Flags().GetString("limit-rate")
+	if limit == "" {
+		return 0, nil
+	}
+	rate, err := parseSize(limit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --limit-rate: %w", err)
+	}
+	return rate, nil
+}
+
+// getChecksumAlgorithm parses the --checksum-algorithm flag
+func getChecksumAlgorithm(cmd *cobra.Command) (checksum.Algorithm, error) {
+	name, _ := cmd.Flags().GetString("checksum-algorithm")
+	algo, err := checksum.Parse(name)
+	if err != nil {
+		return "", fmt.Errorf("invalid --checksum-algorithm: %w", err)
+	}
+	return algo, nil
+}
+
+// getConflictPolicy parses --conflict, which only sync has
+func getConflictPolicy(cmd *cobra.Command) (sync.ConflictPolicy, error) {
+	name, _ := cmd.Flags().GetString("conflict")
+	policy, err := sync.ParseConflictPolicy(name)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --conflict: %w", err)
+	}
+	return policy, nil
+}
+
+// getOrder parses --order
+func getOrder(cmd *cobra.Command) (sync.Order, error) {
+	name, _ := cmd.Flags().GetString("order")
+	order, err := sync.ParseOrder(name)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --order: %w", err)
+	}
+	return order, nil
+}
+
+// getModTimeRange converts --newer-than/--older-than into Unix time bounds; 0 means unbounded
+func getModTimeRange(cmd *cobra.Command) (minTime, maxTime int64, err error) {
+	now := time.Now()
+	if newer, _ := cmd.Flags().GetString("newer-than"); newer != "" {
+		age, err := b2.ParseAge(newer)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid --newer-than: %w", err)
+		}
+		minTime = now.Add(-age).Unix()
+	}
+	if older, _ := cmd.Flags().GetString("older-than"); older != "" {
+		age, err := b2.ParseAge(older)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid --older-than: %w", err)
+		}
+		maxTime = now.Add(-age).Unix()
+	}
+	return minTime, maxTime, nil
+}
+
+// printPaths prints a titled list of paths, or nothing if empty
+func printPaths(title string, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Printf("%s (%d):\n", title, len(paths))
+	for _, p := range paths {
+		fmt.Printf("  %s\n", p)
+	}
+}
+
+// jsonOutput reports whether --output json was requested
+func jsonOutput(cmd *cobra.Command) bool {
+	format, _ := cmd.Flags().GetString("output")
+	return format == "json"
+}
+
+// render prints v as indented JSON when --output json is set,
+// and otherwise calls human to print the default output
+func render(cmd *cobra.Command, v interface{}, human func()) error {
+	if !jsonOutput(cmd) {
+		human()
+		return nil
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// newProgressPrinter returns a callback that prints transferred bytes, speed and,
+// when the total is known, percent and ETA on a single updating line
+func newProgressPrinter(verb string, total int64) progress.Callback {
+	tracker := progress.NewSpeedTracker(total, progress.DefaultSpeedWindow)
+	return func(transferred, total int64) {
+		tracker.Update(transferred)
+		speed := formatSize(int64(tracker.Speed())) + "/s"
+
+		if total < 0 {
+			fmt.Printf("\r%s: %s, %s    ", verb, formatSize(transferred), speed)
+			return
+		}
+
+		percent := 100.0
+		if total > 0 {
+			percent = float64(transferred) / float64(total) * 100
+		}
+		eta := "--:--"
+		if d, ok := tracker.ETA(); ok {
+			eta = formatETA(d)
+		}
+		fmt.Printf("\r%s: %s / %s (%.1f%%), %s, ETA %s    ",
+			verb, formatSize(transferred), formatSize(total), percent, speed, eta)
+	}
+}
+
+// formatETA formats a duration as mm:ss, or h:mm:ss when over an hour
+func formatETA(d time.Duration) string {
+	secs := int64(d.Round(time.Second) / time.Second)
+	if secs >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%02d:%02d", secs/60, secs%60)
+}
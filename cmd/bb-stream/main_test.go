@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ryanoboyle/bb-stream/internal/b2"
+	"github.com/spf13/cobra"
+)
+
+func TestCheckServerSupport(t *testing.T) {
+	t.Setenv("BB_SERVER", "http://localhost:1")
+	rootCmd.InitDefaultHelpCmd()
+	rootCmd.InitDefaultCompletionCmd()
+
+	find := func(args ...string) *cobra.Command {
+		t.Helper()
+		cmd, _, err := rootCmd.Find(args)
+		if err != nil {
+			t.Fatalf("find %v: %v", args, err)
+		}
+		return cmd
+	}
+
+	allowed := [][]string{
+		{"help"},
+		{"completion", "bash"},
+		{"config", "show"},
+		{"config", "init"},
+		{"config", "set"},
+		{"doctor"},
+		{"version"},
+		{"ls"},
+		{"upload"},
+	}
+	for _, args := range allowed {
+		if err := checkServerSupport(find(args...)); err != nil {
+			t.Errorf("%v: unexpected error: %v", args, err)
+		}
+	}
+
+	if err := checkServerSupport(find("mv")); err == nil {
+		t.Error("mv: expected an error with BB_SERVER set")
+	}
+
+	t.Setenv("BB_SERVER", "")
+	if err := checkServerSupport(find("mv")); err != nil {
+		t.Errorf("mv without a server: unexpected error: %v", err)
+	}
+}
+
+func TestTree(t *testing.T) {
+	objects := []b2.ObjectInfo{
+		{Name: "photos/2024/b.jpg", Size: 20},
+		{Name: "photos/2024/trip/c.jpg", Size: 30},
+		{Name: "photos/2023/a.jpg", Size: 10},
+		{Name: "photos/2024/", Size: 0}, // Folder placeholder
+		{Name: "photos/readme.txt", Size: 5},
+	}
+
+	root := newTree("bucket", "photos/20", 0)
+	for _, obj := range objects {
+		root.addObject(obj)
+	}
+	root.sort()
+
+	if root.Name != "bucket/photos/" || root.Files != 4 || root.Size != 65 {
+		t.Fatalf("Unexpected root %s with %d files, %d bytes", root.Name, root.Files, root.Size)
+	}
+	var names []string
+	for _, c := range root.Children {
+		names = append(names, c.Name)
+	}
+	if len(names) != 3 || names[0] != "2023/" || names[1] != "2024/" || names[2] != "readme.txt" {
+		t.Fatalf("Unexpected children %v", names)
+	}
+	y2024 := root.Children[1]
+	if !y2024.Dir || y2024.Files != 2 || y2024.Size != 50 {
+		t.Errorf("Expected 2024/ to hold 2 files of 50 bytes, got %d and %d", y2024.Files, y2024.Size)
+	}
+	if trip := y2024.Children[1]; trip.Name != "trip/" || len(trip.Children) != 1 || trip.Children[0].Name != "c.jpg" {
+		t.Errorf("Expected trip/c.jpg under 2024/, got %+v", trip)
+	}
+
+	// With a depth limit, deeper directories are summarized without children
+	shallow := newTree("bucket", "photos/", 1)
+	for _, obj := range objects {
+		shallow.addObject(obj)
+	}
+	shallow.sort()
+	for _, c := range shallow.Children {
+		if c.Dir && len(c.Children) != 0 {
+			t.Errorf("Expected %s summarized at depth 1, got %d children", c.Name, len(c.Children))
+		}
+	}
+	if y2024 := shallow.Children[1]; y2024.Files != 2 || y2024.Size != 50 {
+		t.Errorf("Expected the summary to count 2 files of 50 bytes, got %d and %d", y2024.Files, y2024.Size)
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	objects := []b2.ObjectInfo{
+		{Name: "data/logs/2024/a.log", Size: 100},
+		{Name: "data/logs/b.log", Size: 50},
+		{Name: "data/img/c.png", Size: 200},
+		{Name: "data/img/", Size: 0}, // Folder placeholder
+		{Name: "data/top.txt", Size: 5},
+	}
+	summarize := func(prefix string, all bool) (map[string]duEntry, []string, duEntry) {
+		usage := newDiskUsage(prefix, all)
+		for _, obj := range objects {
+			// WalkObjects only lists names under the prefix
+			if strings.HasPrefix(obj.Name, prefix) {
+				usage.add(obj)
+			}
+		}
+		got := make(map[string]duEntry)
+		var order []string
+		for _, e := range usage.entries() {
+			got[e.Prefix] = *e
+			order = append(order, e.Prefix)
+		}
+		return got, order, usage.total
+	}
+
+	got, order, total := summarize("data/", false)
+	if total.Files != 4 || total.Size != 355 {
+		t.Errorf("Expected a total of 4 files and 355 bytes, got %+v", total)
+	}
+	want := []string{"data/img/", "data/logs/", "data/top.txt"}
+	if len(order) != len(want) {
+		t.Fatalf("Expected entries %v, got %v", want, order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("Expected entries largest first %v, got %v", want, order)
+			break
+		}
+	}
+	if e := got["data/logs/"]; e.Files != 2 || e.Size != 150 {
+		t.Errorf("Expected data/logs/ to hold 2 files of 150 bytes, got %+v", e)
+	}
+
+	// --all reports every level, and a partial prefix groups under its directory
+	got, _, total = summarize("data/lo", true)
+	if total.Files != 2 || total.Prefix != "data/" {
+		t.Errorf("Expected 2 files under data/, got %+v", total)
+	}
+	if e := got["data/logs/2024/"]; e.Files != 1 || e.Size != 100 {
+		t.Errorf("Expected data/logs/2024/ with 1 file of 100 bytes, got %+v", e)
+	}
+	if e := got["data/logs/"]; e.Files != 2 || e.Size != 150 {
+		t.Errorf("Expected data/logs/ with 2 files of 150 bytes, got %+v", e)
+	}
+}
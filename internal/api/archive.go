@@ -0,0 +1,199 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ryanoboyle/bb-stream/internal/b2"
+	"github.com/ryanoboyle/bb-stream/pkg/logging"
+)
+
+// archiveWriter adds files to an archive streamed to the response
+type archiveWriter interface {
+	// Add starts an entry for obj under name and returns a writer for its contents
+	Add(name string, obj b2.ObjectInfo) (io.Writer, error)
+	Close() error
+}
+
+// zipArchive writes entries as deflated zip members. Sizes aren't needed up
+// front, since the zip format records them after each entry's data.
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchive) Add(name string, obj b2.ObjectInfo) (io.Writer, error) {
+	return a.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Unix(obj.Timestamp, 0),
+	})
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
+
+// tarGzArchive writes entries into a gzipped tar
+type tarGzArchive struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func newTarGzArchive(w io.Writer) *tarGzArchive {
+	gz := gzip.NewWriter(w)
+	return &tarGzArchive{gz: gz, tw: tar.NewWriter(gz)}
+}
+
+func (a *tarGzArchive) Add(name string, obj b2.ObjectInfo) (io.Writer, error) {
+	err := a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     obj.Size,
+		ModTime:  time.Unix(obj.Timestamp, 0),
+	})
+	return a.tw, err
+}
+
+func (a *tarGzArchive) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
+
+// archivePrefix returns the directory prefix named by the URL path and the
+// archive's base file name. An empty path archives the whole bucket.
+func archivePrefix(r *http.Request, bucket string) (string, string, error) {
+	raw := strings.Trim(chi.URLParam(r, "*"), "/")
+	if raw == "" {
+		return "", bucket, nil
+	}
+	cleaned, err := validatePath(raw)
+	if err != nil {
+		return "", "", err
+	}
+	return cleaned + "/", path.Base(cleaned), nil
+}
+
+// archiveEntryName returns the archive entry for an object under prefix, or
+// false for names that would extract outside the target directory, such as
+// absolute ones or ones climbing out with ".."
+func archiveEntryName(objectName, prefix string) (string, bool) {
+	rel := strings.TrimPrefix(objectName, prefix)
+	if strings.HasPrefix(rel, "/") {
+		return "", false
+	}
+	name := path.Clean(rel)
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
+
+// handleArchive streams every object under a prefix as a zip or tar.gz archive,
+// built on the fly with entries named relative to the prefix
+func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
+	bucket := chi.URLParam(r, "bucket")
+	if err := validateBucketName(bucket); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	prefix, name, err := archivePrefix(r, bucket)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	var contentType string
+	switch format {
+	case "", "zip":
+		format, contentType = "zip", "application/zip"
+	case "tar.gz", "tgz":
+		format, contentType = "tar.gz", "application/gzip"
+	default:
+		respondError(w, http.StatusBadRequest, "format must be zip or tar.gz")
+		return
+	}
+
+	ctx := r.Context()
+	objects, err := s.client.ListObjects(ctx, bucket, prefix)
+	if err != nil {
+		handleError(w, r, err, downloadErrorStatus(err), "archive",
+			logging.Bucket(bucket), logging.Path(prefix))
+		return
+	}
+
+	// Folder placeholders have nothing to archive
+	type entry struct {
+		name string
+		obj  b2.ObjectInfo
+	}
+	var files []entry
+	for _, obj := range objects {
+		if obj.Name == prefix || strings.HasSuffix(obj.Name, "/") {
+			continue
+		}
+		name, ok := archiveEntryName(obj.Name, prefix)
+		if !ok {
+			logging.WithContext(ctx).Warn("skipping unsafe archive entry",
+				logging.Bucket(bucket), logging.Object(obj.Name))
+			continue
+		}
+		files = append(files, entry{name: name, obj: obj})
+	}
+	if len(files) == 0 {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("No files under %q", prefix))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": name + "." + format,
+	}))
+	w.Header().Set("Transfer-Encoding", "chunked")
+
+	fw := newFlushingWriter(w, flusher, s.flushThreshold)
+	var archive archiveWriter
+	if format == "zip" {
+		archive = &zipArchive{zw: zip.NewWriter(fw)}
+	} else {
+		archive = newTarGzArchive(fw)
+	}
+
+	// Headers are already sent, so a failure can only cut the archive short
+	for _, f := range files {
+		w, err := archive.Add(f.name, f.obj)
+		if err == nil {
+			err = s.client.StreamDownload(ctx, bucket, f.obj.Name, w, nil)
+		}
+		if err != nil {
+			logging.WithContext(ctx).Error("request failed", logging.Operation("archive"), logging.Err(err),
+				logging.Bucket(bucket), logging.Object(f.obj.Name))
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		logging.WithContext(ctx).Error("request failed", logging.Operation("archive"), logging.Err(err),
+			logging.Bucket(bucket), logging.Path(prefix))
+		return
+	}
+	fw.Flush()
+}
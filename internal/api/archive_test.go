@@ -0,0 +1,157 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ryanoboyle/bb-stream/internal/memstore"
+)
+
+func archiveServer() *chi.Mux {
+	store := memstore.New("my-bucket")
+	store.Put("my-bucket", "photos/a.jpg", []byte("aaa"), time.Now())
+	store.Put("my-bucket", "photos/trip/b.jpg", []byte("bbbb"), time.Now())
+	store.Put("my-bucket", "other.txt", []byte("other"), time.Now())
+	server := &Server{client: store, hub: NewWebSocketHub()}
+
+	r := chi.NewRouter()
+	r.Get("/api/archive/{bucket}/*", server.handleArchive)
+	return r
+}
+
+func TestHandleArchive_Zip(t *testing.T) {
+	rr := httptest.NewRecorder()
+	archiveServer().ServeHTTP(rr, httptest.NewRequest("GET", "/api/archive/my-bucket/photos", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename=photos.zip` {
+		t.Errorf("Unexpected Content-Disposition %q", got)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatalf("Invalid zip: %v", err)
+	}
+	got := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		got[f.Name] = string(data)
+	}
+	if len(got) != 2 || got["a.jpg"] != "aaa" || got["trip/b.jpg"] != "bbbb" {
+		t.Errorf("Unexpected zip contents %v", got)
+	}
+}
+
+func TestHandleArchive_TarGz(t *testing.T) {
+	rr := httptest.NewRecorder()
+	archiveServer().ServeHTTP(rr, httptest.NewRequest("GET", "/api/archive/my-bucket/?format=tar.gz", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	gz, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("Invalid gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Invalid tar: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	if len(names) != 3 || names[0] != "other.txt" || names[2] != "photos/trip/b.jpg" {
+		t.Errorf("Unexpected tar entries %v", names)
+	}
+}
+
+func TestHandleArchive_Errors(t *testing.T) {
+	r := archiveServer()
+	tests := []struct {
+		url  string
+		code int
+	}{
+		{"/api/archive/my-bucket/missing", http.StatusNotFound},
+		{"/api/archive/my-bucket/photos?format=rar", http.StatusBadRequest},
+		{"/api/archive/no-bucket/photos", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", tt.url, nil))
+		if rr.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.url, tt.code, rr.Code)
+		}
+	}
+}
+
+func TestArchiveEntryName(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"photos/a.jpg", "a.jpg", true},
+		{"photos/trip/./b.jpg", "trip/b.jpg", true},
+		{"photos/trip/../c.jpg", "c.jpg", true},
+		{"photos/../../etc/passwd", "", false},
+		{"photos/..", "", false},
+		{"photos//etc/passwd", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := archiveEntryName(tt.name, "photos/")
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("archiveEntryName(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestHandleArchive_SkipsUnsafeNames(t *testing.T) {
+	store := memstore.New("my-bucket")
+	store.Put("my-bucket", "photos/a.jpg", []byte("aaa"), time.Now())
+	store.Put("my-bucket", "photos/../../evil.sh", []byte("evil"), time.Now())
+	store.Put("my-bucket", "photos//etc/cron.d/evil", []byte("evil"), time.Now())
+	server := &Server{client: store, hub: NewWebSocketHub()}
+	r := chi.NewRouter()
+	r.Get("/api/archive/{bucket}/*", server.handleArchive)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/archive/my-bucket/photos", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatalf("Invalid zip: %v", err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "a.jpg" {
+		names := make([]string, len(zr.File))
+		for i, f := range zr.File {
+			names[i] = f.Name
+		}
+		t.Errorf("Expected only a.jpg, got %v", names)
+	}
+}
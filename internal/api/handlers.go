@@ -2,12 +2,19 @@ package api
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"path/filepath"
 	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,6 +26,7 @@ import (
 	"github.com/ryanoboyle/bb-stream/internal/watch"
 	"github.com/ryanoboyle/bb-stream/pkg/errors"
 	"github.com/ryanoboyle/bb-stream/pkg/logging"
+	"github.com/ryanoboyle/bb-stream/pkg/progress"
 )
 
 // safeGo runs a function in a goroutine with panic recovery
@@ -52,6 +60,26 @@ func init() {
 	}()
 }
 
+// newJobID returns a unique, time-ordered job ID: a millisecond timestamp
+// followed by 64 random bits, so IDs sort by creation and never collide in practice
+func newJobID(prefix string) string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return fmt.Sprintf("%s-%011x%s", prefix, time.Now().UnixMilli(), hex.EncodeToString(b[:]))
+}
+
+// touch advances an UpdatedAt timestamp, never letting it move backwards.
+// Callers must hold the job's lock.
+func touch(t *time.Time) {
+	now := time.Now()
+	if !now.After(*t) {
+		now = t.Add(time.Nanosecond)
+	}
+	*t = now
+}
+
 // cleanupOldJobs removes completed/stopped jobs older than jobTTL
 func cleanupOldJobs() {
 	now := time.Now()
@@ -59,7 +87,7 @@ func cleanupOldJobs() {
 	// Cleanup sync jobs
 	syncJobsMu.Lock()
 	for id, job := range syncJobs {
-		if job.Status == "completed" || job.Status == "failed" {
+		if job.Status == "completed" || job.Status == "failed" || job.Status == "cancelled" {
 			if !job.CompletedAt.IsZero() && now.Sub(job.CompletedAt) > jobTTL {
 				delete(syncJobs, id)
 			}
@@ -93,7 +121,7 @@ func respondError(w http.ResponseWriter, status int, message string) {
 
 // handleError logs the error with context and sends a sanitized error response.
 // The internal error is logged but not exposed to clients.
-func handleError(w http.ResponseWriter, err error, status int, operation string, attrs ...any) {
+func handleError(w http.ResponseWriter, r *http.Request, err error, status int, operation string, attrs ...any) {
 	// Build log attributes
 	logAttrs := []any{
 		logging.Operation(operation),
@@ -103,13 +131,30 @@ func handleError(w http.ResponseWriter, err error, status int, operation string,
 	logAttrs = append(logAttrs, attrs...)
 
 	// Log the internal error
-	logging.Logger().Error("request failed", logAttrs...)
+	logging.WithContext(r.Context()).Error("request failed", logAttrs...)
 
 	// Send sanitized error to client
 	safeMessage := errors.Sanitize(err)
 	respondError(w, status, safeMessage)
 }
 
+// notFoundOr returns 404 for not-found errors and the fallback status otherwise
+func notFoundOr(err error, fallback int) int {
+	if errors.IsNotFound(err) {
+		return http.StatusNotFound
+	}
+	return fallback
+}
+
+// downloadErrorStatus returns 401 when B2 refused access to an object, 404 when
+// it doesn't exist and 500 otherwise
+func downloadErrorStatus(err error) int {
+	if stderrors.Is(err, errors.ErrUnauthorized) {
+		return http.StatusUnauthorized
+	}
+	return notFoundOr(err, http.StatusInternalServerError)
+}
+
 // Path validation helpers
 
 // validatePath ensures a path is safe and does not escape the intended scope.
@@ -175,7 +220,7 @@ func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
 
 	// Try to create a client with provided credentials
 	ctx := r.Context()
-	_, err := b2.New(ctx, req.KeyID, req.ApplicationKey)
+	_, err := b2.New(ctx, req.KeyID, req.ApplicationKey, nil)
 	if err != nil {
 		respondError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
@@ -190,76 +235,410 @@ func (s *Server) handleListBuckets(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	buckets, err := s.client.ListBucketInfo(ctx)
 	if err != nil {
-		handleError(w, err, http.StatusInternalServerError, "list_buckets")
+		handleError(w, r, err, http.StatusInternalServerError, "list_buckets")
 		return
 	}
 
 	respondJSON(w, http.StatusOK, buckets)
 }
 
+// CreateBucketRequest is the body of POST /api/buckets
+type CreateBucketRequest struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // "allPrivate" (default) or "allPublic"
+}
+
+func (s *Server) handleCreateBucket(w http.ResponseWriter, r *http.Request) {
+	var req CreateBucketRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateBucketName(req.Name); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	info, err := s.client.CreateBucket(r.Context(), req.Name, req.Type)
+	if err != nil {
+		handleError(w, r, err, bucketErrorStatus(err), "create_bucket",
+			logging.Bucket(req.Name))
+		return
+	}
+
+	s.BroadcastEvent("bucket_created", info)
+	respondJSON(w, http.StatusCreated, info)
+}
+
+// handleDeleteBucket deletes an empty bucket, or a non-empty one with ?force=true
+func (s *Server) handleDeleteBucket(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if err := validateBucketName(name); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	force := r.URL.Query().Get("force") == "true"
+
+	if err := s.client.DeleteBucket(r.Context(), name, force); err != nil {
+		handleError(w, r, err, bucketErrorStatus(err), "delete_bucket",
+			logging.Bucket(name), slog.Bool("force", force))
+		return
+	}
+
+	s.BroadcastEvent("bucket_deleted", map[string]string{"bucket": name})
+	respondJSON(w, http.StatusOK, map[string]string{
+		"status": "deleted",
+		"bucket": name,
+	})
+}
+
+// unfinishedCleaner is a store that can cancel unfinished large file uploads
+type unfinishedCleaner interface {
+	CleanupUnfinished(ctx context.Context, bucketName string, olderThan time.Duration) ([]b2.UnfinishedUpload, error)
+	CancelUnfinishedUpload(ctx context.Context, bucketName, fileID string) error
+}
+
+// UnfinishedUploadInfo describes a cancelled unfinished upload
+type UnfinishedUploadInfo struct {
+	FileID  string     `json:"file_id"`
+	Name    string     `json:"name,omitempty"`
+	Started *time.Time `json:"started,omitempty"`
+}
+
+// CleanupResponse lists the unfinished uploads a cleanup cancelled
+type CleanupResponse struct {
+	Bucket    string                 `json:"bucket"`
+	Cancelled []UnfinishedUploadInfo `json:"cancelled"`
+}
+
+// handleCleanupUnfinished cancels a bucket's unfinished large file uploads
+// that started more than ?older_than= ago (an age such as 24h or 7d, default 24h), or
+// just the one named by ?file_id=
+func (s *Server) handleCleanupUnfinished(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if err := validateBucketName(name); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	olderThan := b2.DefaultUnfinishedAge
+	if v := query.Get("older_than"); v != "" {
+		d, err := b2.ParseAge(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "older_than must be an age such as 24h or 7d")
+			return
+		}
+		olderThan = d
+	}
+
+	cleaner, ok := s.client.(unfinishedCleaner)
+	if !ok {
+		respondError(w, http.StatusNotImplemented, "This storage backend has no unfinished uploads")
+		return
+	}
+
+	var cancelled []b2.UnfinishedUpload
+	var err error
+	if fileID := query.Get("file_id"); fileID != "" {
+		err = cleaner.CancelUnfinishedUpload(r.Context(), name, fileID)
+		if err == nil {
+			cancelled = []b2.UnfinishedUpload{{FileID: fileID}}
+		}
+	} else {
+		cancelled, err = cleaner.CleanupUnfinished(r.Context(), name, olderThan)
+	}
+	if err != nil {
+		handleError(w, r, err, bucketErrorStatus(err), "cleanup_unfinished",
+			logging.Bucket(name))
+		return
+	}
+
+	resp := CleanupResponse{Bucket: name, Cancelled: make([]UnfinishedUploadInfo, 0, len(cancelled))}
+	for _, u := range cancelled {
+		info := UnfinishedUploadInfo{FileID: u.FileID, Name: u.Name}
+		if u.Timestamp > 0 {
+			started := time.Unix(u.Timestamp, 0).UTC()
+			info.Started = &started
+		}
+		resp.Cancelled = append(resp.Cancelled, info)
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// bucketErrorStatus maps bucket management errors to HTTP status codes
+func bucketErrorStatus(err error) int {
+	switch {
+	case stderrors.Is(err, errors.ErrBadRequest):
+		return http.StatusBadRequest
+	case stderrors.Is(err, errors.ErrBucketExists), stderrors.Is(err, errors.ErrBucketNotEmpty):
+		return http.StatusConflict
+	}
+	return notFoundOr(err, http.StatusInternalServerError)
+}
+
+// handleListFiles lists objects under prefix. The optional pattern (a glob on
+// the base name, e.g. *.jpg) and suffix (e.g. .jpg) parameters are applied
+// after listing, since B2 itself can only filter by prefix.
 func (s *Server) handleListFiles(w http.ResponseWriter, r *http.Request) {
 	bucketName := chi.URLParam(r, "name")
-	prefix := r.URL.Query().Get("prefix")
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+
+	pattern := query.Get("pattern")
+	if suffix := query.Get("suffix"); suffix != "" {
+		if pattern != "" {
+			respondError(w, http.StatusBadRequest, "pattern and suffix cannot be combined")
+			return
+		}
+		pattern = "*" + escapeGlob(suffix)
+	}
+	if _, err := b2.FilterObjects(nil, pattern); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	ctx := r.Context()
 	objects, err := s.client.ListObjects(ctx, bucketName, prefix)
 	if err != nil {
-		handleError(w, err, http.StatusInternalServerError, "list_files",
+		handleError(w, r, err, http.StatusInternalServerError, "list_files",
 			logging.Bucket(bucketName))
 		return
 	}
 
+	objects, _ = b2.FilterObjects(objects, pattern)
 	respondJSON(w, http.StatusOK, objects)
 }
 
-// Upload handlers
+// SearchResponse is one page of objects matching a search
+type SearchResponse struct {
+	Objects []b2.ObjectInfo `json:"objects"`
+	Cursor  string          `json:"cursor,omitempty"` // Pass as ?cursor= for the next page
+}
 
-func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
-	// Parse multipart form
-	if err := r.ParseMultipartForm(32 << 20); err != nil { // 32MB max
-		respondError(w, http.StatusBadRequest, "Failed to parse form")
+// handleSearch finds objects whose names contain ?q=, ignoring case. B2 has
+// no search API, so this scans the listing (narrowed by ?prefix=) and stops
+// once ?limit= matches are found, returning a cursor for the rest.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	bucketName := chi.URLParam(r, "name")
+	if err := validateBucketName(bucketName); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	file, header, err := r.FormFile("file")
+	query := r.URL.Query()
+	q := query.Get("q")
+	if q == "" {
+		respondError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	limit := b2.DefaultSearchLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > b2.MaxSearchLimit {
+			respondError(w, http.StatusBadRequest,
+				fmt.Sprintf("limit must be between 1 and %d", b2.MaxSearchLimit))
+			return
+		}
+		limit = n
+	}
+
+	result, err := b2.SearchObjects(r.Context(), s.client, bucketName, query.Get("prefix"), q, query.Get("cursor"), limit)
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "No file provided")
+		handleError(w, r, err, notFoundOr(err, http.StatusInternalServerError), "search",
+			logging.Bucket(bucketName))
 		return
 	}
-	defer file.Close()
 
+	respondJSON(w, http.StatusOK, SearchResponse{Objects: result.Objects, Cursor: result.Cursor})
+}
+
+// escapeGlob quotes filepath.Match metacharacters so s matches literally
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// Upload handlers
+
+// UploadFileResult reports the outcome of one file in a multi-file upload
+type UploadFileResult struct {
+	Filename    string `json:"filename"`
+	Name        string `json:"name,omitempty"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type,omitempty"`
+	SHA1        string `json:"sha1,omitempty"`
+	Error       string `json:"error,omitempty"`
+
+	status int // HTTP status when the file failed
+}
+
+// partUploadFunc uploads one streamed file part to the given object path
+type partUploadFunc func(path string, part io.Reader) (*b2.UploadResult, error)
+
+// handleUpload streams multipart file parts straight to B2 without buffering them.
+// A single "file" field keeps the original response shape; one or more "files"
+// fields return per-file results, using the path query parameter as a prefix.
+// Since path names one object, only the first "file" field may use it.
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	bucket := r.URL.Query().Get("bucket")
 	if err := validateBucketName(bucket); err != nil {
 		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	path := r.URL.Query().Get("path")
-	if path == "" {
-		path = header.Filename
-	}
-	// Validate the path
-	path, err = validatePath(path)
+	mr, err := r.MultipartReader()
 	if err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+		respondError(w, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
 
 	ctx := r.Context()
-	result, err := s.client.UploadWithResult(ctx, bucket, path, file, header.Size, nil)
+	upload := func(path string, part io.Reader) (*b2.UploadResult, error) {
+		// Part sizes aren't known until the part is fully read
+		report := func(transferred, total int64) {
+			s.BroadcastEvent("upload_progress", UploadProgressEvent{
+				File:    path,
+				Percent: percentOf(transferred, total),
+				Bytes:   transferred,
+				Total:   total,
+			})
+		}
+		src := progress.NewReaderThrottled(part, -1, report, progress.DefaultInterval)
+
+		result, err := s.client.UploadWithResult(ctx, bucket, path, src, -1, nil)
+		if err != nil {
+			logging.WithContext(ctx).Error("request failed", logging.Operation("upload"), logging.Err(err),
+				logging.Bucket(bucket), logging.Object(path))
+			return nil, err
+		}
+
+		// Final update now that the size is known
+		s.BroadcastEvent("upload_progress", UploadProgressEvent{
+			File:    path,
+			Percent: 100,
+			Bytes:   result.Size,
+			Total:   result.Size,
+		})
+
+		// Broadcast upload event
+		s.BroadcastEvent("upload_complete", map[string]interface{}{
+			"name": result.Name,
+			"size": result.Size,
+		})
+		return result, nil
+	}
+
+	results, single, err := uploadParts(mr, r.URL.Query().Get("path"), upload)
 	if err != nil {
-		handleError(w, err, http.StatusInternalServerError, "upload",
-			logging.Bucket(bucket), logging.Object(path))
+		respondError(w, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+	if len(results) == 0 {
+		respondError(w, http.StatusBadRequest, "No file provided")
 		return
 	}
 
-	// Broadcast upload event
-	s.BroadcastEvent("upload_complete", map[string]interface{}{
-		"name": result.Name,
-		"size": result.Size,
-	})
+	if single {
+		res := results[0]
+		if res.Error != "" {
+			respondError(w, res.status, res.Error)
+			return
+		}
+		respondJSON(w, http.StatusOK, b2.UploadResult{Name: res.Name, Size: res.Size, ContentType: res.ContentType, SHA1: res.SHA1})
+		return
+	}
 
-	respondJSON(w, http.StatusOK, result)
+	status := http.StatusOK
+	for _, res := range results {
+		if res.Error != "" {
+			status = http.StatusMultiStatus
+			break
+		}
+	}
+	respondJSON(w, status, results)
+}
+
+// uploadParts reads file parts from mr one at a time and hands each to upload
+// as a stream, so memory use is bounded regardless of file size. single reports
+// whether the body was a lone legacy "file" field.
+func uploadParts(mr *multipart.Reader, pathParam string, upload partUploadFunc) (results []UploadFileResult, single bool, err error) {
+	prefix := strings.Trim(pathParam, "/")
+	legacy := 0
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		field := part.FormName()
+		if part.FileName() == "" || (field != "file" && field != "files") {
+			part.Close()
+			continue
+		}
+
+		// A lone "file" field uses path as the full object name; "files" use it as a prefix
+		name := part.FileName()
+		if field == "file" {
+			legacy++
+			if pathParam != "" {
+				if legacy > 1 {
+					// Another "file" part would overwrite the object the first one named
+					results = append(results, UploadFileResult{
+						Filename: part.FileName(),
+						Error:    "path names a single object; send several files as \"files\" fields to use it as a prefix",
+						status:   http.StatusBadRequest,
+					})
+					part.Close()
+					continue
+				}
+				name = pathParam
+			}
+		} else if prefix != "" {
+			name = prefix + "/" + name
+		}
+
+		results = append(results, uploadPart(part, name, upload))
+		part.Close()
+	}
+
+	return results, legacy == 1 && len(results) == 1, nil
+}
+
+// uploadPart validates the destination and streams a single part
+func uploadPart(part *multipart.Part, name string, upload partUploadFunc) UploadFileResult {
+	result := UploadFileResult{Filename: part.FileName()}
+
+	path, err := validatePath(name)
+	if err != nil {
+		result.Error = err.Error()
+		result.status = http.StatusBadRequest
+		return result
+	}
+
+	uploaded, err := upload(path, part)
+	if err != nil {
+		result.Error = errors.Sanitize(err)
+		result.status = http.StatusInternalServerError
+		return result
+	}
+
+	result.Name = uploaded.Name
+	result.Size = uploaded.Size
+	result.ContentType = uploaded.ContentType
+	result.SHA1 = uploaded.SHA1
+	return result
 }
 
 func (s *Server) handleStreamUpload(w http.ResponseWriter, r *http.Request) {
@@ -276,17 +655,55 @@ func (s *Server) handleStreamUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reject oversized uploads up front when the client declares a length,
+	// and cut off chunked ones once they pass the limit
+	body := r.Body
+	if limit := config.Get().MaxUploadBytes; limit > 0 {
+		if r.ContentLength > limit {
+			respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("upload exceeds the %d byte limit", limit))
+			return
+		}
+		body = http.MaxBytesReader(w, body, limit)
+	}
+
+	// The total is unknown until the body ends
+	report := func(transferred, total int64) {
+		s.BroadcastEvent("upload_progress", UploadProgressEvent{
+			File:    path,
+			Percent: -1,
+			Bytes:   transferred,
+			Total:   total,
+		})
+	}
+	src := progress.NewReaderThrottled(body, -1, report, progress.DefaultInterval)
+
 	ctx := r.Context()
-	err = s.client.StreamUpload(ctx, bucket, path, r.Body, nil)
+	result, err := s.client.StreamUploadWithResult(ctx, bucket, path, src, nil)
 	if err != nil {
-		handleError(w, err, http.StatusInternalServerError, "stream_upload",
+		var tooLarge *http.MaxBytesError
+		if stderrors.As(err, &tooLarge) {
+			respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("upload exceeds the %d byte limit", tooLarge.Limit))
+			return
+		}
+		handleError(w, r, err, http.StatusInternalServerError, "stream_upload",
 			logging.Bucket(bucket), logging.Object(path))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{
+	// Final update now that the size is known
+	size := src.Transferred()
+	s.BroadcastEvent("upload_progress", UploadProgressEvent{
+		File:    path,
+		Percent: 100,
+		Bytes:   size,
+		Total:   size,
+	})
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"status": "uploaded",
 		"path":   path,
+		"size":   size,
+		"sha1":   result.SHA1,
 	})
 }
 
@@ -310,24 +727,222 @@ func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 	// Get object info for headers
 	info, err := s.client.GetObjectInfo(ctx, bucket, path)
 	if err != nil {
-		handleError(w, err, http.StatusNotFound, "download",
+		handleError(w, r, err, downloadErrorStatus(err), "download",
 			logging.Bucket(bucket), logging.Object(path))
 		return
 	}
 
+	// Answer conditional requests without sending the body
+	if notModified(r, info) {
+		setObjectHeaders(w, info)
+		w.Header().Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	// Resolve a Range header before committing to a status
+	byteRange, err := parseByteRange(r.Header.Get("Range"), info.Size)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		respondError(w, http.StatusRequestedRangeNotSatisfiable, err.Error())
+		return
+	}
+
 	// Set headers
-	w.Header().Set("Content-Type", info.ContentType)
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size))
+	setObjectHeaders(w, info)
+	w.Header().Set("Accept-Ranges", "bytes")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(path)))
 
+	opts := b2.DefaultDownloadOptions()
+	if byteRange != nil {
+		opts.Range = byteRange
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", byteRange.End-byteRange.Start))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", byteRange.Start, byteRange.End-1, info.Size))
+		w.WriteHeader(http.StatusPartialContent)
+	}
+
 	// Stream the file
-	err = s.client.Download(ctx, bucket, path, w, nil)
+	err = s.client.Download(ctx, bucket, path, w, opts)
 	if err != nil {
 		// Can't send error response after headers are sent
 		return
 	}
 }
 
+// notModified reports whether the request's validators match the object.
+// If-None-Match takes precedence over If-Modified-Since, as in RFC 9110.
+func notModified(r *http.Request, info *b2.ObjectInfo) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if info.SHA1 == "" {
+			return false
+		}
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == `"`+info.SHA1+`"` {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && info.Timestamp > 0 {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		// HTTP dates have second precision
+		return !time.Unix(info.Timestamp, 0).After(since)
+	}
+
+	return false
+}
+
+// errRangeNotSatisfiable is returned when a Range header lies outside the object
+var errRangeNotSatisfiable = fmt.Errorf("requested range not satisfiable")
+
+// parseByteRange parses a single-range "bytes=" header against an object size.
+// It returns nil for an absent or unsupported header (including multi-range),
+// so the caller serves the full object. The returned End is exclusive.
+func parseByteRange(header string, size int64) (*b2.ByteRange, error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil, nil
+	}
+
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, nil
+	}
+
+	// Suffix range: last N bytes
+	if startStr == "" {
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil {
+			return nil, nil
+		}
+		if n <= 0 || size == 0 {
+			return nil, errRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return &b2.ByteRange{Start: size - n, End: size}, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+	if start >= size {
+		return nil, errRangeNotSatisfiable
+	}
+
+	// Open-ended range runs to the end of the object
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return nil, nil
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return &b2.ByteRange{Start: start, End: end + 1}, nil
+}
+
+// handleHeadObject reports object metadata as headers without a body
+func (s *Server) handleHeadObject(w http.ResponseWriter, r *http.Request) {
+	bucket := chi.URLParam(r, "bucket")
+	if err := validateBucketName(bucket); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	path, err := getPathFromURL(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	info, err := s.client.GetObjectInfo(r.Context(), bucket, path)
+	if err != nil {
+		status := downloadErrorStatus(err)
+		if status != http.StatusNotFound {
+			logging.WithContext(r.Context()).Error("request failed", logging.Operation("head"), logging.Status(status),
+				logging.Err(err), logging.Bucket(bucket), logging.Object(path))
+		}
+		w.WriteHeader(status)
+		return
+	}
+
+	setObjectHeaders(w, info)
+	if notModified(r, info) {
+		w.Header().Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// setObjectHeaders sets the standard entity headers for an object
+func setObjectHeaders(w http.ResponseWriter, info *b2.ObjectInfo) {
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size))
+	if info.Timestamp > 0 {
+		w.Header().Set("Last-Modified", time.Unix(info.Timestamp, 0).UTC().Format(http.TimeFormat))
+	}
+	if info.SHA1 != "" {
+		w.Header().Set("ETag", `"`+info.SHA1+`"`)
+	}
+}
+
+// PresignResponse is returned by the presign endpoint
+type PresignResponse struct {
+	URL       string    `json:"url"`
+	ExpiresIn int       `json:"expires_in"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// handlePresign generates a time-limited download URL so clients can fetch directly from B2
+func (s *Server) handlePresign(w http.ResponseWriter, r *http.Request) {
+	bucket := chi.URLParam(r, "bucket")
+	if err := validateBucketName(bucket); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	path, err := getPathFromURL(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	expires := 3600
+	if v := r.URL.Query().Get("expires"); v != "" {
+		expires, err = strconv.Atoi(v)
+		if err != nil || expires < b2.MinAuthSeconds || expires > b2.MaxAuthSeconds {
+			respondError(w, http.StatusBadRequest,
+				fmt.Sprintf("expires must be between %d and %d seconds", b2.MinAuthSeconds, b2.MaxAuthSeconds))
+			return
+		}
+	}
+
+	url, err := s.client.PresignedURL(r.Context(), bucket, path, expires)
+	if err != nil {
+		handleError(w, r, err, downloadErrorStatus(err), "presign",
+			logging.Bucket(bucket), logging.Object(path))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, PresignResponse{
+		URL:       url,
+		ExpiresIn: expires,
+		ExpiresAt: time.Now().Add(time.Duration(expires) * time.Second).UTC(),
+	})
+}
+
 func (s *Server) handleStreamDownload(w http.ResponseWriter, r *http.Request) {
 	bucket := chi.URLParam(r, "bucket")
 	if err := validateBucketName(bucket); err != nil {
@@ -346,7 +961,7 @@ func (s *Server) handleStreamDownload(w http.ResponseWriter, r *http.Request) {
 	// Get object info
 	info, err := s.client.GetObjectInfo(ctx, bucket, path)
 	if err != nil {
-		handleError(w, err, http.StatusNotFound, "stream_download",
+		handleError(w, r, err, downloadErrorStatus(err), "stream_download",
 			logging.Bucket(bucket), logging.Object(path))
 		return
 	}
@@ -363,31 +978,81 @@ func (s *Server) handleStreamDownload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create a writer that flushes periodically
-	flushWriter := &flushingWriter{w: w, f: flusher}
+	flushWriter := newFlushingWriter(w, flusher, s.flushThreshold)
+
+	// Report progress against the known object size
+	report := func(transferred, total int64) {
+		s.BroadcastEvent("download_progress", DownloadProgressEvent{
+			File:    path,
+			Percent: percentOf(transferred, total),
+			Bytes:   transferred,
+			Total:   total,
+		})
+	}
+	dest := progress.NewWriterThrottled(flushWriter, info.Size, report, progress.DefaultInterval)
 
-	err = s.client.StreamDownload(ctx, bucket, path, flushWriter, nil)
+	err = s.client.StreamDownload(ctx, bucket, path, dest, nil)
 	if err != nil {
 		return
 	}
+	// Deliver the tail that didn't reach the flush threshold
+	flushWriter.Flush()
 }
 
+// percentOf returns the completion percentage, or -1 when the total is unknown
+func percentOf(transferred, total int64) float64 {
+	if total <= 0 {
+		return -1
+	}
+	return float64(transferred) / float64(total) * 100
+}
+
+// defaultFlushThreshold is how much a flushingWriter buffers before flushing
+const defaultFlushThreshold = 64 * 1024
+
+// flushingWriter flushes a streamed response every threshold bytes, and as
+// soon as the last byte of a body with a known Content-Length is written
 type flushingWriter struct {
-	w       io.Writer
-	f       http.Flusher
-	written int
+	w         io.Writer
+	f         http.Flusher
+	threshold int   // Bytes between flushes; 0 uses defaultFlushThreshold
+	length    int64 // Declared Content-Length; 0 when unknown
+	total     int64 // Bytes written so far
+	written   int   // Bytes written since the last flush
+}
+
+// newFlushingWriter wraps w, picking up the Content-Length already set on its headers
+func newFlushingWriter(w http.ResponseWriter, f http.Flusher, threshold int) *flushingWriter {
+	fw := &flushingWriter{w: w, f: f, threshold: threshold}
+	if n, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil && n > 0 {
+		fw.length = n
+	}
+	return fw
 }
 
 func (fw *flushingWriter) Write(p []byte) (int, error) {
 	n, err := fw.w.Write(p)
 	fw.written += n
-	// Flush every 64KB
-	if fw.written >= 65536 {
-		fw.f.Flush()
-		fw.written = 0
+	fw.total += int64(n)
+
+	threshold := fw.threshold
+	if threshold <= 0 {
+		threshold = defaultFlushThreshold
+	}
+	// A short write still delivers the bytes that made it through
+	if fw.written > 0 && (fw.written >= threshold || n < len(p) ||
+		(fw.length > 0 && fw.total >= fw.length)) {
+		fw.Flush()
 	}
 	return n, err
 }
 
+// Flush sends any buffered bytes to the client
+func (fw *flushingWriter) Flush() {
+	fw.f.Flush()
+	fw.written = 0
+}
+
 // Sync handlers
 
 var (
@@ -404,17 +1069,26 @@ type SyncJob struct {
 	Direction   string                   `json:"direction"`
 	StartTime   time.Time                `json:"start_time"`
 	CompletedAt time.Time                `json:"completed_at,omitempty"`
+	UpdatedAt   time.Time                `json:"updated_at"`
 	Progress    string                   `json:"progress,omitempty"`
 	Result      *internalSync.SyncResult `json:"result,omitempty"`
+	cancel      context.CancelFunc
 }
 
 type SyncRequest struct {
 	LocalPath string `json:"local_path"`
 	Bucket    string `json:"bucket"`
 	Path      string `json:"path"`
-	Direction string `json:"direction"` // "to_remote" or "to_local"
+	Direction string `json:"direction"` // "to_remote", "to_local" or "bidirectional"
 	DryRun    bool   `json:"dry_run"`
 	Delete    bool   `json:"delete"`
+
+	// Conflict resolves files changed on both sides of a bidirectional sync:
+	// "newer" (the default), "local", "remote" or "skip"
+	Conflict string `json:"conflict,omitempty"`
+
+	MaxErrors             int  `json:"max_errors,omitempty"` // 0 uses the default cap
+	AbortOnRepeatedErrors bool `json:"abort_on_repeated_errors,omitempty"`
 }
 
 func (s *Server) handleSyncStart(w http.ResponseWriter, r *http.Request) {
@@ -433,13 +1107,35 @@ func (s *Server) handleSyncStart(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "bucket is required")
 		return
 	}
-	if req.Direction != "to_remote" && req.Direction != "to_local" {
-		respondError(w, http.StatusBadRequest, "direction must be 'to_remote' or 'to_local'")
+	directions := map[string]internalSync.Direction{
+		"to_remote":     internalSync.ToRemote,
+		"to_local":      internalSync.ToLocal,
+		"bidirectional": internalSync.Bidirectional,
+	}
+	direction, ok := directions[req.Direction]
+	if !ok {
+		respondError(w, http.StatusBadRequest, "direction must be 'to_remote', 'to_local' or 'bidirectional'")
+		return
+	}
+	if req.Delete && direction == internalSync.Bidirectional {
+		respondError(w, http.StatusBadRequest, "delete requires direction 'to_remote' or 'to_local'")
+		return
+	}
+	if req.Conflict != "" && direction != internalSync.Bidirectional {
+		respondError(w, http.StatusBadRequest, "conflict only applies to direction 'bidirectional'")
+		return
+	}
+	conflict, err := internalSync.ParseConflictPolicy(req.Conflict)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Generate job ID
-	jobID := fmt.Sprintf("sync-%d", time.Now().UnixNano())
+	jobID := newJobID("sync")
+
+	// Use a cancelable background context since the HTTP request context will end
+	ctx, cancel := context.WithCancel(context.Background())
 
 	// Create job
 	job := &SyncJob{
@@ -450,53 +1146,74 @@ func (s *Server) handleSyncStart(w http.ResponseWriter, r *http.Request) {
 		Path:      req.Path,
 		Direction: req.Direction,
 		StartTime: time.Now(),
+		cancel:    cancel,
 	}
+	job.UpdatedAt = job.StartTime
 
 	syncJobsMu.Lock()
 	syncJobs[jobID] = job
 	syncJobsMu.Unlock()
 
+	// Job logs outlive the request but keep its request ID for correlation
+	logger := logging.WithContext(r.Context())
+
 	// Run sync in background with panic recovery
 	safeGo(func() {
+		defer cancel()
+
 		opts := internalSync.DefaultSyncOptions()
 		opts.DryRun = req.DryRun
 		opts.Delete = req.Delete
-
-		if req.Direction == "to_remote" {
-			opts.Direction = internalSync.ToRemote
-		} else {
-			opts.Direction = internalSync.ToLocal
+		opts.AbortOnSystemic = req.AbortOnRepeatedErrors
+		if req.MaxErrors > 0 {
+			opts.MaxErrors = req.MaxErrors
 		}
 
+		opts.Direction = direction
+		opts.ConflictPolicy = conflict
+
 		opts.ProgressCallback = func(status internalSync.SyncStatus) {
 			syncJobsMu.Lock()
-			job.Progress = fmt.Sprintf("%s: %s", status.Phase, status.CurrentFile)
+			if status.FilesTotal > 0 {
+				job.Progress = fmt.Sprintf("[%d/%d] %s: %s", status.FilesCompleted, status.FilesTotal, status.Phase, status.CurrentFile)
+			} else {
+				job.Progress = fmt.Sprintf("%s: %s", status.Phase, status.CurrentFile)
+			}
+			touch(&job.UpdatedAt)
 			syncJobsMu.Unlock()
 
-			s.BroadcastEvent("sync_progress", map[string]interface{}{
-				"job_id":   jobID,
-				"phase":    status.Phase,
-				"file":     status.CurrentFile,
+			s.BroadcastEvent("sync_progress", SyncProgressEvent{
+				JobID:   jobID,
+				Phase:   status.Phase,
+				File:    status.CurrentFile,
+				Current: status.FilesCompleted,
+				Total:   status.FilesTotal,
 			})
 		}
 
 		syncer := internalSync.NewSyncer(s.client, opts)
-		// Use background context since HTTP request context will be cancelled
-		result, err := syncer.Sync(context.Background(), req.LocalPath, req.Bucket, req.Path)
+		result, err := syncer.Sync(ctx, req.LocalPath, req.Bucket, req.Path)
 
 		syncJobsMu.Lock()
 		job.CompletedAt = time.Now()
-		if err != nil {
+		touch(&job.UpdatedAt)
+		if job.Status == "cancelled" {
+			job.Result = result
+			logger.Info("sync job cancelled",
+				logging.JobID(jobID),
+				logging.Bucket(req.Bucket))
+		} else if err != nil {
 			job.Status = "failed"
 			job.Progress = err.Error()
-			logging.Logger().Error("sync job failed",
+			job.Result = result // Partial result when the sync aborted mid-way
+			logger.Error("sync job failed",
 				logging.JobID(jobID),
 				logging.Bucket(req.Bucket),
 				logging.Err(err))
 		} else {
 			job.Status = "completed"
 			job.Result = result
-			logging.Logger().Info("sync job completed",
+			logger.Info("sync job completed",
 				logging.JobID(jobID),
 				logging.Bucket(req.Bucket),
 				"uploaded", result.Uploaded,
@@ -532,6 +1249,36 @@ func (s *Server) handleSyncStatus(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, job)
 }
 
+func (s *Server) handleSyncCancel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	syncJobsMu.Lock()
+	job, exists := syncJobs[req.JobID]
+	running := exists && job.Status == "running"
+	if running {
+		job.cancel()
+		job.Status = "cancelled"
+		touch(&job.UpdatedAt)
+	}
+	syncJobsMu.Unlock()
+
+	if !running {
+		respondError(w, http.StatusNotFound, "Job not found or not running")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"job_id": req.JobID,
+		"status": "cancelled",
+	})
+}
+
 // Watch handlers
 
 var (
@@ -547,13 +1294,22 @@ type WatchJob struct {
 	Path      string                `json:"path"`
 	StartTime time.Time             `json:"start_time"`
 	StoppedAt time.Time             `json:"stopped_at,omitempty"`
+	UpdatedAt time.Time             `json:"updated_at"`
 	uploader  *watch.AutoUploader
 }
 
 type WatchRequest struct {
-	LocalPath string `json:"local_path"`
-	Bucket    string `json:"bucket"`
-	Path      string `json:"path"`
+	LocalPath string   `json:"local_path"`
+	Bucket    string   `json:"bucket"`
+	Path      string   `json:"path"`
+	Include   []string `json:"include,omitempty"` // Allowlist globs; empty means all files
+	Exclude   []string `json:"exclude,omitempty"` // Added to the default ignore patterns
+	// MirrorDeletes deletes remote objects when local files are removed
+	MirrorDeletes bool `json:"mirror_deletes,omitempty"`
+	// InitialScan uploads files that changed since the last upload before watching
+	InitialScan bool `json:"initial_scan,omitempty"`
+	// PathTemplate lays out object names under path; see watch.WatcherOptions.PathTemplate
+	PathTemplate string `json:"path_template,omitempty"`
 }
 
 func (s *Server) handleWatchStart(w http.ResponseWriter, r *http.Request) {
@@ -572,14 +1328,31 @@ func (s *Server) handleWatchStart(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "bucket is required")
 		return
 	}
+	if req.PathTemplate != "" {
+		if req.InitialScan {
+			respondError(w, http.StatusBadRequest, "path_template can't be combined with initial_scan")
+			return
+		}
+		if err := watch.ValidatePathTemplate(req.PathTemplate); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 
 	// Generate job ID
-	jobID := fmt.Sprintf("watch-%d", time.Now().UnixNano())
+	jobID := newJobID("watch")
 
 	// Create auto uploader
-	uploader, err := watch.NewAutoUploader(s.client, req.LocalPath, req.Bucket, req.Path, nil)
+	watchOpts := watch.DefaultWatcherOptions().WithPatterns(req.Include, req.Exclude)
+	watchOpts.MirrorDeletes = req.MirrorDeletes
+	watchOpts.InitialScan = req.InitialScan
+	watchOpts.PathTemplate = req.PathTemplate
+	if configPath := config.GetConfigPath(); configPath != "" {
+		watchOpts.RetryJournal = watch.JournalPath(filepath.Dir(configPath), req.LocalPath, req.Bucket, req.Path)
+	}
+	uploader, err := watch.NewAutoUploader(s.client, req.LocalPath, req.Bucket, req.Path, watchOpts)
 	if err != nil {
-		handleError(w, err, http.StatusInternalServerError, "watch_start",
+		handleError(w, r, err, http.StatusInternalServerError, "watch_start",
 			logging.Bucket(req.Bucket), logging.Path(req.LocalPath))
 		return
 	}
@@ -593,9 +1366,43 @@ func (s *Server) handleWatchStart(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			data["error"] = err.Error()
 		}
+
+		watchJobsMu.Lock()
+		if job, ok := watchJobs[jobID]; ok {
+			touch(&job.UpdatedAt)
+		}
+		watchJobsMu.Unlock()
+
 		s.BroadcastEvent(eventType, data)
 	}
 
+	uploader.OnRetry = func(path string, attempt int, wait time.Duration) {
+		s.BroadcastEvent("watch_retry", map[string]interface{}{
+			"job_id":        jobID,
+			"path":          path,
+			"attempt":       attempt,
+			"retry_in_secs": wait.Seconds(),
+		})
+	}
+
+	uploader.OnDelete = func(path string, err error) {
+		data := map[string]interface{}{
+			"job_id": jobID,
+			"path":   path,
+		}
+		if err != nil {
+			data["error"] = errors.Sanitize(err)
+		}
+
+		watchJobsMu.Lock()
+		if job, ok := watchJobs[jobID]; ok {
+			touch(&job.UpdatedAt)
+		}
+		watchJobsMu.Unlock()
+
+		s.BroadcastEvent("watch_delete", data)
+	}
+
 	// Create job
 	job := &WatchJob{
 		ID:        jobID,
@@ -606,14 +1413,28 @@ func (s *Server) handleWatchStart(w http.ResponseWriter, r *http.Request) {
 		StartTime: time.Now(),
 		uploader:  uploader,
 	}
+	job.UpdatedAt = job.StartTime
 
 	watchJobsMu.Lock()
 	watchJobs[jobID] = job
 	watchJobsMu.Unlock()
 
-	// Start watching - use background context since HTTP request will end
+	// Start watching - use background context since HTTP request will end.
+	// With initial_scan the remote listing can fail, which ends the job.
 	go func() {
-		_ = uploader.Start(context.Background())
+		if err := uploader.Start(context.Background()); err != nil {
+			logging.Logger().Error("watch job failed to start", logging.JobID(jobID), logging.Err(err))
+			uploader.Stop()
+			watchJobsMu.Lock()
+			job.Status = "failed"
+			job.StoppedAt = time.Now()
+			touch(&job.UpdatedAt)
+			watchJobsMu.Unlock()
+			s.BroadcastEvent("watch_error", map[string]interface{}{
+				"job_id": jobID,
+				"error":  errors.Sanitize(err),
+			})
+		}
 	}()
 
 	respondJSON(w, http.StatusOK, map[string]string{
@@ -622,6 +1443,50 @@ func (s *Server) handleWatchStart(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// WatchStatusResponse is a watch job with its upload counters
+type WatchStatusResponse struct {
+	*WatchJob
+	FilesUploaded     int64     `json:"files_uploaded"`
+	Failures          int64     `json:"failures"`
+	QueueDepth        int       `json:"queue_depth"`        // Changed files waiting for an upload slot
+	RetryQueue        int       `json:"retry_queue"`        // Failed uploads waiting to be retried
+	FailedPermanently int64     `json:"failed_permanently"` // Files given up on after every retry failed
+	LastError         string    `json:"last_error,omitempty"`
+	LastErrorAt       time.Time `json:"last_error_at,omitempty"`
+}
+
+func (s *Server) handleWatchStatus(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "id")
+
+	watchJobsMu.RLock()
+	job, exists := watchJobs[jobID]
+	var snapshot WatchJob
+	if exists {
+		snapshot = *job
+	}
+	watchJobsMu.RUnlock()
+
+	if !exists {
+		respondError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	resp := WatchStatusResponse{WatchJob: &snapshot}
+	if snapshot.uploader != nil {
+		resp.FilesUploaded = snapshot.uploader.Uploaded()
+		resp.Failures = snapshot.uploader.Failed()
+		resp.QueueDepth = snapshot.uploader.QueueDepth()
+		resp.RetryQueue = snapshot.uploader.RetryDepth()
+		resp.FailedPermanently = snapshot.uploader.FailedPermanently()
+		if at, lastErr := snapshot.uploader.LastError(); lastErr != nil {
+			resp.LastError = errors.Sanitize(lastErr)
+			resp.LastErrorAt = at
+		}
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
 func (s *Server) handleWatchStop(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		JobID string `json:"job_id"`
@@ -633,15 +1498,19 @@ func (s *Server) handleWatchStop(w http.ResponseWriter, r *http.Request) {
 
 	watchJobsMu.Lock()
 	job, exists := watchJobs[req.JobID]
+	watchJobsMu.Unlock()
 	if exists {
+		// Stop waits for queued uploads, whose callbacks take watchJobsMu
 		job.uploader.Stop()
+		watchJobsMu.Lock()
 		job.Status = "stopped"
 		job.StoppedAt = time.Now()
-		logging.Logger().Info("watch job stopped",
+		touch(&job.UpdatedAt)
+		watchJobsMu.Unlock()
+		logging.WithContext(r.Context()).Info("watch job stopped",
 			logging.JobID(req.JobID),
 			logging.Bucket(job.Bucket))
 	}
-	watchJobsMu.Unlock()
 
 	if !exists {
 		respondError(w, http.StatusNotFound, "Job not found")
@@ -656,28 +1525,91 @@ func (s *Server) handleWatchStop(w http.ResponseWriter, r *http.Request) {
 
 // Jobs handler
 
+// JobSummary is a single entry in the jobs list
+type JobSummary struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	Status      string    `json:"status"`
+	Bucket      string    `json:"bucket"`
+	LocalPath   string    `json:"local_path"`
+	StartTime   time.Time `json:"start_time"`
+	CompletedAt time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// handleListJobs lists jobs, optionally filtered by ?type= and ?status=
+// and ordered by ?sort=start_time or ?sort=-start_time (the default)
 func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
-	jobs := make([]interface{}, 0)
+	query := r.URL.Query()
+	typeFilter := query.Get("type")
+	statusFilter := query.Get("status")
 
-	syncJobsMu.RLock()
-	for _, job := range syncJobs {
-		jobs = append(jobs, map[string]interface{}{
-			"id":     job.ID,
-			"type":   "sync",
-			"status": job.Status,
-		})
+	if typeFilter != "" && typeFilter != "sync" && typeFilter != "watch" {
+		respondError(w, http.StatusBadRequest, "type must be 'sync' or 'watch'")
+		return
 	}
-	syncJobsMu.RUnlock()
 
-	watchJobsMu.RLock()
-	for _, job := range watchJobs {
-		jobs = append(jobs, map[string]interface{}{
-			"id":     job.ID,
-			"type":   "watch",
-			"status": job.Status,
-		})
+	sortKey := query.Get("sort")
+	if sortKey == "" {
+		sortKey = "-start_time"
+	}
+	if sortKey != "start_time" && sortKey != "-start_time" {
+		respondError(w, http.StatusBadRequest, "sort must be 'start_time' or '-start_time'")
+		return
 	}
-	watchJobsMu.RUnlock()
+
+	jobs := make([]JobSummary, 0)
+	keep := func(job JobSummary) {
+		if statusFilter != "" && job.Status != statusFilter {
+			return
+		}
+		jobs = append(jobs, job)
+	}
+
+	if typeFilter == "" || typeFilter == "sync" {
+		syncJobsMu.RLock()
+		for _, job := range syncJobs {
+			keep(JobSummary{
+				ID:          job.ID,
+				Type:        "sync",
+				Status:      job.Status,
+				Bucket:      job.Bucket,
+				LocalPath:   job.LocalPath,
+				StartTime:   job.StartTime,
+				CompletedAt: job.CompletedAt,
+				CreatedAt:   job.StartTime,
+				UpdatedAt:   job.UpdatedAt,
+			})
+		}
+		syncJobsMu.RUnlock()
+	}
+
+	if typeFilter == "" || typeFilter == "watch" {
+		watchJobsMu.RLock()
+		for _, job := range watchJobs {
+			keep(JobSummary{
+				ID:          job.ID,
+				Type:        "watch",
+				Status:      job.Status,
+				Bucket:      job.Bucket,
+				LocalPath:   job.LocalPath,
+				StartTime:   job.StartTime,
+				CompletedAt: job.StoppedAt,
+				CreatedAt:   job.StartTime,
+				UpdatedAt:   job.UpdatedAt,
+			})
+		}
+		watchJobsMu.RUnlock()
+	}
+
+	newestFirst := sortKey == "-start_time"
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if newestFirst {
+			return jobs[i].StartTime.After(jobs[j].StartTime)
+		}
+		return jobs[i].StartTime.Before(jobs[j].StartTime)
+	})
 
 	respondJSON(w, http.StatusOK, jobs)
 }
@@ -698,9 +1630,15 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+
+	if r.URL.Query().Get("all_versions") == "true" {
+		s.deleteAllVersions(w, r, bucket, path)
+		return
+	}
+
 	err = s.client.DeleteObject(ctx, bucket, path)
 	if err != nil {
-		handleError(w, err, http.StatusInternalServerError, "delete",
+		handleError(w, r, err, notFoundOr(err, http.StatusInternalServerError), "delete",
 			logging.Bucket(bucket), logging.Object(path))
 		return
 	}
@@ -717,6 +1655,54 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// listedVersionDeleter is a store that can delete a version it has just
+// listed without looking the version up again
+type listedVersionDeleter interface {
+	DeleteListedVersion(ctx context.Context, v b2.ObjectVersion) error
+}
+
+// deleteAllVersions removes every stored version of a file, including hide markers
+func (s *Server) deleteAllVersions(w http.ResponseWriter, r *http.Request, bucket, path string) {
+	ctx := r.Context()
+
+	versions, err := s.client.ListObjectVersions(ctx, bucket, path)
+	if err != nil {
+		handleError(w, r, err, notFoundOr(err, http.StatusInternalServerError), "delete all versions",
+			logging.Bucket(bucket), logging.Object(path))
+		return
+	}
+
+	deleteVersion := func(v b2.ObjectVersion) error {
+		return s.client.DeleteVersion(ctx, bucket, path, v.FileID)
+	}
+	if d, ok := s.client.(listedVersionDeleter); ok {
+		deleteVersion = func(v b2.ObjectVersion) error { return d.DeleteListedVersion(ctx, v) }
+	}
+
+	deleted := 0
+	for _, v := range versions {
+		if err := deleteVersion(v); err != nil {
+			handleError(w, r, err, http.StatusInternalServerError, "delete all versions",
+				logging.Bucket(bucket), logging.Object(path), slog.Int("deleted", deleted))
+			return
+		}
+		deleted++
+	}
+
+	// Broadcast delete event
+	s.BroadcastEvent("file_deleted", map[string]interface{}{
+		"bucket":   bucket,
+		"path":     path,
+		"versions": deleted,
+	})
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "deleted",
+		"path":    path,
+		"deleted": deleted,
+	})
+}
+
 // URL parameter helper
 func getPathFromURL(r *http.Request) (string, error) {
 	path := chi.URLParam(r, "*")
@@ -763,7 +1749,7 @@ func (s *Server) handleSetConfig(w http.ResponseWriter, r *http.Request) {
 	// Validate credentials before saving
 	if req.KeyID != "" && req.ApplicationKey != "" {
 		ctx := r.Context()
-		_, err := b2.New(ctx, req.KeyID, req.ApplicationKey)
+		_, err := b2.New(ctx, req.KeyID, req.ApplicationKey, nil)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, "Invalid credentials: "+err.Error())
 			return
@@ -784,15 +1770,15 @@ func (s *Server) handleSetConfig(w http.ResponseWriter, r *http.Request) {
 
 	// Save config
 	if err := config.Save(); err != nil {
-		handleError(w, err, http.StatusInternalServerError, "save_config")
+		handleError(w, r, err, http.StatusInternalServerError, "save_config")
 		return
 	}
 
 	// Re-initialize B2 client with new credentials
 	if req.KeyID != "" && req.ApplicationKey != "" {
-		newClient, err := b2.New(r.Context(), req.KeyID, req.ApplicationKey)
+		newClient, err := b2.New(r.Context(), req.KeyID, req.ApplicationKey, nil)
 		if err != nil {
-			handleError(w, err, http.StatusInternalServerError, "create_client")
+			handleError(w, r, err, http.StatusInternalServerError, "create_client")
 			return
 		}
 		s.client = newClient
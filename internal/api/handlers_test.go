@@ -2,12 +2,24 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/ryanoboyle/bb-stream/internal/b2"
+	"github.com/ryanoboyle/bb-stream/internal/config"
+	"github.com/ryanoboyle/bb-stream/internal/memstore"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
 )
 
 func TestRespondJSON(t *testing.T) {
@@ -135,11 +147,39 @@ func TestHandleSyncStart_InvalidDirection(t *testing.T) {
 
 	var result map[string]string
 	_ = json.Unmarshal(rr.Body.Bytes(), &result)
-	if result["error"] != "direction must be 'to_remote' or 'to_local'" {
+	if result["error"] != "direction must be 'to_remote', 'to_local' or 'bidirectional'" {
 		t.Errorf("Expected direction error, got '%s'", result["error"])
 	}
 }
 
+func TestHandleSyncStart_InvalidConflict(t *testing.T) {
+	server := &Server{
+		hub: NewWebSocketHub(),
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"Unknown policy", `{"local_path": "/tmp/test", "bucket": "test-bucket", "direction": "bidirectional", "conflict": "both"}`},
+		{"One-way sync", `{"local_path": "/tmp/test", "bucket": "test-bucket", "direction": "to_remote", "conflict": "local"}`},
+		{"Bidirectional delete", `{"local_path": "/tmp/test", "bucket": "test-bucket", "direction": "bidirectional", "delete": true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/sync/start", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+
+			server.handleSyncStart(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
 func TestHandleSyncStatus_NotFound(t *testing.T) {
 	server := &Server{
 		hub: NewWebSocketHub(),
@@ -218,6 +258,26 @@ func TestHandleWatchStart_MissingBucket(t *testing.T) {
 	}
 }
 
+func TestHandleWatchStart_InvalidPathTemplate(t *testing.T) {
+	server := &Server{
+		hub: NewWebSocketHub(),
+	}
+
+	for _, body := range []string{
+		`{"local_path": "/tmp/test", "bucket": "b", "path_template": "{{.Nope}}"}`,
+		`{"local_path": "/tmp/test", "bucket": "b", "path_template": "{{.RelPath}}", "initial_scan": true}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/watch/start", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+
+		server.handleWatchStart(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+		}
+	}
+}
+
 func TestHandleWatchStop_InvalidJSON(t *testing.T) {
 	server := &Server{
 		hub: NewWebSocketHub(),
@@ -595,3 +655,1018 @@ func TestFlushingWriter(t *testing.T) {
 		t.Errorf("Expected written to be reset to 0 after flush, got %d", fw.written)
 	}
 }
+
+// flushRecorder records how much of the body had been flushed to the client
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+	flushed int // Body length at the last flush
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushes++
+	r.flushed = r.Body.Len()
+	r.ResponseRecorder.Flush()
+}
+
+// shortWriter accepts at most limit bytes per write
+type shortWriter struct {
+	io.Writer
+	limit int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n, _ := w.Writer.Write(p[:w.limit])
+		return n, io.ErrShortWrite
+	}
+	return w.Writer.Write(p)
+}
+
+func TestFlushingWriter_Threshold(t *testing.T) {
+	rr := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	fw := newFlushingWriter(rr, rr, 10)
+
+	_, _ = fw.Write(make([]byte, 6))
+	if rr.flushes != 0 {
+		t.Errorf("Expected no flush below the threshold, got %d", rr.flushes)
+	}
+	_, _ = fw.Write(make([]byte, 6))
+	if rr.flushes != 1 || rr.flushed != 12 {
+		t.Errorf("Expected a flush of 12 bytes at the threshold, got %d flushes of %d bytes", rr.flushes, rr.flushed)
+	}
+
+	_, _ = fw.Write(make([]byte, 3))
+	fw.Flush()
+	if rr.flushed != 15 {
+		t.Errorf("Expected Flush to deliver the tail, got %d of 15 bytes", rr.flushed)
+	}
+}
+
+func TestFlushingWriter_ContentLength(t *testing.T) {
+	rr := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	rr.Header().Set("Content-Length", "8")
+	fw := newFlushingWriter(rr, rr, 0)
+
+	_, _ = fw.Write([]byte("abcd"))
+	if rr.flushes != 0 {
+		t.Errorf("Expected no flush mid-body, got %d", rr.flushes)
+	}
+	_, _ = fw.Write([]byte("efgh"))
+	if rr.flushes != 1 || rr.flushed != 8 {
+		t.Errorf("Expected the last byte of the body to flush, got %d flushes of %d bytes", rr.flushes, rr.flushed)
+	}
+}
+
+func TestFlushingWriter_ShortWrite(t *testing.T) {
+	rr := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	fw := &flushingWriter{w: &shortWriter{Writer: rr, limit: 4}, f: rr}
+
+	n, err := fw.Write([]byte("abcdefgh"))
+	if n != 4 || err != io.ErrShortWrite {
+		t.Fatalf("Expected a short write of 4 bytes, got %d, %v", n, err)
+	}
+	if rr.flushes != 1 || rr.flushed != 4 {
+		t.Errorf("Expected the partial write to be flushed, got %d flushes of %d bytes", rr.flushes, rr.flushed)
+	}
+}
+
+func TestHandleStreamDownload_FlushesTail(t *testing.T) {
+	// Smaller than the flush threshold, so only the final flush delivers it
+	data := bytes.Repeat([]byte("x"), 1000)
+	store := memstore.New("my-bucket")
+	store.Put("my-bucket", "big.bin", data, time.Now())
+	server := &Server{client: store, hub: NewWebSocketHub()}
+
+	r := chi.NewRouter()
+	r.Get("/api/stream/{bucket}/*", server.handleStreamDownload)
+	rr := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/stream/my-bucket/big.bin", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if !bytes.Equal(rr.Body.Bytes(), data) {
+		t.Fatalf("Expected %d bytes, got %d", len(data), rr.Body.Len())
+	}
+	if rr.flushed != len(data) {
+		t.Errorf("Expected all %d bytes flushed, only %d were", len(data), rr.flushed)
+	}
+}
+
+func TestNotFoundOr(t *testing.T) {
+	notFound := fmt.Errorf("bucket %q: %w", "missing", errors.ErrBucketNotFound)
+	if got := notFoundOr(notFound, http.StatusInternalServerError); got != http.StatusNotFound {
+		t.Errorf("Expected %d for wrapped ErrBucketNotFound, got %d", http.StatusNotFound, got)
+	}
+
+	network := fmt.Errorf("failed to list buckets: connection refused")
+	if got := notFoundOr(network, http.StatusInternalServerError); got != http.StatusInternalServerError {
+		t.Errorf("Expected %d for network error, got %d", http.StatusInternalServerError, got)
+	}
+}
+
+func TestDownloadErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("failed to download: %w", errors.ErrUnauthorized), http.StatusUnauthorized},
+		{fmt.Errorf("object: %w", errors.ErrObjectNotFound), http.StatusNotFound},
+		{fmt.Errorf("connection reset"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := downloadErrorStatus(tt.err); got != tt.want {
+			t.Errorf("downloadErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestSetObjectHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	setObjectHeaders(w, &b2.ObjectInfo{
+		Name:        "a.txt",
+		Size:        42,
+		ContentType: "text/plain",
+		Timestamp:   1700000000,
+		SHA1:        "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+	})
+
+	h := w.Header()
+	if h.Get("Content-Length") != "42" {
+		t.Errorf("Expected Content-Length 42, got %q", h.Get("Content-Length"))
+	}
+	if h.Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected Content-Type text/plain, got %q", h.Get("Content-Type"))
+	}
+	if h.Get("ETag") != `"da39a3ee5e6b4b0d3255bfef95601890afd80709"` {
+		t.Errorf("Unexpected ETag %q", h.Get("ETag"))
+	}
+	if h.Get("Last-Modified") != "Tue, 14 Nov 2023 22:13:20 GMT" {
+		t.Errorf("Unexpected Last-Modified %q", h.Get("Last-Modified"))
+	}
+
+	// Unknown SHA1 means no ETag
+	w = httptest.NewRecorder()
+	setObjectHeaders(w, &b2.ObjectInfo{Size: 1})
+	if w.Header().Get("ETag") != "" {
+		t.Errorf("Expected no ETag without SHA1, got %q", w.Header().Get("ETag"))
+	}
+}
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		header    string
+		wantStart int64
+		wantEnd   int64
+		wantNil   bool
+		wantErr   bool
+	}{
+		{header: "", wantNil: true},
+		{header: "bytes=0-99", wantStart: 0, wantEnd: 100},
+		{header: "bytes=500-", wantStart: 500, wantEnd: 1000},
+		{header: "bytes=-100", wantStart: 900, wantEnd: 1000},
+		{header: "bytes=900-5000", wantStart: 900, wantEnd: 1000},
+		{header: "bytes=-5000", wantStart: 0, wantEnd: 1000},
+		{header: "bytes=1000-", wantErr: true},
+		{header: "bytes=-0", wantErr: true},
+		{header: "bytes=0-10,20-30", wantNil: true},
+		{header: "bytes=50-10", wantNil: true},
+		{header: "items=0-10", wantNil: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, err := parseByteRange(tt.header, 1000)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.wantNil {
+				if got != nil {
+					t.Errorf("Expected nil range, got %+v", got)
+				}
+				return
+			}
+			if got == nil || got.Start != tt.wantStart || got.End != tt.wantEnd {
+				t.Errorf("Expected [%d,%d), got %+v", tt.wantStart, tt.wantEnd, got)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	info := &b2.ObjectInfo{
+		SHA1:      "abc123",
+		Timestamp: 1700000000,
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{"no validators", nil, false},
+		{"matching etag", map[string]string{"If-None-Match": `"abc123"`}, true},
+		{"etag in list", map[string]string{"If-None-Match": `"zzz", W/"abc123"`}, true},
+		{"wildcard", map[string]string{"If-None-Match": "*"}, true},
+		{"different etag", map[string]string{"If-None-Match": `"zzz"`}, false},
+		{"not modified since", map[string]string{"If-Modified-Since": "Tue, 14 Nov 2023 22:13:20 GMT"}, true},
+		{"modified since", map[string]string{"If-Modified-Since": "Tue, 14 Nov 2023 22:00:00 GMT"}, false},
+		{"etag takes precedence", map[string]string{
+			"If-None-Match":     `"zzz"`,
+			"If-Modified-Since": "Tue, 14 Nov 2023 22:13:20 GMT",
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/download/b/a.txt", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := notModified(req, info); got != tt.want {
+				t.Errorf("notModified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlePresign_InvalidExpires(t *testing.T) {
+	server := &Server{
+		hub: NewWebSocketHub(),
+	}
+
+	r := chi.NewRouter()
+	r.Get("/api/presign/{bucket}/*", server.handlePresign)
+
+	for _, expires := range []string{"0", "604801", "abc"} {
+		req := httptest.NewRequest("GET", "/api/presign/my-bucket/file.txt?expires="+expires, nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expires=%s: expected status %d, got %d", expires, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+// presignErrStore fails every presign with err
+type presignErrStore struct {
+	*memstore.Store
+	err error
+}
+
+func (s *presignErrStore) PresignedURL(ctx context.Context, bucketName, objectName string, validSeconds int) (string, error) {
+	return "", s.err
+}
+
+func TestHandlePresign_ErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"not found", fmt.Errorf("object %q: %w", "file.txt", errors.ErrObjectNotFound), http.StatusNotFound},
+		{"unauthorized", fmt.Errorf("failed to get download authorization: %w", errors.ErrUnauthorized), http.StatusUnauthorized},
+		{"other", fmt.Errorf("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		server := &Server{client: &presignErrStore{Store: memstore.New("my-bucket"), err: tt.err}, hub: NewWebSocketHub()}
+		r := chi.NewRouter()
+		r.Get("/api/presign/{bucket}/*", server.handlePresign)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/presign/my-bucket/file.txt", nil))
+		if rr.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.code, rr.Code)
+		}
+	}
+}
+
+func TestHandleUpload_MultiFileInvalidPaths(t *testing.T) {
+	server := &Server{
+		hub: NewWebSocketHub(),
+	}
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		part, _ := mw.CreateFormFile("files", name)
+		part.Write([]byte("content"))
+	}
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/api/upload?bucket=my-bucket&path=../../etc", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rr := httptest.NewRecorder()
+
+	server.handleUpload(rr, req)
+
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("Expected status %d, got %d", http.StatusMultiStatus, rr.Code)
+	}
+
+	var results []UploadFileResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &results); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	for _, res := range results {
+		if res.Error == "" {
+			t.Errorf("Expected error for %s", res.Filename)
+		}
+	}
+}
+
+func TestHandleUpload_SeveralFilePartsWithPath(t *testing.T) {
+	store := memstore.New("my-bucket")
+	server := &Server{client: store, hub: NewWebSocketHub()}
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		part, _ := mw.CreateFormFile("file", name)
+		part.Write([]byte("content of " + name))
+	}
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/api/upload?bucket=my-bucket&path=docs/report.txt", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rr := httptest.NewRecorder()
+
+	server.handleUpload(rr, req)
+
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusMultiStatus, rr.Code, rr.Body.String())
+	}
+
+	var results []UploadFileResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &results); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].Error != "" || results[0].Name != "docs/report.txt" {
+		t.Errorf("Expected a.txt uploaded as docs/report.txt, got %+v", results[0])
+	}
+	if results[1].Error == "" {
+		t.Errorf("Expected b.txt to be rejected, got %+v", results[1])
+	}
+
+	// The second part must not have overwritten the first
+	obj := store.Object("my-bucket", "docs/report.txt")
+	if obj == nil {
+		t.Fatal("Expected docs/report.txt to exist")
+	}
+	if string(obj.Data) != "content of a.txt" {
+		t.Errorf("Expected the first part's content, got %q", obj.Data)
+	}
+}
+
+func TestUploadParts_StreamsLargePart(t *testing.T) {
+	const partSize = 100 << 20
+
+	// Generate the multipart body on the fly so the test itself holds no large buffers
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile("file", "big.bin")
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		chunk := make([]byte, 64<<10)
+		for written := 0; written < partSize; written += len(chunk) {
+			if _, err := part.Write(chunk); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	upload := func(path string, part io.Reader) (*b2.UploadResult, error) {
+		n, err := io.Copy(io.Discard, part)
+		return &b2.UploadResult{Name: path, Size: n}, err
+	}
+	results, single, err := uploadParts(multipart.NewReader(pr, mw.Boundary()), "", upload)
+	if err != nil {
+		t.Fatalf("uploadParts failed: %v", err)
+	}
+
+	runtime.ReadMemStats(&after)
+
+	if !single || len(results) != 1 {
+		t.Fatalf("Expected a single result, got %d (single=%v)", len(results), single)
+	}
+	if results[0].Size != partSize {
+		t.Errorf("Expected size %d, got %d", partSize, results[0].Size)
+	}
+	if results[0].Name != "big.bin" {
+		t.Errorf("Expected name big.bin, got %q", results[0].Name)
+	}
+
+	// Streaming should allocate a small fraction of the part size in total
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 16<<20 {
+		t.Errorf("Expected bounded allocation, got %d bytes for a %d byte part", allocated, partSize)
+	}
+}
+
+func TestPercentOf(t *testing.T) {
+	if got := percentOf(50, 200); got != 25 {
+		t.Errorf("Expected 25, got %v", got)
+	}
+	if got := percentOf(50, -1); got != -1 {
+		t.Errorf("Expected -1 for unknown total, got %v", got)
+	}
+}
+
+func TestHandleSyncCancel(t *testing.T) {
+	server := &Server{
+		hub: NewWebSocketHub(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	syncJobsMu.Lock()
+	syncJobs["sync-cancel-test"] = &SyncJob{ID: "sync-cancel-test", Status: "running", cancel: cancel}
+	syncJobs["sync-done-test"] = &SyncJob{ID: "sync-done-test", Status: "completed", cancel: func() {}}
+	syncJobsMu.Unlock()
+	defer func() {
+		syncJobsMu.Lock()
+		delete(syncJobs, "sync-cancel-test")
+		delete(syncJobs, "sync-done-test")
+		syncJobsMu.Unlock()
+	}()
+
+	tests := []struct {
+		jobID string
+		want  int
+	}{
+		{"nonexistent-job", http.StatusNotFound},
+		{"sync-done-test", http.StatusNotFound},
+		{"sync-cancel-test", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		body := fmt.Sprintf(`{"job_id": %q}`, tt.jobID)
+		req := httptest.NewRequest("POST", "/api/sync/cancel", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+
+		server.handleSyncCancel(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.jobID, tt.want, rr.Code)
+		}
+	}
+
+	if ctx.Err() == nil {
+		t.Error("Expected job context to be cancelled")
+	}
+	syncJobsMu.RLock()
+	status := syncJobs["sync-cancel-test"].Status
+	syncJobsMu.RUnlock()
+	if status != "cancelled" {
+		t.Errorf("Expected status cancelled, got %s", status)
+	}
+}
+
+func TestHandleSyncStart_UniqueJobIDs(t *testing.T) {
+	server := &Server{
+		hub: NewWebSocketHub(),
+	}
+
+	const n = 50
+	ids := make(chan string, n)
+	var wg sync.WaitGroup
+
+	// Nonexistent path makes each background sync fail fast without touching B2
+	body := `{"local_path": "/nonexistent/bb-stream-test", "bucket": "my-bucket", "direction": "to_remote"}`
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/api/sync/start", bytes.NewBufferString(body))
+			rr := httptest.NewRecorder()
+			server.handleSyncStart(rr, req)
+
+			var result map[string]string
+			_ = json.Unmarshal(rr.Body.Bytes(), &result)
+			ids <- result["job_id"]
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[string]bool)
+	for id := range ids {
+		if id == "" {
+			t.Error("Expected a job ID in the response")
+		}
+		if seen[id] {
+			t.Errorf("Duplicate job ID %s", id)
+		}
+		seen[id] = true
+	}
+
+	syncJobsMu.Lock()
+	for id := range seen {
+		delete(syncJobs, id)
+	}
+	syncJobsMu.Unlock()
+}
+
+func TestTouchMonotonic(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	updated := future
+
+	touch(&updated)
+	if !updated.After(future) {
+		t.Errorf("Expected touch to advance past %v, got %v", future, updated)
+	}
+}
+
+func TestHandleListJobs_FilterAndSort(t *testing.T) {
+	server := &Server{
+		hub: NewWebSocketHub(),
+	}
+
+	base := time.Now()
+	syncJobsMu.Lock()
+	syncJobs = map[string]*SyncJob{
+		"sync-old": {ID: "sync-old", Status: "completed", StartTime: base.Add(-2 * time.Hour)},
+		"sync-new": {ID: "sync-new", Status: "running", StartTime: base},
+	}
+	syncJobsMu.Unlock()
+
+	watchJobsMu.Lock()
+	watchJobs = map[string]*WatchJob{
+		"watch-mid": {ID: "watch-mid", Status: "running", StartTime: base.Add(-time.Hour)},
+	}
+	watchJobsMu.Unlock()
+
+	defer func() {
+		syncJobsMu.Lock()
+		syncJobs = make(map[string]*SyncJob)
+		syncJobsMu.Unlock()
+		watchJobsMu.Lock()
+		watchJobs = make(map[string]*WatchJob)
+		watchJobsMu.Unlock()
+	}()
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"sync-new", "watch-mid", "sync-old"}},
+		{"?sort=start_time", []string{"sync-old", "watch-mid", "sync-new"}},
+		{"?type=sync", []string{"sync-new", "sync-old"}},
+		{"?status=running", []string{"sync-new", "watch-mid"}},
+		{"?type=watch&status=completed", []string{}},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/jobs"+tt.query, nil)
+		rr := httptest.NewRecorder()
+		server.handleListJobs(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", tt.query, http.StatusOK, rr.Code)
+		}
+
+		var jobs []JobSummary
+		if err := json.Unmarshal(rr.Body.Bytes(), &jobs); err != nil {
+			t.Fatalf("%s: failed to unmarshal: %v", tt.query, err)
+		}
+		got := make([]string, len(jobs))
+		for i, job := range jobs {
+			got[i] = job.ID
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/jobs?sort=name", nil)
+	rr := httptest.NewRecorder()
+	server.handleListJobs(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid sort, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandleWatchStatus(t *testing.T) {
+	server := &Server{
+		hub: NewWebSocketHub(),
+	}
+
+	watchJobsMu.Lock()
+	watchJobs["watch-status-test"] = &WatchJob{ID: "watch-status-test", Status: "running", Bucket: "my-bucket"}
+	watchJobsMu.Unlock()
+	defer func() {
+		watchJobsMu.Lock()
+		delete(watchJobs, "watch-status-test")
+		watchJobsMu.Unlock()
+	}()
+
+	r := chi.NewRouter()
+	r.Get("/api/watch/status/{id}", server.handleWatchStatus)
+
+	req := httptest.NewRequest("GET", "/api/watch/status/nonexistent-job", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for nonexistent job, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/watch/status/watch-status-test", nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if result["id"] != "watch-status-test" || result["bucket"] != "my-bucket" {
+		t.Errorf("Expected job fields in response, got %v", result)
+	}
+	if result["files_uploaded"] != float64(0) || result["failures"] != float64(0) {
+		t.Errorf("Expected zero counters, got %v", result)
+	}
+}
+
+func TestHandleReady(t *testing.T) {
+	calls := 0
+	var probeErr error
+	server := &Server{
+		hub: NewWebSocketHub(),
+		readyCheck: func(ctx context.Context) error {
+			calls++
+			return probeErr
+		},
+	}
+
+	probeErr = fmt.Errorf("dial tcp: connection refused")
+	rr := httptest.NewRecorder()
+	server.handleReady(rr, httptest.NewRequest("GET", "/ready", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d when B2 is unreachable, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if body["reason"] != "Connection error" {
+		t.Errorf("Expected sanitized reason, got %q", body["reason"])
+	}
+
+	// A second probe within the cache window reuses the result
+	probeErr = nil
+	rr = httptest.NewRecorder()
+	server.handleReady(rr, httptest.NewRequest("GET", "/ready", nil))
+	if calls != 1 {
+		t.Errorf("Expected the cached result to be reused, got %d probes", calls)
+	}
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected cached status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+
+	// Once the cache expires B2 is probed again
+	server.readyAt = time.Now().Add(-readyCacheTTL)
+	rr = httptest.NewRecorder()
+	server.handleReady(rr, httptest.NewRequest("GET", "/ready", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d after recovery, got %d", http.StatusOK, rr.Code)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 probes, got %d", calls)
+	}
+}
+
+func TestHandleStreamUpload_TooLarge(t *testing.T) {
+	cfg := config.Get()
+	original := cfg.MaxUploadBytes
+	cfg.MaxUploadBytes = 10
+	defer func() { cfg.MaxUploadBytes = original }()
+
+	server := &Server{
+		hub: NewWebSocketHub(),
+	}
+
+	req := httptest.NewRequest("POST", "/api/upload/stream?bucket=my-bucket&path=big.bin", bytes.NewReader(make([]byte, 11)))
+	rr := httptest.NewRecorder()
+	server.handleStreamUpload(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d for an oversized upload, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+}
+
+func TestHandleStreamUpload_ReturnsSHA1(t *testing.T) {
+	store := memstore.New("my-bucket")
+	server := &Server{client: store, hub: NewWebSocketHub()}
+
+	req := httptest.NewRequest("POST", "/api/upload/stream?bucket=my-bucket&path=logs/app.log", strings.NewReader("stream data"))
+	rr := httptest.NewRecorder()
+	server.handleStreamUpload(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Size int64  `json:"size"`
+		SHA1 string `json:"sha1"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Size != 11 || resp.SHA1 != "43e1151086c4a91feebf28cb0393702432cce432" {
+		t.Errorf("Expected 11 bytes with SHA1 43e11510..., got %+v", resp)
+	}
+}
+
+func TestHandleCreateBucket_Validation(t *testing.T) {
+	server := &Server{
+		hub: NewWebSocketHub(),
+	}
+
+	for _, body := range []string{"{not json", `{"name":"ab"}`, `{"name":"Bad_Name!"}`} {
+		req := httptest.NewRequest("POST", "/api/buckets", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		server.handleCreateBucket(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Body %s: expected status %d, got %d", body, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestBucketErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("type: %w", errors.ErrBadRequest), http.StatusBadRequest},
+		{fmt.Errorf("bucket: %w", errors.ErrBucketExists), http.StatusConflict},
+		{fmt.Errorf("bucket: %w", errors.ErrBucketNotEmpty), http.StatusConflict},
+		{fmt.Errorf("bucket: %w", errors.ErrBucketNotFound), http.StatusNotFound},
+		{fmt.Errorf("connection reset"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := bucketErrorStatus(tt.err); got != tt.want {
+			t.Errorf("bucketErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestHandlers_Memstore(t *testing.T) {
+	store := memstore.New("my-bucket")
+	store.Put("my-bucket", "docs/readme.txt", []byte("hello"), time.Now())
+	server := &Server{client: store, hub: NewWebSocketHub()}
+
+	r := chi.NewRouter()
+	r.Get("/api/buckets/{name}/files", server.handleListFiles)
+	r.Get("/api/download/{bucket}/*", server.handleDownload)
+	r.Delete("/api/delete/{bucket}/*", server.handleDelete)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/buckets/my-bucket/files?prefix=docs/", nil))
+	var objects []b2.ObjectInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &objects); err != nil {
+		t.Fatalf("Failed to unmarshal listing: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Name != "docs/readme.txt" {
+		t.Errorf("Expected docs/readme.txt, got %+v", objects)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/download/my-bucket/docs/readme.txt", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "hello" {
+		t.Errorf("Download: got %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("DELETE", "/api/delete/my-bucket/docs/readme.txt", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Delete: expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/download/my-bucket/docs/readme.txt", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Download after delete: expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+// listedDeleteStore is a memstore that deletes listed versions directly,
+// failing the test if a version is looked up by ID instead
+type listedDeleteStore struct {
+	*memstore.Store
+	t      *testing.T
+	listed int
+}
+
+func (s *listedDeleteStore) DeleteListedVersion(ctx context.Context, v b2.ObjectVersion) error {
+	s.listed++
+	return s.Store.DeleteVersion(ctx, "my-bucket", v.Name, v.FileID)
+}
+
+func (s *listedDeleteStore) DeleteVersion(ctx context.Context, bucketName, objectName, fileID string) error {
+	s.t.Errorf("DeleteVersion(%s) called for a listed version", fileID)
+	return s.Store.DeleteVersion(ctx, bucketName, objectName, fileID)
+}
+
+func TestHandleDelete_AllVersionsDeletesListed(t *testing.T) {
+	store := &listedDeleteStore{Store: memstore.New("my-bucket"), t: t}
+	for i := 0; i < 3; i++ {
+		store.Put("my-bucket", "file.txt", []byte(fmt.Sprint(i)), time.Now())
+	}
+	server := &Server{client: store, hub: NewWebSocketHub()}
+
+	r := chi.NewRouter()
+	r.Delete("/api/delete/{bucket}/*", server.handleDelete)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("DELETE", "/api/delete/my-bucket/file.txt?all_versions=true", nil))
+
+	if rr.Code != http.StatusOK || store.listed != 3 {
+		t.Fatalf("Expected 3 listed versions deleted, got %d with status %d: %s", store.listed, rr.Code, rr.Body.String())
+	}
+	if _, err := store.ListObjectVersions(context.Background(), "my-bucket", "file.txt"); !errors.IsNotFound(err) {
+		t.Errorf("Expected no versions left, got %v", err)
+	}
+}
+
+func TestHandleListFiles_Pattern(t *testing.T) {
+	store := memstore.New("my-bucket")
+	store.Put("my-bucket", "photos/a.jpg", []byte("a"), time.Now())
+	store.Put("my-bucket", "photos/b.png", []byte("b"), time.Now())
+	store.Put("my-bucket", "photos/c.tar.gz", []byte("c"), time.Now())
+	server := &Server{client: store, hub: NewWebSocketHub()}
+
+	r := chi.NewRouter()
+	r.Get("/api/buckets/{name}/files", server.handleListFiles)
+
+	tests := []struct {
+		query string
+		code  int
+		want  []string
+	}{
+		{"pattern=*.jpg", http.StatusOK, []string{"photos/a.jpg"}},
+		{"pattern=[ab].*", http.StatusOK, []string{"photos/a.jpg", "photos/b.png"}},
+		{"suffix=.tar.gz", http.StatusOK, []string{"photos/c.tar.gz"}},
+		{"suffix=*", http.StatusOK, []string{}},
+		{"pattern=[a-", http.StatusBadRequest, nil},
+		{"pattern=*.jpg&suffix=.png", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/buckets/my-bucket/files?"+tt.query, nil))
+		if rr.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.query, tt.code, rr.Code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var objects []b2.ObjectInfo
+		if err := json.Unmarshal(rr.Body.Bytes(), &objects); err != nil {
+			t.Fatalf("%s: failed to unmarshal listing: %v", tt.query, err)
+		}
+		var names []string
+		for _, obj := range objects {
+			names = append(names, obj.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got %v, want %v", tt.query, names, tt.want)
+		}
+	}
+}
+
+func TestHandleSearch(t *testing.T) {
+	store := memstore.New("my-bucket")
+	for _, name := range []string{"a/Invoice-1.pdf", "a/notes.txt", "b/invoice-2.pdf", "b/invoice-3.pdf"} {
+		store.Put("my-bucket", name, []byte("x"), time.Now())
+	}
+	server := &Server{client: store, hub: NewWebSocketHub()}
+
+	r := chi.NewRouter()
+	r.Get("/api/buckets/{name}/search", server.handleSearch)
+
+	search := func(query string) (int, SearchResponse) {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/buckets/my-bucket/search?"+query, nil))
+		var resp SearchResponse
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("%s: failed to unmarshal response: %v", query, err)
+			}
+		}
+		return rr.Code, resp
+	}
+
+	code, resp := search("q=invoice&limit=2")
+	if code != http.StatusOK || len(resp.Objects) != 2 || resp.Cursor != "b/invoice-2.pdf" {
+		t.Fatalf("First page: got %d %+v", code, resp)
+	}
+	code, resp = search("q=invoice&limit=2&cursor=" + resp.Cursor)
+	if code != http.StatusOK || len(resp.Objects) != 1 || resp.Objects[0].Name != "b/invoice-3.pdf" || resp.Cursor != "" {
+		t.Errorf("Second page: got %d %+v", code, resp)
+	}
+	if code, resp = search("q=INVOICE&prefix=a/"); code != http.StatusOK || len(resp.Objects) != 1 {
+		t.Errorf("Prefixed search: got %d %+v", code, resp)
+	}
+
+	for _, query := range []string{"", "q=x&limit=0", "q=x&limit=5000", "q=x&limit=abc"} {
+		if code, _ := search(query); code != http.StatusBadRequest {
+			t.Errorf("%q: expected status %d, got %d", query, http.StatusBadRequest, code)
+		}
+	}
+}
+
+// cleanerStore is a memstore that records unfinished upload cleanups
+type cleanerStore struct {
+	*memstore.Store
+	olderThan time.Duration
+}
+
+func (s *cleanerStore) CleanupUnfinished(ctx context.Context, bucketName string, olderThan time.Duration) ([]b2.UnfinishedUpload, error) {
+	s.olderThan = olderThan
+	return []b2.UnfinishedUpload{{FileID: "f1", Name: "big.bin", Timestamp: 1700000000}}, nil
+}
+
+func (s *cleanerStore) CancelUnfinishedUpload(ctx context.Context, bucketName, fileID string) error {
+	if fileID != "f1" {
+		return fmt.Errorf("unfinished upload %s: %w", fileID, errors.ErrNotFound)
+	}
+	return nil
+}
+
+func TestHandleCleanupUnfinished(t *testing.T) {
+	store := &cleanerStore{Store: memstore.New("my-bucket")}
+	server := &Server{client: store, hub: NewWebSocketHub()}
+	r := chi.NewRouter()
+	r.Delete("/api/buckets/{name}/unfinished", server.handleCleanupUnfinished)
+
+	tests := []struct {
+		query     string
+		code      int
+		olderThan time.Duration
+	}{
+		{"", http.StatusOK, b2.DefaultUnfinishedAge},
+		{"?older_than=1h", http.StatusOK, time.Hour},
+		{"?older_than=0s", http.StatusOK, 0},
+		{"?older_than=7d", http.StatusOK, 7 * 24 * time.Hour},
+		{"?older_than=soon", http.StatusBadRequest, 0},
+		{"?older_than=-1h", http.StatusBadRequest, 0},
+		{"?file_id=f1", http.StatusOK, 0},
+		{"?file_id=missing", http.StatusNotFound, 0},
+	}
+	for _, tt := range tests {
+		store.olderThan = 0
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("DELETE", "/api/buckets/my-bucket/unfinished"+tt.query, nil))
+		if rr.Code != tt.code {
+			t.Errorf("%q: expected status %d, got %d: %s", tt.query, tt.code, rr.Code, rr.Body.String())
+			continue
+		}
+		if store.olderThan != tt.olderThan {
+			t.Errorf("%q: expected older than %v, got %v", tt.query, tt.olderThan, store.olderThan)
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var resp CleanupResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%q: failed to unmarshal response: %v", tt.query, err)
+		}
+		if len(resp.Cancelled) != 1 || resp.Cancelled[0].FileID != "f1" {
+			t.Errorf("%q: unexpected response %+v", tt.query, resp)
+		}
+	}
+
+	// Stores without large files can't clean up
+	server.client = memstore.New("my-bucket")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("DELETE", "/api/buckets/my-bucket/unfinished", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d for memstore, got %d", http.StatusNotImplemented, rr.Code)
+	}
+}
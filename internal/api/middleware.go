@@ -1,19 +1,83 @@
 package api
 
 import (
+	"crypto/subtle"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/ryanoboyle/bb-stream/internal/config"
+	"github.com/ryanoboyle/bb-stream/pkg/logging"
 )
 
-// CORSMiddleware handles CORS for the API
+// RequestLoggingMiddleware logs each request as structured fields once it completes,
+// and stores the request ID in the context for logging.WithContext.
+// It should run after middleware.RequestID and middleware.RealIP.
+func RequestLoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r = r.WithContext(logging.ContextWithRequestID(r.Context(), middleware.GetReqID(r.Context())))
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				// Nothing was written; net/http will send 200
+				status = http.StatusOK
+			}
+			logging.WithContext(r.Context()).Info("http request",
+				slog.String("method", r.Method),
+				logging.Path(r.URL.Path),
+				logging.Status(status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.String("remote_ip", r.RemoteAddr),
+				logging.DurationMs(time.Since(start).Milliseconds()),
+			)
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// Default CORS lists, used when the config doesn't override them
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	defaultCORSHeaders = []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-API-Key"}
+)
+
+// CORSMiddleware handles CORS for the API.
+// With no allowed_origins configured any origin is allowed with "*"; otherwise
+// a matching Origin is echoed back, and "*" is only sent if listed explicitly.
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := config.Get()
+
 		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token, X-API-Key")
+		allowed := cfg.AllowedOrigins
+		origin := r.Header.Get("Origin")
+		switch {
+		case len(allowed) == 0 || containsOrigin(allowed, "*"):
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && containsOrigin(allowed, origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Add("Vary", "Origin")
+		default:
+			// The response still depends on Origin, even when it's rejected
+			w.Header().Add("Vary", "Origin")
+		}
+
+		methods, headers := cfg.CORSMethods, cfg.CORSHeaders
+		if len(methods) == 0 {
+			methods = defaultCORSMethods
+		}
+		if len(headers) == 0 {
+			headers = defaultCORSHeaders
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
 		w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type")
 		w.Header().Set("Access-Control-Max-Age", "300")
 
@@ -27,17 +91,25 @@ func CORSMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// AuthMiddleware validates API authentication using API key
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip auth for health check
-		if r.URL.Path == "/health" {
-			next.ServeHTTP(w, r)
-			return
+// containsOrigin reports whether origin is in the list, ignoring case and a trailing slash
+func containsOrigin(list []string, origin string) bool {
+	for _, o := range list {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
 		}
+	}
+	return false
+}
 
-		// Skip auth for WebSocket upgrade (handled separately)
-		if r.Header.Get("Upgrade") == "websocket" {
+// AuthMiddleware validates API authentication using API key.
+// When api_key is configured every client must send it, including localhost
+// (a reverse proxy in the same container looks like localhost). With no key
+// configured the API is open to any client, unless require_api_key is set,
+// in which case every request is refused until a key is configured.
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Skip auth for health and readiness checks
+		if r.URL.Path == "/health" || r.URL.Path == "/ready" {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -51,32 +123,28 @@ func AuthMiddleware(next http.Handler) http.Handler {
 				apiKey = strings.TrimPrefix(auth, "Bearer ")
 			}
 		}
-
-		// If no API key provided, check if we're in local-only mode
-		// For now, allow localhost connections without auth for development
-		if apiKey == "" {
-			remoteAddr := r.RemoteAddr
-			if strings.HasPrefix(remoteAddr, "127.0.0.1") ||
-				strings.HasPrefix(remoteAddr, "localhost") ||
-				strings.HasPrefix(remoteAddr, "[::1]") {
-				next.ServeHTTP(w, r)
-				return
-			}
+		// Browsers can't set headers on WebSocket connections, so upgrades may
+		// pass the key as ?api_key=. It's validated like any other key.
+		if apiKey == "" && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			apiKey = r.URL.Query().Get("api_key")
 		}
 
-		// Validate API key against configured key
 		cfg := config.Get()
-		if cfg.APIKey != "" && apiKey != cfg.APIKey {
+		switch {
+		case cfg.APIKey != "":
+			// Validate API key against configured key
+			if subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.APIKey)) != 1 {
+				http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+				return
+			}
+		case cfg.RequireAPIKey:
+			// Fail closed rather than serve unauthenticated requests
+			logging.WithContext(r.Context()).Error("require_api_key is set but no api_key is configured")
 			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
 			return
 		}
 
 		// If no API key is configured, allow all requests (for backward compatibility)
-		if cfg.APIKey == "" && apiKey == "" {
-			next.ServeHTTP(w, r)
-			return
-		}
-
 		next.ServeHTTP(w, r)
 	})
 }
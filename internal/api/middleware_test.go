@@ -1,11 +1,16 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/ryanoboyle/bb-stream/internal/config"
+	"github.com/ryanoboyle/bb-stream/pkg/logging"
 )
 
 func TestCORSMiddleware(t *testing.T) {
@@ -36,6 +41,54 @@ func TestCORSMiddleware(t *testing.T) {
 	}
 }
 
+func TestCORSMiddleware_AllowedOrigins(t *testing.T) {
+	cfg := config.Get()
+	origOrigins, origMethods := cfg.AllowedOrigins, cfg.CORSMethods
+	defer func() { cfg.AllowedOrigins, cfg.CORSMethods = origOrigins, origMethods }()
+
+	cfg.AllowedOrigins = []string{"https://app.example.com"}
+	cfg.CORSMethods = []string{"GET", "OPTIONS"}
+
+	handler := CORSMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// A listed origin is echoed back
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Expected the request origin to be echoed, got %q", got)
+	}
+	if rr.Header().Get("Vary") != "Origin" {
+		t.Error("Expected Vary: Origin")
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Methods"); got != "GET, OPTIONS" {
+		t.Errorf("Expected configured methods, got %q", got)
+	}
+
+	// Any other origin gets no allow header
+	req = httptest.NewRequest("GET", "/api/test", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no Access-Control-Allow-Origin for a disallowed origin, got %q", got)
+	}
+
+	// The wildcard must be configured explicitly once a list exists
+	cfg.AllowedOrigins = []string{"https://app.example.com", "*"}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected * when configured, got %q", got)
+	}
+}
+
 func TestAuthMiddleware_HealthCheck(t *testing.T) {
 	handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -61,7 +114,50 @@ func TestAuthMiddleware_WebSocketUpgrade(t *testing.T) {
 	handler.ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusOK {
-		t.Errorf("WebSocket should bypass auth, got status %d", rr.Code)
+		t.Errorf("WebSocket with no key configured should be allowed, got status %d", rr.Code)
+	}
+}
+
+func TestAuthMiddleware_WebSocketUpgradeWithConfiguredKey(t *testing.T) {
+	config.SetAPIKey("test-secret-key")
+	defer config.SetAPIKey("")
+
+	handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name    string
+		target  string
+		upgrade bool
+		header  string
+		want    int
+	}{
+		{"Upgrade header alone", "/api/buckets", true, "", http.StatusUnauthorized},
+		{"WebSocket without key", "/api/ws", true, "", http.StatusUnauthorized},
+		{"WebSocket with wrong query key", "/api/ws?api_key=wrong", true, "", http.StatusUnauthorized},
+		{"WebSocket with query key", "/api/ws?api_key=test-secret-key", true, "", http.StatusOK},
+		{"WebSocket with header key", "/api/ws", true, "test-secret-key", http.StatusOK},
+		{"Query key without upgrade", "/api/buckets?api_key=test-secret-key", false, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			req.RemoteAddr = "10.0.0.5:12345"
+			if tt.upgrade {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+			}
+			if tt.header != "" {
+				req.Header.Set("X-API-Key", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, rr.Code)
+			}
+		})
 	}
 }
 
@@ -80,6 +176,61 @@ func TestAuthMiddleware_LocalhostNoAuth(t *testing.T) {
 	}
 }
 
+func TestAuthMiddleware_LocalhostWithConfiguredKey(t *testing.T) {
+	config.SetAPIKey("test-secret-key")
+	defer config.SetAPIKey("")
+
+	handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// A proxy in the same container connects from localhost
+	req := httptest.NewRequest("GET", "/api/buckets", nil)
+	req.RemoteAddr = "127.0.0.1:12345"
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Localhost without the configured key should be rejected, got status %d", rr.Code)
+	}
+
+	req.Header.Set("X-API-Key", "test-secret-key")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Localhost with the configured key should be accepted, got status %d", rr.Code)
+	}
+}
+
+func TestAuthMiddleware_RequireAPIKeyWithoutKey(t *testing.T) {
+	config.SetAPIKey("")
+	config.Get().RequireAPIKey = true
+	defer func() { config.Get().RequireAPIKey = false }()
+
+	handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/api/buckets", nil)
+	req.RemoteAddr = "127.0.0.1:12345"
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("require_api_key with no key configured should reject requests, got status %d", rr.Code)
+	}
+
+	// Health checks stay reachable for probes
+	req = httptest.NewRequest("GET", "/health", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Health check should bypass auth, got status %d", rr.Code)
+	}
+}
+
 func TestAuthMiddleware_ValidAPIKey(t *testing.T) {
 	// Ensure config is initialized
 	_ = config.Get()
@@ -179,3 +330,70 @@ func TestContentTypeJSON(t *testing.T) {
 		t.Errorf("Expected Content-Type: application/json, got %s", contentType)
 	}
 }
+
+func TestRequestLoggingMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	prev := logging.Logger()
+	logging.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer logging.SetLogger(prev)
+
+	handler := middleware.RequestID(RequestLoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("short and stout"))
+	})))
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v (%q)", err, buf.String())
+	}
+
+	if entry["method"] != "GET" {
+		t.Errorf("method = %v, want GET", entry["method"])
+	}
+	if entry["path"] != "/api/test" {
+		t.Errorf("path = %v, want /api/test", entry["path"])
+	}
+	if entry["status"] != float64(http.StatusTeapot) {
+		t.Errorf("status = %v, want %d", entry["status"], http.StatusTeapot)
+	}
+	if entry["bytes"] != float64(len("short and stout")) {
+		t.Errorf("bytes = %v, want %d", entry["bytes"], len("short and stout"))
+	}
+	if id, _ := entry["request_id"].(string); id == "" {
+		t.Error("expected a request_id field")
+	}
+	if _, ok := entry["duration_ms"]; !ok {
+		t.Error("expected a duration_ms field")
+	}
+}
+
+func TestRequestLoggingMiddleware_PropagatesRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	prev := logging.Logger()
+	logging.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer logging.SetLogger(prev)
+
+	handler := middleware.RequestID(RequestLoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.WithContext(r.Context()).Info("inside handler")
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-abc")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	dec := json.NewDecoder(&buf)
+	for i := 0; i < 2; i++ {
+		var entry map[string]interface{}
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatalf("log line %d: %v", i, err)
+		}
+		if entry["request_id"] != "req-abc" {
+			t.Errorf("%v: request_id = %v, want req-abc", entry["msg"], entry["request_id"])
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/ryanoboyle/bb-stream/internal/b2"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
 	"github.com/ryanoboyle/bb-stream/pkg/logging"
 )
 
@@ -21,7 +22,7 @@ const (
 
 // Server is the HTTP API server
 type Server struct {
-	client     *b2.Client
+	client     b2.Storage
 	router     chi.Router
 	httpServer *http.Server
 	port       int
@@ -29,10 +30,23 @@ type Server struct {
 	shutdown   chan struct{}
 	wg         sync.WaitGroup
 	startTime  time.Time
+
+	// flushThreshold is how many bytes streamed responses buffer between
+	// flushes; 0 uses defaultFlushThreshold
+	flushThreshold int
+
+	// readyCheck probes B2 for /ready; nil pings client
+	readyCheck func(ctx context.Context) error
+	readyMu    sync.Mutex
+	readyAt    time.Time // When the cached readiness result was taken
+	readyErr   error
 }
 
+// readyCacheTTL is how long a /ready result is reused before probing B2 again
+const readyCacheTTL = 5 * time.Second
+
 // NewServer creates a new API server
-func NewServer(client *b2.Client, port int) *Server {
+func NewServer(client b2.Storage, port int) *Server {
 	s := &Server{
 		client:    client,
 		port:      port,
@@ -45,15 +59,22 @@ func NewServer(client *b2.Client, port int) *Server {
 	return s
 }
 
+// SetFlushThreshold sets how many bytes streamed downloads and archives
+// buffer before flushing to the client. Smaller values deliver data sooner
+// at the cost of more, smaller writes; n <= 0 restores the 64KB default.
+func (s *Server) SetFlushThreshold(n int) {
+	s.flushThreshold = max(n, 0)
+}
+
 // setupRouter configures the Chi router with all routes
 func (s *Server) setupRouter() {
 	r := chi.NewRouter()
 
 	// Middleware
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
+	r.Use(RequestLoggingMiddleware)
+	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
 	r.Use(SecurityHeadersMiddleware)
 	r.Use(CORSMiddleware)
@@ -64,8 +85,13 @@ func (s *Server) setupRouter() {
 		_, _ = w.Write([]byte("OK"))
 	})
 
+	// Readiness check that verifies B2 is reachable
+	r.Get("/ready", s.handleReady)
+
 	// API routes
 	r.Route("/api", func(r chi.Router) {
+		r.Use(AuthMiddleware)
+
 		// Version and status
 		r.Get("/version", s.handleVersion)
 		r.Get("/status", s.handleStatus)
@@ -75,7 +101,11 @@ func (s *Server) setupRouter() {
 
 		// Buckets
 		r.Get("/buckets", s.handleListBuckets)
+		r.Post("/buckets", s.handleCreateBucket)
+		r.Delete("/buckets/{name}", s.handleDeleteBucket)
+		r.Delete("/buckets/{name}/unfinished", s.handleCleanupUnfinished)
 		r.Get("/buckets/{name}/files", s.handleListFiles)
+		r.Get("/buckets/{name}/search", s.handleSearch)
 
 		// Upload
 		r.Post("/upload", s.handleUpload)
@@ -83,7 +113,10 @@ func (s *Server) setupRouter() {
 
 		// Download
 		r.Get("/download/{bucket}/*", s.handleDownload)
+		r.Head("/download/{bucket}/*", s.handleHeadObject)
 		r.Get("/stream/{bucket}/*", s.handleStreamDownload)
+		r.Get("/presign/{bucket}/*", s.handlePresign)
+		r.Get("/archive/{bucket}/*", s.handleArchive)
 
 		// Delete
 		r.Delete("/delete/{bucket}/*", s.handleDelete)
@@ -91,10 +124,12 @@ func (s *Server) setupRouter() {
 		// Sync
 		r.Post("/sync/start", s.handleSyncStart)
 		r.Get("/sync/status/{id}", s.handleSyncStatus)
+		r.Post("/sync/cancel", s.handleSyncCancel)
 
 		// Watch
 		r.Post("/watch/start", s.handleWatchStart)
 		r.Post("/watch/stop", s.handleWatchStop)
+		r.Get("/watch/status/{id}", s.handleWatchStatus)
 
 		// Jobs
 		r.Get("/jobs", s.handleListJobs)
@@ -157,16 +192,23 @@ func (s *Server) Shutdown(ctx context.Context) error {
 // stopAllWatchJobs stops all running watch jobs
 func stopAllWatchJobs() {
 	watchJobsMu.Lock()
-	defer watchJobsMu.Unlock()
-
+	running := make(map[string]*WatchJob)
 	for id, job := range watchJobs {
 		if job.Status == "running" {
-			job.uploader.Stop()
-			job.Status = "stopped"
-			job.StoppedAt = time.Now()
-			logging.Logger().Info("stopped watch job during shutdown", logging.JobID(id))
+			running[id] = job
 		}
 	}
+	watchJobsMu.Unlock()
+
+	// Stop waits for queued uploads, whose callbacks take watchJobsMu
+	for id, job := range running {
+		job.uploader.Stop()
+		watchJobsMu.Lock()
+		job.Status = "stopped"
+		job.StoppedAt = time.Now()
+		watchJobsMu.Unlock()
+		logging.Logger().Info("stopped watch job during shutdown", logging.JobID(id))
+	}
 }
 
 // GetRouter returns the router (for testing)
@@ -195,6 +237,45 @@ func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleReady reports whether B2 is reachable with the configured credentials
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkReady(r.Context()); err != nil {
+		logging.WithContext(r.Context()).Warn("readiness check failed", logging.Err(err))
+		respondJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"status": "unavailable",
+			"reason": errors.Sanitize(err),
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// checkReady probes B2, reusing a recent result to avoid hammering the API
+func (s *Server) checkReady(ctx context.Context) error {
+	s.readyMu.Lock()
+	defer s.readyMu.Unlock()
+
+	if !s.readyAt.IsZero() && time.Since(s.readyAt) < readyCacheTTL {
+		return s.readyErr
+	}
+
+	check := s.readyCheck
+	if check == nil {
+		check = func(ctx context.Context) error {
+			if s.client == nil {
+				return fmt.Errorf("no B2 client configured")
+			}
+			return s.client.Ping(ctx)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	s.readyErr = check(ctx)
+	s.readyAt = time.Now()
+	return s.readyErr
+}
+
 // handleStatus returns server status information
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	// Count active jobs
@@ -2,20 +2,60 @@ package api
 
 import (
 	"encoding/json"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/ryanoboyle/bb-stream/internal/config"
 	"github.com/ryanoboyle/bb-stream/pkg/logging"
 )
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for development
-	},
+	CheckOrigin:     checkOrigin,
+}
+
+// wildcardWarning logs once that the "*" origin is in use
+var wildcardWarning sync.Once
+
+// checkOrigin allows WebSocket upgrades from non-browser clients (no Origin
+// header), same-origin pages, and the configured allowed origins. With no
+// allowlist configured, localhost origins are also allowed.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	allowed := config.Get().AllowedOrigins
+	if len(allowed) == 0 {
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+	if containsOrigin(allowed, "*") {
+		wildcardWarning.Do(func() {
+			logging.Logger().Warn("WebSocket accepts connections from any origin; set allowed_origins for production")
+		})
+		return true
+	}
+	if containsOrigin(allowed, origin) {
+		return true
+	}
+
+	logging.Logger().Warn("WebSocket origin rejected", slog.String("origin", origin))
+	return false
 }
 
 // Event represents a WebSocket event
@@ -30,6 +70,47 @@ type Client struct {
 	hub  *WebSocketHub
 	conn *websocket.Conn
 	send chan Event
+
+	// Event types the client subscribed to; empty means all
+	topics   map[string]bool
+	topicsMu sync.RWMutex
+
+	slow int // Consecutive broadcasts this client missed; owned by the hub's Run loop
+}
+
+// wants reports whether the client should receive events of the given type
+func (c *Client) wants(eventType string) bool {
+	c.topicsMu.RLock()
+	defer c.topicsMu.RUnlock()
+	return len(c.topics) == 0 || c.topics[eventType]
+}
+
+// updateTopics subscribes to or unsubscribes from a comma-separated list of event types
+// and returns the resulting subscription list
+func (c *Client) updateTopics(list string, subscribe bool) []string {
+	c.topicsMu.Lock()
+	defer c.topicsMu.Unlock()
+
+	if c.topics == nil {
+		c.topics = make(map[string]bool)
+	}
+	for _, topic := range strings.Split(list, ",") {
+		topic = strings.TrimSpace(topic)
+		if topic == "" {
+			continue
+		}
+		if subscribe {
+			c.topics[topic] = true
+		} else {
+			delete(c.topics, topic)
+		}
+	}
+
+	topics := make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	return topics
 }
 
 // WebSocketHub manages WebSocket connections
@@ -81,22 +162,76 @@ func (h *WebSocketHub) Run() {
 			h.mu.Unlock()
 
 		case event := <-h.broadcast:
-			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- event:
-				default:
-					h.mu.RUnlock()
-					h.mu.Lock()
-					delete(h.clients, client)
-					close(client.send)
-					h.mu.Unlock()
-					h.mu.RLock()
-				}
+			h.deliver(event)
+		}
+	}
+}
+
+// Backpressure limits for clients that fall behind
+const (
+	sendTimeout    = 50 * time.Millisecond // Longest a broadcast waits on full client queues
+	maxSlowStrikes = 3                     // Consecutive timed-out sends before a client is evicted
+)
+
+// deliver queues an event for each subscribed client. A client with a full
+// queue gets until sendTimeout to make room; clients that miss maxSlowStrikes
+// events in a row are disconnected. Only Run calls deliver, so the client set
+// can't change underneath it.
+func (h *WebSocketHub) deliver(event Event) {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		if client.wants(event.Type) {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	// One deadline for the whole broadcast, so slow clients can't stall the hub
+	var timer *time.Timer
+	expired := false
+	var evict []*Client
+	for _, client := range clients {
+		select {
+		case client.send <- event:
+			client.slow = 0
+			continue
+		default:
+		}
+
+		sent := false
+		if !expired {
+			if timer == nil {
+				timer = time.NewTimer(sendTimeout)
+				defer timer.Stop()
+			}
+			select {
+			case client.send <- event:
+				sent = true
+			case <-timer.C:
+				expired = true
+			}
+		}
+		if !sent {
+			client.slow++
+			if client.slow >= maxSlowStrikes {
+				evict = append(evict, client)
 			}
-			h.mu.RUnlock()
 		}
 	}
+
+	if len(evict) == 0 {
+		return
+	}
+	h.mu.Lock()
+	for _, client := range evict {
+		if _, ok := h.clients[client]; ok {
+			delete(h.clients, client)
+			close(client.send)
+		}
+	}
+	h.mu.Unlock()
+	logging.Logger().Warn("Evicted slow WebSocket clients", slog.Int("count", len(evict)))
 }
 
 // Stop gracefully shuts down the hub
@@ -172,7 +307,8 @@ func (c *Client) readPump() {
 			break
 		}
 
-		// Handle incoming messages (e.g., subscribe to specific events)
+		// Handle incoming messages (e.g., subscribe to specific events).
+		// Subscription data is a comma-separated list of event types.
 		var msg struct {
 			Type string `json:"type"`
 			Data string `json:"data"`
@@ -183,7 +319,9 @@ func (c *Client) readPump() {
 			case "ping":
 				c.send <- Event{Type: "pong"}
 			case "subscribe":
-				// Could implement topic-based subscriptions here
+				c.send <- Event{Type: "subscribed", Data: c.updateTopics(msg.Data, true)}
+			case "unsubscribe":
+				c.send <- Event{Type: "unsubscribed", Data: c.updateTopics(msg.Data, false)}
 			}
 		}
 	}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/internal/config"
+)
+
+func TestClientTopics(t *testing.T) {
+	c := &Client{}
+
+	if !c.wants("file_deleted") {
+		t.Error("Expected client with no subscriptions to receive all events")
+	}
+
+	topics := c.updateTopics("sync_progress, sync_complete", true)
+	if len(topics) != 2 {
+		t.Errorf("Expected 2 subscriptions, got %v", topics)
+	}
+	if !c.wants("sync_progress") {
+		t.Error("Expected subscribed event to be delivered")
+	}
+	if c.wants("file_deleted") {
+		t.Error("Expected unsubscribed event to be filtered")
+	}
+
+	c.updateTopics("sync_progress,sync_complete", false)
+	if !c.wants("file_deleted") {
+		t.Error("Expected empty subscription set to receive all events again")
+	}
+}
+
+func TestHubBroadcastFiltersByTopic(t *testing.T) {
+	hub := NewWebSocketHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	all := &Client{hub: hub, send: make(chan Event, 4)}
+	filtered := &Client{hub: hub, send: make(chan Event, 4)}
+	filtered.updateTopics("sync_progress", true)
+
+	hub.register <- all
+	hub.register <- filtered
+
+	hub.Broadcast(Event{Type: "file_deleted"})
+	hub.Broadcast(Event{Type: "sync_progress"})
+
+	expect := func(c *Client, want string) {
+		t.Helper()
+		select {
+		case ev := <-c.send:
+			if ev.Type != want {
+				t.Errorf("Expected %s, got %s", want, ev.Type)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %s", want)
+		}
+	}
+
+	expect(all, "file_deleted")
+	expect(all, "sync_progress")
+	expect(filtered, "sync_progress")
+
+	select {
+	case ev := <-filtered.send:
+		t.Errorf("Filtered client received unexpected %s", ev.Type)
+	default:
+	}
+}
+
+func TestHubDeliver_Backpressure(t *testing.T) {
+	hub := NewWebSocketHub()
+
+	slow := &Client{hub: hub, send: make(chan Event, 1)}
+	momentary := &Client{hub: hub, send: make(chan Event, 1)}
+	hub.clients[slow] = true
+	hub.clients[momentary] = true
+
+	// Both queues start full
+	slow.send <- Event{Type: "filler"}
+	momentary.send <- Event{Type: "filler"}
+
+	// The momentarily slow client makes room within the send timeout
+	go func() {
+		time.Sleep(sendTimeout / 5)
+		<-momentary.send
+	}()
+	hub.deliver(Event{Type: "first"})
+
+	if momentary.slow != 0 {
+		t.Errorf("Expected a client that caught up not to be marked slow, got %d", momentary.slow)
+	}
+	if slow.slow != 1 {
+		t.Errorf("Expected one strike for the slow client, got %d", slow.slow)
+	}
+	if hub.ClientCount() != 2 {
+		t.Fatalf("Expected no evictions after one missed event, got %d clients", hub.ClientCount())
+	}
+
+	// Keep the momentary client drained; the slow one never reads
+	<-momentary.send
+	for i := 1; i < maxSlowStrikes; i++ {
+		hub.deliver(Event{Type: "more"})
+		<-momentary.send
+	}
+
+	if hub.ClientCount() != 1 {
+		t.Fatalf("Expected the persistently slow client to be evicted, got %d clients", hub.ClientCount())
+	}
+	if _, ok := hub.clients[momentary]; !ok {
+		t.Error("Expected the responsive client to stay connected")
+	}
+	<-slow.send // Drain the filler
+	if _, open := <-slow.send; open {
+		t.Error("Expected the evicted client's send channel to be closed")
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	cfg := config.Get()
+	original := cfg.AllowedOrigins
+	defer func() { cfg.AllowedOrigins = original }()
+
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"no origin header", []string{"https://app.example.com"}, "", true},
+		{"same origin", []string{"https://app.example.com"}, "http://api.example.com:8080", true},
+		{"allowed origin", []string{"https://app.example.com"}, "https://app.example.com", true},
+		{"allowed origin with trailing slash", []string{"https://app.example.com/"}, "https://app.example.com", true},
+		{"disallowed origin", []string{"https://app.example.com"}, "https://evil.example.com", false},
+		{"localhost without allowlist", nil, "http://localhost:3000", true},
+		{"remote without allowlist", nil, "https://evil.example.com", false},
+		{"localhost not in allowlist", []string{"https://app.example.com"}, "http://localhost:3000", false},
+		{"wildcard", []string{"*"}, "https://anything.example.com", true},
+		{"malformed origin", nil, "::not a url", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.AllowedOrigins = tt.allowed
+			req := httptest.NewRequest("GET", "http://api.example.com:8080/api/ws", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if got := checkOrigin(req); got != tt.want {
+				t.Errorf("checkOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
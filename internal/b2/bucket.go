@@ -0,0 +1,102 @@
+package b2
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/Backblaze/blazer/b2"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+)
+
+// Bucket types accepted by CreateBucket
+const (
+	BucketPrivate = string(b2.Private)
+	BucketPublic  = string(b2.Public)
+)
+
+// validateBucketType checks a bucket type, defaulting an empty one to private
+func validateBucketType(bucketType string) (string, error) {
+	switch bucketType {
+	case "":
+		return BucketPrivate, nil
+	case BucketPrivate, BucketPublic:
+		return bucketType, nil
+	}
+	return "", fmt.Errorf("invalid bucket type %q: must be %s or %s: %w", bucketType, BucketPrivate, BucketPublic, errors.ErrBadRequest)
+}
+
+// CreateBucket creates a bucket of the given type (allPrivate if empty).
+// It fails with errors.ErrBucketExists if the account already has the bucket.
+func (c *Client) CreateBucket(ctx context.Context, name, bucketType string) (*BucketInfo, error) {
+	bucketType, err := validateBucketType(bucketType)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	// Blazer's NewBucket returns an existing bucket rather than failing
+	if _, err := c.Bucket(ctx, name); err == nil {
+		return nil, fmt.Errorf("bucket %q: %w", name, errors.ErrBucketExists)
+	} else if !stderrors.Is(err, errors.ErrBucketNotFound) {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	bucket, err := c.client.NewBucket(ctx, name, &b2.BucketAttrs{Type: b2.BucketType(bucketType)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bucket %s: %w", name, err)
+	}
+
+	return &BucketInfo{
+		Name: bucket.Name(),
+		Type: bucketType,
+	}, nil
+}
+
+// DeleteBucket deletes a bucket. Unless force is set it fails with
+// errors.ErrBucketNotEmpty when the bucket holds any file versions; with
+// force, every version and unfinished large file is removed first.
+func (c *Client) DeleteBucket(ctx context.Context, name string, force bool) error {
+	bucket, err := c.Bucket(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	iter := bucket.List(ctx, b2.ListHidden())
+	for iter.Next() {
+		if !force {
+			return fmt.Errorf("bucket %q: %w", name, errors.ErrBucketNotEmpty)
+		}
+		obj := iter.Object()
+		if err := obj.Delete(ctx); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", obj.Name(), err)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to list file versions: %w", err)
+	}
+
+	if force {
+		unfinished := bucket.List(ctx, b2.ListUnfinished())
+		for unfinished.Next() {
+			obj := unfinished.Object()
+			if err := obj.Cancel(ctx); err != nil {
+				return fmt.Errorf("failed to cancel unfinished upload %s: %w", obj.Name(), err)
+			}
+		}
+		if err := unfinished.Err(); err != nil {
+			return fmt.Errorf("failed to list unfinished uploads: %w", err)
+		}
+	}
+
+	if err := bucket.Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete bucket %s: %w", name, err)
+	}
+	c.forgetBucket(name)
+	return nil
+}
@@ -0,0 +1,36 @@
+package b2
+
+import (
+	"errors"
+	"testing"
+
+	apperrors "github.com/ryanoboyle/bb-stream/pkg/errors"
+)
+
+func TestValidateBucketType(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"", BucketPrivate, false},
+		{"allPrivate", BucketPrivate, false},
+		{"allPublic", BucketPublic, false},
+		{"snapshot", "", true},
+		{"public", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := validateBucketType(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateBucketType(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err != nil && !errors.Is(err, apperrors.ErrBadRequest) {
+			t.Errorf("validateBucketType(%q) error should wrap ErrBadRequest, got %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("validateBucketType(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -3,16 +3,64 @@ package b2
 import (
 	"context"
 	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Backblaze/blazer/b2"
 	"github.com/ryanoboyle/bb-stream/internal/config"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
 )
 
 // Client wraps the Blazer B2 client
 type Client struct {
-	client *b2.Client
-	mu     sync.RWMutex
+	client    *b2.Client
+	keyID     string // Kept for native API calls Blazer doesn't expose
+	appKey    string
+	timeout   time.Duration
+	bucketTTL time.Duration
+	buckets   map[string]cachedBucket // Guarded by mu
+	mu        sync.RWMutex
+
+	// lookupBucket finds a bucket by name; nil lists buckets with client
+	lookupBucket func(ctx context.Context, name string) (*b2.Bucket, error)
+
+	sessionMu sync.Mutex
+	session   *apiAuth // Native API session for calls Blazer doesn't expose, started on first use
+}
+
+// cachedBucket is a bucket lookup kept until expires
+type cachedBucket struct {
+	bucket  *b2.Bucket
+	expires time.Time
+}
+
+// DefaultTimeout bounds a single B2 metadata operation when the caller's context has no deadline
+const DefaultTimeout = 30 * time.Second
+
+// DefaultBucketCacheTTL is how long a bucket lookup is reused before listing buckets again
+const DefaultBucketCacheTTL = time.Minute
+
+// ClientOptions configures a Client
+type ClientOptions struct {
+	// Timeout bounds operations whose duration doesn't depend on the amount of
+	// data, such as bucket lookups, object info and deletes, when the caller's
+	// context has no deadline. Transfers and listings aren't bounded. 0 disables it.
+	Timeout time.Duration
+
+	// BucketCacheTTL is how long a bucket found by name is reused. Lookups
+	// list every bucket, so caching saves a round trip per operation. 0 disables it.
+	BucketCacheTTL time.Duration
+}
+
+// DefaultClientOptions returns sensible defaults
+func DefaultClientOptions() *ClientOptions {
+	return &ClientOptions{
+		Timeout:        DefaultTimeout,
+		BucketCacheTTL: DefaultBucketCacheTTL,
+	}
 }
 
 var (
@@ -20,26 +68,52 @@ var (
 	clientOnce    sync.Once
 )
 
-// New creates a new B2 client with the provided credentials
-func New(ctx context.Context, keyID, appKey string) (*Client, error) {
+// New creates a new B2 client with the provided credentials.
+// A nil opts uses DefaultClientOptions.
+func New(ctx context.Context, keyID, appKey string, opts *ClientOptions) (*Client, error) {
+	if opts == nil {
+		opts = DefaultClientOptions()
+	}
+	c := &Client{
+		keyID:     keyID,
+		appKey:    appKey,
+		timeout:   opts.Timeout,
+		bucketTTL: opts.BucketCacheTTL,
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
 	client, err := b2.NewClient(ctx, keyID, appKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create B2 client: %w", err)
 	}
+	c.client = client
+	return c, nil
+}
 
-	return &Client{
-		client: client,
-	}, nil
+// withTimeout applies the client's timeout to ctx unless it already has a deadline
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.timeout)
 }
 
 // NewFromConfig creates a new B2 client using the stored configuration
 func NewFromConfig(ctx context.Context) (*Client, error) {
-	cfg := config.Get()
-	if !config.IsConfigured() {
-		return nil, fmt.Errorf("B2 credentials not configured. Run 'bb-stream config init' first")
+	creds, err := config.GetProfile("")
+	if err != nil {
+		return nil, err
+	}
+	if !creds.IsConfigured() {
+		return nil, fmt.Errorf("B2 credentials not configured for profile %q. Run 'bb-stream config init' first", config.ActiveProfileName())
 	}
 
-	return New(ctx, cfg.KeyID, cfg.ApplicationKey)
+	return New(ctx, creds.KeyID, creds.ApplicationKey, nil)
 }
 
 // GetDefault returns the default client (singleton)
@@ -60,8 +134,50 @@ func ResetDefault() {
 	defaultClient = nil
 }
 
-// Bucket returns a reference to a bucket by name
+// Bucket returns a reference to a bucket by name.
+// Lookups are cached for the client's BucketCacheTTL.
 func (c *Client) Bucket(ctx context.Context, name string) (*b2.Bucket, error) {
+	c.mu.RLock()
+	cached, ok := c.buckets[name]
+	lookup := c.lookupBucket
+	c.mu.RUnlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.bucket, nil
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	if lookup == nil {
+		lookup = c.findBucket
+	}
+	bucket, err := lookup(ctx, name)
+	if err != nil {
+		c.forgetBucket(name)
+		return nil, err
+	}
+
+	if c.bucketTTL > 0 {
+		c.mu.Lock()
+		if c.buckets == nil {
+			c.buckets = make(map[string]cachedBucket)
+		}
+		c.buckets[name] = cachedBucket{bucket: bucket, expires: time.Now().Add(c.bucketTTL)}
+		c.mu.Unlock()
+	}
+	return bucket, nil
+}
+
+// forgetBucket drops a cached bucket lookup, so the next operation finds the
+// bucket again in case it was deleted or recreated
+func (c *Client) forgetBucket(name string) {
+	c.mu.Lock()
+	delete(c.buckets, name)
+	c.mu.Unlock()
+}
+
+// findBucket lists the account's buckets and returns the named one
+func (c *Client) findBucket(ctx context.Context, name string) (*b2.Bucket, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -76,7 +192,7 @@ func (c *Client) Bucket(ctx context.Context, name string) (*b2.Bucket, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("bucket %q not found", name)
+	return nil, fmt.Errorf("bucket %q: %w", name, errors.ErrBucketNotFound)
 }
 
 // ListBuckets returns all buckets in the account
@@ -84,9 +200,18 @@ func (c *Client) ListBuckets(ctx context.Context) ([]*b2.Bucket, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
 	return c.client.ListBuckets(ctx)
 }
 
+// Ping checks that B2 is reachable and the credentials are valid
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.ListBuckets(ctx)
+	return err
+}
+
 // BucketInfo contains information about a bucket
 type BucketInfo struct {
 	Name string
@@ -95,6 +220,9 @@ type BucketInfo struct {
 
 // ListBucketInfo returns information about all buckets
 func (c *Client) ListBucketInfo(ctx context.Context) ([]BucketInfo, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
 	buckets, err := c.ListBuckets(ctx)
 	if err != nil {
 		return nil, err
@@ -123,16 +251,59 @@ type ObjectInfo struct {
 	Size        int64
 	ContentType string
 	Timestamp   int64
+	SHA1        string // Content SHA1 from B2 metadata; empty if unknown
+	SHA256      string // Content SHA256 stored at upload time; empty if none was stored
 }
 
 // ListObjects lists objects in a bucket with an optional prefix
 func (c *Client) ListObjects(ctx context.Context, bucketName, prefix string) ([]ObjectInfo, error) {
-	bucket, err := c.Bucket(ctx, bucketName)
+	var objects []ObjectInfo
+	err := c.WalkObjects(ctx, bucketName, prefix, func(obj ObjectInfo) error {
+		objects = append(objects, obj)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return objects, nil
+}
+
+// FilterObjects returns the objects whose base name matches pattern, using
+// filepath.Match syntax (e.g. "*.jpg"). B2 can only list by prefix, so this
+// filters client-side after listing. An empty pattern keeps everything.
+func FilterObjects(objects []ObjectInfo, pattern string) ([]ObjectInfo, error) {
+	if pattern == "" {
+		return objects, nil
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	matched := make([]ObjectInfo, 0, len(objects))
+	for _, obj := range objects {
+		if ok, _ := filepath.Match(pattern, path.Base(obj.Name)); ok {
+			matched = append(matched, obj)
+		}
+	}
+	return matched, nil
+}
+
+// WalkObjects calls fn for each object under prefix as pages of the listing
+// arrive, without holding the whole listing in memory. It stops at the first
+// error fn returns and returns that error.
+func (c *Client) WalkObjects(ctx context.Context, bucketName, prefix string, fn func(ObjectInfo) error) error {
+	return c.walkAttrs(ctx, bucketName, prefix, func(obj ObjectInfo, _ *b2.Attrs) error {
+		return fn(obj)
+	})
+}
+
+// walkAttrs is WalkObjects, also passing each object's full attributes
+func (c *Client) walkAttrs(ctx context.Context, bucketName, prefix string, fn func(ObjectInfo, *b2.Attrs) error) error {
+	bucket, err := c.Bucket(ctx, bucketName)
+	if err != nil {
+		return err
+	}
 
-	var objects []ObjectInfo
 	iter := bucket.List(ctx, b2.ListPrefix(prefix))
 
 	for iter.Next() {
@@ -141,24 +312,43 @@ func (c *Client) ListObjects(ctx context.Context, bucketName, prefix string) ([]
 		if err != nil {
 			continue // Skip objects we can't get attrs for
 		}
-		objects = append(objects, ObjectInfo{
+		err = fn(ObjectInfo{
 			Name:        obj.Name(),
 			Size:        attrs.Size,
 			ContentType: attrs.ContentType,
 			Timestamp:   attrs.UploadTimestamp.Unix(),
-		})
+			SHA1:        normalizeSHA1(attrs.SHA1),
+			SHA256:      attrs.Info[sha256Key],
+		}, attrs)
+		if err != nil {
+			return err
+		}
 	}
 
 	if err := iter.Err(); err != nil {
-		return nil, fmt.Errorf("failed to list objects: %w", err)
+		c.forgetBucket(bucketName)
+		return fmt.Errorf("failed to list objects: %w", err)
 	}
 
-	return objects, nil
+	return nil
+}
+
+// normalizeSHA1 returns the SHA1 from B2 attrs, or empty if B2 doesn't know it.
+// Large files report "none" unless a large_file_sha1 was supplied on upload.
+func normalizeSHA1(sha1 string) string {
+	sha1 = strings.TrimPrefix(sha1, "unverified:")
+	if sha1 == "none" {
+		return ""
+	}
+	return sha1
 }
 
 // DeleteObject deletes an object from a bucket
 // B2 requires deleting by file version, so we list versions and delete the latest
 func (c *Client) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
 	bucket, err := c.Bucket(ctx, bucketName)
 	if err != nil {
 		return err
@@ -172,6 +362,7 @@ func (c *Client) DeleteObject(ctx context.Context, bucketName, objectName string
 		obj := iter.Object()
 		if obj.Name() == objectName {
 			if err := obj.Delete(ctx); err != nil {
+				c.forgetBucket(bucketName)
 				return fmt.Errorf("failed to delete %s: %w", objectName, err)
 			}
 			deleted = true
@@ -180,13 +371,112 @@ func (c *Client) DeleteObject(ctx context.Context, bucketName, objectName string
 	}
 
 	if err := iter.Err(); err != nil {
+		c.forgetBucket(bucketName)
 		return fmt.Errorf("failed to list file versions: %w", err)
 	}
 
 	if !deleted {
-		return fmt.Errorf("file %s not found", objectName)
+		return fmt.Errorf("file %s: %w", objectName, errors.ErrObjectNotFound)
+	}
+
+	return nil
+}
+
+// ObjectVersion describes a single stored version of a file
+type ObjectVersion struct {
+	FileID    string
+	Name      string
+	Size      int64
+	Timestamp int64
+	Action    string // "upload" for file content, "hide" for a hide marker
+}
+
+// ListObjectVersions returns every version of the named object, newest first
+func (c *Client) ListObjectVersions(ctx context.Context, bucketName, objectName string) ([]ObjectVersion, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	bucket, err := c.Bucket(ctx, bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []ObjectVersion
+	iter := bucket.List(ctx, b2.ListPrefix(objectName), b2.ListHidden())
+
+	for iter.Next() {
+		obj := iter.Object()
+		if obj.Name() != objectName {
+			continue
+		}
+		attrs, err := obj.Attrs(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get attributes for version %s: %w", obj.ID(), err)
+		}
+		action := "upload"
+		if attrs.Status == b2.Hider {
+			action = "hide"
+		}
+		versions = append(versions, ObjectVersion{
+			FileID:    obj.ID(),
+			Name:      obj.Name(),
+			Size:      attrs.Size,
+			Timestamp: attrs.UploadTimestamp.Unix(),
+			Action:    action,
+		})
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list file versions: %w", err)
+	}
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("file %s: %w", objectName, errors.ErrObjectNotFound)
+	}
+
+	return versions, nil
+}
+
+// DeleteVersion deletes a single version of a file by its file ID.
+// B2 requires the file name alongside the ID to delete a version.
+func (c *Client) DeleteVersion(ctx context.Context, bucketName, objectName, fileID string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	bucket, err := c.Bucket(ctx, bucketName)
+	if err != nil {
+		return err
+	}
+
+	iter := bucket.List(ctx, b2.ListPrefix(objectName), b2.ListHidden())
+	for iter.Next() {
+		obj := iter.Object()
+		if obj.Name() == objectName && obj.ID() == fileID {
+			if err := obj.Delete(ctx); err != nil {
+				return fmt.Errorf("failed to delete version %s: %w", fileID, err)
+			}
+			return nil
+		}
 	}
 
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to list file versions: %w", err)
+	}
+
+	return fmt.Errorf("version %s of %s: %w", fileID, objectName, errors.ErrObjectNotFound)
+}
+
+// DeleteListedVersion deletes a version returned by ListObjectVersions.
+// Its file ID and name are all B2 needs, so unlike DeleteVersion nothing is
+// listed to find it; the caller is trusted to have listed it from the right bucket.
+func (c *Client) DeleteListedVersion(ctx context.Context, v ObjectVersion) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	req := map[string]string{"fileName": v.Name, "fileId": v.FileID}
+	if err := c.nativeCall(ctx, "b2_delete_file_version", req, nil); err != nil {
+		return fmt.Errorf("failed to delete version %s: %w", v.FileID, err)
+	}
 	return nil
 }
 
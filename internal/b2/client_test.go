@@ -0,0 +1,136 @@
+package b2
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Backblaze/blazer/b2"
+)
+
+func TestWithTimeout(t *testing.T) {
+	c := &Client{timeout: time.Minute}
+
+	ctx, cancel := c.withTimeout(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("Expected a deadline within a minute, got %v (set: %v)", deadline, ok)
+	}
+
+	// An existing deadline is kept, even if it's later than the timeout
+	parent, parentCancel := context.WithTimeout(context.Background(), time.Hour)
+	defer parentCancel()
+	ctx, cancel = c.withTimeout(parent)
+	defer cancel()
+	if d, _ := ctx.Deadline(); time.Until(d) < 59*time.Minute {
+		t.Errorf("Expected the caller's deadline to be kept, got %v", d)
+	}
+
+	// A zero timeout disables it
+	c.timeout = 0
+	ctx, cancel = c.withTimeout(context.Background())
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("Expected no deadline with a zero timeout")
+	}
+}
+
+func TestBucket_Cache(t *testing.T) {
+	lookups := 0
+	fail := false
+	c := &Client{bucketTTL: time.Minute}
+	c.lookupBucket = func(ctx context.Context, name string) (*b2.Bucket, error) {
+		lookups++
+		if fail {
+			return nil, fmt.Errorf("lookup failed")
+		}
+		return &b2.Bucket{}, nil
+	}
+
+	first, err := c.Bucket(context.Background(), "bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Bucket(context.Background(), "bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lookups != 1 || first != second {
+		t.Errorf("Expected the second call to reuse the cached bucket, got %d lookups", lookups)
+	}
+
+	// Other names are looked up separately
+	if _, err := c.Bucket(context.Background(), "other"); err != nil {
+		t.Fatal(err)
+	}
+	if lookups != 2 {
+		t.Errorf("Expected 2 lookups, got %d", lookups)
+	}
+
+	// Expired entries are looked up again, and a failure drops the entry
+	c.buckets["bucket"] = cachedBucket{bucket: first, expires: time.Now().Add(-time.Second)}
+	fail = true
+	if _, err := c.Bucket(context.Background(), "bucket"); err == nil {
+		t.Fatal("Expected the lookup error")
+	}
+	if _, ok := c.buckets["bucket"]; ok {
+		t.Error("Expected a failed lookup to drop the cached bucket")
+	}
+	if lookups != 3 {
+		t.Errorf("Expected 3 lookups, got %d", lookups)
+	}
+
+	// Invalidation forces a fresh lookup
+	fail = false
+	c.forgetBucket("other")
+	if _, err := c.Bucket(context.Background(), "other"); err != nil {
+		t.Fatal(err)
+	}
+	if lookups != 4 {
+		t.Errorf("Expected 4 lookups, got %d", lookups)
+	}
+}
+
+func TestBucket_CacheDisabled(t *testing.T) {
+	lookups := 0
+	c := &Client{}
+	c.lookupBucket = func(ctx context.Context, name string) (*b2.Bucket, error) {
+		lookups++
+		return &b2.Bucket{}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Bucket(context.Background(), "bucket"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lookups != 2 {
+		t.Errorf("Expected every call to look up the bucket with caching disabled, got %d lookups", lookups)
+	}
+}
+
+func TestFilterObjects(t *testing.T) {
+	objects := []ObjectInfo{
+		{Name: "photos/a.jpg"},
+		{Name: "photos/b.png"},
+		{Name: "photos/jpg/notes.txt"},
+		{Name: "c.JPG"},
+	}
+
+	matched, err := FilterObjects(objects, "*.jpg")
+	if err != nil {
+		t.Fatalf("FilterObjects failed: %v", err)
+	}
+	if len(matched) != 1 || matched[0].Name != "photos/a.jpg" {
+		t.Errorf("Expected only photos/a.jpg, got %+v", matched)
+	}
+
+	if all, _ := FilterObjects(objects, ""); len(all) != len(objects) {
+		t.Errorf("Empty pattern: expected %d objects, got %d", len(objects), len(all))
+	}
+	if _, err := FilterObjects(objects, "[a-"); err == nil {
+		t.Error("Expected error for malformed pattern")
+	}
+}
@@ -0,0 +1,65 @@
+package b2
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// contentEncodingKey is the file info key B2 serves as the Content-Encoding header
+const contentEncodingKey = "b2-content-encoding"
+
+// gzipReader returns a reader of src's contents compressed with gzip.
+// Closing it stops the compressor if the upload ends early.
+func gzipReader(src io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, src)
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// gunzipWriter decompresses gzip data written to it into dest.
+// Close must be called to flush the output and collect decompression errors.
+type gunzipWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func newGunzipWriter(dest io.Writer) *gunzipWriter {
+	pr, pw := io.Pipe()
+	g := &gunzipWriter{pw: pw, done: make(chan error, 1)}
+	go func() {
+		zr, err := gzip.NewReader(pr)
+		if err == nil {
+			_, err = io.Copy(dest, zr)
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to decompress: %w", err)
+		}
+		pr.CloseWithError(err) // Unblocks a pending Write if decompression failed
+		g.done <- err
+	}()
+	return g
+}
+
+func (g *gunzipWriter) Write(p []byte) (int, error) {
+	return g.pw.Write(p)
+}
+
+// Close ends the compressed stream and waits for the output to be written
+func (g *gunzipWriter) Close() error {
+	g.pw.Close()
+	return <-g.done
+}
+
+// abort stops decompression after a failed download
+func (g *gunzipWriter) abort(err error) {
+	g.pw.CloseWithError(err)
+	<-g.done
+}
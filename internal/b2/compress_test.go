@@ -0,0 +1,59 @@
+package b2
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestGzipRoundTrip(t *testing.T) {
+	original := strings.Repeat("compressible text ", 1000)
+
+	zr := gzipReader(strings.NewReader(original))
+	defer zr.Close()
+	compressed, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("gzipReader: %v", err)
+	}
+	if len(compressed) >= len(original) {
+		t.Errorf("Expected compressed size < %d, got %d", len(original), len(compressed))
+	}
+
+	var out bytes.Buffer
+	gz := newGunzipWriter(&out)
+	if _, err := gz.Write(compressed); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if out.String() != original {
+		t.Error("Decompressed data does not match the original")
+	}
+}
+
+func TestGunzipWriter_InvalidData(t *testing.T) {
+	var out bytes.Buffer
+	gz := newGunzipWriter(&out)
+	gz.Write([]byte("not gzip data"))
+	if err := gz.Close(); err == nil {
+		t.Error("Expected an error decompressing invalid data")
+	}
+}
+
+func TestWriterOptions_Compress(t *testing.T) {
+	opts := DefaultUploadOptions()
+	opts.Compress = true
+	if _, _, err := writerOptions(opts, ""); err != nil {
+		t.Errorf("writerOptions() error = %v", err)
+	}
+
+	opts.Info = make(map[string]string)
+	for i := 0; i < maxFileInfoKeys; i++ {
+		opts.Info[string(rune('a'+i))] = "v"
+	}
+	if _, _, err := writerOptions(opts, ""); err == nil {
+		t.Error("Expected an error when metadata leaves no room for the content encoding")
+	}
+}
@@ -0,0 +1,269 @@
+package b2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Backblaze/blazer/b2"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+)
+
+// MetadataDirective controls how Copy treats the source file's attributes,
+// mirroring the metadataDirective of B2's b2_copy_file
+type MetadataDirective string
+
+const (
+	// MetadataCopy keeps the source's content type and info, applying any overrides on top
+	MetadataCopy MetadataDirective = "COPY"
+	// MetadataReplace discards the source's attributes and uses only those given in CopyOptions
+	MetadataReplace MetadataDirective = "REPLACE"
+)
+
+// b2APIAuthURL starts native API sessions; a variable so tests can point it elsewhere
+var b2APIAuthURL = "https://api.backblazeb2.com/b2api/v2/b2_authorize_account"
+
+// CopyOptions configures a server-side copy
+type CopyOptions struct {
+	Directive   MetadataDirective
+	DestBucket  string            // Destination bucket; empty means same as source
+	ContentType string            // Overrides the source content type
+	Info        map[string]string // Overrides (COPY) or replaces (REPLACE) the source metadata
+}
+
+// DefaultCopyOptions returns options that preserve the source's attributes
+func DefaultCopyOptions() *CopyOptions {
+	return &CopyOptions{
+		Directive: MetadataCopy,
+	}
+}
+
+// copyFileRequest is the body of a b2_copy_file call
+type copyFileRequest struct {
+	SourceFileID        string            `json:"sourceFileId"`
+	DestinationBucketID string            `json:"destinationBucketId,omitempty"`
+	FileName            string            `json:"fileName"`
+	MetadataDirective   string            `json:"metadataDirective"`
+	ContentType         string            `json:"contentType,omitempty"`
+	FileInfo            map[string]string `json:"fileInfo,omitempty"`
+}
+
+// Copy copies an object server-side without transferring its bytes through this process.
+// With MetadataCopy the destination keeps the source's content type and info unless overridden.
+// B2 only supports single-call copies of files up to 5GB.
+func (c *Client) Copy(ctx context.Context, bucketName, srcName, dstName string, opts *CopyOptions) error {
+	if opts == nil {
+		opts = DefaultCopyOptions()
+	}
+
+	bucket, err := c.Bucket(ctx, bucketName)
+	if err != nil {
+		return err
+	}
+
+	// Attrs resolves the object's file ID as a side effect
+	obj := bucket.Object(srcName)
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		if b2.IsNotExist(err) {
+			return fmt.Errorf("object %q: %w", srcName, errors.ErrObjectNotFound)
+		}
+		return fmt.Errorf("failed to get source attributes: %w", err)
+	}
+
+	req, err := buildCopyRequest(obj.ID(), dstName, attrs, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.DestBucket != "" && opts.DestBucket != bucketName {
+		bucketID, err := c.bucketID(ctx, opts.DestBucket)
+		if err != nil {
+			return err
+		}
+		req.DestinationBucketID = bucketID
+	}
+
+	if err := c.nativeCall(ctx, "b2_copy_file", req, nil); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", srcName, dstName, err)
+	}
+
+	return nil
+}
+
+// buildCopyRequest resolves the metadata directive against the source attributes.
+// B2's COPY directive rejects overrides, so COPY with overrides is sent as REPLACE
+// with the source attributes merged underneath.
+func buildCopyRequest(sourceFileID, dstName string, src *b2.Attrs, opts *CopyOptions) (*copyFileRequest, error) {
+	req := &copyFileRequest{
+		SourceFileID: sourceFileID,
+		FileName:     dstName,
+	}
+
+	switch opts.Directive {
+	case MetadataCopy, "":
+		if opts.ContentType == "" && len(opts.Info) == 0 {
+			req.MetadataDirective = string(MetadataCopy)
+			return req, nil
+		}
+
+		info := make(map[string]string, len(src.Info)+len(opts.Info))
+		for k, v := range src.Info {
+			info[k] = v
+		}
+		for k, v := range opts.Info {
+			info[k] = v
+		}
+		validated, err := ValidateFileInfo(info)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata: %w", err)
+		}
+
+		req.MetadataDirective = string(MetadataReplace)
+		req.ContentType = src.ContentType
+		if opts.ContentType != "" {
+			req.ContentType = opts.ContentType
+		}
+		req.FileInfo = validated
+
+	case MetadataReplace:
+		validated, err := ValidateFileInfo(opts.Info)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata: %w", err)
+		}
+
+		req.MetadataDirective = string(MetadataReplace)
+		req.ContentType = opts.ContentType
+		if req.ContentType == "" {
+			req.ContentType = "b2/x-auto"
+		}
+		req.FileInfo = validated
+
+	default:
+		return nil, fmt.Errorf("unknown metadata directive %q", opts.Directive)
+	}
+
+	if req.FileInfo == nil && req.MetadataDirective == string(MetadataReplace) {
+		req.FileInfo = map[string]string{}
+	}
+
+	return req, nil
+}
+
+// apiAuth holds a native B2 API session for calls Blazer doesn't expose
+type apiAuth struct {
+	AccountID string `json:"accountId"`
+	APIURL    string `json:"apiUrl"`
+	Token     string `json:"authorizationToken"`
+
+	RecommendedPartSize int64 `json:"recommendedPartSize"`
+	MinimumPartSize     int64 `json:"absoluteMinimumPartSize"`
+}
+
+// authorize starts a native B2 API session with the client's credentials,
+// bounded by the client's timeout. Use nativeSession to reuse the session.
+func (c *Client) authorize(ctx context.Context) (*apiAuth, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b2APIAuthURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.keyID, c.appKey)
+
+	var auth apiAuth
+	if err := doAPIRequest(req, &auth); err != nil {
+		return nil, fmt.Errorf("failed to authorize account: %w", err)
+	}
+	return &auth, nil
+}
+
+// bucketID looks up a bucket's ID by name with the native API
+func (c *Client) bucketID(ctx context.Context, name string) (string, error) {
+	auth, err := c.nativeSession(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		Buckets []struct {
+			BucketID   string `json:"bucketId"`
+			BucketName string `json:"bucketName"`
+		} `json:"buckets"`
+	}
+	req := map[string]string{"accountId": auth.AccountID, "bucketName": name}
+	if err := c.nativeCall(ctx, "b2_list_buckets", req, &resp); err != nil {
+		return "", fmt.Errorf("failed to list buckets: %w", err)
+	}
+
+	for _, b := range resp.Buckets {
+		if b.BucketName == name {
+			return b.BucketID, nil
+		}
+	}
+	return "", fmt.Errorf("bucket %q: %w", name, errors.ErrBucketNotFound)
+}
+
+// call POSTs a JSON request to a native B2 API method
+func (a *apiAuth) call(ctx context.Context, method string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.APIURL+"/b2api/v2/"+method, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", a.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	return doAPIRequest(req, out)
+}
+
+// apiError is an error response from the native B2 API
+type apiError struct {
+	Path    string
+	Status  int
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s: %d: %s", e.Path, e.Status, e.Message)
+}
+
+// Unwrap maps the status to the matching sentinel, so errors.IsNotFound and
+// friends work on native API failures
+func (e *apiError) Unwrap() error {
+	switch e.Status {
+	case http.StatusNotFound:
+		return errors.ErrNotFound
+	case http.StatusUnauthorized:
+		return errors.ErrUnauthorized
+	}
+	return nil
+}
+
+// doAPIRequest executes a native API request and decodes the JSON response or B2 error
+func doAPIRequest(req *http.Request, out interface{}) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &apiError{Path: req.URL.Path, Status: resp.StatusCode}
+		_ = json.NewDecoder(resp.Body).Decode(apiErr)
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
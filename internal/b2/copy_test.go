@@ -0,0 +1,86 @@
+package b2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Backblaze/blazer/b2"
+)
+
+func TestBuildCopyRequest(t *testing.T) {
+	src := &b2.Attrs{
+		ContentType: "text/plain",
+		Info:        map[string]string{"author": "alice", "project": "x"},
+	}
+
+	t.Run("copy without overrides", func(t *testing.T) {
+		req, err := buildCopyRequest("id1", "dst.txt", src, &CopyOptions{Directive: MetadataCopy})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.MetadataDirective != "COPY" || req.ContentType != "" || req.FileInfo != nil {
+			t.Errorf("expected plain COPY, got %+v", req)
+		}
+		if req.SourceFileID != "id1" || req.FileName != "dst.txt" {
+			t.Errorf("unexpected ids: %+v", req)
+		}
+	})
+
+	t.Run("copy with overrides merges source", func(t *testing.T) {
+		opts := &CopyOptions{Directive: MetadataCopy, Info: map[string]string{"project": "y"}}
+		req, err := buildCopyRequest("id1", "dst.txt", src, opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.MetadataDirective != "REPLACE" {
+			t.Errorf("expected REPLACE, got %s", req.MetadataDirective)
+		}
+		if req.ContentType != "text/plain" {
+			t.Errorf("expected source content type, got %q", req.ContentType)
+		}
+		if req.FileInfo["author"] != "alice" || req.FileInfo["project"] != "y" {
+			t.Errorf("unexpected info: %v", req.FileInfo)
+		}
+	})
+
+	t.Run("replace drops source", func(t *testing.T) {
+		opts := &CopyOptions{Directive: MetadataReplace, ContentType: "application/json"}
+		req, err := buildCopyRequest("id1", "dst.txt", src, opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.ContentType != "application/json" || len(req.FileInfo) != 0 {
+			t.Errorf("expected only replacement attrs, got %+v", req)
+		}
+	})
+
+	t.Run("unknown directive", func(t *testing.T) {
+		if _, err := buildCopyRequest("id1", "dst.txt", src, &CopyOptions{Directive: "MERGE"}); err == nil {
+			t.Error("expected error for unknown directive")
+		}
+	})
+}
+
+func TestNativeSession_ReusedUntilExpired(t *testing.T) {
+	fake := newFakeB2(t)
+	client := &Client{keyID: "id", appKey: "key"}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if id, err := client.bucketID(ctx, "my-bucket"); err != nil || id != "b1" {
+			t.Fatalf("bucketID = %q, %v", id, err)
+		}
+	}
+	if fake.sessions != 1 {
+		t.Errorf("Expected one authorization for repeated calls, got %d", fake.sessions)
+	}
+
+	// An expired token starts one new session and retries the call
+	fake.token = ""
+	if id, err := client.bucketID(ctx, "my-bucket"); err != nil || id != "b1" {
+		t.Fatalf("bucketID after expiry = %q, %v", id, err)
+	}
+	if fake.sessions != 2 {
+		t.Errorf("Expected a second authorization after the token expired, got %d", fake.sessions)
+	}
+}
@@ -4,15 +4,24 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 
 	"github.com/Backblaze/blazer/b2"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
 	"github.com/ryanoboyle/bb-stream/pkg/progress"
+	"github.com/ryanoboyle/bb-stream/pkg/retry"
 )
 
 // DownloadOptions configures a download operation
 type DownloadOptions struct {
 	ConcurrentDownloads int
 	Range               *ByteRange
+	Decompress          bool          // Gunzip objects stored with Content-Encoding: gzip
+	Decrypt             bool          // Decrypt client-side encrypted objects with Passphrase
+	Passphrase          string        // Decryption passphrase
+	MaxBytesPerSec      int64         // Bandwidth cap; 0 means unlimited
+	Retry               *retry.Config // Retry policy for transient failures; nil uses retry.DefaultConfig()
 	ProgressCallback    progress.Callback
 }
 
@@ -26,16 +35,18 @@ type ByteRange struct {
 func DefaultDownloadOptions() *DownloadOptions {
 	return &DownloadOptions{
 		ConcurrentDownloads: 4,
+		Retry:               retry.DefaultConfig(),
 	}
 }
 
-// Download downloads an object to a writer
+// Download downloads an object to a writer.
+// Transient failures are retried, resuming from the last byte written.
 func (c *Client) Download(ctx context.Context, bucketName, objectName string, writer io.Writer, opts *DownloadOptions) error {
 	if opts == nil {
 		opts = DefaultDownloadOptions()
 	}
 
-	bucket, err := c.Bucket(ctx, bucketName)
+	bucket, err := c.bucketWithRetry(ctx, bucketName, opts.Retry)
 	if err != nil {
 		return err
 	}
@@ -43,45 +54,197 @@ func (c *Client) Download(ctx context.Context, bucketName, objectName string, wr
 	obj := bucket.Object(objectName)
 
 	// Get object attributes for size
-	attrs, err := obj.Attrs(ctx)
+	attrs, err := retry.DoWithResult(ctx, LogRetries(opts.Retry, "attrs"), IsRetryable, func() (*b2.Attrs, error) {
+		return obj.Attrs(ctx)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get object attributes: %w", err)
+		c.forgetBucket(bucketName)
+		return fmt.Errorf("failed to get object attributes: %w", accessError(err))
 	}
 
-	var reader *b2.Reader
-
-	// Handle range requests using NewRangeReader
+	// Handle range requests
+	offset, length := int64(0), int64(-1)
 	if opts.Range != nil {
-		length := opts.Range.End - opts.Range.Start
+		offset = opts.Range.Start
+		length = opts.Range.End - opts.Range.Start
 		if length <= 0 {
 			length = attrs.Size - opts.Range.Start
 		}
-		reader = obj.NewRangeReader(ctx, opts.Range.Start, length)
-	} else {
-		reader = obj.NewReader(ctx)
 	}
-	defer reader.Close()
 
-	// Configure download options
-	if opts.ConcurrentDownloads > 0 {
-		reader.ConcurrentDownloads = opts.ConcurrentDownloads
+	// Wrap writer with decryption, decompression, throttling and progress tracking if configured.
+	// Progress counts stored bytes, so it matches attrs.Size when transforming the data.
+	dest, finish, err := decoder(writer, attrs, opts)
+	if err != nil {
+		return err
+	}
+	if opts.MaxBytesPerSec > 0 {
+		dest = progress.NewRateLimitedWriter(ctx, dest, opts.MaxBytesPerSec)
 	}
-
-	// Wrap writer with progress tracking if callback provided
-	var dest io.Writer = writer
 	if opts.ProgressCallback != nil {
-		dest = progress.NewWriter(writer, attrs.Size, opts.ProgressCallback)
+		dest = progress.NewWriterThrottled(dest, attrs.Size, opts.ProgressCallback, progress.DefaultInterval)
 	}
 
-	// Copy data from reader to writer
-	_, err = io.Copy(dest, reader)
-	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
+	if err := finish(readObject(ctx, obj, dest, offset, length, opts)); err != nil {
+		return fmt.Errorf("failed to download: %w", accessError(err))
 	}
 
 	return nil
 }
 
+// accessError marks B2 401 and 403 responses with ErrUnauthorized. For
+// downloads these usually mean the bucket is private and the key can't read
+// it, which Blazer otherwise reports as an opaque API error.
+func accessError(err error) error {
+	switch statusCode(err) {
+	case 401, 403:
+		return fmt.Errorf("%w: bucket is private or credentials lack access: %w", errors.ErrUnauthorized, err)
+	}
+	return err
+}
+
+// decoder wraps dest to undo the encryption and compression recorded in attrs,
+// as requested by opts. The returned finish func must be called with the
+// read's result; it flushes the decoders and returns the overall error.
+func decoder(dest io.Writer, attrs *b2.Attrs, opts *DownloadOptions) (io.Writer, func(error) error, error) {
+	var enc *encryption
+	if _, encrypted := attrs.Info[encryptionKey]; encrypted {
+		if !opts.Decrypt {
+			return nil, nil, fmt.Errorf("enable decryption and set %s to read it: %w", PassphraseEnv, errors.ErrEncrypted)
+		}
+		var err error
+		if enc, err = openEncryption(attrs.Info, opts.Passphrase); err != nil {
+			return nil, nil, err
+		}
+	}
+	decompress := opts.Decompress && attrs.Info[contentEncodingKey] == "gzip"
+	if (enc != nil || decompress) && opts.Range != nil {
+		return nil, nil, fmt.Errorf("cannot decode a byte range of an encrypted or compressed object")
+	}
+
+	var gz *gunzipWriter
+	if decompress {
+		gz = newGunzipWriter(dest)
+		dest = gz
+	}
+	var dw *decryptWriter
+	if enc != nil {
+		dw = newDecryptWriter(dest, enc)
+		dest = dw
+	}
+
+	finish := func(err error) error {
+		if err == nil && dw != nil {
+			err = dw.Close()
+		}
+		if gz == nil {
+			return err
+		}
+		if err != nil {
+			gz.abort(err)
+			return err
+		}
+		return gz.Close()
+	}
+	return dest, finish, nil
+}
+
+// resumeWriter counts bytes written and remembers write failures,
+// so a retry can resume at the right offset and skip destination errors
+type resumeWriter struct {
+	w       io.Writer
+	written int64
+	err     error
+}
+
+func (rw *resumeWriter) Write(p []byte) (int, error) {
+	n, err := rw.w.Write(p)
+	rw.written += int64(n)
+	if err != nil {
+		rw.err = err
+	}
+	return n, err
+}
+
+// readObject copies length bytes (or the rest, if negative) of obj starting at offset into dest.
+// Read failures are retried from where the previous attempt stopped; write failures are not.
+func readObject(ctx context.Context, obj *b2.Object, dest io.Writer, offset, length int64, opts *DownloadOptions) error {
+	rw := &resumeWriter{w: dest}
+	isRetryable := func(err error) bool {
+		return rw.err == nil && IsRetryable(err)
+	}
+
+	return retry.Do(ctx, LogRetries(opts.Retry, "download"), isRetryable, func() error {
+		remaining := int64(-1)
+		if length >= 0 {
+			remaining = length - rw.written
+			if remaining <= 0 {
+				return nil
+			}
+		}
+
+		reader := obj.NewRangeReader(ctx, offset+rw.written, remaining)
+		defer reader.Close()
+
+		// Configure download options
+		if opts.ConcurrentDownloads > 0 {
+			reader.ConcurrentDownloads = opts.ConcurrentDownloads
+		}
+
+		_, err := io.Copy(rw, reader)
+		return err
+	})
+}
+
+// DownloadToFile downloads an object to localPath, creating parent directories,
+// and returns the number of bytes written. Data goes to localPath+".tmp" and is
+// synced and renamed into place, so a failed or interrupted download never
+// leaves a truncated file at localPath.
+func (c *Client) DownloadToFile(ctx context.Context, bucketName, objectName, localPath string, opts *DownloadOptions) (int64, error) {
+	return WriteFileAtomic(localPath, func(w io.Writer) error {
+		return c.Download(ctx, bucketName, objectName, w, opts)
+	})
+}
+
+// WriteFileAtomic creates path's parent directories and calls write with a
+// temporary file beside path, which replaces path only if write succeeds
+func WriteFileAtomic(path string, write func(io.Writer) error) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+
+	var written int64
+	err = write(f)
+	if err == nil {
+		// The file offset is the number of bytes written
+		written, err = f.Seek(0, io.SeekCurrent)
+	}
+	if err == nil {
+		if err = f.Sync(); err != nil {
+			err = fmt.Errorf("failed to sync file: %w", err)
+		}
+	}
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to close file: %w", cerr)
+	}
+	if err == nil {
+		if err = os.Rename(tmpPath, path); err != nil {
+			err = fmt.Errorf("failed to move download into place: %w", err)
+		}
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	return written, nil
+}
+
 // DownloadToWriter is a simplified download to an io.Writer
 func (c *Client) DownloadToWriter(ctx context.Context, bucketName, objectName string, writer io.Writer) error {
 	return c.Download(ctx, bucketName, objectName, writer, nil)
@@ -107,39 +270,41 @@ func (c *Client) StreamDownload(ctx context.Context, bucketName, objectName stri
 		opts = DefaultDownloadOptions()
 	}
 
-	bucket, err := c.Bucket(ctx, bucketName)
+	bucket, err := c.bucketWithRetry(ctx, bucketName, opts.Retry)
 	if err != nil {
 		return err
 	}
 
 	obj := bucket.Object(objectName)
 
-	var reader *b2.Reader
+	// Attributes give range lengths and how the stored bytes are encoded
+	attrs, err := retry.DoWithResult(ctx, LogRetries(opts.Retry, "attrs"), IsRetryable, func() (*b2.Attrs, error) {
+		return obj.Attrs(ctx)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get object attributes: %w", accessError(err))
+	}
 
-	// Handle range requests using NewRangeReader
+	// Handle range requests
+	offset, length := int64(0), int64(-1)
 	if opts.Range != nil {
-		// Get size if needed for calculating length
-		attrs, err := obj.Attrs(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to get object attributes: %w", err)
-		}
-		length := opts.Range.End - opts.Range.Start
+		offset = opts.Range.Start
+		length = opts.Range.End - opts.Range.Start
 		if length <= 0 {
 			length = attrs.Size - opts.Range.Start
 		}
-		reader = obj.NewRangeReader(ctx, opts.Range.Start, length)
-	} else {
-		reader = obj.NewReader(ctx)
 	}
-	defer reader.Close()
 
-	if opts.ConcurrentDownloads > 0 {
-		reader.ConcurrentDownloads = opts.ConcurrentDownloads
+	dest, finish, err := decoder(writer, attrs, opts)
+	if err != nil {
+		return err
+	}
+	if opts.MaxBytesPerSec > 0 {
+		dest = progress.NewRateLimitedWriter(ctx, dest, opts.MaxBytesPerSec)
 	}
 
-	_, err = io.Copy(writer, reader)
-	if err != nil {
-		return fmt.Errorf("failed to stream download: %w", err)
+	if err := finish(readObject(ctx, obj, dest, offset, length, opts)); err != nil {
+		return fmt.Errorf("failed to stream download: %w", accessError(err))
 	}
 
 	return nil
@@ -158,6 +323,9 @@ func (c *Client) GetDownloadReader(ctx context.Context, bucketName, objectName s
 
 // GetObjectInfo returns information about an object
 func (c *Client) GetObjectInfo(ctx context.Context, bucketName, objectName string) (*ObjectInfo, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
 	bucket, err := c.Bucket(ctx, bucketName)
 	if err != nil {
 		return nil, err
@@ -166,7 +334,10 @@ func (c *Client) GetObjectInfo(ctx context.Context, bucketName, objectName strin
 	obj := bucket.Object(objectName)
 	attrs, err := obj.Attrs(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get object attributes: %w", err)
+		if b2.IsNotExist(err) {
+			return nil, fmt.Errorf("object %q: %w", objectName, errors.ErrObjectNotFound)
+		}
+		return nil, fmt.Errorf("failed to get object attributes: %w", accessError(err))
 	}
 
 	return &ObjectInfo{
@@ -174,15 +345,25 @@ func (c *Client) GetObjectInfo(ctx context.Context, bucketName, objectName strin
 		Size:        attrs.Size,
 		ContentType: attrs.ContentType,
 		Timestamp:   attrs.UploadTimestamp.Unix(),
+		SHA1:        normalizeSHA1(attrs.SHA1),
+		SHA256:      attrs.Info[sha256Key],
 	}, nil
 }
 
-// ObjectExists checks if an object exists in a bucket
+// ObjectExists checks if an object exists in a bucket.
+// Only a genuine not-found is reported as (false, nil); other failures are returned.
 func (c *Client) ObjectExists(ctx context.Context, bucketName, objectName string) (bool, error) {
 	_, err := c.GetObjectInfo(ctx, bucketName, objectName)
-	if err != nil {
-		// Check if it's a "not found" error
+	return existsFromErr(err)
+}
+
+// existsFromErr maps an object lookup error to an existence result
+func existsFromErr(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	if errors.IsNotFound(err) {
 		return false, nil
 	}
-	return true, nil
+	return false, err
 }
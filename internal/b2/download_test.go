@@ -0,0 +1,70 @@
+package b2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+)
+
+func TestExistsFromErr(t *testing.T) {
+	exists, err := existsFromErr(nil)
+	if !exists || err != nil {
+		t.Errorf("existsFromErr(nil) = (%v, %v), want (true, nil)", exists, err)
+	}
+
+	notFound := fmt.Errorf("object %q: %w", "a.txt", errors.ErrObjectNotFound)
+	exists, err = existsFromErr(notFound)
+	if exists || err != nil {
+		t.Errorf("existsFromErr(not found) = (%v, %v), want (false, nil)", exists, err)
+	}
+}
+
+func TestExistsFromErr_TimeoutPropagates(t *testing.T) {
+	timeout := fmt.Errorf("failed to get object attributes: %w", context.DeadlineExceeded)
+
+	exists, err := existsFromErr(timeout)
+	if exists {
+		t.Error("Expected exists=false for timeout error")
+	}
+	if err == nil {
+		t.Error("Expected timeout error to be returned, got nil")
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "dir", "file.txt")
+
+	n, err := WriteFileAtomic(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "complete")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WriteFileAtomic: %v", err)
+	}
+	if n != 8 {
+		t.Errorf("Expected 8 bytes written, got %d", n)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "complete" {
+		t.Errorf("Expected file contents %q, got %q", "complete", data)
+	}
+
+	// A failed write leaves the previous file untouched and no temp file behind
+	_, err = WriteFileAtomic(path, func(w io.Writer) error {
+		io.WriteString(w, "trunc")
+		return fmt.Errorf("connection reset")
+	})
+	if err == nil {
+		t.Fatal("Expected the write error to be returned")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "complete" {
+		t.Errorf("Expected the original file to survive a failed download, got %q", data)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected the temp file to be removed, got %v", err)
+	}
+}
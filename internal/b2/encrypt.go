@@ -0,0 +1,233 @@
+package b2
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv is the environment variable the CLI reads the encryption passphrase from
+const PassphraseEnv = "BB_ENCRYPTION_PASSPHRASE"
+
+// File info keys describing client-side encryption
+const (
+	encryptionKey      = "bb-encryption" // Format marker
+	encryptionNonceKey = "bb-nonce"      // Base nonce, base64
+	encryptionSaltKey  = "bb-salt"       // Key derivation salt, base64
+)
+
+// encryptionFormat identifies AES-256-GCM over fixed-size chunks.
+// Each chunk's nonce is the base nonce with the chunk index XORed into its last
+// four bytes, and the final chunk is authenticated as such, so reordered or
+// truncated ciphertext fails to decrypt.
+const encryptionFormat = "aes256gcm-chunked-v1"
+
+const (
+	encryptChunkSize = 64 * 1024
+	saltSize         = 16
+)
+
+// scrypt parameters for deriving the key from a passphrase
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// encryption holds the cipher and base nonce for one object
+type encryption struct {
+	aead  cipher.AEAD
+	nonce []byte
+}
+
+// newEncryption derives a key from passphrase with a fresh salt and nonce,
+// and returns the file info that lets the object be decrypted later
+func newEncryption(passphrase string) (*encryption, map[string]string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	aead, err := deriveAEAD(passphrase, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	info := map[string]string{
+		encryptionKey:      encryptionFormat,
+		encryptionNonceKey: base64.RawURLEncoding.EncodeToString(nonce),
+		encryptionSaltKey:  base64.RawURLEncoding.EncodeToString(salt),
+	}
+	return &encryption{aead: aead, nonce: nonce}, info, nil
+}
+
+// openEncryption rebuilds the cipher for an object from its file info.
+// It returns nil if the object isn't encrypted.
+func openEncryption(info map[string]string, passphrase string) (*encryption, error) {
+	format, ok := info[encryptionKey]
+	if !ok {
+		return nil, nil
+	}
+	if format != encryptionFormat {
+		return nil, fmt.Errorf("unsupported encryption format %q", format)
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("a passphrase is required to decrypt (set %s): %w", PassphraseEnv, errors.ErrEncrypted)
+	}
+
+	nonce, err := base64.RawURLEncoding.DecodeString(info[encryptionNonceKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption nonce: %w", err)
+	}
+	salt, err := base64.RawURLEncoding.DecodeString(info[encryptionSaltKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption salt: %w", err)
+	}
+	aead, err := deriveAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid encryption nonce length %d", len(nonce))
+	}
+	return &encryption{aead: aead, nonce: nonce}, nil
+}
+
+// deriveAEAD derives an AES-256 key from passphrase and salt and returns its GCM cipher
+func deriveAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce for chunk i
+func (e *encryption) chunkNonce(i uint32) []byte {
+	nonce := bytes.Clone(e.nonce)
+	n := len(nonce)
+	binary.BigEndian.PutUint32(nonce[n-4:], binary.BigEndian.Uint32(nonce[n-4:])^i)
+	return nonce
+}
+
+// chunkAD is the additional data marking whether a chunk is the last one
+func chunkAD(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// encryptReader encrypts a plaintext stream chunk by chunk
+type encryptReader struct {
+	enc   *encryption
+	src   *bufio.Reader
+	chunk uint32
+	plain []byte
+	out   []byte // Sealed chunk not yet returned
+	done  bool
+}
+
+func newEncryptReader(src io.Reader, enc *encryption) *encryptReader {
+	return &encryptReader{
+		enc:   enc,
+		src:   bufio.NewReaderSize(src, encryptChunkSize),
+		plain: make([]byte, encryptChunkSize),
+	}
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.seal(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// seal reads and encrypts the next chunk. A chunk is the last one when the
+// source ends with it; an empty plaintext still yields one sealed chunk.
+func (r *encryptReader) seal() error {
+	n, err := io.ReadFull(r.src, r.plain)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	last := err != nil
+	if !last {
+		if _, err := r.src.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	r.out = r.enc.aead.Seal(r.out[:0], r.enc.chunkNonce(r.chunk), r.plain[:n], chunkAD(last))
+	r.chunk++
+	r.done = last
+	return nil
+}
+
+// decryptWriter decrypts ciphertext written to it into dest.
+// Close must be called to decrypt and verify the final chunk.
+type decryptWriter struct {
+	enc   *encryption
+	dest  io.Writer
+	chunk uint32
+	buf   []byte
+}
+
+func newDecryptWriter(dest io.Writer, enc *encryption) *decryptWriter {
+	return &decryptWriter{enc: enc, dest: dest}
+}
+
+func (w *decryptWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	// Only a chunk followed by more data is known not to be the last
+	sealed := encryptChunkSize + w.enc.aead.Overhead()
+	off := 0
+	for len(w.buf)-off > sealed {
+		if err := w.open(w.buf[off:off+sealed], false); err != nil {
+			return 0, err
+		}
+		off += sealed
+	}
+	w.buf = append(w.buf[:0], w.buf[off:]...)
+	return len(p), nil
+}
+
+// Close decrypts the final chunk
+func (w *decryptWriter) Close() error {
+	return w.open(w.buf, true)
+}
+
+func (w *decryptWriter) open(sealed []byte, last bool) error {
+	plain, err := w.enc.aead.Open(nil, w.enc.chunkNonce(w.chunk), sealed, chunkAD(last))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt: wrong passphrase or corrupted data")
+	}
+	w.chunk++
+	_, err = w.dest.Write(plain)
+	return err
+}
@@ -0,0 +1,88 @@
+package b2
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/Backblaze/blazer/b2"
+	apperrors "github.com/ryanoboyle/bb-stream/pkg/errors"
+)
+
+// encryptForTest encrypts plain with a fresh key and returns the ciphertext and file info
+func encryptForTest(t *testing.T, plain []byte) ([]byte, map[string]string) {
+	t.Helper()
+	enc, info, err := newEncryption("correct horse")
+	if err != nil {
+		t.Fatalf("newEncryption: %v", err)
+	}
+	sealed, err := io.ReadAll(newEncryptReader(bytes.NewReader(plain), enc))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	return sealed, info
+}
+
+func decryptForTest(sealed []byte, info map[string]string, passphrase string) ([]byte, error) {
+	enc, err := openEncryption(info, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	dw := newDecryptWriter(&out, enc)
+	if _, err := dw.Write(sealed); err != nil {
+		return nil, err
+	}
+	if err := dw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, encryptChunkSize, encryptChunkSize + 1, 3*encryptChunkSize - 7} {
+		plain := bytes.Repeat([]byte{'x'}, size)
+		sealed, info := encryptForTest(t, plain)
+
+		got, err := decryptForTest(sealed, info, "correct horse")
+		if err != nil {
+			t.Fatalf("size %d: decrypt: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: decrypted data does not match", size)
+		}
+	}
+}
+
+func TestDecrypt_Rejects(t *testing.T) {
+	plain := bytes.Repeat([]byte("secret"), encryptChunkSize/2)
+	sealed, info := encryptForTest(t, plain)
+
+	if _, err := decryptForTest(sealed, info, "wrong"); err == nil {
+		t.Error("Expected an error with the wrong passphrase")
+	}
+
+	// Dropping the final chunk must not pass as a complete object
+	firstChunk := sealed[:encryptChunkSize+16]
+	if _, err := decryptForTest(firstChunk, info, "correct horse"); err == nil {
+		t.Error("Expected an error for truncated ciphertext")
+	}
+
+	if _, err := openEncryption(info, ""); !errors.Is(err, apperrors.ErrEncrypted) {
+		t.Errorf("Expected ErrEncrypted without a passphrase, got %v", err)
+	}
+	if enc, err := openEncryption(map[string]string{}, ""); enc != nil || err != nil {
+		t.Errorf("Expected unencrypted objects to pass through, got (%v, %v)", enc, err)
+	}
+}
+
+func TestDecoder_EncryptedWithoutDecrypt(t *testing.T) {
+	_, info := encryptForTest(t, []byte("data"))
+	attrs := &b2.Attrs{Info: info}
+
+	_, _, err := decoder(io.Discard, attrs, DefaultDownloadOptions())
+	if !errors.Is(err, apperrors.ErrEncrypted) {
+		t.Errorf("Expected ErrEncrypted, got %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package b2
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+)
+
+const (
+	// MaxParts is the most parts B2 accepts for one large file
+	MaxParts = 10000
+	// MaxPartSize is the largest part B2 accepts
+	MaxPartSize = 5 * 1000 * 1000 * 1000
+)
+
+// LargeUpload is an unfinished B2 large file, uploaded part by part with
+// UploadPart and committed with FinishLargeUpload. Its parts survive the
+// process, so a later run can carry on with the same FileID.
+type LargeUpload struct {
+	FileID     string
+	BucketName string
+	ObjectName string
+}
+
+// UploadedPart is a part B2 has stored for a large upload
+type UploadedPart struct {
+	Number int
+	Size   int64
+	SHA1   string
+}
+
+// PartUploader sends parts of one large upload. B2 allows only one upload
+// at a time per part URL, so each concurrent sender needs its own.
+type PartUploader struct {
+	client *Client
+	upload *LargeUpload
+	url    string // Part upload URL, fetched on first use
+	token  string
+}
+
+// nativeSession returns the native API session, starting one if needed
+func (c *Client) nativeSession(ctx context.Context) (*apiAuth, error) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
+	if c.session == nil {
+		auth, err := c.authorize(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.session = auth
+	}
+	return c.session, nil
+}
+
+// nativeCall calls a native API method, starting a new session once if the
+// current one's token has expired
+func (c *Client) nativeCall(ctx context.Context, method string, body, out interface{}) error {
+	auth, err := c.nativeSession(ctx)
+	if err != nil {
+		return err
+	}
+	err = auth.call(ctx, method, body, out)
+	if !stderrors.Is(err, errors.ErrUnauthorized) {
+		return err
+	}
+
+	c.sessionMu.Lock()
+	if c.session == auth {
+		c.session = nil
+	}
+	c.sessionMu.Unlock()
+	if auth, err = c.nativeSession(ctx); err != nil {
+		return err
+	}
+	return auth.call(ctx, method, body, out)
+}
+
+// StartLargeUpload starts a large file that parts can be uploaded to. Only
+// ContentType and Info are used from opts; the parts are stored as given.
+func (c *Client) StartLargeUpload(ctx context.Context, bucketName, objectName string, opts *UploadOptions) (*LargeUpload, error) {
+	if opts == nil {
+		opts = DefaultUploadOptions()
+	}
+	info, err := ValidateFileInfo(opts.Info)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = "b2/x-auto"
+	}
+
+	bucketID, err := c.bucketID(ctx, bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	req := map[string]interface{}{
+		"bucketId":    bucketID,
+		"fileName":    objectName,
+		"contentType": contentType,
+	}
+	if len(info) > 0 {
+		req["fileInfo"] = info
+	}
+	var resp struct {
+		FileID string `json:"fileId"`
+	}
+	if err := c.nativeCall(ctx, "b2_start_large_file", req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to start large upload: %w", err)
+	}
+
+	return &LargeUpload{FileID: resp.FileID, BucketName: bucketName, ObjectName: objectName}, nil
+}
+
+// NewPartUploader returns a sender for the parts of upload
+func (c *Client) NewPartUploader(upload *LargeUpload) *PartUploader {
+	return &PartUploader{client: c, upload: upload}
+}
+
+// UploadPart uploads part number (1-based) of a large upload. sha1 is the
+// hex SHA1 of the part's size bytes, which B2 verifies on receipt. A failed
+// part can be uploaded again; B2 keeps the last copy of each part number.
+func (c *Client) UploadPart(ctx context.Context, upload *LargeUpload, number int, r io.Reader, size int64, sha1 string) error {
+	return c.NewPartUploader(upload).UploadPart(ctx, number, r, size, sha1)
+}
+
+// UploadPart uploads part number of the large upload. After a failure the
+// part URL is dropped, so the next call fetches a fresh one as B2 requires.
+func (p *PartUploader) UploadPart(ctx context.Context, number int, r io.Reader, size int64, sha1 string) error {
+	if number < 1 || number > MaxParts {
+		return fmt.Errorf("part number %d out of range 1-%d", number, MaxParts)
+	}
+
+	if p.url == "" {
+		var resp struct {
+			UploadURL string `json:"uploadUrl"`
+			Token     string `json:"authorizationToken"`
+		}
+		err := p.client.nativeCall(ctx, "b2_get_upload_part_url", map[string]string{"fileId": p.upload.FileID}, &resp)
+		if err != nil {
+			return fmt.Errorf("failed to get part upload URL: %w", err)
+		}
+		p.url, p.token = resp.UploadURL, resp.Token
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, io.NopCloser(r))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Authorization", p.token)
+	req.Header.Set("X-Bz-Part-Number", strconv.Itoa(number))
+	req.Header.Set("X-Bz-Content-Sha1", sha1)
+
+	if err := doAPIRequest(req, nil); err != nil {
+		p.url, p.token = "", ""
+		return fmt.Errorf("failed to upload part %d: %w", number, err)
+	}
+	return nil
+}
+
+// ListParts returns the parts B2 has stored for a large upload, in order
+func (c *Client) ListParts(ctx context.Context, upload *LargeUpload) ([]UploadedPart, error) {
+	var parts []UploadedPart
+	next := 1
+	for next > 0 {
+		var resp struct {
+			Parts []struct {
+				PartNumber    int    `json:"partNumber"`
+				ContentLength int64  `json:"contentLength"`
+				ContentSHA1   string `json:"contentSha1"`
+			} `json:"parts"`
+			NextPartNumber *int `json:"nextPartNumber"`
+		}
+		req := map[string]interface{}{"fileId": upload.FileID, "startPartNumber": next, "maxPartCount": 1000}
+		if err := c.nativeCall(ctx, "b2_list_parts", req, &resp); err != nil {
+			return nil, fmt.Errorf("failed to list parts: %w", err)
+		}
+		for _, part := range resp.Parts {
+			parts = append(parts, UploadedPart{Number: part.PartNumber, Size: part.ContentLength, SHA1: part.ContentSHA1})
+		}
+
+		next = 0
+		if resp.NextPartNumber != nil {
+			next = *resp.NextPartNumber
+		}
+	}
+	return parts, nil
+}
+
+// FinishLargeUpload commits a large upload from its parts. partSHA1s holds
+// each part's SHA1 in part number order, starting with part 1.
+func (c *Client) FinishLargeUpload(ctx context.Context, upload *LargeUpload, partSHA1s []string) (*UploadResult, error) {
+	req := map[string]interface{}{"fileId": upload.FileID, "partSha1Array": partSHA1s}
+	var resp struct {
+		FileName      string `json:"fileName"`
+		ContentLength int64  `json:"contentLength"`
+		ContentType   string `json:"contentType"`
+	}
+	if err := c.nativeCall(ctx, "b2_finish_large_file", req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to finish large upload: %w", err)
+	}
+
+	logUploaded(upload.BucketName, upload.ObjectName, resp.ContentLength, "")
+	return &UploadResult{
+		Name:        resp.FileName,
+		Size:        resp.ContentLength,
+		ContentType: resp.ContentType,
+	}, nil
+}
+
+// CancelLargeUpload abandons a large upload, deleting the parts stored so far
+func (c *Client) CancelLargeUpload(ctx context.Context, upload *LargeUpload) error {
+	if err := c.nativeCall(ctx, "b2_cancel_large_file", map[string]string{"fileId": upload.FileID}, nil); err != nil {
+		return fmt.Errorf("failed to cancel large upload: %w", err)
+	}
+	return nil
+}
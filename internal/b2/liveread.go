@@ -59,7 +59,7 @@ func (c *Client) LiveReadUpload(ctx context.Context, bucketName, objectName stri
 
 	var src io.Reader = reader
 	if opts.ProgressCallback != nil && size > 0 {
-		src = progress.NewReader(reader, size, opts.ProgressCallback)
+		src = progress.NewReaderThrottled(reader, size, opts.ProgressCallback, progress.DefaultInterval)
 	}
 
 	_, err = io.Copy(writer, src)
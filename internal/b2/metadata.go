@@ -0,0 +1,75 @@
+package b2
+
+import (
+	"fmt"
+	"strings"
+)
+
+// B2 file info limits (sent as X-Bz-Info-* headers)
+const (
+	maxFileInfoKeys      = 10
+	maxFileInfoKeyLength = 50
+	maxFileInfoBytes     = 7000
+)
+
+// ValidateFileInfo checks custom file metadata against B2's rules and
+// returns a copy with keys normalized to lowercase, as B2 stores them.
+func ValidateFileInfo(info map[string]string) (map[string]string, error) {
+	if len(info) == 0 {
+		return nil, nil
+	}
+	if len(info) > maxFileInfoKeys {
+		return nil, fmt.Errorf("too many metadata keys: %d (max %d)", len(info), maxFileInfoKeys)
+	}
+
+	normalized := make(map[string]string, len(info))
+	total := 0
+	for key, value := range info {
+		k := strings.ToLower(key)
+		if k == "" {
+			return nil, fmt.Errorf("metadata key cannot be empty")
+		}
+		if len(k) > maxFileInfoKeyLength {
+			return nil, fmt.Errorf("metadata key %q exceeds %d characters", key, maxFileInfoKeyLength)
+		}
+		for _, r := range k {
+			if !((r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_') {
+				return nil, fmt.Errorf("metadata key %q contains invalid character %q", key, r)
+			}
+		}
+		if strings.HasPrefix(k, "b2-") {
+			return nil, fmt.Errorf("metadata key %q uses reserved prefix \"b2-\"", key)
+		}
+		if _, dup := normalized[k]; dup {
+			return nil, fmt.Errorf("duplicate metadata key %q", k)
+		}
+
+		// Header name is sent as X-Bz-Info-<key>
+		total += len("X-Bz-Info-") + len(k) + len(value)
+		normalized[k] = value
+	}
+
+	if total > maxFileInfoBytes {
+		return nil, fmt.Errorf("metadata too large: %d bytes (max %d)", total, maxFileInfoBytes)
+	}
+
+	return normalized, nil
+}
+
+// ParseFileInfo parses "key=value" pairs into a metadata map
+func ParseFileInfo(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	info := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid metadata %q: must be in format key=value", pair)
+		}
+		info[key] = value
+	}
+
+	return ValidateFileInfo(info)
+}
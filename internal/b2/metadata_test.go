@@ -0,0 +1,59 @@
+package b2
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateFileInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    map[string]string
+		wantErr bool
+	}{
+		{"nil map", nil, false},
+		{"valid keys", map[string]string{"author": "me", "build_id": "42", "x-ref": "abc"}, false},
+		{"empty key", map[string]string{"": "v"}, true},
+		{"invalid character", map[string]string{"bad key": "v"}, true},
+		{"reserved prefix", map[string]string{"b2-content-disposition": "v"}, true},
+		{"key too long", map[string]string{strings.Repeat("k", 51): "v"}, true},
+		{"value too large", map[string]string{"big": strings.Repeat("v", 7000)}, true},
+		{"duplicate after lowercasing", map[string]string{"Author": "a", "author": "b"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateFileInfo(tt.info)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateFileInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateFileInfo_TooManyKeys(t *testing.T) {
+	info := make(map[string]string)
+	for i := 0; i < 11; i++ {
+		info[string(rune('a'+i))] = "v"
+	}
+	if _, err := ValidateFileInfo(info); err == nil {
+		t.Error("Expected error for more than 10 keys")
+	}
+}
+
+func TestParseFileInfo(t *testing.T) {
+	info, err := ParseFileInfo([]string{"Author=Jane", "note=a=b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info["author"] != "Jane" {
+		t.Errorf("Expected lowercased key 'author'=Jane, got %v", info)
+	}
+	if info["note"] != "a=b" {
+		t.Errorf("Expected value split on first '=', got %q", info["note"])
+	}
+
+	if _, err := ParseFileInfo([]string{"novalue"}); err == nil {
+		t.Error("Expected error for pair without '='")
+	}
+}
@@ -0,0 +1,51 @@
+package b2
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Bounds B2 places on download authorization lifetimes
+const (
+	MinAuthSeconds = 1
+	MaxAuthSeconds = 7 * 24 * 60 * 60
+)
+
+// GetDownloadAuthToken returns a token authorizing downloads of files under prefix.
+// The key must have the shareFiles capability.
+func (c *Client) GetDownloadAuthToken(ctx context.Context, bucketName, prefix string, validSeconds int) (string, error) {
+	if validSeconds < MinAuthSeconds || validSeconds > MaxAuthSeconds {
+		return "", fmt.Errorf("validity must be between %d and %d seconds", MinAuthSeconds, MaxAuthSeconds)
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	bucket, err := c.Bucket(ctx, bucketName)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := bucket.AuthToken(ctx, prefix, time.Duration(validSeconds)*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("failed to get download authorization: %w", accessError(err))
+	}
+	return token, nil
+}
+
+// PresignedURL returns a time-limited download URL for a single object
+func (c *Client) PresignedURL(ctx context.Context, bucketName, objectName string, validSeconds int) (string, error) {
+	token, err := c.GetDownloadAuthToken(ctx, bucketName, objectName, validSeconds)
+	if err != nil {
+		return "", err
+	}
+
+	bucket, err := c.Bucket(ctx, bucketName)
+	if err != nil {
+		return "", err
+	}
+
+	return bucket.Object(objectName).URL() + "?Authorization=" + url.QueryEscape(token), nil
+}
@@ -0,0 +1,446 @@
+package b2
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/pkg/checksum"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+	"github.com/ryanoboyle/bb-stream/pkg/logging"
+	"github.com/ryanoboyle/bb-stream/pkg/progress"
+	"github.com/ryanoboyle/bb-stream/pkg/retry"
+)
+
+// DefaultPartSize is the part size used when B2 doesn't recommend one
+const DefaultPartSize = 100 * 1000 * 1000
+
+// ResumableOptions configures UploadFileResumable
+type ResumableOptions struct {
+	// ContentType, Info, ConcurrentUploads, MaxBytesPerSec, Retry and
+	// ProgressCallback apply; compression, encryption and SHA256 checksums
+	// aren't supported, since parts must be reproducible byte for byte.
+	UploadOptions
+
+	// Manifest is the file recording the upload's progress between runs
+	Manifest string
+
+	// PartSize is the size of each part; 0 uses the size B2 recommends. A
+	// resumed upload keeps the part size it started with.
+	PartSize int64
+}
+
+// DefaultResumableOptions returns sensible defaults for a manifest path
+func DefaultResumableOptions(manifest string) *ResumableOptions {
+	return &ResumableOptions{
+		UploadOptions: *DefaultUploadOptions(),
+		Manifest:      manifest,
+	}
+}
+
+// uploadManifest records an unfinished resumable upload and the parts B2
+// has confirmed, along with the source's size and mtime when it started
+type uploadManifest struct {
+	FileID   string         `json:"file_id"`
+	Bucket   string         `json:"bucket"`
+	Name     string         `json:"name"`
+	Source   string         `json:"source"`
+	Size     int64          `json:"size"`
+	ModTime  time.Time      `json:"mod_time"`
+	PartSize int64          `json:"part_size"`
+	Parts    map[int]string `json:"parts"` // Part number to SHA1
+}
+
+// ManifestPath returns the manifest for a resumable upload of localPath to
+// bucketName/objectName, kept under dir (normally the config directory)
+func ManifestPath(dir, localPath, bucketName, objectName string) string {
+	if abs, err := filepath.Abs(localPath); err == nil {
+		localPath = abs
+	}
+	sum := sha1.Sum([]byte(localPath + "\x00" + bucketName + "\x00" + objectName))
+	return filepath.Join(dir, "uploads", hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadManifest reads a manifest, returning nil when there isn't one
+func loadManifest(path string) (*uploadManifest, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload manifest: %w", err)
+	}
+	var m uploadManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid upload manifest %s: %w", path, err)
+	}
+	if m.Parts == nil {
+		m.Parts = make(map[int]string)
+	}
+	return &m, nil
+}
+
+// save replaces the manifest in one step so a crash can't leave it half written
+func (m *uploadManifest) save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to save upload manifest: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save upload manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to save upload manifest: %w", err)
+	}
+	return nil
+}
+
+// drifted reports how the source no longer matches the manifest, or "" if it still does
+func (m *uploadManifest) drifted(stat os.FileInfo) string {
+	if stat.Size() != m.Size {
+		return fmt.Sprintf("size changed from %d to %d bytes", m.Size, stat.Size())
+	}
+	if !stat.ModTime().Equal(m.ModTime) {
+		return fmt.Sprintf("modified at %s, after the upload started", stat.ModTime().Format(time.RFC3339))
+	}
+	return ""
+}
+
+// UploadFileResumable uploads a local file as a B2 large file, recording each
+// confirmed part in opts.Manifest. If the upload is interrupted, calling it
+// again with the same manifest lists the parts B2 already has and sends only
+// the rest. If the file's size or mtime changed since the upload started, the
+// unfinished upload is cancelled and an error wrapping errors.ErrSourceChanged
+// is returned; the next call starts over. Files no larger than one part are
+// uploaded normally, since B2 large files need at least two parts.
+func (c *Client) UploadFileResumable(ctx context.Context, localPath, bucketName, objectName string, opts *ResumableOptions) (*UploadResult, error) {
+	if opts == nil || opts.Manifest == "" {
+		return nil, fmt.Errorf("resumable uploads need a manifest path")
+	}
+	if opts.Compress || opts.Encrypt || opts.ChecksumAlgorithm == checksum.SHA256 {
+		return nil, fmt.Errorf("compression, encryption and SHA256 checksums aren't supported for resumable uploads")
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	m, err := loadManifest(opts.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil && (m.Bucket != bucketName || m.Name != objectName) {
+		return nil, fmt.Errorf("manifest %s belongs to an upload to %s/%s", opts.Manifest, m.Bucket, m.Name)
+	}
+
+	var remote map[int]UploadedPart
+	if m != nil {
+		upload := &LargeUpload{FileID: m.FileID, BucketName: bucketName, ObjectName: objectName}
+		if reason := m.drifted(stat); reason != "" {
+			return nil, c.abandonUpload(ctx, upload, opts.Manifest, reason)
+		}
+		if remote, err = c.remoteParts(ctx, upload); uploadGone(err) {
+			// The unfinished file was cancelled or finished elsewhere, so start
+			// again, cancelling it in case B2 still holds its parts
+			logging.Logger().Warn("unfinished upload is gone, starting over",
+				logging.Bucket(bucketName), logging.Object(objectName))
+			if err := c.CancelLargeUpload(ctx, upload); err != nil && !uploadGone(err) {
+				logging.Logger().Warn("failed to cancel unfinished upload",
+					logging.Bucket(bucketName), logging.Object(objectName), logging.Err(err))
+			}
+			m, remote = nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	partSize := opts.PartSize
+	if m != nil {
+		partSize = m.PartSize
+	} else if partSize, err = c.choosePartSize(ctx, stat.Size(), partSize); err != nil {
+		return nil, err
+	}
+
+	if stat.Size() <= partSize {
+		result, err := c.UploadWithResult(ctx, bucketName, objectName, f, stat.Size(), &opts.UploadOptions)
+		if err == nil {
+			_ = os.Remove(opts.Manifest)
+		}
+		return result, err
+	}
+
+	if m == nil {
+		// B2 stores the whole file's SHA1 only when it's given up front
+		sum := sha1.New()
+		if _, err := io.Copy(sum, io.NewSectionReader(f, 0, stat.Size())); err != nil {
+			return nil, fmt.Errorf("failed to hash file: %w", err)
+		}
+		startOpts := opts.UploadOptions
+		startOpts.Info = maps.Clone(opts.Info)
+		if startOpts.Info == nil {
+			startOpts.Info = make(map[string]string)
+		}
+		startOpts.Info["large_file_sha1"] = hex.EncodeToString(sum.Sum(nil))
+
+		upload, err := c.StartLargeUpload(ctx, bucketName, objectName, &startOpts)
+		if err != nil {
+			return nil, err
+		}
+		m = &uploadManifest{
+			FileID:   upload.FileID,
+			Bucket:   bucketName,
+			Name:     objectName,
+			Source:   localPath,
+			Size:     stat.Size(),
+			ModTime:  stat.ModTime(),
+			PartSize: partSize,
+			Parts:    make(map[int]string),
+		}
+		if err := m.save(opts.Manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	upload := &LargeUpload{FileID: m.FileID, BucketName: bucketName, ObjectName: objectName}
+	if err := c.uploadParts(ctx, f, upload, m, remote, opts); err != nil {
+		return nil, err
+	}
+
+	// Parts read from a file that changed underneath would commit a mix of versions
+	if stat, err = os.Stat(localPath); err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if reason := m.drifted(stat); reason != "" {
+		return nil, c.abandonUpload(ctx, upload, opts.Manifest, reason)
+	}
+
+	shas := make([]string, partCount(m.Size, m.PartSize))
+	for i := range shas {
+		shas[i] = m.Parts[i+1]
+	}
+	result, err := c.FinishLargeUpload(ctx, upload, shas)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(opts.Manifest); err != nil && !os.IsNotExist(err) {
+		logging.Logger().Warn("failed to remove upload manifest", logging.Path(opts.Manifest), logging.Err(err))
+	}
+	return result, nil
+}
+
+// remoteParts returns the parts B2 has for upload, by part number
+func (c *Client) remoteParts(ctx context.Context, upload *LargeUpload) (map[int]UploadedPart, error) {
+	parts, err := c.ListParts(ctx, upload)
+	if err != nil {
+		return nil, err
+	}
+	byNumber := make(map[int]UploadedPart, len(parts))
+	for _, p := range parts {
+		byNumber[p.Number] = p
+	}
+	return byNumber, nil
+}
+
+// uploadGone reports whether a large file lookup failed because B2 no longer
+// has it as unfinished. Other 400s, such as malformed requests, are errors.
+func uploadGone(err error) bool {
+	var apiErr *apiError
+	if !stderrors.As(err, &apiErr) {
+		return errors.IsNotFound(err)
+	}
+	switch apiErr.Code {
+	case "not_found", "file_not_present", "bad_bucket_id":
+		return true
+	}
+	return apiErr.Status == http.StatusNotFound
+}
+
+// abandonUpload cancels an upload whose source changed and removes its manifest
+func (c *Client) abandonUpload(ctx context.Context, upload *LargeUpload, manifest, reason string) error {
+	if err := c.CancelLargeUpload(ctx, upload); err != nil && !uploadGone(err) {
+		logging.Logger().Warn("failed to cancel unfinished upload",
+			logging.Bucket(upload.BucketName), logging.Object(upload.ObjectName), logging.Err(err))
+	}
+	if err := os.Remove(manifest); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove upload manifest: %w", err)
+	}
+	return fmt.Errorf("%w: %s; the unfinished upload was cancelled, run it again to start over",
+		errors.ErrSourceChanged, reason)
+}
+
+// choosePartSize picks the part size for a new upload of size bytes: the
+// requested size, else B2's recommendation, raised if needed to stay within MaxParts
+func (c *Client) choosePartSize(ctx context.Context, size, requested int64) (int64, error) {
+	auth, err := c.nativeSession(ctx)
+	if err != nil {
+		return 0, err
+	}
+	partSize := requested
+	if partSize <= 0 {
+		partSize = auth.RecommendedPartSize
+	}
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+	if auth.MinimumPartSize > 0 && partSize < auth.MinimumPartSize {
+		return 0, fmt.Errorf("part size %d is below B2's minimum of %d bytes", partSize, auth.MinimumPartSize)
+	}
+	partSize = max(partSize, (size+MaxParts-1)/MaxParts)
+	if partSize > MaxPartSize {
+		return 0, fmt.Errorf("file is too large: %d bytes needs parts over B2's %d byte limit", size, int64(MaxPartSize))
+	}
+	return partSize, nil
+}
+
+// partCount returns the number of parts of partSize needed for size bytes
+func partCount(size, partSize int64) int {
+	return int((size + partSize - 1) / partSize)
+}
+
+// uploadParts sends every part the manifest doesn't have yet, recording each
+// in the manifest as B2 confirms it. Parts B2 already holds with the same
+// SHA1 are recorded without being sent again.
+func (c *Client) uploadParts(ctx context.Context, f *os.File, upload *LargeUpload, m *uploadManifest, remote map[int]UploadedPart, opts *ResumableOptions) error {
+	count := partCount(m.Size, m.PartSize)
+	workers := max(min(opts.ConcurrentUploads, count), 1)
+
+	rate := opts.MaxBytesPerSec
+	if rate > 0 {
+		rate = max(rate/int64(workers), 1)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex // Guards m, transferred, firstErr and the progress callback
+	var transferred int64
+	var firstErr error
+	report := func(n int64) {
+		transferred += n
+		if opts.ProgressCallback != nil {
+			opts.ProgressCallback(transferred, m.Size)
+		}
+	}
+
+	var pending []int
+	mu.Lock()
+	for n := 1; n <= count; n++ {
+		if sha, ok := m.Parts[n]; ok && remote[n].SHA1 == sha {
+			report(partLength(m, n))
+			continue
+		}
+		delete(m.Parts, n)
+		pending = append(pending, n)
+	}
+	mu.Unlock()
+
+	parts := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			uploader := c.NewPartUploader(upload)
+			for n := range parts {
+				sha, err := c.sendPart(ctx, uploader, f, m, n, remote[n], rate, opts, func(delta int64) {
+					mu.Lock()
+					report(delta)
+					mu.Unlock()
+				})
+				mu.Lock()
+				if err == nil {
+					m.Parts[n] = sha
+					err = m.save(opts.Manifest)
+				}
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, n := range pending {
+		select {
+		case parts <- n:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(parts)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// partLength returns the size of part n
+func partLength(m *uploadManifest, n int) int64 {
+	return min(m.PartSize, m.Size-int64(n-1)*m.PartSize)
+}
+
+// sendPart hashes part n and uploads it, retrying transient failures, unless
+// B2 already holds identical bytes for it. It returns the part's SHA1.
+func (c *Client) sendPart(ctx context.Context, uploader *PartUploader, f *os.File, m *uploadManifest, n int, existing UploadedPart, rate int64, opts *ResumableOptions, report func(int64)) (string, error) {
+	offset, length := int64(n-1)*m.PartSize, partLength(m, n)
+
+	hash := sha1.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(f, offset, length)); err != nil {
+		return "", fmt.Errorf("failed to read part %d: %w", n, err)
+	}
+	sha := hex.EncodeToString(hash.Sum(nil))
+
+	// Uploaded by an earlier run that stopped before recording it
+	if existing.SHA1 == sha && existing.Size == length {
+		report(length)
+		return sha, nil
+	}
+
+	err := retry.DoCtx(ctx, LogRetries(opts.Retry, "upload_part"), IsRetryable, func(ctx context.Context) error {
+		var sent int64
+		var src io.Reader = io.NewSectionReader(f, offset, length)
+		if rate > 0 {
+			src = progress.NewRateLimitedReader(ctx, src, rate)
+		}
+		src = progress.NewReader(src, length, func(transferred, _ int64) {
+			report(transferred - sent)
+			sent = transferred
+		})
+
+		err := uploader.UploadPart(ctx, n, src, length, sha)
+		if err != nil {
+			// The retry sends the whole part again
+			report(-sent)
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return sha, nil
+}
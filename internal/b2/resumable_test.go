@@ -0,0 +1,371 @@
+package b2
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+	"github.com/ryanoboyle/bb-stream/pkg/retry"
+)
+
+// fakeLargeFile is an unfinished large file held by fakeB2
+type fakeLargeFile struct {
+	name    string
+	info    map[string]interface{}
+	started time.Time
+	parts   map[int][]byte
+}
+
+// fakeB2 serves the native large file API from memory
+type fakeB2 struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	unfinished map[string]*fakeLargeFile
+	finished   map[string][]byte // Object name to contents
+	uploads    map[int]int       // Part number to times it was uploaded
+	nextID     int
+	failFrom   int         // Reject parts from this number on; 0 accepts all
+	failOnce   map[int]int // Part number to a status to return on its first upload
+	sessions   int         // Times an account was authorized
+	cancels    int         // Calls to b2_cancel_large_file
+	token      string      // The token API calls must carry; "" rejects every token
+}
+
+func newFakeB2(t *testing.T) *fakeB2 {
+	t.Helper()
+	f := &fakeB2{
+		unfinished: make(map[string]*fakeLargeFile),
+		finished:   make(map[string][]byte),
+		uploads:    make(map[int]int),
+		failOnce:   make(map[int]int),
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+
+	prev := b2APIAuthURL
+	b2APIAuthURL = f.URL + "/b2api/v2/b2_authorize_account"
+	t.Cleanup(func() { b2APIAuthURL = prev })
+	return f
+}
+
+func (f *fakeB2) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reply := func(v interface{}) { _ = json.NewEncoder(w).Encode(v) }
+	fail := func(status int, msg string) {
+		w.WriteHeader(status)
+		reply(map[string]string{"code": "error", "message": msg})
+	}
+	// B2 answers an unknown or finished large file ID like this
+	notPresent := func() {
+		w.WriteHeader(http.StatusBadRequest)
+		reply(map[string]string{"code": "file_not_present", "message": "no such file"})
+	}
+
+	if id, ok := strings.CutPrefix(r.URL.Path, "/upload/"); ok {
+		file := f.unfinished[id]
+		number, _ := strconv.Atoi(r.Header.Get("X-Bz-Part-Number"))
+		data, _ := io.ReadAll(r.Body)
+		sum := sha1.Sum(data)
+		switch {
+		case file == nil:
+			notPresent()
+		case f.failFrom > 0 && number >= f.failFrom:
+			fail(http.StatusForbidden, "rejected")
+		case f.failOnce[number] != 0:
+			fail(f.failOnce[number], "try again")
+			delete(f.failOnce, number)
+		case hex.EncodeToString(sum[:]) != r.Header.Get("X-Bz-Content-Sha1"):
+			fail(http.StatusBadRequest, "sha1 mismatch")
+		default:
+			file.parts[number] = data
+			f.uploads[number]++
+			reply(map[string]interface{}{"partNumber": number})
+		}
+		return
+	}
+
+	var req map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	fileID, _ := req["fileId"].(string)
+
+	method := strings.TrimPrefix(r.URL.Path, "/b2api/v2/")
+	if method != "b2_authorize_account" && r.Header.Get("Authorization") != f.token {
+		fail(http.StatusUnauthorized, "expired token")
+		return
+	}
+
+	switch method {
+	case "b2_authorize_account":
+		f.sessions++
+		f.token = fmt.Sprintf("token-%d", f.sessions)
+		reply(map[string]interface{}{
+			"accountId": "acct", "apiUrl": f.URL, "authorizationToken": f.token,
+			"recommendedPartSize": 10, "absoluteMinimumPartSize": 5,
+		})
+	case "b2_list_buckets":
+		reply(map[string]interface{}{"buckets": []map[string]string{{"bucketId": "b1", "bucketName": "my-bucket"}}})
+	case "b2_start_large_file":
+		f.nextID++
+		id := fmt.Sprintf("file-%d", f.nextID)
+		info, _ := req["fileInfo"].(map[string]interface{})
+		f.unfinished[id] = &fakeLargeFile{name: req["fileName"].(string), info: info, started: time.Now(), parts: make(map[int][]byte)}
+		reply(map[string]string{"fileId": id})
+	case "b2_get_upload_part_url":
+		reply(map[string]string{"uploadUrl": f.URL + "/upload/" + fileID, "authorizationToken": "part-token"})
+	case "b2_list_parts":
+		file := f.unfinished[fileID]
+		if file == nil {
+			notPresent()
+			return
+		}
+		var parts []map[string]interface{}
+		for n, data := range file.parts {
+			sum := sha1.Sum(data)
+			parts = append(parts, map[string]interface{}{
+				"partNumber": n, "contentLength": len(data), "contentSha1": hex.EncodeToString(sum[:]),
+			})
+		}
+		sort.Slice(parts, func(i, j int) bool { return parts[i]["partNumber"].(int) < parts[j]["partNumber"].(int) })
+		reply(map[string]interface{}{"parts": parts, "nextPartNumber": nil})
+	case "b2_finish_large_file":
+		file := f.unfinished[fileID]
+		if file == nil {
+			notPresent()
+			return
+		}
+		var data []byte
+		for i, want := range req["partSha1Array"].([]interface{}) {
+			part := file.parts[i+1]
+			if sum := sha1.Sum(part); hex.EncodeToString(sum[:]) != want {
+				fail(http.StatusBadRequest, fmt.Sprintf("part %d sha1 mismatch", i+1))
+				return
+			}
+			data = append(data, part...)
+		}
+		delete(f.unfinished, fileID)
+		f.finished[file.name] = data
+		reply(map[string]interface{}{"fileName": file.name, "contentLength": len(data), "contentType": "application/octet-stream"})
+	case "b2_list_unfinished_large_files":
+		var files []map[string]interface{}
+		for id, file := range f.unfinished {
+			files = append(files, map[string]interface{}{
+				"fileId": id, "fileName": file.name, "uploadTimestamp": file.started.UnixMilli(),
+			})
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i]["fileId"].(string) < files[j]["fileId"].(string) })
+		reply(map[string]interface{}{"files": files, "nextFileId": nil})
+	case "b2_cancel_large_file":
+		f.cancels++
+		if f.unfinished[fileID] == nil {
+			notPresent()
+			return
+		}
+		delete(f.unfinished, fileID)
+		reply(map[string]string{"fileId": fileID})
+	default:
+		fail(http.StatusNotFound, "unknown method")
+	}
+}
+
+func resumableTestOptions(manifest string) *ResumableOptions {
+	opts := DefaultResumableOptions(manifest)
+	opts.ConcurrentUploads = 1
+	opts.Retry = &retry.Config{MaxAttempts: 3, InitialWait: time.Millisecond, MaxWait: time.Millisecond, Multiplier: 1}
+	return opts
+}
+
+func TestUploadFileResumable_Resume(t *testing.T) {
+	fake := newFakeB2(t)
+	fake.failFrom = 4
+	fake.failOnce[2] = http.StatusServiceUnavailable
+	client := &Client{keyID: "id", appKey: "key"}
+
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("0123456789"), 5)
+	data = append(data, "tail"...)
+	src := filepath.Join(dir, "big.bin")
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := filepath.Join(dir, "manifest.json")
+	ctx := context.Background()
+
+	// The first run stores parts 1-3, retrying the transient failure on part 2
+	if _, err := client.UploadFileResumable(ctx, src, "my-bucket", "big.bin", resumableTestOptions(manifest)); err == nil {
+		t.Fatal("Expected the first run to fail")
+	}
+	m, err := loadManifest(manifest)
+	if err != nil || m == nil || len(m.Parts) != 3 || m.PartSize != 10 {
+		t.Fatalf("Expected a manifest with 3 parts of 10 bytes, got %+v, %v", m, err)
+	}
+
+	fake.failFrom = 0
+	var reported int64
+	opts := resumableTestOptions(manifest)
+	opts.ConcurrentUploads = 2
+	opts.ProgressCallback = func(transferred, total int64) { reported = transferred }
+	result, err := client.UploadFileResumable(ctx, src, "my-bucket", "big.bin", opts)
+	if err != nil {
+		t.Fatalf("Resumed upload failed: %v", err)
+	}
+	if result.Size != int64(len(data)) || !bytes.Equal(fake.finished["big.bin"], data) {
+		t.Errorf("Stored %q, want %q", fake.finished["big.bin"], data)
+	}
+	for n := 1; n <= 6; n++ {
+		if fake.uploads[n] != 1 {
+			t.Errorf("Part %d uploaded %d times, want once", n, fake.uploads[n])
+		}
+	}
+	if reported != int64(len(data)) {
+		t.Errorf("Expected progress to reach %d bytes, got %d", len(data), reported)
+	}
+	if _, err := os.Stat(manifest); !os.IsNotExist(err) {
+		t.Errorf("Expected the manifest to be removed, got %v", err)
+	}
+}
+
+func TestUploadFileResumable_SourceChanged(t *testing.T) {
+	fake := newFakeB2(t)
+	fake.failFrom = 2
+	client := &Client{keyID: "id", appKey: "key"}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "big.bin")
+	if err := os.WriteFile(src, bytes.Repeat([]byte("x"), 35), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := filepath.Join(dir, "manifest.json")
+	ctx := context.Background()
+
+	if _, err := client.UploadFileResumable(ctx, src, "my-bucket", "big.bin", resumableTestOptions(manifest)); err == nil {
+		t.Fatal("Expected the first run to fail")
+	}
+	if len(fake.unfinished) != 1 {
+		t.Fatalf("Expected an unfinished upload, got %d", len(fake.unfinished))
+	}
+
+	if err := os.WriteFile(src, bytes.Repeat([]byte("y"), 40), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fake.failFrom = 0
+	_, err := client.UploadFileResumable(ctx, src, "my-bucket", "big.bin", resumableTestOptions(manifest))
+	if !stderrors.Is(err, errors.ErrSourceChanged) {
+		t.Fatalf("Expected ErrSourceChanged, got %v", err)
+	}
+	if len(fake.unfinished) != 0 {
+		t.Errorf("Expected the unfinished upload to be cancelled")
+	}
+	if _, err := os.Stat(manifest); !os.IsNotExist(err) {
+		t.Errorf("Expected the manifest to be removed, got %v", err)
+	}
+
+	// The next run starts over with the new contents
+	if _, err := client.UploadFileResumable(ctx, src, "my-bucket", "big.bin", resumableTestOptions(manifest)); err != nil {
+		t.Fatalf("Fresh upload failed: %v", err)
+	}
+	if got := fake.finished["big.bin"]; !bytes.Equal(got, bytes.Repeat([]byte("y"), 40)) {
+		t.Errorf("Stored %q after starting over", got)
+	}
+}
+
+func TestUploadFileResumable_GoneStartsOver(t *testing.T) {
+	fake := newFakeB2(t)
+	fake.failFrom = 3
+	client := &Client{keyID: "id", appKey: "key"}
+
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("abcdefghij"), 4)
+	src := filepath.Join(dir, "big.bin")
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := filepath.Join(dir, "manifest.json")
+	ctx := context.Background()
+
+	if _, err := client.UploadFileResumable(ctx, src, "my-bucket", "big.bin", resumableTestOptions(manifest)); err == nil {
+		t.Fatal("Expected the first run to fail")
+	}
+	sum := sha1.Sum(data)
+	for _, file := range fake.unfinished {
+		if got := file.info["large_file_sha1"]; got != hex.EncodeToString(sum[:]) {
+			t.Errorf("Expected large_file_sha1 %x, got %v", sum, got)
+		}
+	}
+
+	// Cancelled elsewhere, so B2 no longer knows the manifest's file ID
+	clear(fake.unfinished)
+	fake.failFrom = 0
+	if _, err := client.UploadFileResumable(ctx, src, "my-bucket", "big.bin", resumableTestOptions(manifest)); err != nil {
+		t.Fatalf("Expected the upload to start over, got %v", err)
+	}
+	if !bytes.Equal(fake.finished["big.bin"], data) {
+		t.Errorf("Stored %q, want %q", fake.finished["big.bin"], data)
+	}
+	if fake.cancels != 1 {
+		t.Errorf("Expected the gone upload to be cancelled once, got %d calls", fake.cancels)
+	}
+}
+
+func TestUploadGone(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"file not present", &apiError{Status: 400, Code: "file_not_present"}, true},
+		{"bad bucket", &apiError{Status: 400, Code: "bad_bucket_id"}, true},
+		{"not found", &apiError{Status: 404, Code: "not_found"}, true},
+		{"wrapped", fmt.Errorf("failed to list parts: %w", &apiError{Status: 400, Code: "file_not_present"}), true},
+		{"bad request", &apiError{Status: 400, Code: "bad_request"}, false},
+		{"unauthorized", &apiError{Status: 401, Code: "expired_auth_token"}, false},
+		{"other not found", errors.ErrNotFound, true},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := uploadGone(tt.err); got != tt.want {
+			t.Errorf("%s: uploadGone(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestChoosePartSize(t *testing.T) {
+	newFakeB2(t)
+	client := &Client{keyID: "id", appKey: "key"}
+	ctx := context.Background()
+
+	tests := []struct {
+		size, requested, want int64
+		wantErr               bool
+	}{
+		{100, 0, 10, false},                           // B2's recommendation
+		{100, 20, 20, false},                          // Requested size
+		{100, 2, 0, true},                             // Below B2's minimum
+		{MaxParts * 10 * 3, 10, 30, false},            // Raised to stay within MaxParts
+		{MaxParts*int64(MaxPartSize) + 1, 0, 0, true}, // Too large for any part size
+	}
+	for _, tt := range tests {
+		got, err := client.choosePartSize(ctx, tt.size, tt.requested)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("choosePartSize(%d, %d) = %d, %v; want %d", tt.size, tt.requested, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package b2
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Backblaze/blazer/b2"
+	"github.com/Backblaze/blazer/base"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+	"github.com/ryanoboyle/bb-stream/pkg/logging"
+	"github.com/ryanoboyle/bb-stream/pkg/retry"
+)
+
+// IsRetryable reports whether a B2 operation that failed with err is worth retrying.
+// Network failures and 5xx/429 responses are transient; auth failures, missing
+// objects and local path errors are not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if stderrors.Is(err, context.Canceled) || stderrors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if b2.IsNotExist(err) || stderrors.Is(err, errors.ErrBucketNotFound) || stderrors.Is(err, errors.ErrObjectNotFound) {
+		return false
+	}
+
+	// Local file system errors won't fix themselves
+	var pathErr *os.PathError
+	if stderrors.As(err, &pathErr) {
+		return false
+	}
+
+	// HTTP status from the B2 API, if present anywhere in the chain
+	if code := statusCode(err); code != 0 {
+		return code >= 500 || code == 408 || code == 429
+	}
+
+	var netErr net.Error
+	if stderrors.As(err, &netErr) {
+		return true
+	}
+	if stderrors.Is(err, io.ErrUnexpectedEOF) ||
+		stderrors.Is(err, syscall.ECONNRESET) ||
+		stderrors.Is(err, syscall.ECONNREFUSED) ||
+		stderrors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	errStr := strings.ToLower(err.Error())
+	for _, s := range []string{"connection reset", "broken pipe", "timeout", "temporarily unavailable"} {
+		if strings.Contains(errStr, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// statusCode walks the error chain looking for a B2 API status code
+func statusCode(err error) int {
+	for err != nil {
+		if code, _ := base.Code(err); code != 0 {
+			return code
+		}
+		if apiErr, ok := err.(*apiError); ok {
+			return apiErr.Status
+		}
+		err = stderrors.Unwrap(err)
+	}
+	return 0
+}
+
+// LogRetries returns a copy of cfg whose OnRetry hook logs each retry of op.
+// Any hook already set on cfg is still called.
+func LogRetries(cfg *retry.Config, op string) *retry.Config {
+	if cfg == nil {
+		cfg = retry.DefaultConfig()
+	}
+	logged := *cfg
+	prev := cfg.OnRetry
+	logged.OnRetry = func(attempt int, err error, nextWait time.Duration) {
+		logging.Logger().Warn("retrying operation",
+			logging.Operation(op),
+			slog.Int("attempt", attempt),
+			logging.Err(err),
+			slog.Duration("next_wait", nextWait),
+		)
+		if prev != nil {
+			prev(attempt, err, nextWait)
+		}
+	}
+	return &logged
+}
+
+// bucketWithRetry resolves a bucket, retrying transient failures
+func (c *Client) bucketWithRetry(ctx context.Context, name string, cfg *retry.Config) (*b2.Bucket, error) {
+	return retry.DoWithResult(ctx, LogRetries(cfg, "bucket"), IsRetryable, func() (*b2.Bucket, error) {
+		return c.Bucket(ctx, name)
+	})
+}
@@ -0,0 +1,65 @@
+package b2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	apperrors "github.com/ryanoboyle/bb-stream/pkg/errors"
+	"github.com/ryanoboyle/bb-stream/pkg/retry"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"network error", &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}, true},
+		{"connection reset", fmt.Errorf("failed to upload: %w", syscall.ECONNRESET), true},
+		{"unexpected EOF", fmt.Errorf("failed to download: %w", io.ErrUnexpectedEOF), true},
+		{"context canceled", context.Canceled, false},
+		{"deadline exceeded", fmt.Errorf("op: %w", context.DeadlineExceeded), false},
+		{"path error", &os.PathError{Op: "open", Path: "/tmp/missing", Err: os.ErrNotExist}, false},
+		{"bucket not found", fmt.Errorf("bucket %q: %w", "missing", apperrors.ErrBucketNotFound), false},
+		{"generic error", errors.New("something went wrong"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogRetries_KeepsExistingHook(t *testing.T) {
+	called := 0
+	cfg := &retry.Config{
+		MaxAttempts: 2,
+		InitialWait: time.Millisecond,
+		MaxWait:     time.Millisecond,
+		Multiplier:  2.0,
+		OnRetry:     func(int, error, time.Duration) { called++ },
+	}
+
+	logged := LogRetries(cfg, "test")
+	if logged == cfg {
+		t.Fatal("LogRetries should return a copy")
+	}
+
+	_ = retry.Do(context.Background(), logged, nil, func() error {
+		return errors.New("transient")
+	})
+	if called != 1 {
+		t.Errorf("existing hook called %d times, want 1", called)
+	}
+}
@@ -0,0 +1,93 @@
+package b2
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultSearchLimit is how many matches a search returns when no limit is given
+	DefaultSearchLimit = 100
+	// MaxSearchLimit caps the matches returned by one search call
+	MaxSearchLimit = 1000
+)
+
+// SearchResult is one page of search matches
+type SearchResult struct {
+	Objects []ObjectInfo
+	// Cursor resumes the search after the last match; empty when there are no more
+	Cursor string
+}
+
+// objectWalker is a store that can stream its listing, letting a search stop
+// as soon as it has a full page
+type objectWalker interface {
+	WalkObjects(ctx context.Context, bucketName, prefix string, fn func(ObjectInfo) error) error
+}
+
+// objectSearcher is a store that can run the search itself, such as a client
+// of a server that does the scan
+type objectSearcher interface {
+	SearchObjects(ctx context.Context, bucketName, prefix, query, cursor string, limit int) (*SearchResult, error)
+}
+
+// errSearchDone stops a walk once a page of matches has been found
+var errSearchDone = stderrors.New("search page complete")
+
+// SearchObjects returns up to limit objects under prefix whose names contain
+// query, ignoring case. B2 has no search API, so this scans the listing in
+// name order; the listing already carries each object's size and timestamps,
+// so no per-object requests are made. Pass the previous result's Cursor to
+// continue where it stopped. The scan restarts from the beginning of prefix
+// each call, so narrowing prefix is what keeps searches of large buckets fast.
+func SearchObjects(ctx context.Context, s Storage, bucketName, prefix, query, cursor string, limit int) (*SearchResult, error) {
+	if query == "" {
+		return nil, fmt.Errorf("search query cannot be empty")
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+	if searcher, ok := s.(objectSearcher); ok {
+		return searcher.SearchObjects(ctx, bucketName, prefix, query, cursor, limit)
+	}
+	needle := strings.ToLower(query)
+
+	result := &SearchResult{Objects: []ObjectInfo{}}
+	more := false
+	match := func(obj ObjectInfo) error {
+		if obj.Name <= cursor || !strings.Contains(strings.ToLower(obj.Name), needle) {
+			return nil
+		}
+		// One match past the page means there's another page
+		if len(result.Objects) == limit {
+			more = true
+			return errSearchDone
+		}
+		result.Objects = append(result.Objects, obj)
+		return nil
+	}
+
+	if walker, ok := s.(objectWalker); ok {
+		if err := walker.WalkObjects(ctx, bucketName, prefix, match); err != nil && !stderrors.Is(err, errSearchDone) {
+			return nil, err
+		}
+	} else {
+		objects, err := s.ListObjects(ctx, bucketName, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			if match(obj) != nil {
+				break
+			}
+		}
+	}
+
+	if more {
+		result.Cursor = result.Objects[len(result.Objects)-1].Name
+	}
+	return result, nil
+}
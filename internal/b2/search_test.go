@@ -0,0 +1,93 @@
+package b2
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// listStore serves a fixed, name-ordered listing
+type listStore struct {
+	Storage
+	objects []ObjectInfo
+}
+
+func (s *listStore) ListObjects(ctx context.Context, bucketName, prefix string) ([]ObjectInfo, error) {
+	return s.objects, nil
+}
+
+// walkStore streams the listing and counts how much of it was read
+type walkStore struct {
+	listStore
+	visited int
+}
+
+func (s *walkStore) WalkObjects(ctx context.Context, bucketName, prefix string, fn func(ObjectInfo) error) error {
+	for _, obj := range s.objects {
+		s.visited++
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func searchNames(result *SearchResult) []string {
+	names := make([]string, len(result.Objects))
+	for i, obj := range result.Objects {
+		names[i] = obj.Name
+	}
+	return names
+}
+
+func TestSearchObjects(t *testing.T) {
+	store := &listStore{objects: []ObjectInfo{
+		{Name: "2024/Invoice-001.pdf"},
+		{Name: "2024/receipt.pdf"},
+		{Name: "2025/invoice-002.pdf"},
+		{Name: "2025/invoices/summary.txt"},
+	}}
+	ctx := context.Background()
+
+	result, err := SearchObjects(ctx, store, "bucket", "", "INVOICE", "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := searchNames(result); fmt.Sprint(got) != "[2024/Invoice-001.pdf 2025/invoice-002.pdf]" {
+		t.Errorf("Unexpected first page %v", got)
+	}
+	if result.Cursor != "2025/invoice-002.pdf" {
+		t.Fatalf("Expected a cursor after the last match, got %q", result.Cursor)
+	}
+
+	result, err = SearchObjects(ctx, store, "bucket", "", "invoice", result.Cursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := searchNames(result); fmt.Sprint(got) != "[2025/invoices/summary.txt]" || result.Cursor != "" {
+		t.Errorf("Unexpected last page %v, cursor %q", got, result.Cursor)
+	}
+
+	if _, err := SearchObjects(ctx, store, "bucket", "", "", "", 0); err == nil {
+		t.Error("Expected an error for an empty query")
+	}
+}
+
+func TestSearchObjects_StopsAtLimit(t *testing.T) {
+	store := &walkStore{}
+	for i := 0; i < 100; i++ {
+		store.objects = append(store.objects, ObjectInfo{Name: fmt.Sprintf("file-%03d.txt", i)})
+	}
+
+	result, err := SearchObjects(context.Background(), store, "bucket", "", "file", "", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Objects) != 5 || result.Cursor != "file-004.txt" {
+		t.Errorf("Unexpected page %v, cursor %q", searchNames(result), result.Cursor)
+	}
+	// The walk stops at the match after the page, rather than reading the rest
+	if store.visited != 6 {
+		t.Errorf("Expected the walk to stop after 6 objects, visited %d", store.visited)
+	}
+}
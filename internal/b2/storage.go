@@ -0,0 +1,37 @@
+package b2
+
+import (
+	"context"
+	"io"
+)
+
+// Storage is the object store the sync, watch and API layers work against.
+// *Client implements it on top of B2; other implementations let those layers
+// run against a different backend or entirely in memory.
+type Storage interface {
+	// Ping checks that the backend is reachable and the credentials work
+	Ping(ctx context.Context) error
+
+	ListBucketInfo(ctx context.Context) ([]BucketInfo, error)
+	CreateBucket(ctx context.Context, name, bucketType string) (*BucketInfo, error)
+	DeleteBucket(ctx context.Context, name string, force bool) error
+
+	ListObjects(ctx context.Context, bucketName, prefix string) ([]ObjectInfo, error)
+	GetObjectInfo(ctx context.Context, bucketName, objectName string) (*ObjectInfo, error)
+	DeleteObject(ctx context.Context, bucketName, objectName string) error
+	ListObjectVersions(ctx context.Context, bucketName, objectName string) ([]ObjectVersion, error)
+	DeleteVersion(ctx context.Context, bucketName, objectName, fileID string) error
+
+	Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts *UploadOptions) error
+	UploadWithResult(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts *UploadOptions) (*UploadResult, error)
+	StreamUpload(ctx context.Context, bucketName, objectName string, reader io.Reader, opts *UploadOptions) error
+	StreamUploadWithResult(ctx context.Context, bucketName, objectName string, reader io.Reader, opts *UploadOptions) (*UploadResult, error)
+
+	Download(ctx context.Context, bucketName, objectName string, writer io.Writer, opts *DownloadOptions) error
+	DownloadToFile(ctx context.Context, bucketName, objectName, localPath string, opts *DownloadOptions) (int64, error)
+	StreamDownload(ctx context.Context, bucketName, objectName string, writer io.Writer, opts *DownloadOptions) error
+
+	PresignedURL(ctx context.Context, bucketName, objectName string, validSeconds int) (string, error)
+}
+
+var _ Storage = (*Client)(nil)
@@ -0,0 +1,118 @@
+package b2
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Backblaze/blazer/b2"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+)
+
+// tagPrefix marks file info keys that hold tags, so project=alpha is stored
+// as tag-project=alpha alongside any other custom metadata
+const tagPrefix = "tag-"
+
+// tagsFromInfo extracts the tags from an object's file info
+func tagsFromInfo(info map[string]string) map[string]string {
+	tags := make(map[string]string)
+	for k, v := range info {
+		if name, ok := strings.CutPrefix(k, tagPrefix); ok {
+			tags[name] = v
+		}
+	}
+	return tags
+}
+
+// infoWithTags returns a copy of info with its tags replaced by tags.
+// Other metadata, including checksums and encryption parameters, is kept.
+func infoWithTags(info, tags map[string]string) (map[string]string, error) {
+	merged := make(map[string]string, len(info)+len(tags))
+	for k, v := range info {
+		if !strings.HasPrefix(k, tagPrefix) {
+			merged[k] = v
+		}
+	}
+	for k, v := range tags {
+		if k == "" {
+			return nil, fmt.Errorf("tag name cannot be empty")
+		}
+		merged[tagPrefix+strings.ToLower(k)] = v
+	}
+
+	validated, err := ValidateFileInfo(merged)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tags: %w", err)
+	}
+	return validated, nil
+}
+
+// GetObjectTags returns the tags stored in an object's metadata
+func (c *Client) GetObjectTags(ctx context.Context, bucketName, objectName string) (map[string]string, error) {
+	attrs, err := c.objectAttrs(ctx, bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	return tagsFromInfo(attrs.Info), nil
+}
+
+// SetObjectTags replaces an object's tags, keeping its other metadata.
+// B2 metadata can't be changed in place, so this makes a server-side copy of
+// the object onto itself: a new version holding the same bytes. The previous
+// version is kept and still billed until it's deleted or a lifecycle rule
+// hides it, and objects over 5GB can't be tagged.
+func (c *Client) SetObjectTags(ctx context.Context, bucketName, objectName string, tags map[string]string) error {
+	attrs, err := c.objectAttrs(ctx, bucketName, objectName)
+	if err != nil {
+		return err
+	}
+
+	info, err := infoWithTags(attrs.Info, tags)
+	if err != nil {
+		return err
+	}
+
+	return c.Copy(ctx, bucketName, objectName, objectName, &CopyOptions{
+		Directive:   MetadataReplace,
+		ContentType: attrs.ContentType,
+		Info:        info,
+	})
+}
+
+// ListByTag lists the objects under prefix tagged key=value, or carrying the
+// tag key with any value when value is empty. B2 can't query metadata, so
+// this reads the attributes of every object under prefix and filters client-side.
+func (c *Client) ListByTag(ctx context.Context, bucketName, prefix, key, value string) ([]ObjectInfo, error) {
+	infoKey := tagPrefix + strings.ToLower(key)
+	var objects []ObjectInfo
+	err := c.walkAttrs(ctx, bucketName, prefix, func(obj ObjectInfo, attrs *b2.Attrs) error {
+		if v, ok := attrs.Info[infoKey]; ok && (value == "" || v == value) {
+			objects = append(objects, obj)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// objectAttrs fetches an object's attributes, mapping a missing object to ErrObjectNotFound
+func (c *Client) objectAttrs(ctx context.Context, bucketName, objectName string) (*b2.Attrs, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	bucket, err := c.Bucket(ctx, bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := bucket.Object(objectName).Attrs(ctx)
+	if err != nil {
+		if b2.IsNotExist(err) {
+			return nil, fmt.Errorf("object %q: %w", objectName, errors.ErrObjectNotFound)
+		}
+		return nil, fmt.Errorf("failed to get object attributes: %w", accessError(err))
+	}
+	return attrs, nil
+}
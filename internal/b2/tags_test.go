@@ -0,0 +1,53 @@
+package b2
+
+import "testing"
+
+func TestTagsFromInfo(t *testing.T) {
+	tags := tagsFromInfo(map[string]string{
+		"tag-project": "alpha",
+		"tag-owner":   "ops",
+		"author":      "jane",
+		sha256Key:     "abc",
+	})
+	if len(tags) != 2 || tags["project"] != "alpha" || tags["owner"] != "ops" {
+		t.Errorf("Unexpected tags %v", tags)
+	}
+}
+
+func TestInfoWithTags(t *testing.T) {
+	info := map[string]string{
+		"tag-project": "alpha",
+		"tag-stale":   "yes",
+		"author":      "jane",
+		sha256Key:     "abc",
+	}
+
+	got, err := infoWithTags(info, map[string]string{"Project": "beta", "env": "prod"})
+	if err != nil {
+		t.Fatalf("infoWithTags failed: %v", err)
+	}
+	want := map[string]string{
+		"tag-project": "beta",
+		"tag-env":     "prod",
+		"author":      "jane",
+		sha256Key:     "abc",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+	if info["tag-project"] != "alpha" {
+		t.Error("infoWithTags modified its input")
+	}
+
+	if _, err := infoWithTags(nil, map[string]string{"bad key": "x"}); err == nil {
+		t.Error("Expected error for invalid tag name")
+	}
+	if _, err := infoWithTags(nil, map[string]string{"": "x"}); err == nil {
+		t.Error("Expected error for empty tag name")
+	}
+}
@@ -0,0 +1,141 @@
+package b2
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+)
+
+// DefaultUnfinishedAge is how old an unfinished upload must be before
+// cleanup cancels it, so uploads still in progress are left alone
+const DefaultUnfinishedAge = 24 * time.Hour
+
+// ParseAge parses a relative age such as "7d", "2w" or "12h".
+// Days and weeks are accepted in addition to time.ParseDuration units.
+func ParseAge(s string) (time.Duration, error) {
+	str := strings.TrimSpace(s)
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(str, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(str, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit > 0 {
+		value, err := strconv.ParseFloat(str[:len(str)-1], 64)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(value * float64(unit)), nil
+	}
+
+	d, err := time.ParseDuration(str)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// UnfinishedUpload is a large file that was started but never finished or
+// cancelled. B2 bills for the parts it holds until it's cancelled.
+type UnfinishedUpload struct {
+	FileID      string
+	Name        string
+	ContentType string
+	Timestamp   int64 // When the upload started, in Unix seconds
+}
+
+// ListUnfinishedUploads lists the large files in a bucket that were started
+// but never finished
+func (c *Client) ListUnfinishedUploads(ctx context.Context, bucketName string) ([]UnfinishedUpload, error) {
+	bucketID, err := c.bucketID(ctx, bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	var uploads []UnfinishedUpload
+	next := ""
+	for {
+		req := map[string]interface{}{"bucketId": bucketID, "maxFileCount": 100}
+		if next != "" {
+			req["startFileId"] = next
+		}
+		var resp struct {
+			Files []struct {
+				FileID          string `json:"fileId"`
+				FileName        string `json:"fileName"`
+				ContentType     string `json:"contentType"`
+				UploadTimestamp int64  `json:"uploadTimestamp"` // Milliseconds
+			} `json:"files"`
+			NextFileID *string `json:"nextFileId"`
+		}
+		if err := c.nativeCall(ctx, "b2_list_unfinished_large_files", req, &resp); err != nil {
+			return nil, fmt.Errorf("failed to list unfinished uploads: %w", err)
+		}
+		for _, f := range resp.Files {
+			uploads = append(uploads, UnfinishedUpload{
+				FileID:      f.FileID,
+				Name:        f.FileName,
+				ContentType: f.ContentType,
+				Timestamp:   f.UploadTimestamp / 1000,
+			})
+		}
+
+		if resp.NextFileID == nil || *resp.NextFileID == "" {
+			return uploads, nil
+		}
+		next = *resp.NextFileID
+	}
+}
+
+// CancelUnfinishedUpload cancels one unfinished upload in a bucket, deleting
+// its parts. It fails with ErrNotFound unless fileID is an unfinished upload
+// in that bucket, so a file ID can't reach into another bucket.
+func (c *Client) CancelUnfinishedUpload(ctx context.Context, bucketName, fileID string) error {
+	uploads, err := c.ListUnfinishedUploads(ctx, bucketName)
+	if err != nil {
+		return err
+	}
+	for _, u := range uploads {
+		if u.FileID == fileID {
+			return c.CancelLargeUpload(ctx, &LargeUpload{FileID: fileID, BucketName: bucketName, ObjectName: u.Name})
+		}
+	}
+	return fmt.Errorf("unfinished upload %s: %w", fileID, errors.ErrNotFound)
+}
+
+// CleanupUnfinished cancels the unfinished uploads in a bucket that started
+// more than olderThan ago, returning the ones cancelled. A resumable upload
+// whose file is cancelled starts over the next time it runs.
+func (c *Client) CleanupUnfinished(ctx context.Context, bucketName string, olderThan time.Duration) ([]UnfinishedUpload, error) {
+	uploads, err := c.ListUnfinishedUploads(ctx, bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled := []UnfinishedUpload{}
+	for _, u := range StartedBefore(uploads, olderThan) {
+		if err := c.CancelLargeUpload(ctx, &LargeUpload{FileID: u.FileID, BucketName: bucketName, ObjectName: u.Name}); err != nil {
+			return cancelled, fmt.Errorf("%s: %w", u.Name, err)
+		}
+		cancelled = append(cancelled, u)
+	}
+	return cancelled, nil
+}
+
+// StartedBefore returns the uploads that started more than olderThan ago,
+// the ones CleanupUnfinished cancels
+func StartedBefore(uploads []UnfinishedUpload, olderThan time.Duration) []UnfinishedUpload {
+	cutoff := time.Now().Add(-olderThan).Unix()
+	old := []UnfinishedUpload{}
+	for _, u := range uploads {
+		if u.Timestamp <= cutoff {
+			old = append(old, u)
+		}
+	}
+	return old
+}
@@ -0,0 +1,87 @@
+package b2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+)
+
+func TestCleanupUnfinished(t *testing.T) {
+	fake := newFakeB2(t)
+	client := &Client{keyID: "id", appKey: "key"}
+	ctx := context.Background()
+
+	for _, name := range []string{"old.bin", "other.bin", "recent.bin"} {
+		if _, err := client.StartLargeUpload(ctx, "my-bucket", name, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake.unfinished["file-1"].started = time.Now().Add(-48 * time.Hour)
+	fake.unfinished["file-2"].started = time.Now().Add(-30 * time.Hour)
+
+	uploads, err := client.ListUnfinishedUploads(ctx, "my-bucket")
+	if err != nil || len(uploads) != 3 {
+		t.Fatalf("ListUnfinishedUploads = %v, %v", uploads, err)
+	}
+	if uploads[0].Name != "old.bin" || uploads[0].Timestamp > time.Now().Add(-47*time.Hour).Unix() {
+		t.Errorf("Unexpected first upload %+v", uploads[0])
+	}
+
+	cancelled, err := client.CleanupUnfinished(ctx, "my-bucket", DefaultUnfinishedAge)
+	if err != nil {
+		t.Fatalf("CleanupUnfinished: %v", err)
+	}
+	if len(cancelled) != 2 || len(fake.unfinished) != 1 || fake.unfinished["file-3"] == nil {
+		t.Errorf("Expected the two old uploads cancelled, got %+v with %d left", cancelled, len(fake.unfinished))
+	}
+
+	if err := client.CancelUnfinishedUpload(ctx, "my-bucket", "file-1"); !errors.IsNotFound(err) {
+		t.Errorf("Expected not found for a cancelled upload, got %v", err)
+	}
+	if err := client.CancelUnfinishedUpload(ctx, "my-bucket", "file-3"); err != nil {
+		t.Errorf("CancelUnfinishedUpload: %v", err)
+	}
+	if len(fake.unfinished) != 0 {
+		t.Errorf("Expected no unfinished uploads left, got %d", len(fake.unfinished))
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"12h", 12 * time.Hour, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"0s", 0, false},
+		{"-1h", 0, true},
+		{"-2d", 0, true},
+		{"soon", 0, true},
+		{"d", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestStartedBefore(t *testing.T) {
+	now := time.Now()
+	uploads := []UnfinishedUpload{
+		{FileID: "old", Timestamp: now.Add(-48 * time.Hour).Unix()},
+		{FileID: "new", Timestamp: now.Add(-time.Hour).Unix()},
+	}
+	if got := StartedBefore(uploads, DefaultUnfinishedAge); len(got) != 1 || got[0].FileID != "old" {
+		t.Errorf("Expected only the old upload, got %+v", got)
+	}
+	if got := StartedBefore(uploads, 0); len(got) != 2 {
+		t.Errorf("Expected every upload for a zero age, got %+v", got)
+	}
+}
@@ -2,11 +2,19 @@ package b2
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
 
 	"github.com/Backblaze/blazer/b2"
+	"github.com/ryanoboyle/bb-stream/pkg/checksum"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+	"github.com/ryanoboyle/bb-stream/pkg/logging"
 	"github.com/ryanoboyle/bb-stream/pkg/progress"
+	"github.com/ryanoboyle/bb-stream/pkg/retry"
 )
 
 // UploadOptions configures an upload operation
@@ -14,7 +22,18 @@ type UploadOptions struct {
 	ContentType       string
 	ConcurrentUploads int
 	LiveRead          bool
+	Info              map[string]string // Custom file metadata (stored as X-Bz-Info-* headers)
+	Compress          bool              // Gzip the data and store it with Content-Encoding: gzip
+	Encrypt           bool              // Encrypt the data client-side with a key derived from Passphrase
+	Passphrase        string            // Encryption passphrase; required when Encrypt is set
+	MaxBytesPerSec    int64             // Bandwidth cap; 0 means unlimited
+	Retry             *retry.Config     // Retry policy for transient failures; nil uses retry.DefaultConfig()
 	ProgressCallback  progress.Callback
+
+	// ChecksumAlgorithm selects the hash recorded for later verification. B2
+	// always computes SHA1; SHA256 is hashed before uploading and stored as
+	// metadata, so it needs a seekable reader.
+	ChecksumAlgorithm checksum.Algorithm
 }
 
 // DefaultUploadOptions returns sensible defaults
@@ -23,56 +42,65 @@ func DefaultUploadOptions() *UploadOptions {
 		ContentType:       "application/octet-stream",
 		ConcurrentUploads: 4,
 		LiveRead:          false,
+		Retry:             retry.DefaultConfig(),
 	}
 }
 
-// Upload uploads data from a reader to B2
-func (c *Client) Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts *UploadOptions) error {
-	if opts == nil {
-		opts = DefaultUploadOptions()
-	}
+// sha256Key is the file info key holding a content SHA256 computed before upload
+const sha256Key = "bb-sha256"
 
-	bucket, err := c.Bucket(ctx, bucketName)
+// writerOptions builds Blazer writer options (content type and metadata) from upload options.
+// sha256 is the precomputed content hash to store, if any.
+// When encryption is enabled it also returns the object's fresh cipher.
+func writerOptions(opts *UploadOptions, sha256 string) ([]b2.WriterOption, *encryption, error) {
+	info, err := ValidateFileInfo(opts.Info)
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("invalid metadata: %w", err)
 	}
 
-	obj := bucket.Object(objectName)
-
-	// Create writer with attributes for content type
-	writerOpts := []b2.WriterOption{}
-	if opts.ContentType != "" {
-		writerOpts = append(writerOpts, b2.WithAttrsOption(&b2.Attrs{
-			ContentType: opts.ContentType,
-		}))
+	// Reserved keys describing how the stored bytes were transformed
+	reserved := make(map[string]string)
+	if sha256 != "" {
+		reserved[sha256Key] = sha256
 	}
-
-	writer := obj.NewWriter(ctx, writerOpts...)
-
-	// Configure upload options
-	if opts.ConcurrentUploads > 0 {
-		writer.ConcurrentUploads = opts.ConcurrentUploads
+	if opts.Compress {
+		reserved[contentEncodingKey] = "gzip"
 	}
-
-	// Wrap reader with progress tracking if callback provided
-	var src io.Reader = reader
-	if opts.ProgressCallback != nil && size > 0 {
-		src = progress.NewReader(reader, size, opts.ProgressCallback)
+	var enc *encryption
+	if opts.Encrypt {
+		if opts.Passphrase == "" {
+			return nil, nil, fmt.Errorf("encryption needs a passphrase (set %s)", PassphraseEnv)
+		}
+		var encInfo map[string]string
+		if enc, encInfo, err = newEncryption(opts.Passphrase); err != nil {
+			return nil, nil, err
+		}
+		maps.Copy(reserved, encInfo)
 	}
-
-	// Copy data to writer
-	_, err = io.Copy(writer, src)
-	if err != nil {
-		writer.Close() // Attempt to close on error
-		return fmt.Errorf("failed to upload: %w", err)
+	if len(reserved) > 0 {
+		if len(info)+len(reserved) > maxFileInfoKeys {
+			return nil, nil, fmt.Errorf("invalid metadata: checksums, compression and encryption need %d of the %d metadata keys", len(reserved), maxFileInfoKeys)
+		}
+		if info == nil {
+			info = make(map[string]string, len(reserved))
+		}
+		maps.Copy(info, reserved)
 	}
 
-	// Close the writer to finalize the upload
-	if err := writer.Close(); err != nil {
-		return fmt.Errorf("failed to finalize upload: %w", err)
+	writerOpts := []b2.WriterOption{}
+	if opts.ContentType != "" || len(info) > 0 {
+		writerOpts = append(writerOpts, b2.WithAttrsOption(&b2.Attrs{
+			ContentType: opts.ContentType,
+			Info:        info,
+		}))
 	}
+	return writerOpts, enc, nil
+}
 
-	return nil
+// Upload uploads data from a reader to B2
+func (c *Client) Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts *UploadOptions) error {
+	_, err := c.UploadWithResult(ctx, bucketName, objectName, reader, size, opts)
+	return err
 }
 
 // UploadReader is a simplified upload from an io.Reader
@@ -89,45 +117,94 @@ func (c *Client) UploadWithProgress(ctx context.Context, bucketName, objectName
 
 // StreamUpload handles streaming uploads from stdin or other unbounded readers
 func (c *Client) StreamUpload(ctx context.Context, bucketName, objectName string, reader io.Reader, opts *UploadOptions) error {
+	_, err := c.StreamUploadWithResult(ctx, bucketName, objectName, reader, opts)
+	return err
+}
+
+// StreamUploadWithResult streams an unbounded reader like StreamUpload and
+// returns the size and SHA1 of what was stored
+func (c *Client) StreamUploadWithResult(ctx context.Context, bucketName, objectName string, reader io.Reader, opts *UploadOptions) (*UploadResult, error) {
 	if opts == nil {
 		opts = DefaultUploadOptions()
 	}
 
-	bucket, err := c.Bucket(ctx, bucketName)
-	if err != nil {
-		return err
+	if opts.ChecksumAlgorithm == checksum.SHA256 {
+		return nil, fmt.Errorf("SHA256 checksums can't be stored for streaming uploads")
 	}
 
-	obj := bucket.Object(objectName)
+	writerOpts, enc, err := writerOptions(opts, "")
+	if err != nil {
+		return nil, err
+	}
 
-	// Create writer with attributes for content type
-	writerOpts := []b2.WriterOption{}
-	if opts.ContentType != "" {
-		writerOpts = append(writerOpts, b2.WithAttrsOption(&b2.Attrs{
-			ContentType: opts.ContentType,
-		}))
+	bucket, err := c.bucketWithRetry(ctx, bucketName, opts.Retry)
+	if err != nil {
+		return nil, err
 	}
 
-	writer := obj.NewWriter(ctx, writerOpts...)
+	// Cancelling the writer's context aborts the upload instead of committing it
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	obj := bucket.Object(objectName)
+	writer := obj.NewWriter(wctx, writerOpts...)
 
 	// Configure for streaming - Blazer handles chunking automatically
 	if opts.ConcurrentUploads > 0 {
 		writer.ConcurrentUploads = opts.ConcurrentUploads
 	}
 
+	var src io.Reader = reader
+	if opts.Compress {
+		zr := gzipReader(src)
+		defer zr.Close()
+		src = zr
+	}
+	if enc != nil {
+		src = newEncryptReader(src, enc)
+	}
+	if opts.MaxBytesPerSec > 0 {
+		src = progress.NewRateLimitedReader(ctx, src, opts.MaxBytesPerSec)
+	}
+
 	// For streaming, we don't know the size upfront
 	// Blazer's writer handles this by buffering and using multipart upload
-	_, err = io.Copy(writer, reader)
+	written, sum, err := copyWithSHA1(writer, src)
 	if err != nil {
+		cancel() // Don't commit a truncated object
 		writer.Close()
-		return fmt.Errorf("failed to stream upload: %w", err)
+		return nil, fmt.Errorf("failed to stream upload: %w", err)
 	}
 
 	if err := writer.Close(); err != nil {
-		return fmt.Errorf("failed to finalize stream upload: %w", err)
+		return nil, fmt.Errorf("failed to finalize stream upload: %w", err)
 	}
 
-	return nil
+	logUploaded(bucketName, objectName, written, sum)
+	return &UploadResult{
+		Name:        objectName,
+		Size:        written,
+		ContentType: opts.ContentType,
+		SHA1:        sum,
+	}, nil
+}
+
+// copyWithSHA1 copies src to dst like io.Copy, and also returns the
+// hex-encoded SHA1 of the bytes copied
+func copyWithSHA1(dst io.Writer, src io.Reader) (int64, string, error) {
+	hash := sha1.New()
+	written, err := io.Copy(dst, io.TeeReader(src, hash))
+	return written, hex.EncodeToString(hash.Sum(nil)), err
+}
+
+// logUploaded records a completed upload and the SHA1 of what was stored
+func logUploaded(bucketName, objectName string, size int64, sum string) {
+	logging.Logger().Debug("upload complete",
+		logging.Bucket(bucketName),
+		logging.Object(objectName),
+		logging.Size(size),
+		slog.String("sha1", sum),
+	)
 }
 
 // UploadResult contains information about a completed upload
@@ -135,55 +212,213 @@ type UploadResult struct {
 	Name        string
 	Size        int64
 	ContentType string
+	SHA1        string // Hex SHA1 of the stored bytes, computed while uploading
+	SHA256      string // Hex SHA256 of the source content, when ChecksumAlgorithm is SHA256
+	Skipped     bool   // Set by UploadIfChanged when the stored object already matched
 }
 
-// UploadWithResult uploads and returns information about the uploaded object
+// UploadWithResult uploads and returns information about the uploaded object.
+// Seekable readers are rewound and retried in full on transient failures;
+// other readers only have the bucket lookup retried, since their data can't be replayed.
 func (c *Client) UploadWithResult(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts *UploadOptions) (*UploadResult, error) {
 	if opts == nil {
 		opts = DefaultUploadOptions()
 	}
 
-	bucket, err := c.Bucket(ctx, bucketName)
+	seeker, seekable := reader.(io.Seeker)
+	var start int64
+	var err error
+	if seekable {
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
+
+	// SHA256 goes in the object's metadata, which is sent before the data
+	var sha256 string
+	if opts.ChecksumAlgorithm == checksum.SHA256 {
+		if !seekable {
+			return nil, fmt.Errorf("SHA256 checksums need a seekable source")
+		}
+		if sha256, err = checksum.SHA256.Reader(reader); err != nil {
+			return nil, fmt.Errorf("failed to hash source: %w", err)
+		}
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to rewind source: %w", err)
+		}
+	}
+
+	var written int64
+	var sum string
+	if seekable {
+		err = retry.Do(ctx, LogRetries(opts.Retry, "upload"), IsRetryable, func() error {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to rewind source: %w", err)
+			}
+			// Each attempt gets a fresh salt and nonce, so a retry never
+			// encrypts under a key and nonce an earlier attempt used
+			writerOpts, enc, err := writerOptions(opts, sha256)
+			if err != nil {
+				return err
+			}
+			bucket, err := c.Bucket(ctx, bucketName)
+			if err != nil {
+				return err
+			}
+			written, sum, err = writeObject(ctx, bucket, objectName, reader, size, writerOpts, enc, opts)
+			if err != nil {
+				c.forgetBucket(bucketName)
+			}
+			return err
+		})
+	} else {
+		var writerOpts []b2.WriterOption
+		var enc *encryption
+		if writerOpts, enc, err = writerOptions(opts, sha256); err != nil {
+			return nil, err
+		}
+		var bucket *b2.Bucket
+		bucket, err = c.bucketWithRetry(ctx, bucketName, opts.Retry)
+		if err != nil {
+			return nil, err
+		}
+		written, sum, err = writeObject(ctx, bucket, objectName, reader, size, writerOpts, enc, opts)
+		if err != nil {
+			c.forgetBucket(bucketName)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	obj := bucket.Object(objectName)
+	logUploaded(bucketName, objectName, written, sum)
+	return &UploadResult{
+		Name:        objectName,
+		Size:        written,
+		ContentType: opts.ContentType,
+		SHA1:        sum,
+		SHA256:      sha256,
+	}, nil
+}
 
-	// Create writer with attributes for content type
-	writerOpts := []b2.WriterOption{}
-	if opts.ContentType != "" {
-		writerOpts = append(writerOpts, b2.WithAttrsOption(&b2.Attrs{
-			ContentType: opts.ContentType,
-		}))
+// UploadIfChanged uploads reader unless objectName already exists with the same
+// size and, when compareChecksum is set, the same checksum (SHA256 if that's the
+// selected algorithm and the object has one stored, SHA1 otherwise). A skipped
+// upload returns the existing object's details with Skipped set.
+func (c *Client) UploadIfChanged(ctx context.Context, bucketName, objectName string, reader io.ReadSeeker, size int64, compareChecksum bool, opts *UploadOptions) (*UploadResult, error) {
+	if opts == nil {
+		opts = DefaultUploadOptions()
+	}
+	if opts.Compress || opts.Encrypt {
+		return nil, fmt.Errorf("can't compare compressed or encrypted uploads with the stored object")
+	}
+
+	existing, err := c.GetObjectInfo(ctx, bucketName, objectName)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
 	}
+	if existing != nil {
+		same, err := matchesObject(existing, reader, size, compareChecksum, opts.ChecksumAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		if same {
+			logging.Logger().Debug("upload skipped, object unchanged", logging.Bucket(bucketName), logging.Object(objectName))
+			return &UploadResult{
+				Name:        objectName,
+				Size:        existing.Size,
+				ContentType: existing.ContentType,
+				SHA1:        existing.SHA1,
+				SHA256:      existing.SHA256,
+				Skipped:     true,
+			}, nil
+		}
+	}
+
+	return c.UploadWithResult(ctx, bucketName, objectName, reader, size, opts)
+}
+
+// matchesObject reports whether reader's content matches the stored object by
+// size and optionally checksum. The reader is rewound to where it started.
+func matchesObject(obj *ObjectInfo, reader io.ReadSeeker, size int64, compareChecksum bool, algo checksum.Algorithm) (bool, error) {
+	if obj.Size != size {
+		return false, nil
+	}
+	if !compareChecksum {
+		return true, nil
+	}
+
+	want := obj.SHA1
+	if algo == checksum.SHA256 && obj.SHA256 != "" {
+		want = obj.SHA256
+	} else {
+		algo = checksum.SHA1
+	}
+	if want == "" {
+		return false, nil // Nothing to compare against, so upload to be safe
+	}
+
+	start, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, fmt.Errorf("failed to read source position: %w", err)
+	}
+	got, err := algo.Reader(reader)
+	if err != nil {
+		return false, fmt.Errorf("failed to hash source: %w", err)
+	}
+	if _, err := reader.Seek(start, io.SeekStart); err != nil {
+		return false, fmt.Errorf("failed to rewind source: %w", err)
+	}
+	return got == want, nil
+}
 
-	writer := obj.NewWriter(ctx, writerOpts...)
+// writeObject performs a single upload attempt of reader into the named object
+// and returns the number of bytes stored and their SHA1
+func writeObject(ctx context.Context, bucket *b2.Bucket, objectName string, reader io.Reader, size int64, writerOpts []b2.WriterOption, enc *encryption, opts *UploadOptions) (int64, string, error) {
+	// Cancelling the writer's context aborts the upload instead of committing it
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	obj := bucket.Object(objectName)
+	writer := obj.NewWriter(wctx, writerOpts...)
 
+	// Configure upload options
 	if opts.ConcurrentUploads > 0 {
 		writer.ConcurrentUploads = opts.ConcurrentUploads
 	}
 
+	// Wrap reader with progress tracking, compression, encryption and throttling if configured.
+	// Progress counts source bytes, so it still matches size when transforming the data.
 	var src io.Reader = reader
 	if opts.ProgressCallback != nil && size > 0 {
-		src = progress.NewReader(reader, size, opts.ProgressCallback)
+		src = progress.NewReaderThrottled(src, size, opts.ProgressCallback, progress.DefaultInterval)
+	}
+	if opts.Compress {
+		zr := gzipReader(src)
+		defer zr.Close()
+		src = zr
+	}
+	if enc != nil {
+		src = newEncryptReader(src, enc)
+	}
+	if opts.MaxBytesPerSec > 0 {
+		src = progress.NewRateLimitedReader(ctx, src, opts.MaxBytesPerSec)
 	}
 
-	written, err := io.Copy(writer, src)
+	// Copy data to writer, hashing the bytes as stored so the result matches B2's own SHA1
+	written, sum, err := copyWithSHA1(writer, src)
 	if err != nil {
+		cancel() // Don't commit a truncated object
 		writer.Close()
-		return nil, fmt.Errorf("failed to upload: %w", err)
+		return written, "", fmt.Errorf("failed to upload: %w", err)
 	}
 
+	// Close the writer to finalize the upload
 	if err := writer.Close(); err != nil {
-		return nil, fmt.Errorf("failed to finalize upload: %w", err)
+		return written, "", fmt.Errorf("failed to finalize upload: %w", err)
 	}
 
-	return &UploadResult{
-		Name:        objectName,
-		Size:        written,
-		ContentType: opts.ContentType,
-	}, nil
+	return written, sum, nil
 }
 
 // GetUploadWriter returns a writer for manual upload control
@@ -0,0 +1,62 @@
+package b2
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ryanoboyle/bb-stream/pkg/checksum"
+)
+
+func TestCopyWithSHA1(t *testing.T) {
+	var dst bytes.Buffer
+	written, sum, err := copyWithSHA1(&dst, strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatalf("copyWithSHA1: %v", err)
+	}
+	if written != 11 || dst.String() != "hello world" {
+		t.Errorf("Expected 11 bytes copied intact, got %d %q", written, dst.String())
+	}
+	if want := "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed"; sum != want {
+		t.Errorf("SHA1 = %s, want %s", sum, want)
+	}
+}
+
+func TestMatchesObject(t *testing.T) {
+	const helloSHA1 = "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"
+	helloSHA256, _ := checksum.SHA256.Reader(strings.NewReader("hello"))
+
+	tests := []struct {
+		name     string
+		obj      ObjectInfo
+		content  string
+		compare  bool
+		algo     checksum.Algorithm
+		expected bool
+	}{
+		{"same size, no checksum", ObjectInfo{Size: 5}, "world", false, checksum.SHA1, true},
+		{"different size", ObjectInfo{Size: 4, SHA1: helloSHA1}, "hello", true, checksum.SHA1, false},
+		{"same SHA1", ObjectInfo{Size: 5, SHA1: helloSHA1}, "hello", true, checksum.SHA1, true},
+		{"different SHA1", ObjectInfo{Size: 5, SHA1: helloSHA1}, "world", true, checksum.SHA1, false},
+		{"unknown SHA1", ObjectInfo{Size: 5}, "hello", true, checksum.SHA1, false},
+		{"same SHA256", ObjectInfo{Size: 5, SHA256: helloSHA256}, "hello", true, checksum.SHA256, true},
+		{"SHA256 falls back to SHA1", ObjectInfo{Size: 5, SHA1: helloSHA1}, "hello", true, checksum.SHA256, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := strings.NewReader(tt.content)
+			got, err := matchesObject(&tt.obj, r, int64(len(tt.content)), tt.compare, tt.algo)
+			if err != nil {
+				t.Fatalf("matchesObject failed: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("matchesObject = %v, want %v", got, tt.expected)
+			}
+			if pos, _ := r.Seek(0, io.SeekCurrent); pos != 0 {
+				t.Errorf("Reader left at offset %d, want 0", pos)
+			}
+		})
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -15,22 +18,93 @@ type Config struct {
 	DefaultBucket  string `mapstructure:"default_bucket"`
 	APIPort        int    `mapstructure:"api_port"`
 	APIKey         string `mapstructure:"api_key"`
+	RequireAPIKey  bool   `mapstructure:"require_api_key"`  // Refuse API requests while no api_key is set
+	MaxUploadBytes int64  `mapstructure:"max_upload_bytes"` // Largest streamed upload the API accepts; 0 means no limit
+
+	// AllowedOrigins lists browser origins trusted for CORS requests and
+	// WebSocket connections, in addition to same-origin; "*" allows any origin
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+
+	// CORSMethods and CORSHeaders override the methods and request headers
+	// the API allows cross-origin; empty uses the built-in lists
+	CORSMethods []string `mapstructure:"cors_methods"`
+	CORSHeaders []string `mapstructure:"cors_headers"`
+
+	// Profiles holds additional named credentials; the top-level
+	// KeyID/ApplicationKey act as the "default" profile
+	Profiles      map[string]Credentials `mapstructure:"profiles"`
+	ActiveProfile string                 `mapstructure:"active_profile"`
+
+	unknownKeys []string // Top-level keys in the config file that aren't recognized
 }
 
+// Credentials is a B2 application key pair
+type Credentials struct {
+	KeyID          string `mapstructure:"key_id"`
+	ApplicationKey string `mapstructure:"application_key"`
+}
+
+// DefaultProfile names the top-level credentials
+const DefaultProfile = "default"
+
+// ConfigFileEnv names the environment variable that overrides the config file location
+const ConfigFileEnv = "BB_CONFIG_FILE"
+
 var (
-	cfg        *Config
-	configPath string
+	cfg          *Config
+	configPath   string
+	pathOverride string // Config file selected for this run, overriding ConfigFileEnv
+	profile      string // Profile selected for this run, overriding ActiveProfile
 )
 
-// Init initializes the configuration system
-func Init() error {
+// SetConfigPath selects the config file Init reads and Save writes, in place
+// of BB_CONFIG_FILE or the default ~/.config/bb-stream/config.yaml
+func SetConfigPath(path string) {
+	pathOverride = path
+}
+
+// resolveConfigPath returns the config file to use: the one selected with
+// SetConfigPath, else BB_CONFIG_FILE, else the default in the home directory
+func resolveConfigPath() (string, error) {
+	path := pathOverride
+	if path == "" {
+		path = os.Getenv(ConfigFileEnv)
+	}
+	if path != "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", fmt.Errorf("invalid config file path %s: %w", path, err)
+		}
+		return abs, nil
+	}
+
 	home, err := os.UserHomeDir()
 	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
+	return filepath.Join(home, ".config", "bb-stream", "config.yaml"), nil
+}
 
-	configDir := filepath.Join(home, ".config", "bb-stream")
-	configPath = filepath.Join(configDir, "config.yaml")
+// Init loads the configuration and validates it, for commands that go on
+// to use it
+func Init() error {
+	if err := Load(); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config %s: %w", configPath, err)
+	}
+	return nil
+}
+
+// Load reads the configuration without validating it, so the config
+// commands can still show and repair a file that doesn't validate
+func Load() error {
+	var err error
+	if configPath, err = resolveConfigPath(); err != nil {
+		return err
+	}
+	configDir := filepath.Dir(configPath)
 
 	// Create config directory if it doesn't exist
 	if err := os.MkdirAll(configDir, 0755); err != nil {
@@ -49,6 +123,10 @@ func Init() error {
 	_ = viper.BindEnv("application_key", "BB_APP_KEY")
 	_ = viper.BindEnv("default_bucket", "BB_DEFAULT_BUCKET")
 	_ = viper.BindEnv("api_key", "BB_API_KEY")
+	_ = viper.BindEnv("require_api_key", "BB_REQUIRE_API_KEY")
+	_ = viper.BindEnv("max_upload_bytes", "BB_MAX_UPLOAD_BYTES")
+	_ = viper.BindEnv("active_profile", "BB_PROFILE")
+	_ = viper.BindEnv("allowed_origins", "BB_ALLOWED_ORIGINS") // Comma-separated
 
 	// Try to read config file (ignore error if doesn't exist)
 	if err := viper.ReadInConfig(); err != nil {
@@ -64,10 +142,94 @@ func Init() error {
 	if err := viper.Unmarshal(cfg); err != nil {
 		return fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	cfg.unknownKeys = unknownKeys(viper.AllKeys())
+
+	// Env values are split on commas but not trimmed
+	cfg.AllowedOrigins = splitList(strings.Join(cfg.AllowedOrigins, ","))
+	cfg.CORSMethods = splitList(strings.Join(cfg.CORSMethods, ","))
+	cfg.CORSHeaders = splitList(strings.Join(cfg.CORSHeaders, ","))
+
+	return nil
+}
+
+// Validate checks field values and reports the first problem by field name
+func (c *Config) Validate() error {
+	if len(c.unknownKeys) > 0 {
+		return fmt.Errorf("unknown field %q (valid fields: %s, profiles)", c.unknownKeys[0], strings.Join(Keys(), ", "))
+	}
+
+	if c.APIPort < 1 || c.APIPort > 65535 {
+		return fmt.Errorf("api_port %d is out of range 1-65535", c.APIPort)
+	}
+
+	if c.MaxUploadBytes < 0 {
+		return fmt.Errorf("max_upload_bytes %d must not be negative", c.MaxUploadBytes)
+	}
+
+	if err := validateCredentials("", Credentials{KeyID: c.KeyID, ApplicationKey: c.ApplicationKey}); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := validateCredentials("profiles."+name+".", c.Profiles[name]); err != nil {
+			return err
+		}
+	}
 
+	if c.ActiveProfile != "" && c.ActiveProfile != DefaultProfile {
+		if _, ok := c.Profiles[c.ActiveProfile]; !ok {
+			return fmt.Errorf("active_profile %q does not match any profile", c.ActiveProfile)
+		}
+	}
+
+	return nil
+}
+
+// validateCredentials checks that a key pair is either complete or entirely unset
+func validateCredentials(prefix string, creds Credentials) error {
+	fields := []struct{ name, value string }{
+		{"key_id", creds.KeyID},
+		{"application_key", creds.ApplicationKey},
+	}
+	for _, f := range fields {
+		if f.value != strings.TrimSpace(f.value) {
+			return fmt.Errorf("%s%s has leading or trailing whitespace", prefix, f.name)
+		}
+	}
+	if creds.KeyID != "" && creds.ApplicationKey == "" {
+		return fmt.Errorf("%sapplication_key is empty but %skey_id is set", prefix, prefix)
+	}
+	if creds.KeyID == "" && creds.ApplicationKey != "" {
+		return fmt.Errorf("%skey_id is empty but %sapplication_key is set", prefix, prefix)
+	}
 	return nil
 }
 
+// unknownKeys returns the sorted top-level keys not recognized as config fields
+func unknownKeys(keys []string) []string {
+	known := map[string]bool{"profiles": true}
+	for _, k := range Keys() {
+		known[k] = true
+	}
+
+	seen := make(map[string]bool)
+	var unknown []string
+	for _, key := range keys {
+		top, _, _ := strings.Cut(key, ".")
+		if !known[top] && !seen[top] {
+			seen[top] = true
+			unknown = append(unknown, top)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
 // Get returns the current configuration
 func Get() *Config {
 	if cfg == nil {
@@ -83,6 +245,21 @@ func Save() error {
 	viper.Set("default_bucket", cfg.DefaultBucket)
 	viper.Set("api_port", cfg.APIPort)
 	viper.Set("api_key", cfg.APIKey)
+	viper.Set("require_api_key", cfg.RequireAPIKey)
+	viper.Set("max_upload_bytes", cfg.MaxUploadBytes)
+	viper.Set("active_profile", cfg.ActiveProfile)
+	viper.Set("allowed_origins", cfg.AllowedOrigins)
+	viper.Set("cors_methods", cfg.CORSMethods)
+	viper.Set("cors_headers", cfg.CORSHeaders)
+
+	profiles := make(map[string]interface{}, len(cfg.Profiles))
+	for name, creds := range cfg.Profiles {
+		profiles[name] = map[string]string{
+			"key_id":          creds.KeyID,
+			"application_key": creds.ApplicationKey,
+		}
+	}
+	viper.Set("profiles", profiles)
 
 	return viper.WriteConfigAs(configPath)
 }
@@ -98,6 +275,19 @@ func SetCredentials(keyID, appKey string) {
 	cfg.ApplicationKey = appKey
 }
 
+// SetProfileCredentials updates the credentials of a named profile,
+// or the top-level credentials for DefaultProfile
+func SetProfileCredentials(name, keyID, appKey string) {
+	if name == DefaultProfile {
+		SetCredentials(keyID, appKey)
+		return
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]Credentials)
+	}
+	cfg.Profiles[name] = Credentials{KeyID: keyID, ApplicationKey: appKey}
+}
+
 // SetDefaultBucket updates the default bucket
 func SetDefaultBucket(bucket string) {
 	cfg.DefaultBucket = bucket
@@ -108,14 +298,166 @@ func SetAPIPort(port int) {
 	cfg.APIPort = port
 }
 
+// SetProfile selects the credentials profile for this run without saving it
+func SetProfile(name string) {
+	profile = name
+}
+
+// ActiveProfileName returns the profile in use: the one selected with
+// SetProfile, else the configured active profile, else DefaultProfile
+func ActiveProfileName() string {
+	if profile != "" {
+		return profile
+	}
+	if name := Get().ActiveProfile; name != "" {
+		return name
+	}
+	return DefaultProfile
+}
+
+// GetProfile returns the credentials for a named profile.
+// An empty name means the active profile. DefaultProfile is always the
+// top-level credentials, even if profiles has an entry of that name.
+func GetProfile(name string) (Credentials, error) {
+	if name == "" {
+		name = ActiveProfileName()
+	}
+
+	c := Get()
+	if name == DefaultProfile {
+		return Credentials{KeyID: c.KeyID, ApplicationKey: c.ApplicationKey}, nil
+	}
+	if creds, ok := c.Profiles[name]; ok {
+		return creds, nil
+	}
+	return Credentials{}, fmt.Errorf("unknown profile %q", name)
+}
+
+// ProfileNames returns the default profile followed by the named profiles in order
+func ProfileNames() []string {
+	names := []string{DefaultProfile}
+	for name := range Get().Profiles {
+		if name != DefaultProfile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// Keys lists the settings accepted by GetValue and SetValue
+func Keys() []string {
+	return []string{"key_id", "application_key", "default_bucket", "api_port", "api_key", "require_api_key", "max_upload_bytes", "active_profile", "allowed_origins", "cors_methods", "cors_headers"}
+}
+
+// GetValue returns a setting by its config file key
+func GetValue(key string) (string, error) {
+	c := Get()
+	switch key {
+	case "key_id":
+		return c.KeyID, nil
+	case "application_key":
+		return c.ApplicationKey, nil
+	case "default_bucket":
+		return c.DefaultBucket, nil
+	case "api_port":
+		return strconv.Itoa(c.APIPort), nil
+	case "api_key":
+		return c.APIKey, nil
+	case "require_api_key":
+		return strconv.FormatBool(c.RequireAPIKey), nil
+	case "max_upload_bytes":
+		return strconv.FormatInt(c.MaxUploadBytes, 10), nil
+	case "active_profile":
+		return c.ActiveProfile, nil
+	case "allowed_origins":
+		return strings.Join(c.AllowedOrigins, ","), nil
+	case "cors_methods":
+		return strings.Join(c.CORSMethods, ","), nil
+	case "cors_headers":
+		return strings.Join(c.CORSHeaders, ","), nil
+	}
+	return "", unknownKey(key)
+}
+
+// SetValue updates a setting by its config file key, validating the value
+func SetValue(key, value string) error {
+	c := Get()
+	switch key {
+	case "key_id":
+		c.KeyID = value
+	case "application_key":
+		c.ApplicationKey = value
+	case "default_bucket":
+		c.DefaultBucket = value
+	case "api_port":
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid api_port %q: must be an integer between 1 and 65535", value)
+		}
+		c.APIPort = port
+	case "api_key":
+		c.APIKey = value
+	case "require_api_key":
+		require, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid require_api_key %q: must be true or false", value)
+		}
+		c.RequireAPIKey = require
+	case "max_upload_bytes":
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid max_upload_bytes %q: must be a non-negative integer", value)
+		}
+		c.MaxUploadBytes = limit
+	case "active_profile":
+		if value != DefaultProfile {
+			if _, ok := c.Profiles[value]; !ok {
+				return fmt.Errorf("unknown profile %q", value)
+			}
+		}
+		c.ActiveProfile = value
+	case "allowed_origins":
+		c.AllowedOrigins = splitList(value)
+	case "cors_methods":
+		c.CORSMethods = splitList(value)
+	case "cors_headers":
+		c.CORSHeaders = splitList(value)
+	default:
+		return unknownKey(key)
+	}
+	return nil
+}
+
+// splitList parses a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func unknownKey(key string) error {
+	return fmt.Errorf("unknown config key %q (valid keys: %s)", key, strings.Join(Keys(), ", "))
+}
+
 // GetConfigPath returns the path to the config file
 func GetConfigPath() string {
 	return configPath
 }
 
-// IsConfigured returns true if credentials are set (package level)
+// IsConfigured returns true if the active profile's credentials are set (package level)
 func IsConfigured() bool {
-	return cfg.KeyID != "" && cfg.ApplicationKey != ""
+	creds, err := GetProfile("")
+	return err == nil && creds.IsConfigured()
+}
+
+// IsConfigured returns true if both halves of the key pair are set
+func (c Credentials) IsConfigured() bool {
+	return c.KeyID != "" && c.ApplicationKey != ""
 }
 
 // IsConfigured returns true if credentials are set (struct level)
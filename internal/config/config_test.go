@@ -2,7 +2,11 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/spf13/viper"
 )
 
 func TestDefaultValues(t *testing.T) {
@@ -127,3 +131,290 @@ func TestGetReturnsNonNil(t *testing.T) {
 		t.Error("Get() should never return nil")
 	}
 }
+
+func TestGetProfile(t *testing.T) {
+	cfg = &Config{
+		KeyID:          "default-key",
+		ApplicationKey: "default-secret",
+		Profiles: map[string]Credentials{
+			"work": {KeyID: "work-key", ApplicationKey: "work-secret"},
+		},
+	}
+	defer SetProfile("")
+
+	creds, err := GetProfile("")
+	if err != nil || creds.KeyID != "default-key" {
+		t.Errorf("Expected the default profile when none is active, got %+v, %v", creds, err)
+	}
+
+	creds, err = GetProfile("work")
+	if err != nil || creds.KeyID != "work-key" {
+		t.Errorf("Expected work credentials, got %+v, %v", creds, err)
+	}
+
+	if _, err := GetProfile("missing"); err == nil {
+		t.Error("Expected an error for an unknown profile")
+	}
+
+	// The configured active profile applies until overridden for the run
+	cfg.ActiveProfile = "work"
+	if name := ActiveProfileName(); name != "work" {
+		t.Errorf("Expected active profile 'work', got %q", name)
+	}
+	SetProfile(DefaultProfile)
+	creds, _ = GetProfile("")
+	if creds.KeyID != "default-key" {
+		t.Errorf("Expected SetProfile to override the active profile, got %+v", creds)
+	}
+}
+
+func TestGetProfile_DefaultWithActiveProfile(t *testing.T) {
+	cfg = &Config{
+		KeyID:          "default-key",
+		ApplicationKey: "default-secret",
+		ActiveProfile:  "work",
+		Profiles: map[string]Credentials{
+			"work": {KeyID: "work-key", ApplicationKey: "work-secret"},
+			// Can't replace the top-level credentials
+			DefaultProfile: {KeyID: "shadow-key", ApplicationKey: "shadow-secret"},
+		},
+	}
+	defer SetProfile("")
+
+	creds, err := GetProfile(DefaultProfile)
+	if err != nil || creds.KeyID != "default-key" {
+		t.Errorf("Expected the top-level credentials for %q, got %+v, %v", DefaultProfile, creds, err)
+	}
+	if creds, _ := GetProfile(""); creds.KeyID != "work-key" {
+		t.Errorf("Expected the active profile for an empty name, got %+v", creds)
+	}
+}
+
+func TestProfileNames(t *testing.T) {
+	cfg = &Config{Profiles: map[string]Credentials{"zeta": {}, "client": {}}}
+
+	names := ProfileNames()
+	expected := []string{DefaultProfile, "client", "zeta"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, names)
+			break
+		}
+	}
+}
+
+func TestSetProfileCredentials(t *testing.T) {
+	cfg = &Config{}
+
+	SetProfileCredentials("client", "client-key", "client-secret")
+	SetProfileCredentials(DefaultProfile, "key", "secret")
+
+	if cfg.Profiles["client"].KeyID != "client-key" {
+		t.Errorf("Expected client profile to be stored, got %+v", cfg.Profiles)
+	}
+	if cfg.KeyID != "key" || cfg.ApplicationKey != "secret" {
+		t.Errorf("Expected default profile to use top-level credentials, got %q/%q", cfg.KeyID, cfg.ApplicationKey)
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	cfg = &Config{Profiles: map[string]Credentials{"work": {}}}
+
+	if err := SetValue("api_port", "9090"); err != nil {
+		t.Fatalf("SetValue failed: %v", err)
+	}
+	if got, _ := GetValue("api_port"); got != "9090" {
+		t.Errorf("Expected api_port 9090, got %q", got)
+	}
+
+	if err := SetValue("default_bucket", "backups"); err != nil {
+		t.Fatalf("SetValue failed: %v", err)
+	}
+	if got, _ := GetValue("default_bucket"); got != "backups" {
+		t.Errorf("Expected default_bucket 'backups', got %q", got)
+	}
+
+	for _, port := range []string{"abc", "0", "65536", "-1"} {
+		if err := SetValue("api_port", port); err == nil {
+			t.Errorf("Expected error for api_port %q", port)
+		}
+	}
+	if cfg.APIPort != 9090 {
+		t.Errorf("Expected invalid ports to leave api_port unchanged, got %d", cfg.APIPort)
+	}
+
+	if err := SetValue("active_profile", "missing"); err == nil {
+		t.Error("Expected error for an unknown profile")
+	}
+	if err := SetValue("active_profile", "work"); err != nil {
+		t.Errorf("Expected known profile to be accepted, got %v", err)
+	}
+}
+
+func TestUnknownKey(t *testing.T) {
+	cfg = &Config{}
+
+	err := SetValue("colour", "blue")
+	if err == nil || !strings.Contains(err.Error(), "api_port") {
+		t.Errorf("Expected error listing valid keys, got %v", err)
+	}
+	if _, err := GetValue("colour"); err == nil {
+		t.Error("Expected GetValue error for unknown key")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"Valid", Config{APIPort: 8080, KeyID: "key", ApplicationKey: "secret"}, ""},
+		{"Unconfigured", Config{APIPort: 8080}, ""},
+		{"Port too high", Config{APIPort: 70000}, "api_port"},
+		{"Port zero", Config{APIPort: 0}, "api_port"},
+		{"Missing app key", Config{APIPort: 8080, KeyID: "key"}, "application_key"},
+		{"Whitespace key", Config{APIPort: 8080, KeyID: " key", ApplicationKey: "secret"}, "key_id"},
+		{"Bad profile", Config{APIPort: 8080, Profiles: map[string]Credentials{"work": {ApplicationKey: "secret"}}}, "profiles.work.key_id"},
+		{"Unknown active profile", Config{APIPort: 8080, ActiveProfile: "missing"}, "active_profile"},
+		{"Unknown field", Config{APIPort: 8080, unknownKeys: []string{"api_prot"}}, "api_prot"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error naming %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestInit_RejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"Bad port", "api_port: 70000\n", "70000"},
+		{"Malformed port", "api_port: abc\n", "api_port"},
+		{"Unknown key", "api_port: 8080\ndefault_buckett: media\n", "default_buckett"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			dir := filepath.Join(home, ".config", "bb-stream")
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(tt.yaml), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			err := Init()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error naming %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidConfig(t *testing.T) {
+	viper.Reset()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".config", "bb-stream")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("api_port: 70000\ndefault_bucket: media\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Load reads a config that doesn't validate, so it can be repaired
+	if err := Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if Get().DefaultBucket != "media" {
+		t.Errorf("Expected default_bucket media, got %q", Get().DefaultBucket)
+	}
+	if err := SetValue("api_port", "8080"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Get().Validate(); err != nil {
+		t.Errorf("Expected the repaired config to validate, got %v", err)
+	}
+}
+
+func TestInit_AllowedOriginsFromEnv(t *testing.T) {
+	viper.Reset()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("BB_ALLOWED_ORIGINS", "https://app.example.com, https://admin.example.com")
+
+	if err := Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	got := Get().AllowedOrigins
+	want := []string{"https://app.example.com", "https://admin.example.com"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("AllowedOrigins[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestInit_ConfigPathOverride(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := t.TempDir()
+	fromEnv := filepath.Join(dir, "env.yaml")
+	fromFlag := filepath.Join(dir, "nested", "flag.yaml")
+	if err := os.WriteFile(fromEnv, []byte("default_bucket: env-bucket\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(ConfigFileEnv, fromEnv)
+	t.Cleanup(func() { SetConfigPath("") })
+
+	viper.Reset()
+	if err := Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if GetConfigPath() != fromEnv || Get().DefaultBucket != "env-bucket" {
+		t.Errorf("Expected %s to be read, got %s with bucket %q", fromEnv, GetConfigPath(), Get().DefaultBucket)
+	}
+
+	// SetConfigPath overrides the environment, and Save writes to the chosen file
+	SetConfigPath(fromFlag)
+	viper.Reset()
+	if err := Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if GetConfigPath() != fromFlag || Get().DefaultBucket != "" {
+		t.Errorf("Expected a fresh config at %s, got %s with bucket %q", fromFlag, GetConfigPath(), Get().DefaultBucket)
+	}
+	SetDefaultBucket("flag-bucket")
+	if err := Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if data, err := os.ReadFile(fromFlag); err != nil || !strings.Contains(string(data), "flag-bucket") {
+		t.Errorf("Expected %s to hold the saved config, got %q, %v", fromFlag, data, err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "bb-stream", "config.yaml")); !os.IsNotExist(err) {
+		t.Errorf("Expected the default config file to be untouched, got %v", err)
+	}
+}
@@ -0,0 +1,418 @@
+// Package memstore implements b2.Storage entirely in memory, so the sync,
+// watch and API layers can be exercised end-to-end without B2 credentials.
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/internal/b2"
+	"github.com/ryanoboyle/bb-stream/pkg/checksum"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+)
+
+// Object is one stored version of a file
+type Object struct {
+	FileID      string
+	Name        string
+	Data        []byte
+	ContentType string
+	ModTime     time.Time // Upload time, reported as ObjectInfo.Timestamp
+	SHA1        string
+	SHA256      string // Set when uploaded with ChecksumAlgorithm SHA256
+	Info        map[string]string
+}
+
+// info returns the object's listing entry
+func (o *Object) info() b2.ObjectInfo {
+	return b2.ObjectInfo{
+		Name:        o.Name,
+		Size:        int64(len(o.Data)),
+		ContentType: o.ContentType,
+		Timestamp:   o.ModTime.Unix(),
+		SHA1:        o.SHA1,
+		SHA256:      o.SHA256,
+	}
+}
+
+type bucket struct {
+	typ      string
+	versions map[string][]*Object // By name, newest first
+}
+
+// Store is an in-memory b2.Storage. The zero value is not usable; call New.
+type Store struct {
+	mu      sync.RWMutex
+	buckets map[string]*bucket
+	nextID  int
+}
+
+var _ b2.Storage = (*Store)(nil)
+
+// New returns an empty store with the named private buckets
+func New(buckets ...string) *Store {
+	s := &Store{buckets: make(map[string]*bucket)}
+	for _, name := range buckets {
+		s.buckets[name] = newBucket(b2.BucketPrivate)
+	}
+	return s
+}
+
+func newBucket(typ string) *bucket {
+	return &bucket{typ: typ, versions: make(map[string][]*Object)}
+}
+
+// Put stores data as a new version of name with the given upload time,
+// creating the bucket if needed. It's meant for seeding tests.
+func (s *Store) Put(bucketName, name string, data []byte, modTime time.Time) *Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[bucketName]
+	if !ok {
+		b = newBucket(b2.BucketPrivate)
+		s.buckets[bucketName] = b
+	}
+	return s.put(b, &Object{Name: name, Data: bytes.Clone(data), ModTime: modTime})
+}
+
+// put records obj as the newest version of its name. The caller holds mu.
+func (s *Store) put(b *bucket, obj *Object) *Object {
+	s.nextID++
+	obj.FileID = strconv.Itoa(s.nextID)
+	if obj.SHA1 == "" {
+		sum := sha1.Sum(obj.Data)
+		obj.SHA1 = hex.EncodeToString(sum[:])
+	}
+	b.versions[obj.Name] = append([]*Object{obj}, b.versions[obj.Name]...)
+	return obj
+}
+
+// Object returns the latest version of name, or nil if there is none
+func (s *Store) Object(bucketName, name string) *Object {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, ok := s.buckets[bucketName]
+	if !ok || len(b.versions[name]) == 0 {
+		return nil
+	}
+	return b.versions[name][0]
+}
+
+// bucket returns the named bucket. The caller holds mu.
+func (s *Store) bucket(name string) (*bucket, error) {
+	b, ok := s.buckets[name]
+	if !ok {
+		return nil, fmt.Errorf("bucket %q: %w", name, errors.ErrBucketNotFound)
+	}
+	return b, nil
+}
+
+// latest returns the newest version of an object. The caller holds mu.
+func (s *Store) latest(bucketName, objectName string) (*Object, error) {
+	b, err := s.bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	versions := b.versions[objectName]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("object %q: %w", objectName, errors.ErrObjectNotFound)
+	}
+	return versions[0], nil
+}
+
+// Ping always succeeds
+func (s *Store) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// ListBucketInfo returns the buckets sorted by name
+func (s *Store) ListBucketInfo(ctx context.Context) ([]b2.BucketInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info := make([]b2.BucketInfo, 0, len(s.buckets))
+	for name, b := range s.buckets {
+		info = append(info, b2.BucketInfo{Name: name, Type: b.typ})
+	}
+	sort.Slice(info, func(i, j int) bool { return info[i].Name < info[j].Name })
+	return info, nil
+}
+
+// CreateBucket creates a bucket of the given type (allPrivate if empty)
+func (s *Store) CreateBucket(ctx context.Context, name, bucketType string) (*b2.BucketInfo, error) {
+	switch bucketType {
+	case "":
+		bucketType = b2.BucketPrivate
+	case b2.BucketPrivate, b2.BucketPublic:
+	default:
+		return nil, fmt.Errorf("invalid bucket type %q: %w", bucketType, errors.ErrBadRequest)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.buckets[name]; ok {
+		return nil, fmt.Errorf("bucket %q: %w", name, errors.ErrBucketExists)
+	}
+	s.buckets[name] = newBucket(bucketType)
+	return &b2.BucketInfo{Name: name, Type: bucketType}, nil
+}
+
+// DeleteBucket deletes a bucket, which must be empty unless force is set
+func (s *Store) DeleteBucket(ctx context.Context, name string, force bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.bucket(name)
+	if err != nil {
+		return err
+	}
+	if !force && len(b.versions) > 0 {
+		return fmt.Errorf("bucket %q: %w", name, errors.ErrBucketNotEmpty)
+	}
+	delete(s.buckets, name)
+	return nil
+}
+
+// ListObjects returns the latest version of each object under prefix, sorted by name
+func (s *Store) ListObjects(ctx context.Context, bucketName, prefix string) ([]b2.ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, err := s.bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []b2.ObjectInfo
+	for name, versions := range b.versions {
+		if strings.HasPrefix(name, prefix) {
+			objects = append(objects, versions[0].info())
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
+}
+
+// GetObjectInfo returns information about the latest version of an object
+func (s *Store) GetObjectInfo(ctx context.Context, bucketName, objectName string) (*b2.ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	obj, err := s.latest(bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	info := obj.info()
+	return &info, nil
+}
+
+// DeleteObject deletes every version of an object
+func (s *Store) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.latest(bucketName, objectName); err != nil {
+		return err
+	}
+	delete(s.buckets[bucketName].versions, objectName)
+	return nil
+}
+
+// ListObjectVersions returns every version of an object, newest first
+func (s *Store) ListObjectVersions(ctx context.Context, bucketName, objectName string) ([]b2.ObjectVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.latest(bucketName, objectName); err != nil {
+		return nil, err
+	}
+
+	var versions []b2.ObjectVersion
+	for _, obj := range s.buckets[bucketName].versions[objectName] {
+		versions = append(versions, b2.ObjectVersion{
+			FileID:    obj.FileID,
+			Name:      obj.Name,
+			Size:      int64(len(obj.Data)),
+			Timestamp: obj.ModTime.Unix(),
+			Action:    "upload",
+		})
+	}
+	return versions, nil
+}
+
+// DeleteVersion deletes a single version of an object by its file ID
+func (s *Store) DeleteVersion(ctx context.Context, bucketName, objectName, fileID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	versions := b.versions[objectName]
+	for i, obj := range versions {
+		if obj.FileID == fileID {
+			versions = append(versions[:i:i], versions[i+1:]...)
+			if len(versions) == 0 {
+				delete(b.versions, objectName)
+			} else {
+				b.versions[objectName] = versions
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("version %s of %s: %w", fileID, objectName, errors.ErrObjectNotFound)
+}
+
+// Upload stores the reader's contents as a new version of objectName
+func (s *Store) Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts *b2.UploadOptions) error {
+	_, err := s.UploadWithResult(ctx, bucketName, objectName, reader, size, opts)
+	return err
+}
+
+// StreamUpload stores a reader of unknown length
+func (s *Store) StreamUpload(ctx context.Context, bucketName, objectName string, reader io.Reader, opts *b2.UploadOptions) error {
+	return s.Upload(ctx, bucketName, objectName, reader, -1, opts)
+}
+
+// StreamUploadWithResult stores a reader of unknown length and describes the new object
+func (s *Store) StreamUploadWithResult(ctx context.Context, bucketName, objectName string, reader io.Reader, opts *b2.UploadOptions) (*b2.UploadResult, error) {
+	return s.UploadWithResult(ctx, bucketName, objectName, reader, -1, opts)
+}
+
+// UploadWithResult stores the reader's contents and describes the new object.
+// Compression and encryption aren't supported.
+func (s *Store) UploadWithResult(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts *b2.UploadOptions) (*b2.UploadResult, error) {
+	if opts == nil {
+		opts = b2.DefaultUploadOptions()
+	}
+	if opts.Compress || opts.Encrypt {
+		return nil, fmt.Errorf("memstore doesn't support compressed or encrypted uploads")
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload data: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if opts.ProgressCallback != nil {
+		opts.ProgressCallback(int64(len(data)), int64(len(data)))
+	}
+
+	obj := &Object{
+		Name:        objectName,
+		Data:        data,
+		ContentType: opts.ContentType,
+		ModTime:     time.Now(),
+		Info:        maps.Clone(opts.Info),
+	}
+	if opts.ChecksumAlgorithm == checksum.SHA256 {
+		obj.SHA256, _ = checksum.SHA256.Reader(bytes.NewReader(data))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	s.put(b, obj)
+
+	return &b2.UploadResult{
+		Name:        objectName,
+		Size:        int64(len(data)),
+		ContentType: obj.ContentType,
+		SHA1:        obj.SHA1,
+		SHA256:      obj.SHA256,
+	}, nil
+}
+
+// Download writes the latest version of an object, or opts.Range of it, to writer
+func (s *Store) Download(ctx context.Context, bucketName, objectName string, writer io.Writer, opts *b2.DownloadOptions) error {
+	s.mu.RLock()
+	obj, err := s.latest(bucketName, objectName)
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if opts == nil {
+		opts = b2.DefaultDownloadOptions()
+	}
+
+	data := obj.Data
+	if r := opts.Range; r != nil {
+		// As with B2, End is exclusive and an End at or before Start reads to the end
+		if r.Start < 0 || r.Start > int64(len(data)) {
+			return fmt.Errorf("invalid range %d-%d: %w", r.Start, r.End, errors.ErrBadRequest)
+		}
+		end := int64(len(data))
+		if r.End > r.Start {
+			end = min(r.End, end)
+		}
+		data = data[r.Start:end]
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	n, err := writer.Write(data)
+	if opts.ProgressCallback != nil {
+		opts.ProgressCallback(int64(n), int64(len(data)))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write download: %w", err)
+	}
+	return nil
+}
+
+// StreamDownload is Download; there's nothing to stream from memory
+func (s *Store) StreamDownload(ctx context.Context, bucketName, objectName string, writer io.Writer, opts *b2.DownloadOptions) error {
+	return s.Download(ctx, bucketName, objectName, writer, opts)
+}
+
+// DownloadToFile writes an object to localPath, creating parent directories
+func (s *Store) DownloadToFile(ctx context.Context, bucketName, objectName, localPath string, opts *b2.DownloadOptions) (int64, error) {
+	var buf bytes.Buffer
+	if err := s.Download(ctx, bucketName, objectName, &buf, opts); err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(localPath, buf.Bytes(), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write file: %w", err)
+	}
+	return int64(buf.Len()), nil
+}
+
+// PresignedURL returns a mem:// URL naming the object; it can't be fetched
+func (s *Store) PresignedURL(ctx context.Context, bucketName, objectName string, validSeconds int) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.latest(bucketName, objectName); err != nil {
+		return "", err
+	}
+	u := url.URL{Scheme: "mem", Host: bucketName, Path: "/" + objectName}
+	u.RawQuery = url.Values{"expires": {strconv.Itoa(validSeconds)}}.Encode()
+	return u.String(), nil
+}
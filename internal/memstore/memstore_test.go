@@ -0,0 +1,152 @@
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/internal/b2"
+	"github.com/ryanoboyle/bb-stream/pkg/checksum"
+	apperrors "github.com/ryanoboyle/bb-stream/pkg/errors"
+)
+
+func TestUploadAndDownload(t *testing.T) {
+	ctx := context.Background()
+	s := New("bucket")
+
+	opts := b2.DefaultUploadOptions()
+	opts.ChecksumAlgorithm = checksum.SHA256
+	result, err := s.UploadWithResult(ctx, "bucket", "dir/file.txt", strings.NewReader("hello"), 5, opts)
+	if err != nil {
+		t.Fatalf("UploadWithResult failed: %v", err)
+	}
+	if result.Size != 5 || result.SHA1 != "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if want, _ := checksum.SHA256.Reader(strings.NewReader("hello")); result.SHA256 != want {
+		t.Errorf("SHA256 = %q, want %q", result.SHA256, want)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download(ctx, "bucket", "dir/file.txt", &buf, nil); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if buf.String() != "hello" {
+		t.Errorf("Downloaded %q, want hello", buf.String())
+	}
+
+	buf.Reset()
+	rangeOpts := b2.DefaultDownloadOptions()
+	rangeOpts.Range = &b2.ByteRange{Start: 1, End: 3}
+	if err := s.Download(ctx, "bucket", "dir/file.txt", &buf, rangeOpts); err != nil {
+		t.Fatalf("Range download failed: %v", err)
+	}
+	if buf.String() != "el" {
+		t.Errorf("Range downloaded %q, want el", buf.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "nested", "file.txt")
+	n, err := s.DownloadToFile(ctx, "bucket", "dir/file.txt", path, nil)
+	if err != nil || n != 5 {
+		t.Fatalf("DownloadToFile = %d, %v", n, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "hello" {
+		t.Errorf("File contains %q, want hello", data)
+	}
+}
+
+func TestListObjects(t *testing.T) {
+	s := New()
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.Put("bucket", "a/2.txt", []byte("two"), mtime)
+	s.Put("bucket", "a/1.txt", []byte("one"), mtime)
+	s.Put("bucket", "b/3.txt", []byte("three"), mtime)
+
+	objects, err := s.ListObjects(context.Background(), "bucket", "a/")
+	if err != nil {
+		t.Fatalf("ListObjects failed: %v", err)
+	}
+	if len(objects) != 2 || objects[0].Name != "a/1.txt" || objects[1].Name != "a/2.txt" {
+		t.Fatalf("Expected a/1.txt and a/2.txt, got %+v", objects)
+	}
+	if objects[0].Size != 3 || objects[0].Timestamp != mtime.Unix() || objects[0].SHA1 == "" {
+		t.Errorf("Unexpected object info %+v", objects[0])
+	}
+}
+
+func TestVersions(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	first := s.Put("bucket", "file.txt", []byte("v1"), time.Unix(100, 0))
+	s.Put("bucket", "file.txt", []byte("v2"), time.Unix(200, 0))
+
+	versions, err := s.ListObjectVersions(ctx, "bucket", "file.txt")
+	if err != nil {
+		t.Fatalf("ListObjectVersions failed: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Timestamp != 200 {
+		t.Fatalf("Expected 2 versions newest first, got %+v", versions)
+	}
+
+	if err := s.DeleteVersion(ctx, "bucket", "file.txt", first.FileID); err != nil {
+		t.Fatalf("DeleteVersion failed: %v", err)
+	}
+	if obj := s.Object("bucket", "file.txt"); obj == nil || string(obj.Data) != "v2" {
+		t.Errorf("Expected v2 to remain, got %+v", obj)
+	}
+
+	if err := s.DeleteObject(ctx, "bucket", "file.txt"); err != nil {
+		t.Fatalf("DeleteObject failed: %v", err)
+	}
+	if obj := s.Object("bucket", "file.txt"); obj != nil {
+		t.Errorf("Expected object to be deleted, got %+v", obj)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	ctx := context.Background()
+	s := New("bucket")
+
+	if _, err := s.GetObjectInfo(ctx, "bucket", "missing"); !apperrors.IsNotFound(err) {
+		t.Errorf("Missing object: expected not-found error, got %v", err)
+	}
+	if err := s.DeleteObject(ctx, "bucket", "missing"); !apperrors.IsNotFound(err) {
+		t.Errorf("Delete missing object: expected not-found error, got %v", err)
+	}
+	if _, err := s.ListObjects(ctx, "other", ""); !apperrors.IsNotFound(err) {
+		t.Errorf("Missing bucket: expected not-found error, got %v", err)
+	}
+	if err := s.Upload(ctx, "other", "file.txt", strings.NewReader("x"), 1, nil); !apperrors.IsNotFound(err) {
+		t.Errorf("Upload to missing bucket: expected not-found error, got %v", err)
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	if _, err := s.CreateBucket(ctx, "bucket", ""); err != nil {
+		t.Fatalf("CreateBucket failed: %v", err)
+	}
+	if _, err := s.CreateBucket(ctx, "bucket", ""); !errors.Is(err, apperrors.ErrBucketExists) {
+		t.Errorf("Expected ErrBucketExists, got %v", err)
+	}
+
+	s.Put("bucket", "file.txt", []byte("x"), time.Now())
+	if err := s.DeleteBucket(ctx, "bucket", false); !errors.Is(err, apperrors.ErrBucketNotEmpty) {
+		t.Errorf("Expected ErrBucketNotEmpty, got %v", err)
+	}
+	if err := s.DeleteBucket(ctx, "bucket", true); err != nil {
+		t.Fatalf("Forced DeleteBucket failed: %v", err)
+	}
+
+	buckets, err := s.ListBucketInfo(ctx)
+	if err != nil || len(buckets) != 0 {
+		t.Errorf("Expected no buckets, got %v, %v", buckets, err)
+	}
+}
@@ -1,44 +1,110 @@
 package sync
 
 import (
-	"crypto/sha1"
-	"encoding/hex"
-	"io"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/ryanoboyle/bb-stream/pkg/checksum"
+	"github.com/ryanoboyle/bb-stream/pkg/ignore"
 )
 
 // FileInfo represents a file for comparison
 type FileInfo struct {
-	Path      string
-	Size      int64
-	ModTime   int64
-	SHA1      string
-	IsDir     bool
-	IsRemote  bool
+	Path     string
+	Size     int64
+	ModTime  int64
+	SHA1     string
+	SHA256   string
+	IsDir    bool
+	IsRemote bool
 }
 
 // DiffResult contains the result of comparing two file sets
 type DiffResult struct {
-	ToUpload   []FileInfo // Files that need to be uploaded (local → remote)
-	ToDownload []FileInfo // Files that need to be downloaded (remote → local)
-	ToDelete   []FileInfo // Files that need to be deleted
-	Unchanged  []FileInfo // Files that are the same
+	ToUpload   []FileInfo     // Files that need to be uploaded (local → remote)
+	ToDownload []FileInfo     // Files that need to be downloaded (remote → local)
+	ToDelete   []FileInfo     // Files that need to be deleted
+	Unchanged  []FileInfo     // Files that are the same
+	Conflicts  []FileConflict // Files changed on both sides with no clear winner (bidirectional only)
+}
+
+// FileConflict describes a file that differs on both sides where neither copy
+// is newer by more than the timestamp tolerance
+type FileConflict struct {
+	Path       string
+	Local      FileInfo
+	Remote     FileInfo
+	Resolution string // "upload", "download" or "skipped" once resolved
+}
+
+// ConflictPolicy decides which copy wins when a file changed on both sides
+type ConflictPolicy int
+
+const (
+	PreferNewer  ConflictPolicy = iota // Keep the copy with the later modification time
+	PreferLocal                        // Always keep the local copy
+	PreferRemote                       // Always keep the remote copy
+	Skip                               // Leave both copies untouched
+)
+
+// ParseConflictPolicy returns the policy named by s: newer, local, remote or
+// skip. An empty name selects PreferNewer.
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "newer":
+		return PreferNewer, nil
+	case "local":
+		return PreferLocal, nil
+	case "remote":
+		return PreferRemote, nil
+	case "skip":
+		return Skip, nil
+	}
+	return 0, fmt.Errorf("unknown conflict policy %q (use newer, local, remote or skip)", s)
+}
+
+func (p ConflictPolicy) String() string {
+	switch p {
+	case PreferLocal:
+		return "local"
+	case PreferRemote:
+		return "remote"
+	case Skip:
+		return "skip"
+	default:
+		return "newer"
+	}
 }
 
 // DiffOptions configures the diff operation
 type DiffOptions struct {
-	DeleteExtra bool   // Delete files that exist only in destination
-	Checksum    bool   // Use SHA1 checksum for comparison (slower but more accurate)
-	IgnorePatterns []string // Patterns to ignore
+	DeleteExtra   bool // Delete files that exist only in the destination named by Direction; not for bidirectional diffs
+	Checksum      bool // Compare checksums, SHA256 where both sides have one (slower but more accurate)
+	Bidirectional bool // Send changed files toward the newer side and report ties as conflicts
+	// Mirror makes the destination an exact copy of the source named by Direction:
+	// every difference flows from the source and destination-only files are deleted
+	Mirror    bool
+	Direction Direction
+	// TimeToleranceSeconds is the modification time skew still treated as equal;
+	// zero or less uses DefaultTimeTolerance
+	TimeToleranceSeconds int64
+	MinModTime           int64           // Only transfer files modified at or after this Unix time; 0 means no bound
+	MaxModTime           int64           // Only transfer files modified at or before this Unix time; 0 means no bound
+	IgnorePatterns       []string        // Patterns to ignore
+	Matcher              *ignore.Matcher // Prebuilt matcher, e.g. with .bbignore rules; overrides IgnorePatterns
 }
 
+// DefaultTimeTolerance covers FAT's 2-second mtime granularity and upload timestamp drift
+const DefaultTimeTolerance = 2
+
 // DefaultDiffOptions returns sensible defaults
 func DefaultDiffOptions() *DiffOptions {
 	return &DiffOptions{
-		DeleteExtra: false,
-		Checksum:    false,
+		DeleteExtra:          false,
+		Checksum:             false,
+		TimeToleranceSeconds: DefaultTimeTolerance,
 		IgnorePatterns: []string{
 			".git",
 			".DS_Store",
@@ -56,29 +122,45 @@ func Diff(local, remote []FileInfo, opts *DiffOptions) *DiffResult {
 		opts = DefaultDiffOptions()
 	}
 
+	tolerance := opts.TimeToleranceSeconds
+	if tolerance <= 0 {
+		tolerance = DefaultTimeTolerance
+	}
+
 	result := &DiffResult{
 		ToUpload:   []FileInfo{},
 		ToDownload: []FileInfo{},
 		ToDelete:   []FileInfo{},
 		Unchanged:  []FileInfo{},
+		Conflicts:  []FileConflict{},
 	}
 
 	// Create maps for quick lookup
 	localMap := make(map[string]FileInfo)
 	remoteMap := make(map[string]FileInfo)
 
+	matcher := opts.Matcher
+	if matcher == nil {
+		matcher = ignore.New(opts.IgnorePatterns)
+	}
+
 	for _, f := range local {
-		if !shouldIgnore(f.Path, opts.IgnorePatterns) {
+		if !matcher.Match(f.Path, f.IsDir) {
 			localMap[f.Path] = f
 		}
 	}
 
 	for _, f := range remote {
-		if !shouldIgnore(f.Path, opts.IgnorePatterns) {
+		if !matcher.Match(f.Path, f.IsDir) {
 			remoteMap[f.Path] = f
 		}
 	}
 
+	if opts.Mirror {
+		mirrorDiff(result, localMap, remoteMap, opts, tolerance)
+		return result
+	}
+
 	// Find files to upload (in local but not in remote, or different)
 	for path, localFile := range localMap {
 		if localFile.IsDir {
@@ -88,10 +170,35 @@ func Diff(local, remote []FileInfo, opts *DiffOptions) *DiffResult {
 		remoteFile, exists := remoteMap[path]
 		if !exists {
 			// File exists locally but not remotely - upload
-			result.ToUpload = append(result.ToUpload, localFile)
-		} else if !filesEqual(localFile, remoteFile, opts.Checksum) {
-			// File exists in both but is different - upload
-			result.ToUpload = append(result.ToUpload, localFile)
+			if opts.inTimeRange(localFile) {
+				result.ToUpload = append(result.ToUpload, localFile)
+			}
+		} else if !filesEqual(localFile, remoteFile, opts.Checksum, tolerance) {
+			// File exists in both but is different
+			delta := modTimeDelta(localFile, remoteFile)
+			switch {
+			case !opts.Bidirectional && opts.Direction == ToLocal:
+				// One-way pull - the remote copy replaces the local one
+				if opts.inTimeRange(remoteFile) {
+					result.ToDownload = append(result.ToDownload, remoteFile)
+				}
+			case !opts.Bidirectional || delta > tolerance:
+				// One-way push, or local is clearly newer - upload
+				if opts.inTimeRange(localFile) {
+					result.ToUpload = append(result.ToUpload, localFile)
+				}
+			case delta < -tolerance:
+				// Remote is clearly newer - download
+				if opts.inTimeRange(remoteFile) {
+					result.ToDownload = append(result.ToDownload, remoteFile)
+				}
+			case opts.inTimeRange(localFile) || opts.inTimeRange(remoteFile):
+				result.Conflicts = append(result.Conflicts, FileConflict{
+					Path:   path,
+					Local:  localFile,
+					Remote: remoteFile,
+				})
+			}
 		} else {
 			// Files are the same
 			result.Unchanged = append(result.Unchanged, localFile)
@@ -105,20 +212,25 @@ func Diff(local, remote []FileInfo, opts *DiffOptions) *DiffResult {
 		}
 
 		_, exists := localMap[path]
-		if !exists {
+		if !exists && opts.inTimeRange(remoteFile) {
 			// File exists remotely but not locally - download
 			result.ToDownload = append(result.ToDownload, remoteFile)
 		}
 	}
 
-	// Find files to delete if DeleteExtra is enabled
-	if opts.DeleteExtra {
-		for path, remoteFile := range remoteMap {
-			if remoteFile.IsDir {
+	// Find destination-only files to delete if DeleteExtra is enabled. The
+	// destination is local for ToLocal, so a pull never deletes its source.
+	if opts.DeleteExtra && !opts.Bidirectional {
+		src, dst := localMap, remoteMap
+		if opts.Direction == ToLocal {
+			src, dst = remoteMap, localMap
+		}
+		for path, dstFile := range dst {
+			if dstFile.IsDir {
 				continue
 			}
-			if _, exists := localMap[path]; !exists {
-				result.ToDelete = append(result.ToDelete, remoteFile)
+			if _, exists := src[path]; !exists && opts.inTimeRange(dstFile) {
+				result.ToDelete = append(result.ToDelete, dstFile)
 			}
 		}
 	}
@@ -126,52 +238,120 @@ func Diff(local, remote []FileInfo, opts *DiffOptions) *DiffResult {
 	return result
 }
 
-// filesEqual compares two files for equality
-func filesEqual(local, remote FileInfo, useChecksum bool) bool {
+// mirrorDiff fills result so the destination side ends up identical to the source side.
+// Deletions carry IsRemote to tell which side they apply to.
+func mirrorDiff(result *DiffResult, localMap, remoteMap map[string]FileInfo, opts *DiffOptions, tolerance int64) {
+	src, dst := localMap, remoteMap
+	transfers := &result.ToUpload
+	if opts.Direction == ToLocal {
+		src, dst = remoteMap, localMap
+		transfers = &result.ToDownload
+	}
+
+	for path, srcFile := range src {
+		if srcFile.IsDir {
+			continue
+		}
+		if _, exists := dst[path]; exists && filesEqual(localMap[path], remoteMap[path], opts.Checksum, tolerance) {
+			result.Unchanged = append(result.Unchanged, srcFile)
+		} else if opts.inTimeRange(srcFile) {
+			*transfers = append(*transfers, srcFile)
+		}
+	}
+
+	for path, dstFile := range dst {
+		if dstFile.IsDir {
+			continue
+		}
+		if _, exists := src[path]; !exists && opts.inTimeRange(dstFile) {
+			result.ToDelete = append(result.ToDelete, dstFile)
+		}
+	}
+}
+
+// inTimeRange reports whether f's modification time is within MinModTime and MaxModTime
+func (o *DiffOptions) inTimeRange(f FileInfo) bool {
+	mtime := toSeconds(f.ModTime)
+	if o.MinModTime > 0 && mtime < o.MinModTime {
+		return false
+	}
+	if o.MaxModTime > 0 && mtime > o.MaxModTime {
+		return false
+	}
+	return true
+}
+
+// filesEqual compares two files for equality, allowing tolerance seconds of mtime skew
+func filesEqual(local, remote FileInfo, useChecksum bool, tolerance int64) bool {
 	// Size must match
 	if local.Size != remote.Size {
 		return false
 	}
 
-	// If using checksum, compare SHA1
-	if useChecksum && local.SHA1 != "" && remote.SHA1 != "" {
-		return local.SHA1 == remote.SHA1
+	// If using checksum, compare the strongest hash both sides know
+	if useChecksum {
+		if local.SHA256 != "" && remote.SHA256 != "" {
+			return local.SHA256 == remote.SHA256
+		}
+		if local.SHA1 != "" && remote.SHA1 != "" {
+			return local.SHA1 == remote.SHA1
+		}
 	}
 
 	// Otherwise, compare by modification time
-	// B2 returns timestamps in milliseconds, local files use Unix seconds
-	// Truncate both to second precision for reliable comparison
-	localSeconds := local.ModTime
-	remoteSeconds := remote.ModTime
-
-	// If remote timestamp appears to be in milliseconds (very large), convert to seconds
-	if remoteSeconds > 1e12 {
-		remoteSeconds = remoteSeconds / 1000
-	}
-
-	// Allow 1 second tolerance for filesystem timestamp differences
-	diff := localSeconds - remoteSeconds
+	diff := modTimeDelta(local, remote)
 	if diff < 0 {
 		diff = -diff
 	}
-	return diff <= 1
+	return diff <= tolerance
+}
+
+// modTimeDelta returns how many seconds newer the local file is than the remote one
+func modTimeDelta(local, remote FileInfo) int64 {
+	return toSeconds(local.ModTime) - toSeconds(remote.ModTime)
+}
+
+// toSeconds normalizes a Unix timestamp to whole seconds.
+// B2 returns timestamps in milliseconds while local files use seconds;
+// anything past 1e12 (year 33658 in seconds) must be milliseconds.
+func toSeconds(ts int64) int64 {
+	if ts > 1e12 {
+		return ts / 1000
+	}
+	return ts
 }
 
-// shouldIgnore checks if a path should be ignored
-func shouldIgnore(path string, patterns []string) bool {
-	for _, pattern := range patterns {
-		// Simple matching - check if pattern appears in path
-		if strings.Contains(path, pattern) {
-			return true
+// ResolveConflicts applies policy to the detected conflicts, moving each into
+// ToUpload or ToDownload, and returns them with their resolution recorded
+func (d *DiffResult) ResolveConflicts(policy ConflictPolicy) []FileConflict {
+	resolved := make([]FileConflict, 0, len(d.Conflicts))
+	for _, c := range d.Conflicts {
+		upload, download := false, false
+		switch policy {
+		case PreferLocal:
+			upload = true
+		case PreferRemote:
+			download = true
+		case PreferNewer:
+			// Within the tolerance, so any remaining difference is the best signal we have
+			delta := modTimeDelta(c.Local, c.Remote)
+			upload, download = delta > 0, delta < 0
 		}
-		// Also check just the filename
-		filename := filepath.Base(path)
-		matched, _ := filepath.Match(pattern, filename)
-		if matched {
-			return true
+
+		switch {
+		case upload:
+			c.Resolution = "upload"
+			d.ToUpload = append(d.ToUpload, c.Local)
+		case download:
+			c.Resolution = "download"
+			d.ToDownload = append(d.ToDownload, c.Remote)
+		default:
+			c.Resolution = "skipped"
 		}
+		resolved = append(resolved, c)
 	}
-	return false
+	d.Conflicts = nil
+	return resolved
 }
 
 // ScanLocalDir scans a local directory and returns file info
@@ -207,7 +387,7 @@ func ScanLocalDir(root string, computeChecksum bool) ([]FileInfo, error) {
 
 		// Compute SHA1 if requested and it's a file
 		if computeChecksum && !info.IsDir() {
-			sha1, err := computeSHA1(path)
+			sha1, err := checksum.SHA1.File(path)
 			if err == nil {
 				fileInfo.SHA1 = sha1
 			}
@@ -220,20 +400,49 @@ func ScanLocalDir(root string, computeChecksum bool) ([]FileInfo, error) {
 	return files, err
 }
 
-// computeSHA1 computes the SHA1 hash of a file
-func computeSHA1(path string) (string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", err
+// checksumOf returns f's hash for the algorithm, or empty if it isn't known
+func checksumOf(f FileInfo, algo checksum.Algorithm) string {
+	if algo == checksum.SHA256 {
+		return f.SHA256
+	}
+	return f.SHA1
+}
+
+// setChecksum records sum as f's hash for the algorithm
+func setChecksum(f *FileInfo, algo checksum.Algorithm, sum string) {
+	if algo == checksum.SHA256 {
+		f.SHA256 = sum
+	} else {
+		f.SHA1 = sum
 	}
-	defer f.Close()
+}
 
-	h := sha1.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
+// ComputeChecksums fills in the algo hash for local files whose size matches a
+// remote file with a known hash of that kind. Files that differ in size are never
+// hashed since they will be transferred regardless, which keeps checksum syncs
+// cheap on large trees.
+func ComputeChecksums(root string, local, remote []FileInfo, algo checksum.Algorithm) {
+	remoteMap := make(map[string]FileInfo, len(remote))
+	for _, f := range remote {
+		remoteMap[f.Path] = f
 	}
 
-	return hex.EncodeToString(h.Sum(nil)), nil
+	for i := range local {
+		file := &local[i]
+		if file.IsDir || checksumOf(*file, algo) != "" {
+			continue
+		}
+
+		remoteFile, exists := remoteMap[file.Path]
+		if !exists || checksumOf(remoteFile, algo) == "" || remoteFile.Size != file.Size {
+			continue
+		}
+
+		sum, err := algo.File(filepath.Join(root, filepath.FromSlash(file.Path)))
+		if err == nil {
+			setChecksum(file, algo, sum)
+		}
+	}
 }
 
 // Summary returns a summary of the diff result
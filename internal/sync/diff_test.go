@@ -4,6 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/ryanoboyle/bb-stream/pkg/checksum"
+	"github.com/ryanoboyle/bb-stream/pkg/ignore"
 )
 
 func TestDiff_NewFilesToUpload(t *testing.T) {
@@ -93,6 +96,24 @@ func TestDiff_DeleteExtra(t *testing.T) {
 	}
 }
 
+func TestDiff_DeleteExtraToLocal(t *testing.T) {
+	local := []FileInfo{
+		{Path: "keep.txt", Size: 100, ModTime: 1000},
+		{Path: "stale.txt", Size: 10, ModTime: 1000},
+	}
+	remote := []FileInfo{
+		{Path: "keep.txt", Size: 100, ModTime: 1000, IsRemote: true},
+		{Path: "new.txt", Size: 50, ModTime: 500, IsRemote: true},
+	}
+
+	result := Diff(local, remote, &DiffOptions{DeleteExtra: true, Direction: ToLocal})
+
+	// Only the local side is pruned; the remote source is never deleted
+	if len(result.ToDelete) != 1 || result.ToDelete[0].Path != "stale.txt" || result.ToDelete[0].IsRemote {
+		t.Errorf("Expected only local stale.txt to be deleted, got %v", result.ToDelete)
+	}
+}
+
 func TestDiff_IgnorePatterns(t *testing.T) {
 	local := []FileInfo{
 		{Path: "file.txt", Size: 100, ModTime: 1000},
@@ -118,7 +139,7 @@ func TestFilesEqual_SameSize(t *testing.T) {
 	local := FileInfo{Path: "file.txt", Size: 100, ModTime: 1000}
 	remote := FileInfo{Path: "file.txt", Size: 100, ModTime: 1000}
 
-	if !filesEqual(local, remote, false) {
+	if !filesEqual(local, remote, false, DefaultTimeTolerance) {
 		t.Error("Files with same size and time should be equal")
 	}
 }
@@ -127,7 +148,7 @@ func TestFilesEqual_DifferentSize(t *testing.T) {
 	local := FileInfo{Path: "file.txt", Size: 100, ModTime: 1000}
 	remote := FileInfo{Path: "file.txt", Size: 200, ModTime: 1000}
 
-	if filesEqual(local, remote, false) {
+	if filesEqual(local, remote, false, DefaultTimeTolerance) {
 		t.Error("Files with different sizes should not be equal")
 	}
 }
@@ -136,7 +157,7 @@ func TestFilesEqual_TimeTolerance(t *testing.T) {
 	local := FileInfo{Path: "file.txt", Size: 100, ModTime: 1000}
 	remote := FileInfo{Path: "file.txt", Size: 100, ModTime: 1001} // 1 second difference
 
-	if !filesEqual(local, remote, false) {
+	if !filesEqual(local, remote, false, DefaultTimeTolerance) {
 		t.Error("Files with 1 second time difference should be equal (tolerance)")
 	}
 }
@@ -145,18 +166,32 @@ func TestFilesEqual_Checksum(t *testing.T) {
 	local := FileInfo{Path: "file.txt", Size: 100, ModTime: 1000, SHA1: "abc123"}
 	remote := FileInfo{Path: "file.txt", Size: 100, ModTime: 2000, SHA1: "abc123"}
 
-	if !filesEqual(local, remote, true) {
+	if !filesEqual(local, remote, true, DefaultTimeTolerance) {
 		t.Error("Files with same checksum should be equal regardless of time")
 	}
 
 	remote.SHA1 = "different"
-	if filesEqual(local, remote, true) {
+	if filesEqual(local, remote, true, DefaultTimeTolerance) {
 		t.Error("Files with different checksums should not be equal")
 	}
 }
 
-func TestShouldIgnore(t *testing.T) {
-	patterns := []string{".git", "node_modules", "*.pyc"}
+func TestFilesEqual_PrefersSHA256(t *testing.T) {
+	local := FileInfo{Path: "file.txt", Size: 100, SHA1: "abc123", SHA256: "def456"}
+	remote := FileInfo{Path: "file.txt", Size: 100, SHA1: "abc123", SHA256: "other"}
+
+	if filesEqual(local, remote, true, DefaultTimeTolerance) {
+		t.Error("Expected a SHA256 mismatch to win over matching SHA1s")
+	}
+
+	remote.SHA256 = ""
+	if !filesEqual(local, remote, true, DefaultTimeTolerance) {
+		t.Error("Expected SHA1 to be compared when only one side has a SHA256")
+	}
+}
+
+func TestIgnoreMatcher_SyncPatterns(t *testing.T) {
+	matcher := ignore.New([]string{".git", "node_modules", "*.pyc", "build", "*.log"})
 
 	tests := []struct {
 		path     string
@@ -167,12 +202,16 @@ func TestShouldIgnore(t *testing.T) {
 		{"__pycache__/file.pyc", true},
 		{"src/main.go", false},
 		{"README.md", false},
+		{"build/out.o", true},
+		{"rebuild.go", false},
+		{"src/building/x", false},
+		{"logs/nested/app.log", true},
 	}
 
 	for _, tt := range tests {
-		result := shouldIgnore(tt.path, patterns)
+		result := matcher.Match(tt.path, false)
 		if result != tt.expected {
-			t.Errorf("shouldIgnore(%s) = %v, expected %v", tt.path, result, tt.expected)
+			t.Errorf("Match(%s) = %v, expected %v", tt.path, result, tt.expected)
 		}
 	}
 }
@@ -220,6 +259,42 @@ func TestScanLocalDir(t *testing.T) {
 	}
 }
 
+func TestComputeChecksums_OnlySizeMatches(t *testing.T) {
+	tempDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tempDir, "same.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "bigger.txt"), []byte("hello world"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	local, err := ScanLocalDir(tempDir, false)
+	if err != nil {
+		t.Fatalf("ScanLocalDir failed: %v", err)
+	}
+
+	remote := []FileInfo{
+		{Path: "same.txt", Size: 5, SHA1: "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d", IsRemote: true},
+		{Path: "bigger.txt", Size: 5, SHA1: "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d", IsRemote: true},
+	}
+
+	ComputeChecksums(tempDir, local, remote, checksum.SHA1)
+
+	for _, f := range local {
+		switch f.Path {
+		case "same.txt":
+			if f.SHA1 != "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d" {
+				t.Errorf("Expected SHA1 for size-matching file, got %q", f.SHA1)
+			}
+		case "bigger.txt":
+			if f.SHA1 != "" {
+				t.Errorf("Expected no SHA1 for size-mismatched file, got %q", f.SHA1)
+			}
+		}
+	}
+}
+
 func TestDiffSummary(t *testing.T) {
 	result := &DiffResult{
 		ToUpload:   []FileInfo{{Path: "a.txt", Size: 100}, {Path: "b.txt", Size: 200}},
@@ -246,3 +321,196 @@ func TestDiffSummary(t *testing.T) {
 		t.Errorf("Expected UnchangedCount=1, got %d", summary.UnchangedCount)
 	}
 }
+
+func TestDiff_BidirectionalConflicts(t *testing.T) {
+	local := []FileInfo{
+		{Path: "local-newer.txt", Size: 150, ModTime: 1700002000},
+		{Path: "remote-newer.txt", Size: 150, ModTime: 1700001000},
+		{Path: "conflict.txt", Size: 150, ModTime: 1700001000},
+	}
+	remote := []FileInfo{
+		{Path: "local-newer.txt", Size: 100, ModTime: 1700001000 * 1000, IsRemote: true},
+		{Path: "remote-newer.txt", Size: 100, ModTime: 1700002000 * 1000, IsRemote: true},
+		{Path: "conflict.txt", Size: 100, ModTime: 1700001001 * 1000, IsRemote: true},
+	}
+
+	result := Diff(local, remote, &DiffOptions{Bidirectional: true})
+
+	if len(result.ToUpload) != 1 || result.ToUpload[0].Path != "local-newer.txt" {
+		t.Errorf("Expected only local-newer.txt to upload, got %v", result.ToUpload)
+	}
+	if len(result.ToDownload) != 1 || result.ToDownload[0].Path != "remote-newer.txt" {
+		t.Errorf("Expected only remote-newer.txt to download, got %v", result.ToDownload)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Path != "conflict.txt" {
+		t.Fatalf("Expected conflict.txt to conflict, got %v", result.Conflicts)
+	}
+
+	// One-way syncs never report conflicts
+	result = Diff(local, remote, nil)
+	if len(result.Conflicts) != 0 || len(result.ToUpload) != 3 {
+		t.Errorf("Expected 3 uploads and no conflicts, got %d uploads and %d conflicts",
+			len(result.ToUpload), len(result.Conflicts))
+	}
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	for _, name := range []string{"newer", "local", "remote", "skip"} {
+		policy, err := ParseConflictPolicy(name)
+		if err != nil || policy.String() != name {
+			t.Errorf("ParseConflictPolicy(%q) = %v, %v", name, policy, err)
+		}
+	}
+	if policy, err := ParseConflictPolicy(""); err != nil || policy != PreferNewer {
+		t.Errorf("Expected an empty policy to select newer, got %v, %v", policy, err)
+	}
+	if _, err := ParseConflictPolicy("both"); err == nil {
+		t.Error("Expected an unknown policy to be rejected")
+	}
+}
+
+func TestResolveConflicts(t *testing.T) {
+	conflict := FileConflict{
+		Path:   "a.txt",
+		Local:  FileInfo{Path: "a.txt", Size: 10, ModTime: 1700001000},
+		Remote: FileInfo{Path: "a.txt", Size: 20, ModTime: 1700001001 * 1000, IsRemote: true},
+	}
+
+	tests := []struct {
+		policy     ConflictPolicy
+		resolution string
+		uploads    int
+		downloads  int
+	}{
+		{PreferNewer, "download", 0, 1},
+		{PreferLocal, "upload", 1, 0},
+		{PreferRemote, "download", 0, 1},
+		{Skip, "skipped", 0, 0},
+	}
+
+	for _, tt := range tests {
+		diff := &DiffResult{Conflicts: []FileConflict{conflict}}
+		resolved := diff.ResolveConflicts(tt.policy)
+
+		if len(resolved) != 1 || resolved[0].Resolution != tt.resolution {
+			t.Errorf("policy %d: expected resolution %q, got %v", tt.policy, tt.resolution, resolved)
+		}
+		if len(diff.ToUpload) != tt.uploads || len(diff.ToDownload) != tt.downloads {
+			t.Errorf("policy %d: expected %d uploads and %d downloads, got %d and %d",
+				tt.policy, tt.uploads, tt.downloads, len(diff.ToUpload), len(diff.ToDownload))
+		}
+		if len(diff.Conflicts) != 0 {
+			t.Errorf("policy %d: expected conflicts to be cleared", tt.policy)
+		}
+	}
+}
+
+func TestDiff_FATTimestampSkew(t *testing.T) {
+	// FAT stores mtimes at 2-second granularity, so the local copy can read
+	// up to 2 seconds behind the upload timestamp
+	local := []FileInfo{
+		{Path: "photo.jpg", Size: 100, ModTime: 1700000000},
+	}
+	remote := []FileInfo{
+		{Path: "photo.jpg", Size: 100, ModTime: 1700000002500, IsRemote: true},
+	}
+
+	result := Diff(local, remote, nil)
+	if len(result.ToUpload) != 0 || len(result.Unchanged) != 1 {
+		t.Errorf("Expected 2s skew to be unchanged, got %d uploads", len(result.ToUpload))
+	}
+
+	// A tighter tolerance flags it again
+	result = Diff(local, remote, &DiffOptions{TimeToleranceSeconds: 1})
+	if len(result.ToUpload) != 1 {
+		t.Errorf("Expected 2s skew to exceed a 1s tolerance, got %d uploads", len(result.ToUpload))
+	}
+}
+
+func TestToSeconds(t *testing.T) {
+	if got := toSeconds(1700000000); got != 1700000000 {
+		t.Errorf("Expected seconds to pass through, got %d", got)
+	}
+	if got := toSeconds(1700000000999); got != 1700000000 {
+		t.Errorf("Expected milliseconds to truncate to seconds, got %d", got)
+	}
+}
+
+func TestDiff_ModTimeRange(t *testing.T) {
+	local := []FileInfo{
+		{Path: "old-local.txt", Size: 10, ModTime: 1000},
+		{Path: "new-local.txt", Size: 10, ModTime: 5000},
+	}
+	remote := []FileInfo{
+		{Path: "old-remote.txt", Size: 10, ModTime: 1000, IsRemote: true},
+		{Path: "new-remote.txt", Size: 10, ModTime: 5000, IsRemote: true},
+	}
+
+	result := Diff(local, remote, &DiffOptions{MinModTime: 3000, DeleteExtra: true})
+	if len(result.ToUpload) != 1 || result.ToUpload[0].Path != "new-local.txt" {
+		t.Errorf("Expected only new-local.txt to upload, got %v", result.ToUpload)
+	}
+	if len(result.ToDownload) != 1 || result.ToDownload[0].Path != "new-remote.txt" {
+		t.Errorf("Expected only new-remote.txt to download, got %v", result.ToDownload)
+	}
+	if len(result.ToDelete) != 1 || result.ToDelete[0].Path != "new-remote.txt" {
+		t.Errorf("Expected only new-remote.txt to delete, got %v", result.ToDelete)
+	}
+
+	result = Diff(local, remote, &DiffOptions{MaxModTime: 3000})
+	if len(result.ToUpload) != 1 || result.ToUpload[0].Path != "old-local.txt" {
+		t.Errorf("Expected only old-local.txt to upload, got %v", result.ToUpload)
+	}
+	if len(result.ToDownload) != 1 || result.ToDownload[0].Path != "old-remote.txt" {
+		t.Errorf("Expected only old-remote.txt to download, got %v", result.ToDownload)
+	}
+}
+
+func TestDiff_Mirror(t *testing.T) {
+	local := []FileInfo{
+		{Path: "same.txt", Size: 10, ModTime: 1000},
+		{Path: "changed.txt", Size: 20, ModTime: 1000},
+		{Path: "local-only.txt", Size: 30, ModTime: 1000},
+	}
+	remote := []FileInfo{
+		{Path: "same.txt", Size: 10, ModTime: 1000, IsRemote: true},
+		{Path: "changed.txt", Size: 25, ModTime: 5000, IsRemote: true},
+		{Path: "remote-only.txt", Size: 40, ModTime: 1000, IsRemote: true},
+	}
+
+	t.Run("ToRemote", func(t *testing.T) {
+		result := Diff(local, remote, &DiffOptions{Mirror: true, Direction: ToRemote})
+
+		if len(result.ToDownload) != 0 {
+			t.Errorf("Expected no downloads, got %v", result.ToDownload)
+		}
+		if len(result.ToUpload) != 2 {
+			t.Errorf("Expected changed.txt and local-only.txt to upload, got %v", result.ToUpload)
+		}
+		if len(result.ToDelete) != 1 || result.ToDelete[0].Path != "remote-only.txt" || !result.ToDelete[0].IsRemote {
+			t.Errorf("Expected remote-only.txt to be deleted remotely, got %v", result.ToDelete)
+		}
+		if len(result.Unchanged) != 1 {
+			t.Errorf("Expected 1 unchanged file, got %d", len(result.Unchanged))
+		}
+	})
+
+	t.Run("ToLocal", func(t *testing.T) {
+		result := Diff(local, remote, &DiffOptions{Mirror: true, Direction: ToLocal})
+
+		if len(result.ToUpload) != 0 {
+			t.Errorf("Expected no uploads, got %v", result.ToUpload)
+		}
+		if len(result.ToDownload) != 2 {
+			t.Errorf("Expected changed.txt and remote-only.txt to download, got %v", result.ToDownload)
+		}
+		for _, f := range result.ToDownload {
+			if !f.IsRemote {
+				t.Errorf("Expected remote file info for %s", f.Path)
+			}
+		}
+		if len(result.ToDelete) != 1 || result.ToDelete[0].Path != "local-only.txt" || result.ToDelete[0].IsRemote {
+			t.Errorf("Expected local-only.txt to be deleted locally, got %v", result.ToDelete)
+		}
+	})
+}
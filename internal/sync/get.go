@@ -0,0 +1,134 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Get downloads every object under remotePath into localPath without
+// comparing against what's already there. Subdirectories below the prefix
+// are recreated locally, or with flatten every file lands directly in
+// localPath, with a numeric suffix added to names that would collide.
+// Transfers use the same worker pool, retries and error handling as Sync.
+func (s *Syncer) Get(ctx context.Context, bucketName, remotePath, localPath string, flatten bool) (*SyncResult, error) {
+	startTime := time.Now()
+	result := &SyncResult{}
+
+	localPath = filepath.Clean(localPath)
+	remotePath = normalizeRemotePrefix(remotePath)
+
+	s.reportStatus(SyncStatus{Phase: "Scanning remote files"})
+	objects, err := s.client.ListObjects(ctx, bucketName, remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote objects: %w", err)
+	}
+
+	// Folder placeholders have nothing to download
+	var files []FileInfo
+	for _, f := range remoteFileInfos(objects, remotePath) {
+		if !strings.HasSuffix(f.Path, "/") {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	// Local names relative to localPath, keyed by remote path
+	targets := make(map[string]string, len(files))
+	if flatten {
+		paths := make([]string, len(files))
+		for i, f := range files {
+			paths[i] = f.Path
+		}
+		for i, name := range flattenNames(paths) {
+			targets[files[i].Path] = name
+		}
+	} else {
+		for _, f := range files {
+			targets[f.Path] = f.Path
+		}
+	}
+
+	var bytesTotal int64
+	for _, f := range files {
+		bytesTotal += f.Size
+	}
+	s.reportStatus(SyncStatus{Phase: "Planning", FilesTotal: len(files), BytesTotal: bytesTotal})
+	sortFiles(files, s.opts.Order)
+
+	if s.opts.DryRun {
+		result.WouldDownload = make([]string, 0, len(files))
+		for _, f := range files {
+			result.WouldDownload = append(result.WouldDownload, targets[f.Path])
+		}
+		result.Downloaded = len(result.WouldDownload)
+		result.Duration = time.Since(startTime)
+		return result, nil
+	}
+
+	ctx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
+	fail := s.failFunc(result, abort)
+
+	var completed, downloaded, bytesDownloaded int64
+	s.forEach(ctx, files, func(file FileInfo) {
+		defer atomic.AddInt64(&completed, 1)
+
+		localFilePath, err := validateRelativePath(localPath, targets[file.Path])
+		if err != nil {
+			fail(fmt.Errorf("invalid path %s: %w", file.Path, err))
+			return
+		}
+
+		s.reportStatus(SyncStatus{
+			Phase:          "Downloading",
+			CurrentFile:    file.Path,
+			FilesTotal:     len(files),
+			FilesCompleted: int(atomic.LoadInt64(&completed)),
+		})
+		n, err := s.downloadFile(ctx, bucketName, remotePath+file.Path, localFilePath, file.ModTime)
+		if err != nil {
+			fail(fmt.Errorf("download %s: %w", file.Path, err))
+			return
+		}
+		atomic.AddInt64(&downloaded, 1)
+		atomic.AddInt64(&bytesDownloaded, n)
+	})
+	result.Downloaded = int(downloaded)
+	result.BytesDownloaded = bytesDownloaded
+
+	s.reportStatus(SyncStatus{
+		Phase:          "Complete",
+		FilesTotal:     len(files),
+		FilesCompleted: int(atomic.LoadInt64(&completed)),
+	})
+	result.finish(startTime)
+
+	return result, context.Cause(ctx)
+}
+
+// flattenNames maps slash-separated paths to unique base names, in the same
+// order. Later duplicates get -1, -2, ... inserted before the extension.
+func flattenNames(paths []string) []string {
+	used := make(map[string]bool, len(paths))
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		base := path.Base(p)
+		ext := path.Ext(base)
+		stem := strings.TrimSuffix(base, ext)
+
+		name := base
+		for n := 1; used[name]; n++ {
+			name = stem + "-" + strconv.Itoa(n) + ext
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
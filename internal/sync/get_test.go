@@ -0,0 +1,104 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/internal/memstore"
+)
+
+func TestFlattenNames(t *testing.T) {
+	got := flattenNames([]string{"a/photo.jpg", "b/photo.jpg", "c/photo.jpg", "photo-1.jpg", "README"})
+	want := []string{"photo.jpg", "photo-1.jpg", "photo-2.jpg", "photo-1-1.jpg", "README"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("flattenNames()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New("bucket")
+	store.Put("bucket", "photos/a.jpg", []byte("a"), time.Now())
+	store.Put("bucket", "photos/2024/a.jpg", []byte("a2024"), time.Now())
+	store.Put("bucket", "other/b.jpg", []byte("b"), time.Now())
+
+	tests := []struct {
+		name    string
+		flatten bool
+		want    map[string]string
+	}{
+		{"nested", false, map[string]string{"a.jpg": "a", "2024/a.jpg": "a2024"}},
+		{"flatten", true, map[string]string{"a.jpg": "a2024", "a-1.jpg": "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			result, err := NewSyncer(store, DefaultSyncOptions()).Get(ctx, "bucket", "photos", dir, tt.flatten)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if result.Downloaded != len(tt.want) || result.BytesDownloaded != 6 {
+				t.Errorf("Expected %d files and 6 bytes, got %d and %d (errors: %v)",
+					len(tt.want), result.Downloaded, result.BytesDownloaded, result.Errors)
+			}
+			for name, content := range tt.want {
+				data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+				if err != nil || string(data) != content {
+					t.Errorf("%s: got %q (%v), want %q", name, data, err, content)
+				}
+			}
+		})
+	}
+}
+
+func TestGet_DryRun(t *testing.T) {
+	store := memstore.New("bucket")
+	store.Put("bucket", "photos/a.jpg", []byte("a"), time.Now())
+
+	opts := DefaultSyncOptions()
+	opts.DryRun = true
+	dir := t.TempDir()
+	result, err := NewSyncer(store, opts).Get(context.Background(), "bucket", "photos/", dir, false)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(result.WouldDownload) != 1 || result.WouldDownload[0] != "a.jpg" {
+		t.Errorf("Expected WouldDownload [a.jpg], got %v", result.WouldDownload)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Dry run wrote %d entries", len(entries))
+	}
+}
+
+func TestGet_DryRunOrder(t *testing.T) {
+	store := memstore.New("bucket")
+	store.Put("bucket", "photos/a.jpg", []byte("aaa"), time.Now())
+	store.Put("bucket", "photos/b.jpg", []byte("b"), time.Now())
+	store.Put("bucket", "photos/c.jpg", []byte("cc"), time.Now())
+
+	opts := DefaultSyncOptions()
+	opts.DryRun = true
+	opts.Order = OrderLargestFirst
+	result, err := NewSyncer(store, opts).Get(context.Background(), "bucket", "photos", t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	// The plan lists files in the order they would download
+	want := []string{"a.jpg", "c.jpg", "b.jpg"}
+	if len(result.WouldDownload) != len(want) {
+		t.Fatalf("Expected WouldDownload %v, got %v", want, result.WouldDownload)
+	}
+	for i := range want {
+		if result.WouldDownload[i] != want[i] {
+			t.Errorf("Expected WouldDownload %v, got %v", want, result.WouldDownload)
+			break
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Order is the order in which a sync starts its transfers
+type Order int
+
+const (
+	OrderByPath        Order = iota // Lexical path order, so re-runs go the same way
+	OrderSmallestFirst              // Ascending size, so many small files finish early
+	OrderLargestFirst               // Descending size, so the longest transfers start first
+)
+
+// ParseOrder returns the order named by s: path, smallest or largest.
+// An empty name selects OrderByPath.
+func ParseOrder(s string) (Order, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "path":
+		return OrderByPath, nil
+	case "smallest":
+		return OrderSmallestFirst, nil
+	case "largest":
+		return OrderLargestFirst, nil
+	}
+	return 0, fmt.Errorf("unknown order %q (use path, smallest or largest)", s)
+}
+
+func (o Order) String() string {
+	switch o {
+	case OrderSmallestFirst:
+		return "smallest"
+	case OrderLargestFirst:
+		return "largest"
+	default:
+		return "path"
+	}
+}
+
+// sortFiles sorts files in place by order. Files of equal size keep path
+// order, so every order is deterministic.
+func sortFiles(files []FileInfo, order Order) {
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.Size != b.Size {
+			switch order {
+			case OrderSmallestFirst:
+				return a.Size < b.Size
+			case OrderLargestFirst:
+				return a.Size > b.Size
+			}
+		}
+		return a.Path < b.Path
+	})
+}
@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ryanoboyle/bb-stream/internal/memstore"
+)
+
+func TestParseOrder(t *testing.T) {
+	for _, name := range []string{"path", "smallest", "largest"} {
+		order, err := ParseOrder(name)
+		if err != nil || order.String() != name {
+			t.Errorf("ParseOrder(%q) = %v, %v", name, order, err)
+		}
+	}
+	if order, err := ParseOrder(""); err != nil || order != OrderByPath {
+		t.Errorf("Expected an empty order to select path order, got %v, %v", order, err)
+	}
+	if _, err := ParseOrder("random"); err == nil {
+		t.Error("Expected an unknown order to be rejected")
+	}
+}
+
+func TestSync_Order(t *testing.T) {
+	src := t.TempDir()
+	sizes := map[string]int{"c.txt": 30, "a.txt": 20, "b.txt": 10, "d.txt": 20}
+	for name, size := range sizes {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(strings.Repeat("x", size)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		order Order
+		want  string
+	}{
+		{OrderByPath, "[a.txt b.txt c.txt d.txt]"},
+		{OrderSmallestFirst, "[b.txt a.txt d.txt c.txt]"},
+		{OrderLargestFirst, "[c.txt a.txt d.txt b.txt]"},
+	}
+	for _, tt := range tests {
+		var started []string
+		opts := DefaultSyncOptions()
+		opts.NoIgnoreFile = true
+		opts.Concurrent = 1
+		opts.Order = tt.order
+		opts.ProgressCallback = func(status SyncStatus) {
+			if status.Phase == "Uploading" {
+				started = append(started, status.CurrentFile)
+			}
+		}
+
+		if _, err := NewSyncer(memstore.New("bucket"), opts).Sync(context.Background(), src, "bucket", ""); err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(started); got != tt.want {
+			t.Errorf("%s order uploaded %s, want %s", tt.order, got, tt.want)
+		}
+
+		// Dry runs list files in the same order
+		opts.DryRun = true
+		result, err := NewSyncer(memstore.New("bucket"), opts).Sync(context.Background(), src, "bucket", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(result.WouldUpload); got != tt.want {
+			t.Errorf("%s order dry run listed %s, want %s", tt.order, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package sync
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -12,7 +14,10 @@ import (
 	"time"
 
 	"github.com/ryanoboyle/bb-stream/internal/b2"
+	"github.com/ryanoboyle/bb-stream/pkg/checksum"
+	"github.com/ryanoboyle/bb-stream/pkg/ignore"
 	"github.com/ryanoboyle/bb-stream/pkg/progress"
+	"github.com/ryanoboyle/bb-stream/pkg/retry"
 )
 
 // validateRelativePath ensures a relative path from diff results is safe.
@@ -39,35 +44,94 @@ func validateRelativePath(basePath, relativePath string) (string, error) {
 	return joined, nil
 }
 
+// normalizeRemotePrefix converts a remote path to a slash-terminated prefix, or "" for the bucket root
+func normalizeRemotePrefix(remotePath string) string {
+	remotePath = strings.Trim(filepath.ToSlash(remotePath), "/")
+	if remotePath == "" {
+		return ""
+	}
+	return remotePath + "/"
+}
+
+// remoteFileInfos converts listed objects to FileInfo with paths relative to prefix.
+// prefix must already be normalized; objects outside it, and the prefix itself, are skipped.
+func remoteFileInfos(objects []b2.ObjectInfo, prefix string) []FileInfo {
+	files := make([]FileInfo, 0, len(objects))
+	for _, obj := range objects {
+		if !strings.HasPrefix(obj.Name, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(obj.Name, prefix)
+		if name == "" {
+			continue
+		}
+		files = append(files, FileInfo{
+			Path:     name,
+			Size:     obj.Size,
+			ModTime:  obj.Timestamp,
+			SHA1:     obj.SHA1,
+			SHA256:   obj.SHA256,
+			IsRemote: true,
+		})
+	}
+	return files
+}
+
 // Direction specifies the sync direction
 type Direction int
 
 const (
-	ToRemote Direction = iota // Local → B2
-	ToLocal                   // B2 → Local
-	Bidirectional             // Both directions
+	ToRemote      Direction = iota // Local → B2
+	ToLocal                        // B2 → Local
+	Bidirectional                  // Both directions
 )
 
 // SyncOptions configures a sync operation
 type SyncOptions struct {
-	Direction       Direction
-	DryRun          bool
-	Delete          bool // Delete files in destination that don't exist in source
-	Checksum        bool // Use checksum for comparison
-	Concurrent      int  // Number of concurrent transfers
-	IgnorePatterns  []string
+	Direction        Direction
+	DryRun           bool
+	Delete           bool           // Delete files in destination that don't exist in source; the same as Mirror
+	Mirror           bool           // Make the destination an exact copy of the source; implies Delete
+	Checksum         bool           // Use checksum for comparison
+	Concurrent       int            // Number of concurrent transfers
+	ConflictPolicy   ConflictPolicy // How bidirectional syncs resolve files changed on both sides
+	IgnorePatterns   []string
+	MinModTime       int64         // Only transfer files modified at or after this Unix time; 0 means no bound
+	MaxModTime       int64         // Only transfer files modified at or before this Unix time; 0 means no bound
+	NoIgnoreFile     bool          // Skip loading .bbignore files from the local tree
+	MaxBytesPerSec   int64         // Total bandwidth cap shared by all transfers; 0 means unlimited
+	Retry            *retry.Config // Per-file retry policy for transient failures; nil uses retry.DefaultConfig()
+	MaxErrors        int           // Errors kept in SyncResult before truncating; 0 uses DefaultMaxErrors
+	AbortOnSystemic  bool          // Abort once MaxErrors is reached if every error has the same cause
+	FailFast         bool          // Stop at the first file that still fails after retries
 	ProgressCallback func(status SyncStatus)
+
+	// ChecksumAlgorithm is the hash compared by Checksum and Verify, and stored
+	// on upload when it's SHA256; the zero value is SHA1
+	ChecksumAlgorithm checksum.Algorithm
+
+	// PreserveModTime sets each downloaded file's modification time to the
+	// object's upload time, so the next sync doesn't see it as newer
+	PreserveModTime bool
+
+	// Order is the order uploads and downloads start in, and that dry runs
+	// list them in; the zero value is path order. Deletes go in path order.
+	Order Order
+
+	// Stop, once closed, stops new transfers from starting while those in
+	// progress finish. Cancelling the context abandons them as well.
+	Stop <-chan struct{}
 }
 
 // SyncStatus represents the current sync progress
 type SyncStatus struct {
-	Phase           string
-	CurrentFile     string
-	FilesTotal      int
-	FilesCompleted  int
-	BytesTotal      int64
+	Phase            string
+	CurrentFile      string
+	FilesTotal       int
+	FilesCompleted   int
+	BytesTotal       int64
 	BytesTransferred int64
-	Errors          []string
+	Errors           []string
 }
 
 // DefaultSyncOptions returns sensible defaults
@@ -78,6 +142,10 @@ func DefaultSyncOptions() *SyncOptions {
 		Delete:     false,
 		Checksum:   false,
 		Concurrent: 4,
+		Retry:      retry.DefaultConfig(),
+		MaxErrors:  DefaultMaxErrors,
+
+		PreserveModTime: true,
 		IgnorePatterns: []string{
 			".git",
 			".DS_Store",
@@ -87,53 +155,142 @@ func DefaultSyncOptions() *SyncOptions {
 	}
 }
 
+// ignoreMatcher combines the configured ignore patterns with any .bbignore
+// files in the local tree, unless disabled
+func ignoreMatcher(localPath string, opts *SyncOptions) (*ignore.Matcher, error) {
+	if opts.NoIgnoreFile {
+		return ignore.New(opts.IgnorePatterns), nil
+	}
+	matcher, err := ignore.LoadDir(localPath, opts.IgnorePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s files: %w", ignore.FileName, err)
+	}
+	return matcher, nil
+}
+
 // Syncer handles sync operations
 type Syncer struct {
-	client *b2.Client
-	opts   *SyncOptions
+	client   b2.Storage
+	opts     *SyncOptions
+	parallel int        // Number of worker goroutines; they share MaxBytesPerSec
+	statusMu sync.Mutex // Serializes progress callbacks from worker goroutines
 }
 
-// NewSyncer creates a new syncer
-func NewSyncer(client *b2.Client, opts *SyncOptions) *Syncer {
+// NewSyncer creates a new syncer that runs opts.Concurrent transfers at a time
+func NewSyncer(client b2.Storage, opts *SyncOptions) *Syncer {
 	if opts == nil {
 		opts = DefaultSyncOptions()
 	}
+	parallel := opts.Concurrent
+	if parallel < 1 {
+		parallel = 1
+	}
 	return &Syncer{
-		client: client,
-		opts:   opts,
+		client:   client,
+		opts:     opts,
+		parallel: parallel,
 	}
 }
 
+// DefaultMaxErrors is how many errors a SyncResult keeps before truncating
+const DefaultMaxErrors = 100
+
+// ErrSystemicFailure is returned when a sync aborts because every error had the same cause
+var ErrSystemicFailure = errors.New("sync aborted after repeated identical errors")
+
 // SyncResult contains the results of a sync operation
 type SyncResult struct {
-	Uploaded   int
-	Downloaded int
-	Deleted    int
-	Skipped    int
-	Errors     []error
-	Duration   time.Duration
+	Uploaded            int
+	Downloaded          int
+	Deleted             int
+	Skipped             int
+	Conflicts           []FileConflict // Files changed on both sides and how each was resolved
+	WouldUpload         []string       // Dry run only: paths that would be uploaded, in SyncOptions.Order
+	WouldDownload       []string       // Dry run only: paths that would be downloaded, in SyncOptions.Order
+	WouldDelete         []string       // Dry run only: paths that would be deleted, sorted
+	BytesUploaded       int64
+	BytesDownloaded     int64
+	Throughput          float64 // Bytes per second over the whole sync
+	Errors              []error
+	TruncatedErrorCount int // Errors dropped after the MaxErrors cap was reached
+	Duration            time.Duration
+}
+
+// MarshalJSON renders Errors as messages, since error values have no JSON form
+func (r SyncResult) MarshalJSON() ([]byte, error) {
+	type plain SyncResult
+	errs := make([]string, len(r.Errors))
+	for i, err := range r.Errors {
+		errs[i] = err.Error()
+	}
+	return json.Marshal(struct {
+		plain
+		Errors []string
+	}{plain(r), errs})
+}
+
+// addError stores err unless max errors are already stored, in which case it
+// only counts it. It reports whether this error filled the cap with errors
+// that all share one root cause.
+func (r *SyncResult) addError(err error, max int) bool {
+	if len(r.Errors) >= max {
+		r.TruncatedErrorCount++
+		return false
+	}
+	r.Errors = append(r.Errors, err)
+	return len(r.Errors) == max && sameRootCause(r.Errors)
+}
+
+// sameRootCause reports whether every error unwraps to the same message
+func sameRootCause(errs []error) bool {
+	for _, err := range errs[1:] {
+		if rootCause(err).Error() != rootCause(errs[0]).Error() {
+			return false
+		}
+	}
+	return true
+}
+
+// rootCause returns the innermost error in a wrap chain
+func rootCause(err error) error {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+}
+
+// finish records the elapsed time and the resulting throughput
+func (r *SyncResult) finish(startTime time.Time) {
+	r.Duration = time.Since(startTime)
+	if secs := r.Duration.Seconds(); secs > 0 {
+		r.Throughput = float64(r.BytesUploaded+r.BytesDownloaded) / secs
+	}
 }
 
 // Sync performs a sync operation between local directory and B2 bucket
 func (s *Syncer) Sync(ctx context.Context, localPath, bucketName, remotePath string) (*SyncResult, error) {
+	if s.opts.Mirror && s.opts.Direction == Bidirectional {
+		return nil, fmt.Errorf("mirror requires a one-way sync direction")
+	}
+	if s.opts.Delete && s.opts.Direction == Bidirectional {
+		return nil, fmt.Errorf("delete requires a one-way sync direction")
+	}
+
 	startTime := time.Now()
 	result := &SyncResult{}
 
 	// Normalize paths
 	localPath = filepath.Clean(localPath)
-	remotePath = filepath.ToSlash(remotePath)
-	if remotePath != "" && remotePath[len(remotePath)-1] != '/' {
-		remotePath += "/"
-	}
-	if remotePath == "/" {
-		remotePath = ""
-	}
+	remotePath = normalizeRemotePrefix(remotePath)
 
 	// Report status
 	s.reportStatus(SyncStatus{Phase: "Scanning local files"})
 
 	// Scan local files
-	localFiles, err := ScanLocalDir(localPath, s.opts.Checksum)
+	localFiles, err := ScanLocalDir(localPath, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan local directory: %w", err)
 	}
@@ -147,401 +304,387 @@ func (s *Syncer) Sync(ctx context.Context, localPath, bucketName, remotePath str
 		return nil, fmt.Errorf("failed to list remote objects: %w", err)
 	}
 
-	// Convert remote objects to FileInfo
-	remoteFiles := make([]FileInfo, len(remoteObjects))
-	for i, obj := range remoteObjects {
-		// Remove remote path prefix for comparison
-		name := obj.Name
-		if remotePath != "" && len(name) > len(remotePath) {
-			name = name[len(remotePath):]
-		}
-		remoteFiles[i] = FileInfo{
-			Path:     name,
-			Size:     obj.Size,
-			ModTime:  obj.Timestamp,
-			IsRemote: true,
-		}
+	// Convert remote objects to FileInfo relative to the prefix
+	remoteFiles := remoteFileInfos(remoteObjects, remotePath)
+
+	// Hash only local files that could match a remote checksum
+	if s.opts.Checksum {
+		ComputeChecksums(localPath, localFiles, remoteFiles, s.opts.ChecksumAlgorithm)
 	}
 
-	// Calculate diff
+	matcher, err := ignoreMatcher(localPath, s.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Calculate diff. Delete gets mirror semantics: the destination is made
+	// to match the source, and only destination-side files are deleted.
 	diffOpts := &DiffOptions{
 		DeleteExtra:    s.opts.Delete,
 		Checksum:       s.opts.Checksum,
+		Bidirectional:  s.opts.Direction == Bidirectional,
+		Mirror:         s.deletes(),
+		Direction:      s.opts.Direction,
+		MinModTime:     s.opts.MinModTime,
+		MaxModTime:     s.opts.MaxModTime,
 		IgnorePatterns: s.opts.IgnorePatterns,
+		Matcher:        matcher,
 	}
 	diff := Diff(localFiles, remoteFiles, diffOpts)
+	result.Conflicts = diff.ResolveConflicts(s.opts.ConflictPolicy)
 	summary := diff.Summary()
 
+	// The diff comes from map iteration; transfer in a predictable order
+	sortFiles(diff.ToUpload, s.opts.Order)
+	sortFiles(diff.ToDownload, s.opts.Order)
+	sortFiles(diff.ToDelete, OrderByPath)
+
 	// Report plan
+	filesTotal := s.plannedTransfers(diff)
 	s.reportStatus(SyncStatus{
 		Phase:      "Planning",
-		FilesTotal: summary.ToUploadCount + summary.ToDownloadCount + summary.ToDeleteCount,
+		FilesTotal: filesTotal,
 		BytesTotal: summary.ToUploadSize + summary.ToDownloadSize,
 	})
 
 	// Handle dry run
 	if s.opts.DryRun {
-		result.Uploaded = summary.ToUploadCount
-		result.Downloaded = summary.ToDownloadCount
-		result.Deleted = summary.ToDeleteCount
-		result.Skipped = summary.UnchangedCount
+		s.planDryRun(diff, result)
 		result.Duration = time.Since(startTime)
 		return result, nil
 	}
 
+	// Files processed so far across all workers (success or failure)
+	var completed int64
+
+	// Lets fail-fast or a systemic failure stop the remaining transfers
+	ctx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
+
+	fail := s.failFunc(result, abort)
+	report := func(phase, file string) {
+		s.reportStatus(SyncStatus{
+			Phase:          phase,
+			CurrentFile:    file,
+			FilesTotal:     filesTotal,
+			FilesCompleted: int(atomic.LoadInt64(&completed)),
+		})
+	}
+
 	// Perform uploads
 	if s.opts.Direction == ToRemote || s.opts.Direction == Bidirectional {
-		for _, file := range diff.ToUpload {
-			select {
-			case <-ctx.Done():
-				return result, ctx.Err()
-			default:
-			}
-
-			s.reportStatus(SyncStatus{
-				Phase:       "Uploading",
-				CurrentFile: file.Path,
-			})
+		var uploaded, bytesUploaded int64
+		s.forEach(ctx, diff.ToUpload, func(file FileInfo) {
+			defer atomic.AddInt64(&completed, 1)
 
 			localFilePath, err := validateRelativePath(localPath, file.Path)
 			if err != nil {
-				result.Errors = append(result.Errors, fmt.Errorf("invalid path %s: %w", file.Path, err))
-				continue
+				fail(fmt.Errorf("invalid path %s: %w", file.Path, err))
+				return
 			}
-			remoteFilePath := remotePath + file.Path
 
-			err = s.uploadFile(ctx, localFilePath, bucketName, remoteFilePath)
+			report("Uploading", file.Path)
+			n, err := s.uploadFile(ctx, localFilePath, bucketName, remotePath+file.Path)
 			if err != nil {
-				result.Errors = append(result.Errors, fmt.Errorf("upload %s: %w", file.Path, err))
-			} else {
-				result.Uploaded++
+				fail(fmt.Errorf("upload %s: %w", file.Path, err))
+				return
 			}
-		}
+			atomic.AddInt64(&uploaded, 1)
+			atomic.AddInt64(&bytesUploaded, n)
+		})
+		result.Uploaded = int(uploaded)
+		result.BytesUploaded = bytesUploaded
 	}
 
 	// Perform downloads
 	if s.opts.Direction == ToLocal || s.opts.Direction == Bidirectional {
-		for _, file := range diff.ToDownload {
-			select {
-			case <-ctx.Done():
-				return result, ctx.Err()
-			default:
-			}
-
-			s.reportStatus(SyncStatus{
-				Phase:       "Downloading",
-				CurrentFile: file.Path,
-			})
+		var downloaded, bytesDownloaded int64
+		s.forEach(ctx, diff.ToDownload, func(file FileInfo) {
+			defer atomic.AddInt64(&completed, 1)
 
 			localFilePath, err := validateRelativePath(localPath, file.Path)
 			if err != nil {
-				result.Errors = append(result.Errors, fmt.Errorf("invalid path %s: %w", file.Path, err))
-				continue
+				fail(fmt.Errorf("invalid path %s: %w", file.Path, err))
+				return
 			}
-			remoteFilePath := remotePath + file.Path
 
-			err = s.downloadFile(ctx, bucketName, remoteFilePath, localFilePath)
+			report("Downloading", file.Path)
+			n, err := s.downloadFile(ctx, bucketName, remotePath+file.Path, localFilePath, file.ModTime)
 			if err != nil {
-				result.Errors = append(result.Errors, fmt.Errorf("download %s: %w", file.Path, err))
-			} else {
-				result.Downloaded++
+				fail(fmt.Errorf("download %s: %w", file.Path, err))
+				return
 			}
-		}
+			atomic.AddInt64(&downloaded, 1)
+			atomic.AddInt64(&bytesDownloaded, n)
+		})
+		result.Downloaded = int(downloaded)
+		result.BytesDownloaded = bytesDownloaded
 	}
 
 	// Perform deletions
-	if s.opts.Delete {
-		for _, file := range diff.ToDelete {
-			select {
-			case <-ctx.Done():
-				return result, ctx.Err()
-			default:
+	if s.deletes() {
+		var deleted int64
+		s.forEach(ctx, diff.ToDelete, func(file FileInfo) {
+			defer atomic.AddInt64(&completed, 1)
+
+			report("Deleting", file.Path)
+			if err := s.deleteEntry(ctx, file, localPath, bucketName, remotePath); err != nil {
+				fail(fmt.Errorf("delete %s: %w", file.Path, err))
+				return
 			}
+			atomic.AddInt64(&deleted, 1)
+		})
+		result.Deleted = int(deleted)
+	}
 
-			s.reportStatus(SyncStatus{
-				Phase:       "Deleting",
-				CurrentFile: file.Path,
-			})
+	s.reportStatus(SyncStatus{
+		Phase:          "Complete",
+		FilesTotal:     filesTotal,
+		FilesCompleted: int(atomic.LoadInt64(&completed)),
+	})
 
-			remoteFilePath := remotePath + file.Path
-			err := s.client.DeleteObject(ctx, bucketName, remoteFilePath)
-			if err != nil {
-				result.Errors = append(result.Errors, fmt.Errorf("delete %s: %w", file.Path, err))
-			} else {
-				result.Deleted++
-			}
+	result.Skipped = summary.UnchangedCount
+	result.finish(startTime)
+
+	return result, context.Cause(ctx)
+}
+
+// failFunc returns a thread-safe function that records a per-file error in
+// result, capped at MaxErrors, and aborts on fail-fast or a systemic failure
+func (s *Syncer) failFunc(result *SyncResult, abort context.CancelCauseFunc) func(error) {
+	maxErrors := s.opts.MaxErrors
+	if maxErrors <= 0 {
+		maxErrors = DefaultMaxErrors
+	}
+	var errorsMu sync.Mutex
+	return func(err error) {
+		errorsMu.Lock()
+		systemic := result.addError(err, maxErrors)
+		errorsMu.Unlock()
+		switch {
+		case s.opts.FailFast:
+			abort(fmt.Errorf("sync stopped at first error: %w", err))
+		case systemic && s.opts.AbortOnSystemic:
+			abort(fmt.Errorf("%w: %v", ErrSystemicFailure, rootCause(err)))
 		}
 	}
+}
 
-	result.Skipped = summary.UnchangedCount
-	result.Duration = time.Since(startTime)
+// forEach calls fn for each file from a pool of s.parallel workers.
+// Workers stop picking up new files once ctx is cancelled or Stop is closed.
+func (s *Syncer) forEach(ctx context.Context, files []FileInfo, fn func(FileInfo)) {
+	queue := make(chan FileInfo, len(files))
+	for _, f := range files {
+		queue <- f
+	}
+	close(queue)
+
+	workers := s.parallel
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range queue {
+				if ctx.Err() != nil || s.stopped() {
+					return
+				}
+				fn(file)
+			}
+		}()
+	}
+	wg.Wait()
+}
 
-	return result, nil
+// stopped reports whether Stop has been closed
+func (s *Syncer) stopped() bool {
+	select {
+	case <-s.opts.Stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// singleAttempt disables the client's own retries inside a per-file retry,
+// so a failing transfer isn't retried MaxAttempts² times
+var singleAttempt = &retry.Config{MaxAttempts: 1}
+
+// uploadFile uploads a single file, retrying transient failures, and returns its size
+func (s *Syncer) uploadFile(ctx context.Context, localPath, bucketName, remotePath string) (int64, error) {
+	return retry.DoWithResult(ctx, b2.LogRetries(s.opts.Retry, "sync_upload"), b2.IsRetryable, func() (int64, error) {
+		return s.uploadOnce(ctx, localPath, bucketName, remotePath)
+	})
 }
 
-// uploadFile uploads a single file
-func (s *Syncer) uploadFile(ctx context.Context, localPath, bucketName, remotePath string) error {
+// uploadOnce makes a single upload attempt from a freshly opened file
+func (s *Syncer) uploadOnce(ctx context.Context, localPath, bucketName, remotePath string) (int64, error) {
 	f, err := os.Open(localPath)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer f.Close()
 
 	info, err := f.Stat()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return s.client.Upload(ctx, bucketName, remotePath, f, info.Size(), nil)
-}
-
-// downloadFile downloads a single file
-func (s *Syncer) downloadFile(ctx context.Context, bucketName, remotePath, localPath string) error {
-	// Ensure directory exists
-	dir := filepath.Dir(localPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
+	opts := b2.DefaultUploadOptions()
+	opts.MaxBytesPerSec = s.transferRate()
+	opts.Retry = singleAttempt
+	opts.ChecksumAlgorithm = s.opts.ChecksumAlgorithm
+	if err := s.client.Upload(ctx, bucketName, remotePath, f, info.Size(), opts); err != nil {
+		return 0, err
 	}
+	return info.Size(), nil
+}
 
-	f, err := os.Create(localPath)
+// downloadFile downloads a single file, retrying transient failures, and returns the bytes written.
+// With PreserveModTime the file takes modTime, the object's upload time.
+func (s *Syncer) downloadFile(ctx context.Context, bucketName, remotePath, localPath string, modTime int64) (int64, error) {
+	n, err := retry.DoWithResult(ctx, b2.LogRetries(s.opts.Retry, "sync_download"), b2.IsRetryable, func() (int64, error) {
+		return s.downloadOnce(ctx, bucketName, remotePath, localPath)
+	})
 	if err != nil {
-		return err
+		return n, err
 	}
-	defer f.Close()
-
-	return s.client.Download(ctx, bucketName, remotePath, f, nil)
+	if s.opts.PreserveModTime && modTime > 0 {
+		mtime := time.Unix(toSeconds(modTime), 0)
+		if err := os.Chtimes(localPath, mtime, mtime); err != nil {
+			return n, fmt.Errorf("failed to set modification time: %w", err)
+		}
+	}
+	return n, nil
 }
 
-// reportStatus calls the progress callback if set
-func (s *Syncer) reportStatus(status SyncStatus) {
-	if s.opts.ProgressCallback != nil {
-		s.opts.ProgressCallback(status)
-	}
+// downloadOnce makes a single download attempt. The file is replaced only
+// once the download completes, so a failed attempt leaves no partial file.
+func (s *Syncer) downloadOnce(ctx context.Context, bucketName, remotePath, localPath string) (int64, error) {
+	opts := b2.DefaultDownloadOptions()
+	opts.MaxBytesPerSec = s.transferRate()
+	opts.Retry = singleAttempt
+	return s.client.DownloadToFile(ctx, bucketName, remotePath, localPath, opts)
 }
 
-// ConcurrentSyncer handles concurrent sync operations
-type ConcurrentSyncer struct {
-	*Syncer
-	workers int
+// deleteFile deletes a remote object, retrying transient failures
+func (s *Syncer) deleteFile(ctx context.Context, bucketName, remotePath string) error {
+	return retry.Do(ctx, b2.LogRetries(s.opts.Retry, "sync_delete"), b2.IsRetryable, func() error {
+		return s.client.DeleteObject(ctx, bucketName, remotePath)
+	})
 }
 
-// NewConcurrentSyncer creates a syncer with concurrent workers
-func NewConcurrentSyncer(client *b2.Client, opts *SyncOptions) *ConcurrentSyncer {
-	workers := 4
-	if opts != nil && opts.Concurrent > 0 {
-		workers = opts.Concurrent
+// deleteEntry deletes a ToDelete entry from whichever side it was found on
+func (s *Syncer) deleteEntry(ctx context.Context, file FileInfo, localPath, bucketName, remotePath string) error {
+	if file.IsRemote {
+		return s.deleteFile(ctx, bucketName, remotePath+file.Path)
 	}
-	return &ConcurrentSyncer{
-		Syncer:  NewSyncer(client, opts),
-		workers: workers,
+	localFilePath, err := validateRelativePath(localPath, file.Path)
+	if err != nil {
+		return err
 	}
+	return os.Remove(localFilePath)
 }
 
-// SyncConcurrent performs sync with concurrent transfers
-func (cs *ConcurrentSyncer) SyncConcurrent(ctx context.Context, localPath, bucketName, remotePath string) (*SyncResult, error) {
-	startTime := time.Now()
-	result := &SyncResult{}
+// deletes reports whether the sync removes destination-only files
+func (s *Syncer) deletes() bool {
+	return s.opts.Delete || s.opts.Mirror
+}
 
-	// Normalize paths
-	localPath = filepath.Clean(localPath)
-	remotePath = filepath.ToSlash(remotePath)
-	if remotePath != "" && remotePath[len(remotePath)-1] != '/' {
-		remotePath += "/"
+// transferRate splits the bandwidth cap evenly across parallel transfers
+func (s *Syncer) transferRate() int64 {
+	if s.opts.MaxBytesPerSec <= 0 || s.parallel <= 1 {
+		return s.opts.MaxBytesPerSec
 	}
-	if remotePath == "/" {
-		remotePath = ""
+	rate := s.opts.MaxBytesPerSec / int64(s.parallel)
+	if rate < 1 {
+		rate = 1
 	}
+	return rate
+}
 
-	// Scan and diff
-	localFiles, err := ScanLocalDir(localPath, cs.opts.Checksum)
-	if err != nil {
-		return nil, fmt.Errorf("failed to scan local directory: %w", err)
+// plannedTransfers returns the number of files the sync will act on for its direction
+func (s *Syncer) plannedTransfers(diff *DiffResult) int {
+	total := 0
+	if s.opts.Direction == ToRemote || s.opts.Direction == Bidirectional {
+		total += len(diff.ToUpload)
 	}
-
-	remoteObjects, err := cs.client.ListObjects(ctx, bucketName, remotePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list remote objects: %w", err)
+	if s.opts.Direction == ToLocal || s.opts.Direction == Bidirectional {
+		total += len(diff.ToDownload)
 	}
-
-	remoteFiles := make([]FileInfo, len(remoteObjects))
-	for i, obj := range remoteObjects {
-		name := obj.Name
-		if remotePath != "" && len(name) > len(remotePath) {
-			name = name[len(remotePath):]
-		}
-		remoteFiles[i] = FileInfo{
-			Path:     name,
-			Size:     obj.Size,
-			ModTime:  obj.Timestamp,
-			IsRemote: true,
-		}
+	if s.deletes() {
+		total += len(diff.ToDelete)
 	}
+	return total
+}
 
-	diffOpts := &DiffOptions{
-		DeleteExtra:    cs.opts.Delete,
-		Checksum:       cs.opts.Checksum,
-		IgnorePatterns: cs.opts.IgnorePatterns,
+// planDryRun records the paths a sync would act on for its direction, in the
+// order it would act on them
+func (s *Syncer) planDryRun(diff *DiffResult, result *SyncResult) {
+	if s.opts.Direction == ToRemote || s.opts.Direction == Bidirectional {
+		result.WouldUpload = orderedPaths(diff.ToUpload, s.opts.Order)
 	}
-	diff := Diff(localFiles, remoteFiles, diffOpts)
-
-	if cs.opts.DryRun {
-		summary := diff.Summary()
-		result.Uploaded = summary.ToUploadCount
-		result.Downloaded = summary.ToDownloadCount
-		result.Deleted = summary.ToDeleteCount
-		result.Skipped = summary.UnchangedCount
-		result.Duration = time.Since(startTime)
-		return result, nil
+	if s.opts.Direction == ToLocal || s.opts.Direction == Bidirectional {
+		result.WouldDownload = orderedPaths(diff.ToDownload, s.opts.Order)
 	}
-
-	// Thread-safe error collection
-	var errorsMu sync.Mutex
-	var errors []error
-
-	// Process uploads concurrently
-	if cs.opts.Direction == ToRemote || cs.opts.Direction == Bidirectional {
-		var uploaded int64
-		var wg sync.WaitGroup
-		uploadCh := make(chan FileInfo, len(diff.ToUpload))
-		for _, f := range diff.ToUpload {
-			uploadCh <- f
-		}
-		close(uploadCh)
-
-		for i := 0; i < cs.workers; i++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				for file := range uploadCh {
-					select {
-					case <-ctx.Done():
-						return
-					default:
-					}
-
-					localFilePath, err := validateRelativePath(localPath, file.Path)
-					if err != nil {
-						errorsMu.Lock()
-						errors = append(errors, fmt.Errorf("invalid path %s: %w", file.Path, err))
-						errorsMu.Unlock()
-						continue
-					}
-					remoteFilePath := remotePath + file.Path
-
-					cs.reportStatus(SyncStatus{
-						Phase:       "Uploading",
-						CurrentFile: file.Path,
-					})
-
-					if err := cs.uploadFile(ctx, localFilePath, bucketName, remoteFilePath); err != nil {
-						errorsMu.Lock()
-						errors = append(errors, fmt.Errorf("upload %s: %w", file.Path, err))
-						errorsMu.Unlock()
-					} else {
-						atomic.AddInt64(&uploaded, 1)
-					}
-				}
-			}()
-		}
-		wg.Wait()
-		result.Uploaded = int(atomic.LoadInt64(&uploaded))
+	if s.deletes() {
+		result.WouldDelete = orderedPaths(diff.ToDelete, OrderByPath)
 	}
+	result.Uploaded = len(result.WouldUpload)
+	result.Downloaded = len(result.WouldDownload)
+	result.Deleted = len(result.WouldDelete)
+	result.Skipped = len(diff.Unchanged)
+}
 
-	// Process downloads concurrently
-	if cs.opts.Direction == ToLocal || cs.opts.Direction == Bidirectional {
-		var downloaded int64
-		var wg sync.WaitGroup
-		downloadCh := make(chan FileInfo, len(diff.ToDownload))
-		for _, f := range diff.ToDownload {
-			downloadCh <- f
-		}
-		close(downloadCh)
-
-		for i := 0; i < cs.workers; i++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				for file := range downloadCh {
-					select {
-					case <-ctx.Done():
-						return
-					default:
-					}
-
-					localFilePath, err := validateRelativePath(localPath, file.Path)
-					if err != nil {
-						errorsMu.Lock()
-						errors = append(errors, fmt.Errorf("invalid path %s: %w", file.Path, err))
-						errorsMu.Unlock()
-						continue
-					}
-					remoteFilePath := remotePath + file.Path
-
-					cs.reportStatus(SyncStatus{
-						Phase:       "Downloading",
-						CurrentFile: file.Path,
-					})
-
-					if err := cs.downloadFile(ctx, bucketName, remoteFilePath, localFilePath); err != nil {
-						errorsMu.Lock()
-						errors = append(errors, fmt.Errorf("download %s: %w", file.Path, err))
-						errorsMu.Unlock()
-					} else {
-						atomic.AddInt64(&downloaded, 1)
-					}
-				}
-			}()
-		}
-		wg.Wait()
-		result.Downloaded = int(atomic.LoadInt64(&downloaded))
+// orderedPaths returns the paths of files sorted by order, leaving files as is
+func orderedPaths(files []FileInfo, order Order) []string {
+	sorted := append([]FileInfo(nil), files...)
+	sortFiles(sorted, order)
+	paths := make([]string, len(sorted))
+	for i, f := range sorted {
+		paths[i] = f.Path
 	}
+	return paths
+}
 
-	// Process deletions concurrently
-	if cs.opts.Delete {
-		var deleted int64
-		var wg sync.WaitGroup
-		deleteCh := make(chan FileInfo, len(diff.ToDelete))
-		for _, f := range diff.ToDelete {
-			deleteCh <- f
-		}
-		close(deleteCh)
-
-		for i := 0; i < cs.workers; i++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				for file := range deleteCh {
-					select {
-					case <-ctx.Done():
-						return
-					default:
-					}
-
-					cs.reportStatus(SyncStatus{
-						Phase:       "Deleting",
-						CurrentFile: file.Path,
-					})
-
-					remoteFilePath := remotePath + file.Path
-					if err := cs.client.DeleteObject(ctx, bucketName, remoteFilePath); err != nil {
-						errorsMu.Lock()
-						errors = append(errors, fmt.Errorf("delete %s: %w", file.Path, err))
-						errorsMu.Unlock()
-					} else {
-						atomic.AddInt64(&deleted, 1)
-					}
-				}
-			}()
-		}
-		wg.Wait()
-		result.Deleted = int(atomic.LoadInt64(&deleted))
+// reportStatus calls the progress callback if set.
+// Calls are serialized so the callback is safe to use from concurrent workers.
+func (s *Syncer) reportStatus(status SyncStatus) {
+	if s.opts.ProgressCallback == nil {
+		return
 	}
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.opts.ProgressCallback(status)
+}
 
-	result.Errors = errors
-	result.Skipped = len(diff.Unchanged)
-	result.Duration = time.Since(startTime)
+// ConcurrentSyncer is a Syncer that defaults to 4 workers when Concurrent is unset.
+//
+// Deprecated: Syncer already runs transfers on a pool of Concurrent workers; use NewSyncer.
+type ConcurrentSyncer struct {
+	*Syncer
+}
 
-	return result, nil
+// NewConcurrentSyncer creates a Syncer with 4 workers unless opts sets Concurrent.
+//
+// Deprecated: use NewSyncer.
+func NewConcurrentSyncer(client b2.Storage, opts *SyncOptions) *ConcurrentSyncer {
+	syncer := NewSyncer(client, opts)
+	if opts == nil || opts.Concurrent <= 0 {
+		syncer.parallel = 4
+	}
+	return &ConcurrentSyncer{Syncer: syncer}
+}
+
+// SyncConcurrent performs a sync.
+//
+// Deprecated: use Sync, which is the same.
+func (cs *ConcurrentSyncer) SyncConcurrent(ctx context.Context, localPath, bucketName, remotePath string) (*SyncResult, error) {
+	return cs.Sync(ctx, localPath, bucketName, remotePath)
 }
 
 // ProgressWriter wraps an io.Writer with progress reporting
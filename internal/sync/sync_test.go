@@ -2,8 +2,21 @@ package sync
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/internal/b2"
+	"github.com/ryanoboyle/bb-stream/internal/memstore"
+	"github.com/ryanoboyle/bb-stream/pkg/retry"
 )
 
 func TestDefaultSyncOptions(t *testing.T) {
@@ -191,22 +204,22 @@ func TestNewSyncer_CustomOptions(t *testing.T) {
 func TestNewConcurrentSyncer(t *testing.T) {
 	// Test with nil options
 	cs := NewConcurrentSyncer(nil, nil)
-	if cs.workers != 4 {
-		t.Errorf("Expected default workers=4, got %d", cs.workers)
+	if cs.parallel != 4 {
+		t.Errorf("Expected default workers=4, got %d", cs.parallel)
 	}
 
 	// Test with custom concurrent value
 	opts := &SyncOptions{Concurrent: 10}
 	cs = NewConcurrentSyncer(nil, opts)
-	if cs.workers != 10 {
-		t.Errorf("Expected workers=10, got %d", cs.workers)
+	if cs.parallel != 10 {
+		t.Errorf("Expected workers=10, got %d", cs.parallel)
 	}
 
 	// Test with zero concurrent (should use default)
 	opts = &SyncOptions{Concurrent: 0}
 	cs = NewConcurrentSyncer(nil, opts)
-	if cs.workers != 4 {
-		t.Errorf("Expected default workers=4 when Concurrent=0, got %d", cs.workers)
+	if cs.parallel != 4 {
+		t.Errorf("Expected default workers=4 when Concurrent=0, got %d", cs.parallel)
 	}
 }
 
@@ -422,3 +435,601 @@ func TestSyncResultWithErrors(t *testing.T) {
 		t.Error("Expected second error to be io.ErrShortWrite")
 	}
 }
+
+func TestSyncResult_Throughput(t *testing.T) {
+	result := &SyncResult{BytesUploaded: 3 << 20, BytesDownloaded: 1 << 20}
+	result.finish(time.Now().Add(-2 * time.Second))
+
+	// 4MB over ~2s
+	if result.Throughput < 1.9*(1<<20) || result.Throughput > 2.1*(1<<20) {
+		t.Errorf("Expected about 2MB/s, got %.0f B/s", result.Throughput)
+	}
+}
+
+func TestSyncerPlanDryRun(t *testing.T) {
+	diff := &DiffResult{
+		ToUpload:   []FileInfo{{Path: "b.txt"}, {Path: "a/z.txt"}, {Path: "a.txt"}},
+		ToDownload: []FileInfo{{Path: "remote.txt"}},
+		ToDelete:   []FileInfo{{Path: "stale.txt"}},
+		Unchanged:  []FileInfo{{Path: "same.txt"}},
+	}
+
+	syncer := NewSyncer(nil, &SyncOptions{Direction: ToRemote, Delete: true})
+	result := &SyncResult{}
+	syncer.planDryRun(diff, result)
+
+	expected := []string{"a.txt", "a/z.txt", "b.txt"}
+	if len(result.WouldUpload) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, result.WouldUpload)
+	}
+	for i, path := range expected {
+		if result.WouldUpload[i] != path {
+			t.Errorf("Expected %s at index %d, got %s", path, i, result.WouldUpload[i])
+		}
+	}
+
+	// Downloads don't happen when syncing to remote
+	if len(result.WouldDownload) != 0 || result.Downloaded != 0 {
+		t.Errorf("Expected no downloads for ToRemote, got %v", result.WouldDownload)
+	}
+	if len(result.WouldDelete) != 1 || result.Deleted != 1 {
+		t.Errorf("Expected stale.txt to be deleted, got %v", result.WouldDelete)
+	}
+	if result.Uploaded != 3 || result.Skipped != 1 {
+		t.Errorf("Expected Uploaded=3 Skipped=1, got %d and %d", result.Uploaded, result.Skipped)
+	}
+}
+
+// flakyStorage fails the first failures uploads with a connection reset.
+// Methods the syncer doesn't use are left to the nil embedded Storage.
+type flakyStorage struct {
+	b2.Storage
+	failures int
+	uploads  int
+	uploaded map[string]int64
+}
+
+func (f *flakyStorage) ListObjects(ctx context.Context, bucketName, prefix string) ([]b2.ObjectInfo, error) {
+	return nil, nil
+}
+
+func (f *flakyStorage) Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts *b2.UploadOptions) error {
+	f.uploads++
+	if f.uploads <= f.failures {
+		return fmt.Errorf("upload: %w", syscall.ECONNRESET)
+	}
+	n, err := io.Copy(io.Discard, reader)
+	if err != nil {
+		return err
+	}
+	f.uploaded[objectName] = n
+	return nil
+}
+
+func (f *flakyStorage) DownloadToFile(ctx context.Context, bucketName, objectName, localPath string, opts *b2.DownloadOptions) (int64, error) {
+	return 0, fmt.Errorf("unexpected download of %s", objectName)
+}
+
+func (f *flakyStorage) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	return fmt.Errorf("unexpected delete of %s", objectName)
+}
+
+func TestSyncConcurrent_RetriesTransientUpload(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultSyncOptions()
+	opts.NoIgnoreFile = true
+	opts.Retry = &retry.Config{MaxAttempts: 3, InitialWait: time.Millisecond, MaxWait: time.Millisecond, Multiplier: 1}
+
+	store := &flakyStorage{failures: 2, uploaded: map[string]int64{}}
+	cs := NewConcurrentSyncer(nil, opts)
+	cs.client = store
+
+	result, err := cs.SyncConcurrent(context.Background(), dir, "bucket", "backup")
+	if err != nil {
+		t.Fatalf("SyncConcurrent failed: %v", err)
+	}
+
+	if len(result.Errors) != 0 {
+		t.Errorf("Expected no errors after retry, got %v", result.Errors)
+	}
+	if result.Uploaded != 1 {
+		t.Errorf("Expected Uploaded=1, got %d", result.Uploaded)
+	}
+	if store.uploads != 3 {
+		t.Errorf("Expected 3 upload attempts, got %d", store.uploads)
+	}
+	if store.uploaded["backup/file.txt"] != 5 {
+		t.Errorf("Expected 5 bytes uploaded to backup/file.txt, got %v", store.uploaded)
+	}
+}
+
+func TestNormalizeRemotePrefix(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"/", ""},
+		{"a/b", "a/b/"},
+		{"a/b/", "a/b/"},
+		{"/a/b", "a/b/"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeRemotePrefix(tt.input); got != tt.expected {
+			t.Errorf("normalizeRemotePrefix(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestRemoteFileInfos(t *testing.T) {
+	objects := []b2.ObjectInfo{
+		{Name: "a/b/file.txt", Size: 1},
+		{Name: "a/b/nested/deep.txt", Size: 2},
+		{Name: "a/b/", Size: 0},   // Folder marker equal to the prefix
+		{Name: "a/b", Size: 3},    // One byte shorter than the prefix
+		{Name: "a/bc/other.txt"},  // Shares the prefix without the slash
+		{Name: "x/unrelated.txt"}, // Listed outside the prefix
+	}
+
+	for _, remotePath := range []string{"a/b", "a/b/"} {
+		files := remoteFileInfos(objects, normalizeRemotePrefix(remotePath))
+
+		if len(files) != 2 {
+			t.Fatalf("prefix %q: expected 2 files, got %v", remotePath, files)
+		}
+		if files[0].Path != "file.txt" || files[1].Path != "nested/deep.txt" {
+			t.Errorf("prefix %q: expected relative paths, got %q and %q", remotePath, files[0].Path, files[1].Path)
+		}
+		if !files[0].IsRemote {
+			t.Errorf("prefix %q: expected IsRemote to be set", remotePath)
+		}
+	}
+
+	// The bucket root keeps every name intact
+	if files := remoteFileInfos(objects, ""); len(files) != len(objects) {
+		t.Errorf("Expected all %d objects at the root, got %d", len(objects), len(files))
+	}
+}
+
+// slowStorage records how many uploads run at the same time
+type slowStorage struct {
+	flakyStorage
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (s *slowStorage) Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts *b2.UploadOptions) error {
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	s.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	s.mu.Lock()
+	s.inFlight--
+	s.uploads++
+	s.mu.Unlock()
+	return nil
+}
+
+func TestSync_UsesConcurrentWorkers(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 8; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.txt", i)), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var statusMu sync.Mutex
+	statusCalls := 0
+	opts := DefaultSyncOptions()
+	opts.NoIgnoreFile = true
+	opts.Concurrent = 4
+	opts.ProgressCallback = func(status SyncStatus) {
+		statusMu.Lock()
+		statusCalls++
+		statusMu.Unlock()
+	}
+
+	store := &slowStorage{}
+	syncer := NewSyncer(nil, opts)
+	syncer.client = store
+
+	result, err := syncer.Sync(context.Background(), dir, "bucket", "")
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if result.Uploaded != 8 || store.uploads != 8 {
+		t.Errorf("Expected 8 uploads, got Uploaded=%d calls=%d", result.Uploaded, store.uploads)
+	}
+	if store.maxInFlight < 2 || store.maxInFlight > 4 {
+		t.Errorf("Expected between 2 and 4 concurrent uploads, got %d", store.maxInFlight)
+	}
+	if statusCalls < 8 {
+		t.Errorf("Expected progress callback per file, got %d calls", statusCalls)
+	}
+}
+
+func TestSyncResult_MarshalJSON(t *testing.T) {
+	result := &SyncResult{Uploaded: 2, Errors: []error{io.ErrShortWrite}}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var decoded struct {
+		Uploaded int
+		Errors   []string
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.Uploaded != 2 {
+		t.Errorf("Expected Uploaded=2, got %d", decoded.Uploaded)
+	}
+	if len(decoded.Errors) != 1 || decoded.Errors[0] != io.ErrShortWrite.Error() {
+		t.Errorf("Expected error messages, got %v", decoded.Errors)
+	}
+}
+
+func TestSync_CapsErrors(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.txt", i)), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newSyncer := func(abort bool) (*Syncer, *flakyStorage) {
+		opts := DefaultSyncOptions()
+		opts.NoIgnoreFile = true
+		opts.Concurrent = 1
+		opts.MaxErrors = 3
+		opts.AbortOnSystemic = abort
+		opts.Retry = &retry.Config{MaxAttempts: 1}
+
+		store := &flakyStorage{failures: 100, uploaded: map[string]int64{}}
+		syncer := NewSyncer(nil, opts)
+		syncer.client = store
+		return syncer, store
+	}
+
+	syncer, _ := newSyncer(false)
+	result, err := syncer.Sync(context.Background(), dir, "bucket", "")
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(result.Errors) != 3 {
+		t.Errorf("Expected 3 stored errors, got %d", len(result.Errors))
+	}
+	if result.TruncatedErrorCount != 7 {
+		t.Errorf("Expected TruncatedErrorCount=7, got %d", result.TruncatedErrorCount)
+	}
+
+	// Every failure is the same connection reset, so the sync stops at the cap
+	syncer, store := newSyncer(true)
+	result, err = syncer.Sync(context.Background(), dir, "bucket", "")
+	if !errors.Is(err, ErrSystemicFailure) {
+		t.Fatalf("Expected ErrSystemicFailure, got %v", err)
+	}
+	if store.uploads != 3 {
+		t.Errorf("Expected the sync to stop after 3 uploads, got %d", store.uploads)
+	}
+	if result == nil || len(result.Errors) != 3 {
+		t.Errorf("Expected the partial result to keep its errors, got %+v", result)
+	}
+}
+
+func TestSameRootCause(t *testing.T) {
+	same := []error{
+		fmt.Errorf("upload a: %w", syscall.ECONNRESET),
+		fmt.Errorf("upload b: %w", fmt.Errorf("attempt: %w", syscall.ECONNRESET)),
+	}
+	if !sameRootCause(same) {
+		t.Error("Expected errors wrapping the same cause to match")
+	}
+
+	mixed := append(same, fmt.Errorf("upload c: %w", os.ErrPermission))
+	if sameRootCause(mixed) {
+		t.Error("Expected errors with different causes not to match")
+	}
+}
+
+func TestSync_FailFast(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.txt", i)), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := DefaultSyncOptions()
+	opts.NoIgnoreFile = true
+	opts.Concurrent = 1
+	opts.FailFast = true
+	opts.Retry = &retry.Config{MaxAttempts: 1}
+
+	// The first two uploads succeed, the third fails
+	store := &flakyStorage{uploaded: map[string]int64{}}
+	syncer := NewSyncer(nil, opts)
+	syncer.client = &failAfterStorage{flakyStorage: store, succeed: 2}
+
+	result, err := syncer.Sync(context.Background(), dir, "bucket", "")
+	if err == nil || !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("Expected the first upload error, got %v", err)
+	}
+	if result == nil {
+		t.Fatal("Expected a partial result")
+	}
+	if result.Uploaded != 2 {
+		t.Errorf("Expected Uploaded=2 for completed transfers, got %d", result.Uploaded)
+	}
+	if len(result.Errors) != 1 {
+		t.Errorf("Expected 1 error, got %v", result.Errors)
+	}
+	if store.uploads != 3 {
+		t.Errorf("Expected no uploads after the failure, got %d attempts", store.uploads)
+	}
+}
+
+// failAfterStorage lets the first succeed uploads through and fails the rest
+type failAfterStorage struct {
+	*flakyStorage
+	succeed int
+}
+
+func (f *failAfterStorage) Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts *b2.UploadOptions) error {
+	if f.uploads >= f.succeed {
+		f.uploads++
+		return fmt.Errorf("upload: %w", syscall.ECONNRESET)
+	}
+	return f.flakyStorage.Upload(ctx, bucketName, objectName, reader, size, opts)
+}
+
+// downloadStorage serves a fixed listing and downloads each object as its name
+type downloadStorage struct {
+	listStorage
+}
+
+func (d *downloadStorage) DownloadToFile(ctx context.Context, bucketName, objectName, localPath string, opts *b2.DownloadOptions) (int64, error) {
+	if err := os.WriteFile(localPath, []byte(objectName), 0644); err != nil {
+		return 0, err
+	}
+	return int64(len(objectName)), nil
+}
+
+func TestSync_PreserveModTime(t *testing.T) {
+	uploaded := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, preserve := range []bool{true, false} {
+		t.Run(fmt.Sprint(preserve), func(t *testing.T) {
+			dir := t.TempDir()
+			opts := DefaultSyncOptions()
+			opts.Direction = ToLocal
+			opts.NoIgnoreFile = true
+			opts.PreserveModTime = preserve
+
+			syncer := NewSyncer(nil, opts)
+			syncer.client = &downloadStorage{listStorage{objects: []b2.ObjectInfo{
+				{Name: "backup/file.txt", Size: 15, Timestamp: uploaded.Unix()},
+			}}}
+
+			result, err := syncer.Sync(context.Background(), dir, "bucket", "backup")
+			if err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+			if result.Downloaded != 1 {
+				t.Fatalf("Expected Downloaded=1, got %d (errors: %v)", result.Downloaded, result.Errors)
+			}
+
+			info, err := os.Stat(filepath.Join(dir, "file.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.ModTime().Equal(uploaded); got != preserve {
+				t.Errorf("ModTime = %v, want upload time: %v", info.ModTime(), preserve)
+			}
+		})
+	}
+}
+
+func TestSync_RoundTripMemstore(t *testing.T) {
+	ctx := context.Background()
+	src := t.TempDir()
+	files := map[string]string{
+		"a.txt":        "alpha",
+		"nested/b.txt": "bravo",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := memstore.New("bucket")
+	opts := DefaultSyncOptions()
+	opts.NoIgnoreFile = true
+
+	result, err := NewSyncer(store, opts).Sync(ctx, src, "bucket", "backup")
+	if err != nil {
+		t.Fatalf("Upload sync failed: %v", err)
+	}
+	if result.Uploaded != 2 {
+		t.Fatalf("Expected Uploaded=2, got %d (errors: %v)", result.Uploaded, result.Errors)
+	}
+	if obj := store.Object("bucket", "backup/nested/b.txt"); obj == nil || string(obj.Data) != "bravo" {
+		t.Errorf("Expected backup/nested/b.txt to hold bravo, got %+v", obj)
+	}
+
+	// A second upload sync finds nothing to do
+	result, err = NewSyncer(store, opts).Sync(ctx, src, "bucket", "backup")
+	if err != nil {
+		t.Fatalf("Repeat sync failed: %v", err)
+	}
+	if result.Uploaded != 0 {
+		t.Errorf("Expected repeat sync to upload nothing, got %d", result.Uploaded)
+	}
+
+	dest := t.TempDir()
+	opts.Direction = ToLocal
+	result, err = NewSyncer(store, opts).Sync(ctx, dest, "bucket", "backup")
+	if err != nil {
+		t.Fatalf("Download sync failed: %v", err)
+	}
+	if result.Downloaded != 2 {
+		t.Fatalf("Expected Downloaded=2, got %d (errors: %v)", result.Downloaded, result.Errors)
+	}
+	for name, content := range files {
+		data, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+		if err != nil || string(data) != content {
+			t.Errorf("%s: got %q, %v; want %q", name, data, err, content)
+		}
+	}
+}
+
+func TestSync_PullDeleteKeepsRemote(t *testing.T) {
+	ctx := context.Background()
+	dest := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dest, "stale.txt"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := memstore.New("bucket")
+	store.Put("bucket", "backup/a.txt", []byte("alpha"), time.Now())
+
+	opts := DefaultSyncOptions()
+	opts.Direction = ToLocal
+	opts.Delete = true
+	opts.NoIgnoreFile = true
+
+	result, err := NewSyncer(store, opts).Sync(ctx, dest, "bucket", "backup")
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if result.Downloaded != 1 || result.Deleted != 1 {
+		t.Errorf("Expected Downloaded=1 Deleted=1, got %d and %d (errors: %v)", result.Downloaded, result.Deleted, result.Errors)
+	}
+	if store.Object("bucket", "backup/a.txt") == nil {
+		t.Error("Pull with delete removed the remote source")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "stale.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected local-only stale.txt to be deleted, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "a.txt")); err != nil {
+		t.Errorf("Expected a.txt to be downloaded: %v", err)
+	}
+
+	opts.Direction = Bidirectional
+	if _, err := NewSyncer(store, opts).Sync(ctx, dest, "bucket", "backup"); err == nil {
+		t.Error("Expected delete with a bidirectional sync to be rejected")
+	}
+}
+
+func TestSync_StopFinishesInFlight(t *testing.T) {
+	ctx := context.Background()
+	src := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := memstore.New("bucket")
+
+	// Stop arrives while the first upload is starting
+	stop := make(chan struct{})
+	opts := DefaultSyncOptions()
+	opts.Concurrent = 1
+	opts.NoIgnoreFile = true
+	opts.Stop = stop
+	opts.ProgressCallback = func(status SyncStatus) {
+		if status.Phase == "Uploading" && status.FilesCompleted == 0 {
+			close(stop)
+		}
+	}
+
+	result, err := NewSyncer(store, opts).Sync(ctx, src, "bucket", "")
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Uploaded != 1 || store.Object("bucket", "a.txt") == nil {
+		t.Errorf("Expected only the in-flight a.txt to be uploaded, got %d uploads", result.Uploaded)
+	}
+}
+
+func TestSync_BidirectionalConflictPolicy(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	local := filepath.Join(dir, "notes.txt")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.WriteFile(local, []byte("local edit"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(local, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	store := memstore.New("bucket")
+	store.Put("bucket", "notes.txt", []byte("remote"), modTime)
+
+	opts := DefaultSyncOptions()
+	opts.Direction = Bidirectional
+	opts.ConflictPolicy = PreferRemote
+	opts.NoIgnoreFile = true
+
+	result, err := NewSyncer(store, opts).Sync(ctx, dir, "bucket", "")
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Resolution != "download" {
+		t.Fatalf("Expected one conflict resolved by download, got %+v", result.Conflicts)
+	}
+	if data, _ := os.ReadFile(local); string(data) != "remote" {
+		t.Errorf("Expected the remote copy to win, got %q", data)
+	}
+}
+
+func TestSync_PullFetchesChangedRemote(t *testing.T) {
+	ctx := context.Background()
+	dest := t.TempDir()
+	local := filepath.Join(dest, "report.txt")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.WriteFile(local, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(local, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	store := memstore.New("bucket")
+	store.Put("bucket", "backup/report.txt", []byte("newer remote"), time.Now())
+
+	opts := DefaultSyncOptions()
+	opts.Direction = ToLocal
+	opts.NoIgnoreFile = true
+
+	result, err := NewSyncer(store, opts).Sync(ctx, dest, "bucket", "backup")
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if result.Downloaded != 1 || result.Uploaded != 0 {
+		t.Errorf("Expected one download and no uploads, got %d and %d", result.Downloaded, result.Uploaded)
+	}
+	if data, _ := os.ReadFile(local); string(data) != "newer remote" {
+		t.Errorf("Expected the changed remote file to be fetched, got %q", data)
+	}
+}
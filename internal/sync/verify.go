@@ -0,0 +1,113 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/ryanoboyle/bb-stream/pkg/checksum"
+	"github.com/ryanoboyle/bb-stream/pkg/ignore"
+)
+
+// VerifyResult reports how a local tree compares with a remote prefix by content.
+// Nothing is transferred to produce it.
+type VerifyResult struct {
+	Matched    int      `json:"matched"`    // Files whose size and checksum agree
+	Missing    []string `json:"missing"`    // Local files with no remote copy
+	Extra      []string `json:"extra"`      // Remote files with no local copy
+	Mismatched []string `json:"mismatched"` // Files whose size or checksum differ
+	Unverified []string `json:"unverified"` // Same size, but B2 has no checksum to compare (e.g. large files)
+	Errors     []string `json:"errors"`     // Local files that couldn't be hashed
+}
+
+// OK reports whether every file matched. Unverified files don't count as differences.
+func (r *VerifyResult) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatched) == 0 && len(r.Errors) == 0
+}
+
+// Verify compares the files under localPath with those under remotePath in the
+// bucket by size and checksum, honoring the same ignore rules as Sync
+func (s *Syncer) Verify(ctx context.Context, localPath, bucketName, remotePath string) (*VerifyResult, error) {
+	localPath = filepath.Clean(localPath)
+	remotePath = normalizeRemotePrefix(remotePath)
+
+	s.reportStatus(SyncStatus{Phase: "Scanning local files"})
+	localFiles, err := ScanLocalDir(localPath, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan local directory: %w", err)
+	}
+
+	s.reportStatus(SyncStatus{Phase: "Scanning remote files"})
+	remoteObjects, err := s.client.ListObjects(ctx, bucketName, remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote objects: %w", err)
+	}
+	remoteFiles := remoteFileInfos(remoteObjects, remotePath)
+
+	matcher, err := ignoreMatcher(localPath, s.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.reportStatus(SyncStatus{Phase: "Verifying checksums"})
+	return VerifyFiles(ctx, localPath, localFiles, remoteFiles, matcher, s.opts.ChecksumAlgorithm)
+}
+
+// VerifyFiles compares local and remote file lists by size and the algo hash.
+// Local files are hashed only when a remote file of the same size has a checksum.
+func VerifyFiles(ctx context.Context, root string, local, remote []FileInfo, matcher *ignore.Matcher, algo checksum.Algorithm) (*VerifyResult, error) {
+	if matcher == nil {
+		matcher = ignore.New(nil)
+	}
+
+	remoteMap := make(map[string]FileInfo, len(remote))
+	for _, f := range remote {
+		if !f.IsDir && !matcher.Match(f.Path, false) {
+			remoteMap[f.Path] = f
+		}
+	}
+
+	result := &VerifyResult{}
+	seen := make(map[string]bool, len(local))
+	for _, f := range local {
+		if f.IsDir || matcher.Match(f.Path, false) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		seen[f.Path] = true
+
+		remoteFile, exists := remoteMap[f.Path]
+		switch {
+		case !exists:
+			result.Missing = append(result.Missing, f.Path)
+		case f.Size != remoteFile.Size:
+			result.Mismatched = append(result.Mismatched, f.Path)
+		case checksumOf(remoteFile, algo) == "":
+			result.Unverified = append(result.Unverified, f.Path)
+		default:
+			sum, err := algo.File(filepath.Join(root, filepath.FromSlash(f.Path)))
+			switch {
+			case err != nil:
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", f.Path, err))
+			case sum != checksumOf(remoteFile, algo):
+				result.Mismatched = append(result.Mismatched, f.Path)
+			default:
+				result.Matched++
+			}
+		}
+	}
+
+	for path := range remoteMap {
+		if !seen[path] {
+			result.Extra = append(result.Extra, path)
+		}
+	}
+
+	for _, list := range [][]string{result.Missing, result.Extra, result.Mismatched, result.Unverified, result.Errors} {
+		sort.Strings(list)
+	}
+	return result, nil
+}
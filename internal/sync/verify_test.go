@@ -0,0 +1,129 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ryanoboyle/bb-stream/internal/b2"
+	"github.com/ryanoboyle/bb-stream/pkg/checksum"
+)
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// listStorage serves a fixed listing and fails on any transfer
+type listStorage struct {
+	flakyStorage
+	objects []b2.ObjectInfo
+}
+
+func (l *listStorage) ListObjects(ctx context.Context, bucketName, prefix string) ([]b2.ObjectInfo, error) {
+	return l.objects, nil
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"same.txt":       "hello",
+		"changed.txt":    "local",
+		"resized.txt":    "short",
+		"large.bin":      "big data",
+		"only-local.txt": "new",
+		".DS_Store":      "ignored",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := &listStorage{
+		flakyStorage: flakyStorage{failures: 1 << 30},
+		objects: []b2.ObjectInfo{
+			{Name: "backup/same.txt", Size: 5, SHA1: sha1Hex("hello")},
+			{Name: "backup/changed.txt", Size: 5, SHA1: sha1Hex("other")},
+			{Name: "backup/resized.txt", Size: 50, SHA1: sha1Hex("longer")},
+			{Name: "backup/large.bin", Size: 8},
+			{Name: "backup/only-remote.txt", Size: 3, SHA1: sha1Hex("old")},
+		},
+	}
+
+	opts := DefaultSyncOptions()
+	opts.NoIgnoreFile = true
+	syncer := NewSyncer(nil, opts)
+	syncer.client = store
+
+	result, err := syncer.Verify(context.Background(), dir, "bucket", "backup")
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	want := &VerifyResult{
+		Matched:    1,
+		Missing:    []string{"only-local.txt"},
+		Extra:      []string{"only-remote.txt"},
+		Mismatched: []string{"changed.txt", "resized.txt"},
+		Unverified: []string{"large.bin"},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Verify() = %+v, want %+v", result, want)
+	}
+	if result.OK() {
+		t.Error("Expected OK() = false when files differ")
+	}
+	if store.uploads != 0 {
+		t.Errorf("Expected verify not to transfer anything, got %d uploads", store.uploads)
+	}
+}
+
+func TestVerifyResult_OK(t *testing.T) {
+	r := &VerifyResult{Matched: 3, Unverified: []string{"large.bin"}}
+	if !r.OK() {
+		t.Error("Expected unverified files alone not to fail verification")
+	}
+}
+
+func TestVerifyFiles_SHA256(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "alpha", "b.txt": "bravo", "c.txt": "charl"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	local, err := ScanLocalDir(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote := []FileInfo{
+		{Path: "a.txt", Size: 5, SHA1: sha1Hex("alpha"), SHA256: sha256Hex("alpha")},
+		{Path: "b.txt", Size: 5, SHA1: sha1Hex("bravo"), SHA256: sha256Hex("tampered")},
+		{Path: "c.txt", Size: 5, SHA1: sha1Hex("charl")}, // Uploaded without a SHA256
+	}
+
+	result, err := VerifyFiles(context.Background(), dir, local, remote, nil, checksum.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &VerifyResult{
+		Matched:    1,
+		Mismatched: []string{"b.txt"},
+		Unverified: []string{"c.txt"},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("VerifyFiles() = %+v, want %+v", result, want)
+	}
+}
@@ -162,6 +162,7 @@ func (bd *BatchDebouncer) Pending() int {
 type WriteCompleteWaiter struct {
 	checkInterval time.Duration
 	stableTime    time.Duration
+	maxWait       time.Duration
 }
 
 // NewWriteCompleteWaiter creates a waiter that checks for write completion
@@ -172,12 +173,24 @@ func NewWriteCompleteWaiter(checkInterval, stableTime time.Duration) *WriteCompl
 	}
 }
 
-// Wait waits for a file to stop changing size
+// WithMaxWait caps how long Wait blocks, so files that never stop growing
+// (such as logs) are still returned periodically. Zero means no cap.
+func (w *WriteCompleteWaiter) WithMaxWait(maxWait time.Duration) *WriteCompleteWaiter {
+	w.maxWait = maxWait
+	return w
+}
+
+// Wait waits for a file to stop changing size, or until the max wait elapses
 func (w *WriteCompleteWaiter) Wait(path string, getSizeFn func(string) (int64, error)) error {
 	var lastSize int64 = -1
 	stableStart := time.Time{}
+	start := time.Now()
 
 	for {
+		if w.maxWait > 0 && time.Since(start) >= w.maxWait {
+			return nil
+		}
+
 		size, err := getSizeFn(path)
 		if err != nil {
 			return err
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package watch
+
+import "os"
+
+// fileIdentity isn't available on this platform, so renames are only
+// resolved by name, after the debounce delay
+func fileIdentity(info os.FileInfo) fileID {
+	return fileID{}
+}
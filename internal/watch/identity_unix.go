@@ -0,0 +1,17 @@
+//go:build unix
+
+package watch
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the device and inode of a file, which a rename keeps
+func fileIdentity(info os.FileInfo) fileID {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+}
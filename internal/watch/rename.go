@@ -0,0 +1,126 @@
+package watch
+
+import (
+	"os"
+	"time"
+)
+
+// fileID identifies a file independently of its name, so a file renamed
+// within the watched tree is recognised under its new name. The zero value
+// means the identity is unknown.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// valid reports whether the identity is known
+func (id fileID) valid() bool {
+	return id != fileID{}
+}
+
+// stateOf returns the state of a file as recorded in the snapshot
+func stateOf(info os.FileInfo) fileState {
+	return fileState{size: info.Size(), modTime: info.ModTime(), id: fileIdentity(info)}
+}
+
+// pendingRename is a delivered file renamed away whose new name hasn't been seen yet
+type pendingRename struct {
+	state fileState
+	timer *time.Timer
+}
+
+// renamedAway holds back the event for a delivered file renamed away from
+// path. Editors save atomically by renaming the old file away and putting a
+// new one in its place, which isn't a removal; if nothing reappears at path
+// within the debounce delay, or the file shows up under another name, the
+// rename is delivered.
+func (w *Watcher) renamedAway(path string) {
+	w.snapMu.Lock()
+	state, known := w.snapshot[path]
+	w.snapMu.Unlock()
+	if !known {
+		return // Never delivered, such as an editor's temp file
+	}
+
+	w.renameMu.Lock()
+	defer w.renameMu.Unlock()
+	if prev := w.renames[path]; prev != nil {
+		prev.timer.Stop()
+	}
+	pr := &pendingRename{state: state}
+	pr.timer = time.AfterFunc(w.opts.DebounceDelay, func() { w.resolveRename(path, pr) })
+	w.renames[path] = pr
+}
+
+// resolveRename delivers a held rename unless a file has taken the old
+// name's place, in which case that file's own events upload it
+func (w *Watcher) resolveRename(path string, pr *pendingRename) {
+	w.renameMu.Lock()
+	if w.renames[path] != pr {
+		w.renameMu.Unlock()
+		return // Already paired with its new name
+	}
+	delete(w.renames, path)
+	w.renameMu.Unlock()
+
+	if _, err := os.Lstat(path); err == nil {
+		return
+	}
+	w.deliverGone(path, Rename)
+}
+
+// arrived pairs a file created at path with a held rename of the same file,
+// delivering the rename of the old name. It reports whether the file is the
+// unchanged one last delivered at path, such as a file renamed away and
+// back, so that uploading it again would be a duplicate.
+func (w *Watcher) arrived(path string, info os.FileInfo) bool {
+	state := stateOf(info)
+	if !state.id.valid() {
+		return false // Without identity a file can't be told from a new one
+	}
+
+	from := ""
+	w.renameMu.Lock()
+	for old, pr := range w.renames {
+		if pr.state.id == state.id {
+			pr.timer.Stop()
+			delete(w.renames, old)
+			from = old
+			break
+		}
+	}
+	w.renameMu.Unlock()
+	if from != "" && from != path {
+		w.deliverGone(from, Rename)
+	}
+
+	w.snapMu.Lock()
+	prev, ok := w.snapshot[path]
+	w.snapMu.Unlock()
+	return ok && prev == state
+}
+
+// deliverGone delivers the removal or rename of a file, skipping files that
+// were never delivered, since nothing was uploaded under their name
+func (w *Watcher) deliverGone(path string, op Operation) {
+	if !w.forgetFile(path) {
+		return
+	}
+	if w.opts.OnEvent != nil {
+		w.opts.OnEvent(Event{
+			Path:      path,
+			Op:        op,
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// cancelRenames drops every held rename
+func (w *Watcher) cancelRenames() {
+	w.renameMu.Lock()
+	defer w.renameMu.Unlock()
+	for path, pr := range w.renames {
+		pr.timer.Stop()
+		delete(w.renames, path)
+	}
+}
@@ -0,0 +1,151 @@
+package watch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const testDelay = 20 * time.Millisecond
+
+// eventLog records delivered events as "op name" with names relative to the watched dir
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, fmt.Sprintf("%s %s", e.Op, filepath.Base(e.Path)))
+}
+
+// settle waits for held events to be delivered and returns them
+func (l *eventLog) settle() string {
+	time.Sleep(10 * testDelay)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fmt.Sprint(l.events)
+}
+
+// newRenameTestWatcher returns a watcher over dir whose events are fed by
+// the test, with the given files already delivered
+func newRenameTestWatcher(t *testing.T, dir string, delivered ...string) (*Watcher, *eventLog) {
+	t.Helper()
+	log := &eventLog{}
+	opts := DefaultWatcherOptions()
+	opts.DebounceDelay = testDelay
+	opts.OnEvent = log.add
+
+	w, err := NewWatcher(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(w.Stop)
+	w.roots = []string{dir}
+
+	for _, name := range delivered {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		w.recordFile(path)
+	}
+	return w, log
+}
+
+// op performs a file operation and feeds the watcher the events fsnotify reports for it
+func op(t *testing.T, w *Watcher, err error, events ...fsnotify.Event) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events {
+		w.handleEvent(e)
+	}
+}
+
+func TestWatcher_VimAtomicSave(t *testing.T) {
+	dir := t.TempDir()
+	w, log := newRenameTestWatcher(t, dir, "file.txt")
+	file := filepath.Join(dir, "file.txt")
+	backup := file + "~"
+	probe := filepath.Join(dir, "4913")
+
+	// Vim checks the directory is writable, moves the original to a backup,
+	// writes the new contents and removes the backup
+	op(t, w, os.WriteFile(probe, nil, 0644), fsnotify.Event{Name: probe, Op: fsnotify.Create})
+	op(t, w, os.Remove(probe), fsnotify.Event{Name: probe, Op: fsnotify.Remove})
+	op(t, w, os.Rename(file, backup),
+		fsnotify.Event{Name: file, Op: fsnotify.Rename},
+		fsnotify.Event{Name: backup, Op: fsnotify.Create})
+	op(t, w, os.WriteFile(file, []byte("edited"), 0644),
+		fsnotify.Event{Name: file, Op: fsnotify.Create},
+		fsnotify.Event{Name: file, Op: fsnotify.Write})
+	op(t, w, os.Remove(backup), fsnotify.Event{Name: backup, Op: fsnotify.Remove})
+
+	if got := log.settle(); got != "[write file.txt]" {
+		t.Errorf("Expected a single write of the saved file, got %s", got)
+	}
+}
+
+func TestWatcher_TempFileRenamedOver(t *testing.T) {
+	dir := t.TempDir()
+	w, log := newRenameTestWatcher(t, dir, "file.txt")
+	file := filepath.Join(dir, "file.txt")
+	temp := filepath.Join(dir, ".file.txt.vsctmp")
+
+	// VS Code and most other editors write a temp file and rename it over the target
+	op(t, w, os.WriteFile(temp, []byte("edited"), 0644),
+		fsnotify.Event{Name: temp, Op: fsnotify.Create},
+		fsnotify.Event{Name: temp, Op: fsnotify.Write})
+	op(t, w, os.Rename(temp, file),
+		fsnotify.Event{Name: temp, Op: fsnotify.Rename},
+		fsnotify.Event{Name: file, Op: fsnotify.Create})
+
+	if got := log.settle(); got != "[write file.txt]" {
+		t.Errorf("Expected only the target to be written, got %s", got)
+	}
+}
+
+func TestWatcher_RenameMovesFile(t *testing.T) {
+	dir := t.TempDir()
+	w, log := newRenameTestWatcher(t, dir, "old.txt")
+	oldPath := filepath.Join(dir, "old.txt")
+	newPath := filepath.Join(dir, "new.txt")
+
+	op(t, w, os.Rename(oldPath, newPath),
+		fsnotify.Event{Name: oldPath, Op: fsnotify.Rename},
+		fsnotify.Event{Name: newPath, Op: fsnotify.Create})
+
+	if got := log.settle(); got != "[rename old.txt write new.txt]" {
+		t.Errorf("Expected the old name renamed and the new one written, got %s", got)
+	}
+}
+
+func TestWatcher_RenameAwayAndBack(t *testing.T) {
+	dir := t.TempDir()
+	w, log := newRenameTestWatcher(t, dir, "file.txt")
+	file := filepath.Join(dir, "file.txt")
+	if info, err := os.Stat(file); err != nil || !fileIdentity(info).valid() {
+		t.Skip("File identity isn't available on this platform")
+	}
+	backup := file + "~"
+
+	op(t, w, os.Rename(file, backup),
+		fsnotify.Event{Name: file, Op: fsnotify.Rename},
+		fsnotify.Event{Name: backup, Op: fsnotify.Create})
+	op(t, w, os.Rename(backup, file),
+		fsnotify.Event{Name: backup, Op: fsnotify.Rename},
+		fsnotify.Event{Name: file, Op: fsnotify.Create})
+
+	// The same unchanged file is back in place, so there's nothing to upload
+	if got := log.settle(); got != "[]" {
+		t.Errorf("Expected no events, got %s", got)
+	}
+}
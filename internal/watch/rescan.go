@@ -0,0 +1,129 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileState is the last known state of a watched file
+type fileState struct {
+	size    int64
+	modTime time.Time
+	id      fileID
+}
+
+// wantsFile reports whether events for a file would be delivered
+func (w *Watcher) wantsFile(path string) bool {
+	if w.shouldIgnore(path, false) {
+		return false
+	}
+	return len(w.opts.IncludePatterns) == 0 || w.shouldInclude(path)
+}
+
+// recordFile stores the current state of a file after its event is delivered
+func (w *Watcher) recordFile(path string) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return
+	}
+
+	w.snapMu.Lock()
+	w.snapshot[path] = stateOf(info)
+	w.snapMu.Unlock()
+}
+
+// forgetFile drops a removed file from the snapshot, reporting whether it was there
+func (w *Watcher) forgetFile(path string) bool {
+	w.snapMu.Lock()
+	defer w.snapMu.Unlock()
+	_, ok := w.snapshot[path]
+	delete(w.snapshot, path)
+	return ok
+}
+
+// scanTree walks a root and returns the state of every wanted file,
+// watching any directories that aren't watched yet
+func (w *Watcher) scanTree(root string) map[string]fileState {
+	files := make(map[string]fileState)
+
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip errors
+		}
+		if info.IsDir() {
+			if path != root && (!w.opts.Recursive || w.shouldIgnore(path, true)) {
+				return filepath.SkipDir
+			}
+			_ = w.addPath(path)
+			return nil
+		}
+		if w.wantsFile(path) {
+			files[path] = stateOf(info)
+		}
+		return nil
+	})
+
+	return files
+}
+
+// takeSnapshot records the initial state of a newly watched tree
+func (w *Watcher) takeSnapshot(root string) {
+	files := w.scanTree(root)
+
+	w.snapMu.Lock()
+	for path, state := range files {
+		w.snapshot[path] = state
+	}
+	w.snapMu.Unlock()
+}
+
+// rescan re-walks every watched tree after events were dropped and replays
+// what was missed: new or changed files as writes, vanished files as removes
+func (w *Watcher) rescan() {
+	if !w.rescanning.CompareAndSwap(false, true) {
+		return // A rescan is already in progress
+	}
+	defer w.rescanning.Store(false)
+
+	w.mu.RLock()
+	roots := append([]string{}, w.roots...)
+	w.mu.RUnlock()
+
+	current := make(map[string]fileState)
+	for _, root := range roots {
+		for path, state := range w.scanTree(root) {
+			current[path] = state
+		}
+	}
+
+	var changed, removed []string
+	w.snapMu.Lock()
+	for path, state := range current {
+		if prev, ok := w.snapshot[path]; !ok || prev != state {
+			changed = append(changed, path)
+		}
+	}
+	for path := range w.snapshot {
+		if _, ok := current[path]; !ok {
+			removed = append(removed, path)
+			delete(w.snapshot, path)
+		}
+	}
+	w.snapMu.Unlock()
+
+	// Changed files go through the debouncer, which updates the snapshot on delivery
+	for _, path := range changed {
+		w.debouncer.Trigger(path)
+	}
+
+	if w.opts.OnEvent != nil {
+		for _, path := range removed {
+			w.opts.OnEvent(Event{
+				Path:      path,
+				Op:        Remove,
+				Timestamp: time.Now(),
+			})
+		}
+	}
+}
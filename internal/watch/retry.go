@@ -0,0 +1,234 @@
+package watch
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/pkg/logging"
+)
+
+// maxRetryWait caps the backoff between attempts at a failed upload
+const maxRetryWait = 30 * time.Minute
+
+// retryEntry is a failed upload waiting for its next attempt
+type retryEntry struct {
+	Path      string    `json:"path"`
+	Attempts  int       `json:"attempts"`
+	NextRetry time.Time `json:"next_retry"`
+	LastError string    `json:"last_error,omitempty"`
+
+	timer *time.Timer
+}
+
+// retryQueue schedules failed uploads for another attempt with exponential
+// backoff. Pending entries are mirrored to a journal file, when one is set,
+// so a restarted watcher picks them up again.
+type retryQueue struct {
+	maxAttempts int
+	wait        time.Duration
+	journal     string
+	retry       func(path string) // Called when an entry is due
+
+	mu        sync.Mutex
+	entries   map[string]*retryEntry
+	stopped   bool
+	abandoned atomic.Int64 // Uploads that used every attempt
+}
+
+func newRetryQueue(maxAttempts int, wait time.Duration, journal string, retry func(path string)) *retryQueue {
+	if wait <= 0 {
+		wait = time.Second
+	}
+	return &retryQueue{
+		maxAttempts: max(maxAttempts, 1),
+		wait:        wait,
+		journal:     journal,
+		retry:       retry,
+		entries:     make(map[string]*retryEntry),
+	}
+}
+
+// JournalPath returns the retry journal for a watch of localPath into
+// bucketName/remotePath, kept under dir (normally the config directory)
+func JournalPath(dir, localPath, bucketName, remotePath string) string {
+	if abs, err := filepath.Abs(localPath); err == nil {
+		localPath = abs
+	}
+	sum := sha1.Sum([]byte(localPath + "\x00" + bucketName + "\x00" + remotePath))
+	return filepath.Join(dir, "watch-queue", hex.EncodeToString(sum[:8])+".json")
+}
+
+// load schedules the retries a previous run left in the journal
+func (q *retryQueue) load() error {
+	if q.journal == "" {
+		return nil
+	}
+	data, err := os.ReadFile(q.journal)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read retry journal: %w", err)
+	}
+
+	var entries []*retryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("invalid retry journal %s: %w", q.journal, err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, e := range entries {
+		q.entries[e.Path] = e
+		q.schedule(e)
+	}
+	return nil
+}
+
+// failed records a failed attempt at path and schedules the next one. It
+// returns the attempt number and the wait before the retry, or false once
+// path has used all its attempts and is dropped.
+func (q *retryQueue) failed(path string, err error) (int, time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e := q.entries[path]
+	if e == nil {
+		e = &retryEntry{Path: path}
+		q.entries[path] = e
+	}
+	e.Attempts++
+	e.LastError = err.Error()
+
+	if e.Attempts >= q.maxAttempts {
+		q.remove(e)
+		q.abandoned.Add(1)
+		q.save()
+		return e.Attempts, 0, false
+	}
+
+	wait := q.backoff(e.Attempts)
+	e.NextRetry = time.Now().Add(wait)
+	// After Stop the entry is only journaled, for the next run
+	if !q.stopped {
+		q.schedule(e)
+	}
+	q.save()
+	return e.Attempts, wait, true
+}
+
+// done drops any pending retry for path once it has uploaded or no longer exists
+func (q *retryQueue) done(path string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if e, ok := q.entries[path]; ok {
+		q.remove(e)
+		q.save()
+	}
+}
+
+// stop cancels the pending timers. Entries stay in the journal.
+func (q *retryQueue) stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.stopped = true
+	for _, e := range q.entries {
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+	}
+}
+
+// pending reports whether path is waiting to be retried
+func (q *retryQueue) pending(path string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.entries[path]
+	return ok
+}
+
+// len returns the number of uploads waiting to be retried
+func (q *retryQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// backoff returns the wait after a given number of failed attempts
+func (q *retryQueue) backoff(attempts int) time.Duration {
+	wait := q.wait
+	for i := 1; i < attempts && wait < maxRetryWait; i++ {
+		wait *= 2
+	}
+	return min(wait, maxRetryWait)
+}
+
+// schedule arms e's timer for its next retry. Callers hold q.mu.
+func (q *retryQueue) schedule(e *retryEntry) {
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	path := e.Path
+	e.timer = time.AfterFunc(time.Until(e.NextRetry), func() { q.retry(path) })
+}
+
+// remove drops e and cancels its timer. Callers hold q.mu.
+func (q *retryQueue) remove(e *retryEntry) {
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	delete(q.entries, e.Path)
+}
+
+// save writes the pending entries to the journal, deleting it once nothing
+// is pending. A failed write is logged rather than interrupting uploads.
+// Callers hold q.mu.
+func (q *retryQueue) save() {
+	if q.journal == "" {
+		return
+	}
+	if err := q.writeJournal(); err != nil {
+		logging.Logger().Warn("failed to save watch retry journal",
+			logging.Path(q.journal), logging.Err(err))
+	}
+}
+
+func (q *retryQueue) writeJournal() error {
+	if len(q.entries) == 0 {
+		if err := os.Remove(q.journal); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	entries := make([]*retryEntry, 0, len(q.entries))
+	for _, e := range q.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(q.journal), 0700); err != nil {
+		return err
+	}
+
+	// Replace the journal in one step so a crash can't leave it half written
+	tmp := q.journal + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.journal)
+}
@@ -0,0 +1,164 @@
+package watch
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/internal/memstore"
+)
+
+func TestRetryQueue_Backoff(t *testing.T) {
+	q := newRetryQueue(10, time.Second, "", func(string) {})
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{5, 16 * time.Second},
+		{12, maxRetryWait}, // 2048s would pass the cap
+		{100, maxRetryWait},
+	}
+	for _, tt := range tests {
+		if got := q.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestAutoUploader_GivesUpAfterMaxAttempts(t *testing.T) {
+	dir := t.TempDir()
+	// Uploads fail because the bucket doesn't exist
+	store := memstore.New("other")
+
+	opts := DefaultWatcherOptions()
+	opts.StableTime = 0
+	opts.MaxUploadAttempts = 3
+	opts.RetryWait = 10 * time.Millisecond
+	au, err := NewAutoUploader(store, dir, "bucket", "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(au.Stop)
+
+	var mu sync.Mutex
+	var retries []int
+	au.OnRetry = func(path string, attempt int, wait time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		retries = append(retries, attempt)
+	}
+	go au.worker()
+
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	au.handleEvent(Event{Path: path, Op: Write})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for au.FailedPermanently() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting to give up, %d attempts failed", au.Failed())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if got := au.Failed(); got != 3 {
+		t.Errorf("Expected 3 failed attempts, got %d", got)
+	}
+	if got := au.RetryDepth(); got != 0 {
+		t.Errorf("Expected no pending retries, got %d", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// The last attempt is given up on rather than retried
+	if len(retries) != 2 || retries[0] != 1 || retries[1] != 2 {
+		t.Errorf("Expected retries for attempts [1 2], got %v", retries)
+	}
+}
+
+func TestRetryQueue_JournalReload(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "watch-queue", "test.json")
+
+	first := newRetryQueue(5, 10*time.Millisecond, journal, func(string) {})
+	first.failed("/data/a.txt", stderrors.New("connection reset"))
+	first.failed("/data/b.txt", stderrors.New("connection reset"))
+	first.stop()
+
+	data, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatalf("Expected a journal: %v", err)
+	}
+	var entries []retryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Path != "/data/a.txt" || entries[0].LastError != "connection reset" {
+		t.Fatalf("Unexpected journal entries: %+v", entries)
+	}
+
+	// A new queue, as after a restart, picks the entries up and retries them
+	due := make(chan string, 2)
+	second := newRetryQueue(5, 10*time.Millisecond, journal, func(path string) { due <- path })
+	t.Cleanup(second.stop)
+	if err := second.load(); err != nil {
+		t.Fatal(err)
+	}
+	if !second.pending("/data/a.txt") || !second.pending("/data/b.txt") {
+		t.Fatal("Expected both journaled entries to be pending")
+	}
+
+	got := map[string]bool{}
+	for len(got) < 2 {
+		select {
+		case path := <-due:
+			got[path] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for journaled retries, got %v", got)
+		}
+	}
+
+	// The attempt count carries over from the previous run
+	if attempt, _, _ := second.failed("/data/a.txt", stderrors.New("again")); attempt != 2 {
+		t.Errorf("Expected attempt 2 after reload, got %d", attempt)
+	}
+}
+
+func TestRetryQueue_DoneUpdatesJournal(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "queue.json")
+	q := newRetryQueue(5, time.Hour, journal, func(string) {})
+	t.Cleanup(q.stop)
+
+	q.failed("/data/a.txt", stderrors.New("boom"))
+	q.failed("/data/b.txt", stderrors.New("boom"))
+
+	q.done("/data/a.txt")
+	data, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []retryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Path != "/data/b.txt" {
+		t.Errorf("Expected only b.txt journaled, got %+v", entries)
+	}
+
+	// The journal is removed once nothing is pending
+	q.done("/data/b.txt")
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Errorf("Expected the journal to be removed, got %v", err)
+	}
+	if q.len() != 0 {
+		t.Errorf("Expected an empty queue, got %d", q.len())
+	}
+}
@@ -0,0 +1,41 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	internalSync "github.com/ryanoboyle/bb-stream/internal/sync"
+)
+
+// initialScan compares the watched tree with the remote prefix, using the
+// sync planner, and queues uploads for files that are new or changed since
+// they were last uploaded. It returns the number of files queued.
+func (au *AutoUploader) initialScan(ctx context.Context) (int, error) {
+	// Remote timestamps are upload times, so a one-way plan would re-upload
+	// every file not modified at the moment it was uploaded. A bidirectional
+	// plan only uploads files modified since then; what it would download is
+	// ignored, and files that differ within the time tolerance go to the local copy.
+	opts := internalSync.DefaultSyncOptions()
+	opts.Direction = internalSync.Bidirectional
+	opts.ConflictPolicy = internalSync.PreferLocal
+	opts.DryRun = true
+	opts.IgnorePatterns = au.watcher.opts.IgnorePatterns
+	opts.NoIgnoreFile = au.watcher.opts.NoIgnoreFile
+
+	result, err := internalSync.NewSyncer(au.client, opts).Sync(ctx, au.localPath, au.bucketName, au.remotePath)
+	if err != nil {
+		return 0, fmt.Errorf("initial scan failed: %w", err)
+	}
+
+	// The planner already applied the ignore rules; the allowlist is watch-only
+	var paths []string
+	for _, rel := range result.WouldUpload {
+		path := filepath.Join(au.localPath, filepath.FromSlash(rel))
+		if len(au.watcher.opts.IncludePatterns) == 0 || au.watcher.shouldInclude(path) {
+			paths = append(paths, path)
+		}
+	}
+	au.enqueue(paths...)
+	return len(paths), nil
+}
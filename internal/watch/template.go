@@ -0,0 +1,73 @@
+package watch
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// PathData is what a PathTemplate is executed with, for one changed file
+type PathData struct {
+	// Date is the upload date as YYYY/MM/DD, for date-partitioned layouts
+	Date string
+	// Time is when the upload happens, for custom layouts such as {{.Time.Format "2006-01"}}
+	Time time.Time
+	// RelPath is the file's path relative to the watched directory, with slashes
+	RelPath string
+	// Dir is the directory part of RelPath, or "." for files at the top
+	Dir string
+	// Basename is the file name without its extension
+	Basename string
+	// Ext is the file name's extension including the dot, or ""
+	Ext string
+}
+
+// newPathData describes the file at rel, a slash path, uploaded at now
+func newPathData(rel string, now time.Time) PathData {
+	name := path.Base(rel)
+	ext := path.Ext(name)
+	return PathData{
+		Date:     now.Format("2006/01/02"),
+		Time:     now,
+		RelPath:  rel,
+		Dir:      path.Dir(rel),
+		Basename: strings.TrimSuffix(name, ext),
+		Ext:      ext,
+	}
+}
+
+// parsePathTemplate parses a PathTemplate and checks it renders a usable
+// name, so mistakes surface when the uploader is created
+func parsePathTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("path").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template: %w", err)
+	}
+	if _, err := renderPath(tmpl, newPathData("dir/file.txt", time.Now())); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// ValidatePathTemplate reports whether text is a usable PathTemplate
+func ValidatePathTemplate(text string) error {
+	_, err := parsePathTemplate(text)
+	return err
+}
+
+// renderPath executes a path template, returning a name relative to the
+// remote prefix. Names that are empty or climb out of the prefix are rejected.
+func renderPath(tmpl *template.Template, data PathData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid path template: %w", err)
+	}
+
+	name := path.Clean(strings.TrimPrefix(b.String(), "/"))
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("path template gave %q for %s, which isn't a file name under the remote prefix", b.String(), data.RelPath)
+	}
+	return name, nil
+}
@@ -0,0 +1,123 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/internal/memstore"
+)
+
+func TestRenderPath(t *testing.T) {
+	now := time.Date(2024, 6, 15, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{"{{.Date}}/{{.RelPath}}", "2024/06/15/photos/cat.jpg", false},
+		{`{{.Dir}}/{{.Basename}}-{{.Time.Format "150405"}}{{.Ext}}`, "photos/cat-103000.jpg", false},
+		{"/{{.Basename}}", "cat", false},
+		{"../{{.RelPath}}", "", true},
+		{"{{if false}}x{{end}}", "", true},
+	}
+	for _, tt := range tests {
+		tmpl, err := parsePathTemplate(tt.template)
+		if err != nil {
+			if !tt.wantErr {
+				t.Errorf("parsePathTemplate(%q) failed: %v", tt.template, err)
+			}
+			continue
+		}
+		got, err := renderPath(tmpl, newPathData("photos/cat.jpg", now))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("renderPath(%q) = %q, %v; want %q", tt.template, got, err, tt.want)
+		}
+	}
+}
+
+func TestNewAutoUploader_PathTemplate(t *testing.T) {
+	dir := t.TempDir()
+
+	opts := DefaultWatcherOptions()
+	opts.PathTemplate = "{{.Date}}/{{.RelPath}}"
+	au, err := NewAutoUploader(nil, dir, "bucket", "backups", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(au.Stop)
+	name, err := au.remoteName(filepath.Join(dir, "docs", "a.txt"))
+	want := "backups/" + time.Now().Format("2006/01/02") + "/docs/a.txt"
+	if err != nil || name != want {
+		t.Errorf("remoteName = %q, %v; want %q", name, err, want)
+	}
+
+	for _, text := range []string{"{{.Nope}}", "{{.Date"} {
+		opts := DefaultWatcherOptions()
+		opts.PathTemplate = text
+		if _, err := NewAutoUploader(nil, dir, "bucket", "backups", opts); err == nil {
+			t.Errorf("Expected template %q to be rejected", text)
+		}
+	}
+
+	opts = DefaultWatcherOptions()
+	opts.PathTemplate = "{{.RelPath}}"
+	opts.InitialScan = true
+	if _, err := NewAutoUploader(nil, dir, "bucket", "backups", opts); err == nil {
+		t.Error("Expected a path template with an initial scan to be rejected")
+	}
+}
+
+func TestAutoUploader_PathTemplateMirrorDeletes(t *testing.T) {
+	dir := t.TempDir()
+	store := memstore.New("bucket")
+
+	// Every render gives a new name, as a time-based layout does across midnight
+	opts := DefaultWatcherOptions()
+	opts.StableTime = 0
+	opts.MirrorDeletes = true
+	opts.PathTemplate = `{{.Time.Format "150405.000000000"}}/{{.RelPath}}`
+	au, err := NewAutoUploader(store, dir, "bucket", "backups", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(au.Stop)
+	deletes := make(chan error, 2)
+	au.OnDelete = func(path string, err error) { deletes <- err }
+	go au.worker()
+
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	au.handleEvent(Event{Path: path, Op: Write})
+	waitFor(t, "the upload", func() bool { return au.Uploaded() == 1 })
+
+	objects, err := store.ListObjects(context.Background(), "bucket", "backups/")
+	if err != nil || len(objects) != 1 {
+		t.Fatalf("Expected one uploaded object, got %v, %v", objects, err)
+	}
+
+	au.handleEvent(Event{Path: path, Op: Remove})
+	select {
+	case err := <-deletes:
+		if err != nil {
+			t.Fatalf("Unexpected delete error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the delete")
+	}
+	if store.Object("bucket", objects[0].Name) != nil {
+		t.Errorf("Expected %s, the uploaded name, to be deleted", objects[0].Name)
+	}
+
+	// A file this run never uploaded has no known object to delete
+	au.handleEvent(Event{Path: filepath.Join(dir, "b.txt"), Op: Remove})
+	select {
+	case err := <-deletes:
+		t.Errorf("Expected no delete for b.txt, got one (err %v)", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -2,15 +2,21 @@ package watch
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/ryanoboyle/bb-stream/internal/b2"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+	"github.com/ryanoboyle/bb-stream/pkg/ignore"
 )
 
 // Event represents a file system event
@@ -47,12 +53,57 @@ func (op Operation) String() string {
 
 // WatcherOptions configures the watcher
 type WatcherOptions struct {
-	DebounceDelay   time.Duration
-	IgnorePatterns  []string
+	DebounceDelay  time.Duration
+	IgnorePatterns []string
+	// IncludePatterns, when non-empty, is an allowlist: only files whose base
+	// name matches one of these globs (see shouldInclude) produce events
 	IncludePatterns []string
 	Recursive       bool
-	OnEvent         func(Event)
-	OnError         func(error)
+	// StableTime is how long a file's size must stay unchanged before it is
+	// uploaded, checked every CheckInterval. Zero disables the check.
+	StableTime    time.Duration
+	CheckInterval time.Duration
+	// MaxStableWait caps the stability wait so continuously growing files still upload
+	MaxStableWait time.Duration
+	// MirrorDeletes deletes the remote object when a local file is removed or renamed away
+	MirrorDeletes bool
+	// NoIgnoreFile skips loading .bbignore files from the watched tree
+	NoIgnoreFile bool
+	// MaxConcurrentUploads bounds AutoUploader's parallel uploads; further
+	// changed files wait in a queue. Values below 1 mean 1.
+	MaxConcurrentUploads int
+	// BatchDelay makes AutoUploader collect changed files until none have
+	// changed for this long, then queue the whole burst at once in path order.
+	// Zero queues each file as soon as its events are debounced.
+	BatchDelay time.Duration
+	// MaxUploadAttempts caps how often AutoUploader tries a file whose upload
+	// keeps failing before counting it as failed permanently. Values below 1
+	// mean 1, so failures aren't retried.
+	MaxUploadAttempts int
+	// RetryWait is the wait before retrying a failed upload. It doubles with
+	// each further failure, up to 30 minutes.
+	RetryWait time.Duration
+	// RetryJournal, when set, is a file recording uploads still waiting to be
+	// retried, so they resume when a watch of the same tree restarts
+	RetryJournal string
+	// InitialScan makes AutoUploader.Start compare the tree with the remote
+	// prefix before watching, and upload files that changed while nothing was
+	// watching them
+	InitialScan bool
+	// PathTemplate, when set, is a text/template giving each file's object
+	// name under the remote prefix from a PathData, such as
+	// "{{.Date}}/{{.RelPath}}" for date-partitioned backups. Unset, the local
+	// tree is mirrored as is. Since names can depend on the upload time,
+	// mirrored deletes remove the object this run last uploaded the file as,
+	// and leave files it hasn't uploaded alone. It can't be combined with
+	// InitialScan, which compares files with the mirrored names.
+	PathTemplate string
+	// DryRun makes AutoUploader report the uploads and mirrored deletes it
+	// would make, through OnUpload and OnDelete with a nil error, without
+	// changing anything in the bucket. The retry journal is left alone.
+	DryRun  bool
+	OnEvent func(Event)
+	OnError func(error)
 }
 
 // DefaultWatcherOptions returns sensible defaults
@@ -68,18 +119,45 @@ func DefaultWatcherOptions() *WatcherOptions {
 			"*.tmp",
 			"*~",
 		},
-		Recursive: true,
+		Recursive:            true,
+		StableTime:           time.Second,
+		CheckInterval:        250 * time.Millisecond,
+		MaxStableWait:        time.Minute,
+		MaxConcurrentUploads: 4,
+		MaxUploadAttempts:    10,
+		RetryWait:            5 * time.Second,
 	}
 }
 
+// WithPatterns returns a copy of the options with exclude patterns appended to
+// the ignore list and include patterns appended to the allowlist
+func (o *WatcherOptions) WithPatterns(include, exclude []string) *WatcherOptions {
+	merged := *o
+	merged.IgnorePatterns = append(append([]string{}, o.IgnorePatterns...), exclude...)
+	merged.IncludePatterns = append(append([]string{}, o.IncludePatterns...), include...)
+	return &merged
+}
+
 // Watcher watches a directory for changes
 type Watcher struct {
 	watcher   *fsnotify.Watcher
 	opts      *WatcherOptions
 	debouncer *Debouncer
+	ignore    *ignore.Matcher            // The configured patterns, for roots without their own
+	matchers  map[string]*ignore.Matcher // Each root's patterns and .bbignore files
 	watching  map[string]struct{}
 	mu        sync.RWMutex
 	done      chan struct{}
+
+	// Recovery state for dropped events; see rescan.go
+	roots      []string
+	snapshot   map[string]fileState
+	snapMu     sync.Mutex
+	rescanning atomic.Bool
+
+	// Renames held back until their new name is known; see rename.go
+	renames  map[string]*pendingRename
+	renameMu sync.Mutex
 }
 
 // NewWatcher creates a new file system watcher
@@ -96,12 +174,17 @@ func NewWatcher(opts *WatcherOptions) (*Watcher, error) {
 	w := &Watcher{
 		watcher:  fsWatcher,
 		opts:     opts,
+		ignore:   ignore.New(opts.IgnorePatterns),
+		matchers: make(map[string]*ignore.Matcher),
 		watching: make(map[string]struct{}),
 		done:     make(chan struct{}),
+		snapshot: make(map[string]fileState),
+		renames:  make(map[string]*pendingRename),
 	}
 
 	// Set up debouncer
 	w.debouncer = NewDebouncer(opts.DebounceDelay, func(path string) {
+		w.recordFile(path)
 		if w.opts.OnEvent != nil {
 			w.opts.OnEvent(Event{
 				Path:      path,
@@ -126,13 +209,29 @@ func (w *Watcher) Watch(ctx context.Context, path string) error {
 		return err
 	}
 
+	// Pick up per-directory .bbignore files
+	var matcher *ignore.Matcher
+	if !w.opts.NoIgnoreFile {
+		if matcher, err = ignore.LoadDir(absPath, w.opts.IgnorePatterns); err != nil {
+			return fmt.Errorf("failed to load %s files: %w", ignore.FileName, err)
+		}
+	}
+
+	// Ignore patterns and recovery are relative to the watched roots
+	w.mu.Lock()
+	w.roots = append(w.roots, absPath)
+	if matcher != nil {
+		w.matchers[absPath] = matcher
+	}
+	w.mu.Unlock()
+
 	// If recursive, add all subdirectories
 	if w.opts.Recursive {
 		err := filepath.Walk(absPath, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return nil // Skip errors
 			}
-			if info.IsDir() && !w.shouldIgnore(path) {
+			if info.IsDir() && !w.shouldIgnore(path, true) {
 				return w.addPath(path)
 			}
 			return nil
@@ -142,6 +241,9 @@ func (w *Watcher) Watch(ctx context.Context, path string) error {
 		}
 	}
 
+	// Remember the tree so dropped events can be recovered
+	w.takeSnapshot(absPath)
+
 	// Start event loop
 	go w.eventLoop(ctx)
 
@@ -198,6 +300,10 @@ func (w *Watcher) eventLoop(ctx context.Context) {
 			if w.opts.OnError != nil {
 				w.opts.OnError(err)
 			}
+			// The kernel queue overflowed, so some events were lost
+			if stderrors.Is(err, fsnotify.ErrEventOverflow) {
+				go w.rescan()
+			}
 		}
 	}
 }
@@ -207,21 +313,21 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 	path := event.Name
 
 	// Check if we should ignore this path
-	if w.shouldIgnore(path) {
-		return
-	}
-
-	// Check if file matches include patterns (if specified)
-	if len(w.opts.IncludePatterns) > 0 && !w.shouldInclude(path) {
+	if w.shouldIgnore(path, false) {
 		return
 	}
 
-	// Handle directory creation - add to watch list
+	// Handle directory creation - add to watch list.
+	// This happens before include filtering so allowlisted files in new subdirectories are seen.
+	var created os.FileInfo
 	if event.Op&fsnotify.Create != 0 {
 		info, err := os.Stat(path)
 		if err == nil && info.IsDir() && w.opts.Recursive {
 			_ = w.addPath(path)
 		}
+		if err == nil && !info.IsDir() {
+			created = info
+		}
 	}
 
 	// Handle directory removal - remove from watch list
@@ -229,46 +335,61 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 		w.removePath(path)
 	}
 
+	// A removed or renamed file no longer needs its pending write event
+	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		w.debouncer.Cancel(path)
+	}
+
+	// Check if file matches include patterns (if specified)
+	if len(w.opts.IncludePatterns) > 0 && !w.shouldInclude(path) {
+		return
+	}
+
 	// Determine operation type
-	var op Operation
 	switch {
 	case event.Op&fsnotify.Create != 0:
-		op = Create
+		// A file renamed into place may be the one already delivered here
+		if created != nil && w.arrived(path, created) {
+			return
+		}
+		w.debouncer.Trigger(path)
 	case event.Op&fsnotify.Write != 0:
-		op = Write
+		// Debounce to wait for the file to finish writing
+		w.debouncer.Trigger(path)
 	case event.Op&fsnotify.Remove != 0:
-		op = Remove
+		w.deliverGone(path, Remove)
 	case event.Op&fsnotify.Rename != 0:
-		op = Rename
-	default:
-		return // Ignore other operations
+		w.renamedAway(path)
 	}
+}
 
-	// For write events, debounce to wait for file to finish writing
-	if op == Write || op == Create {
-		w.debouncer.Trigger(path)
-	} else if w.opts.OnEvent != nil {
-		w.opts.OnEvent(Event{
-			Path:      path,
-			Op:        op,
-			Timestamp: time.Now(),
-		})
+// shouldIgnore checks if a path matches the gitignore-style ignore patterns
+// of the watched root that contains it, relative to that root
+func (w *Watcher) shouldIgnore(path string, isDir bool) bool {
+	root, rel := w.rootOf(path)
+
+	w.mu.RLock()
+	matcher, ok := w.matchers[root]
+	if !ok {
+		matcher = w.ignore
 	}
+	w.mu.RUnlock()
+	return matcher.Match(rel, isDir)
 }
 
-// shouldIgnore checks if a path should be ignored
-func (w *Watcher) shouldIgnore(path string) bool {
-	for _, pattern := range w.opts.IgnorePatterns {
-		// Check full path
-		if strings.Contains(path, pattern) {
-			return true
-		}
-		// Check filename
-		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
-			return true
+// rootOf returns the watched root containing path and the path relative to
+// it in slash form. Paths outside every root get an empty root and their base name.
+func (w *Watcher) rootOf(path string) (root, rel string) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	for _, root := range w.roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return root, filepath.ToSlash(rel)
 		}
 	}
-	return false
+	return "", filepath.Base(path)
 }
 
 // shouldInclude checks if a path matches include patterns
@@ -286,6 +407,7 @@ func (w *Watcher) Stop() {
 	close(w.done)
 	w.watcher.Close()
 	w.debouncer.CancelAll()
+	w.cancelRenames()
 }
 
 // Paths returns the currently watched paths
@@ -302,28 +424,95 @@ func (w *Watcher) Paths() []string {
 
 // AutoUploader watches a directory and uploads changed files to B2
 type AutoUploader struct {
-	client     *b2.Client
+	client     b2.Storage
 	watcher    *Watcher
 	localPath  string
 	bucketName string
 	remotePath string
 	mu         sync.Mutex
-	uploading  map[string]struct{}
+	uploading  map[string]struct{} // Paths queued or being uploaded
+	queue      []string            // Paths waiting for a worker
+	queueReady *sync.Cond          // Signals workers when queue grows or stopped is set
+	batch      *BatchDebouncer     // Collects bursts of changes when BatchDelay is set
+	retries    *retryQueue         // Failed uploads waiting for another attempt
+	stopped    bool
+	stopOnce   sync.Once
+	workers    int
+	running    sync.WaitGroup // Workers started by Start
+	waiter     *WriteCompleteWaiter
+	mirror     bool
+	dryRun     bool
+	pathTmpl   *template.Template // Object names from WatcherOptions.PathTemplate, if set
+	uploadedAs map[string]string  // Templated object name last uploaded, by local path
 	OnUpload   func(path string, err error)
+	OnDelete   func(path string, err error)
+	// OnRetry is called when a failed upload is scheduled for another attempt
+	OnRetry func(path string, attempt int, wait time.Duration)
+	// OnScan is called with the number of files queued by the initial scan
+	OnScan func(queued int)
+
+	uploaded    atomic.Int64
+	failed      atomic.Int64
+	lastErr     error
+	lastErrTime time.Time
+	errMu       sync.Mutex
 }
 
 // NewAutoUploader creates a watcher that automatically uploads changed files
-func NewAutoUploader(client *b2.Client, localPath, bucketName, remotePath string, opts *WatcherOptions) (*AutoUploader, error) {
+func NewAutoUploader(client b2.Storage, localPath, bucketName, remotePath string, opts *WatcherOptions) (*AutoUploader, error) {
 	if opts == nil {
 		opts = DefaultWatcherOptions()
 	}
 
+	var pathTmpl *template.Template
+	if opts.PathTemplate != "" {
+		if opts.InitialScan {
+			return nil, fmt.Errorf("a path template can't be combined with an initial scan")
+		}
+		var err error
+		if pathTmpl, err = parsePathTemplate(opts.PathTemplate); err != nil {
+			return nil, err
+		}
+	}
+
+	// Events carry absolute paths, so remote names are computed against an absolute root
+	if abs, err := filepath.Abs(localPath); err == nil {
+		localPath = abs
+	}
+
 	au := &AutoUploader{
 		client:     client,
 		localPath:  localPath,
 		bucketName: bucketName,
 		remotePath: remotePath,
 		uploading:  make(map[string]struct{}),
+		workers:    max(opts.MaxConcurrentUploads, 1),
+		mirror:     opts.MirrorDeletes,
+		dryRun:     opts.DryRun,
+		pathTmpl:   pathTmpl,
+		uploadedAs: make(map[string]string),
+	}
+	au.queueReady = sync.NewCond(&au.mu)
+	journal := opts.RetryJournal
+	if opts.DryRun {
+		journal = ""
+	}
+	au.retries = newRetryQueue(opts.MaxUploadAttempts, opts.RetryWait, journal, func(path string) {
+		au.enqueue(path)
+	})
+	if opts.BatchDelay > 0 {
+		au.batch = NewBatchDebouncer(opts.BatchDelay, func(paths []string) {
+			sort.Strings(paths)
+			au.enqueue(paths...)
+		})
+	}
+
+	if opts.StableTime > 0 {
+		interval := opts.CheckInterval
+		if interval <= 0 {
+			interval = opts.StableTime / 4
+		}
+		au.waiter = NewWriteCompleteWaiter(interval, opts.StableTime).WithMaxWait(opts.MaxStableWait)
 	}
 
 	// Set up event handler
@@ -338,18 +527,143 @@ func NewAutoUploader(client *b2.Client, localPath, bucketName, remotePath string
 	return au, nil
 }
 
-// Start begins watching and uploading
+// Start begins watching and uploading. Retries left in the journal by a
+// previous run are scheduled first, then with InitialScan files that changed
+// since the last upload are queued before live watching begins. Once Stop has
+// been called, Start doesn't watch anything.
 func (au *AutoUploader) Start(ctx context.Context) error {
+	au.mu.Lock()
+	if au.stopped {
+		au.mu.Unlock()
+		return nil
+	}
+	au.running.Add(au.workers)
+	au.mu.Unlock()
+	for i := 0; i < au.workers; i++ {
+		go func() {
+			defer au.running.Done()
+			au.worker()
+		}()
+	}
+	if err := au.retries.load(); err != nil {
+		return err
+	}
+	if au.watcher.opts.InitialScan {
+		queued, err := au.initialScan(ctx)
+		if err != nil {
+			return err
+		}
+		if au.OnScan != nil {
+			au.OnScan(queued)
+		}
+	}
+
+	// Stop may have been called during the scan
+	au.mu.Lock()
+	stopped := au.stopped
+	au.mu.Unlock()
+	if stopped {
+		return nil
+	}
 	return au.watcher.Watch(ctx, au.localPath)
 }
 
-// Stop stops the auto uploader
+// Stop stops watching and waits for the workers to upload the files already
+// queued, including a batch still collecting changes. Pending retries stay in
+// the journal, if there is one. Calling Stop again has no effect.
 func (au *AutoUploader) Stop() {
-	au.watcher.Stop()
+	au.stopOnce.Do(func() {
+		au.watcher.Stop()
+		if au.batch != nil {
+			au.batch.Flush()
+		}
+		au.retries.stop()
+
+		au.mu.Lock()
+		au.stopped = true
+		au.mu.Unlock()
+		au.queueReady.Broadcast()
+		au.running.Wait()
+	})
+}
+
+// QueueDepth returns the number of changed files waiting for an upload slot
+func (au *AutoUploader) QueueDepth() int {
+	au.mu.Lock()
+	defer au.mu.Unlock()
+	return len(au.queue)
+}
+
+// DryRun reports whether uploads and deletes are only reported, not made
+func (au *AutoUploader) DryRun() bool {
+	return au.dryRun
+}
+
+// Uploaded returns the number of files uploaded successfully, or in a dry
+// run the number that would have been
+func (au *AutoUploader) Uploaded() int64 {
+	return au.uploaded.Load()
+}
+
+// Failed returns the number of failed upload attempts, including ones that will be retried
+func (au *AutoUploader) Failed() int64 {
+	return au.failed.Load()
+}
+
+// RetryDepth returns the number of failed uploads waiting to be retried
+func (au *AutoUploader) RetryDepth() int {
+	return au.retries.len()
+}
+
+// FailedPermanently returns the number of files given up on after MaxUploadAttempts
+func (au *AutoUploader) FailedPermanently() int64 {
+	return au.retries.abandoned.Load()
+}
+
+// LastError returns when the most recent upload error occurred and the error,
+// or a zero time and nil if no upload has failed
+func (au *AutoUploader) LastError() (time.Time, error) {
+	au.errMu.Lock()
+	defer au.errMu.Unlock()
+	return au.lastErrTime, au.lastErr
+}
+
+// recordResult updates the counters for an upload attempt, notifies OnUpload
+// and schedules a retry for a failure
+func (au *AutoUploader) recordResult(path string, err error) {
+	if err != nil {
+		au.failed.Add(1)
+		au.errMu.Lock()
+		au.lastErr = err
+		au.lastErrTime = time.Now()
+		au.errMu.Unlock()
+	} else {
+		au.uploaded.Add(1)
+	}
+
+	if au.OnUpload != nil {
+		au.OnUpload(path, err)
+	}
+
+	if err == nil {
+		au.retries.done(path)
+		return
+	}
+	if attempt, wait, ok := au.retries.failed(path, err); ok && au.OnRetry != nil {
+		au.OnRetry(path, attempt, wait)
+	}
 }
 
 // handleEvent handles file system events by uploading files
 func (au *AutoUploader) handleEvent(event Event) {
+	// Removes and the old name of a rename are mirrored as remote deletes
+	if event.Op == Remove || event.Op == Rename {
+		if au.mirror {
+			go au.deleteRemote(event.Path)
+		}
+		return
+	}
+
 	// Only handle create and write events
 	if event.Op != Create && event.Op != Write {
 		return
@@ -361,48 +675,158 @@ func (au *AutoUploader) handleEvent(event Event) {
 		return
 	}
 
-	// Prevent concurrent uploads of the same file
+	if au.batch != nil {
+		au.batch.Add(event.Path)
+		return
+	}
+	au.enqueue(event.Path)
+}
+
+// enqueue queues uploads for paths, skipping any already queued or uploading
+func (au *AutoUploader) enqueue(paths ...string) {
 	au.mu.Lock()
-	if _, uploading := au.uploading[event.Path]; uploading {
-		au.mu.Unlock()
+	defer au.mu.Unlock()
+	if au.stopped {
 		return
 	}
-	au.uploading[event.Path] = struct{}{}
-	au.mu.Unlock()
+	for _, path := range paths {
+		if _, uploading := au.uploading[path]; uploading {
+			continue
+		}
+		au.uploading[path] = struct{}{}
+		au.queue = append(au.queue, path)
+	}
+	au.queueReady.Broadcast()
+}
 
-	// Upload in goroutine
-	go func() {
-		defer func() {
-			au.mu.Lock()
-			delete(au.uploading, event.Path)
+// worker uploads queued files one at a time until the uploader stops and
+// the queue is empty
+func (au *AutoUploader) worker() {
+	for {
+		au.mu.Lock()
+		for len(au.queue) == 0 && !au.stopped {
+			au.queueReady.Wait()
+		}
+		if len(au.queue) == 0 {
 			au.mu.Unlock()
-		}()
-
-		// Calculate remote path
-		relPath, err := filepath.Rel(au.localPath, event.Path)
-		if err != nil {
-			if au.OnUpload != nil {
-				au.OnUpload(event.Path, err)
-			}
 			return
 		}
+		path := au.queue[0]
+		au.queue = au.queue[1:]
+		au.mu.Unlock()
+
+		au.upload(path)
 
-		remotePath := filepath.ToSlash(filepath.Join(au.remotePath, relPath))
+		au.mu.Lock()
+		delete(au.uploading, path)
+		au.mu.Unlock()
+	}
+}
 
-		// Open file
-		f, err := os.Open(event.Path)
+// upload waits for a changed file to settle and uploads it
+func (au *AutoUploader) upload(path string) {
+	// Wait for writes to settle so partially written files aren't uploaded
+	if au.waiter != nil {
+		err := au.waiter.Wait(path, fileSize)
+		if os.IsNotExist(err) {
+			au.retries.done(path)
+			return // Removed before it settled, e.g. a temp file
+		}
 		if err != nil {
-			if au.OnUpload != nil {
-				au.OnUpload(event.Path, err)
-			}
+			au.recordResult(path, err)
 			return
 		}
-		defer f.Close()
+	}
 
-		// Upload
-		err = au.client.Upload(context.Background(), au.bucketName, remotePath, f, info.Size(), nil)
-		if au.OnUpload != nil {
-			au.OnUpload(event.Path, err)
+	// Calculate remote path
+	remotePath, err := au.remoteName(path)
+	if err != nil {
+		au.recordResult(path, err)
+		return
+	}
+
+	// Open file. A batched file may have been removed while the burst settled,
+	// and a retried one since its last attempt.
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && (au.batch != nil || au.retries.pending(path)) {
+		au.retries.done(path)
+		return
+	}
+	if err != nil {
+		au.recordResult(path, err)
+		return
+	}
+	defer f.Close()
+
+	// Size may have changed while waiting
+	stat, err := f.Stat()
+	if err != nil {
+		au.recordResult(path, err)
+		return
+	}
+
+	if !au.dryRun {
+		err = au.client.Upload(context.Background(), au.bucketName, remotePath, f, stat.Size(), nil)
+	}
+	if err == nil && au.pathTmpl != nil {
+		au.mu.Lock()
+		au.uploadedAs[path] = remotePath
+		au.mu.Unlock()
+	}
+	au.recordResult(path, err)
+}
+
+// remoteName maps a local path under the watched root to its object name,
+// through the path template if there is one
+func (au *AutoUploader) remoteName(localPath string) (string, error) {
+	relPath, err := filepath.Rel(au.localPath, localPath)
+	if err != nil {
+		return "", err
+	}
+	if au.pathTmpl != nil {
+		if relPath, err = renderPath(au.pathTmpl, newPathData(filepath.ToSlash(relPath), time.Now())); err != nil {
+			return "", err
+		}
+	}
+	return filepath.ToSlash(filepath.Join(au.remotePath, relPath)), nil
+}
+
+// deleteRemote removes the object for a deleted local file.
+// Paths with no remote object, such as directories, are skipped silently.
+// A dry run only reports the delete.
+func (au *AutoUploader) deleteRemote(localPath string) {
+	var remotePath string
+	var err error
+	if au.pathTmpl != nil {
+		// Rendering the template now could give a different name
+		au.mu.Lock()
+		name, ok := au.uploadedAs[localPath]
+		delete(au.uploadedAs, localPath)
+		au.mu.Unlock()
+		if !ok {
+			return
 		}
-	}()
+		remotePath = name
+	} else {
+		remotePath, err = au.remoteName(localPath)
+	}
+	if err == nil && !au.dryRun {
+		err = au.client.DeleteObject(context.Background(), au.bucketName, remotePath)
+		if errors.IsNotFound(err) {
+			return
+		}
+	}
+
+	if au.OnDelete != nil {
+		au.OnDelete(localPath, err)
+	}
+}
+
+// fileSize returns the current size of a file
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
 }
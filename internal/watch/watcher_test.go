@@ -0,0 +1,327 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/internal/memstore"
+)
+
+func TestAutoUploader_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	store := memstore.New("bucket")
+	store.Put("bucket", "backup/old.txt", []byte("old"), time.Now())
+
+	opts := DefaultWatcherOptions()
+	opts.StableTime = 0
+	opts.MirrorDeletes = true
+	opts.DryRun = true
+	au, err := NewAutoUploader(store, dir, "bucket", "backup", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(au.Stop)
+
+	uploads := make(chan string, 1)
+	deletes := make(chan string, 1)
+	au.OnUpload = func(path string, err error) {
+		if err != nil {
+			t.Errorf("Unexpected upload error: %v", err)
+		}
+		uploads <- filepath.Base(path)
+	}
+	au.OnDelete = func(path string, err error) {
+		if err != nil {
+			t.Errorf("Unexpected delete error: %v", err)
+		}
+		deletes <- filepath.Base(path)
+	}
+	go au.worker()
+
+	path := filepath.Join(dir, "new.txt")
+	if err := os.WriteFile(path, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	au.handleEvent(Event{Path: path, Op: Write})
+	au.handleEvent(Event{Path: filepath.Join(dir, "old.txt"), Op: Remove})
+
+	for _, tc := range []struct {
+		ch   chan string
+		want string
+	}{{uploads, "new.txt"}, {deletes, "old.txt"}} {
+		select {
+		case got := <-tc.ch:
+			if got != tc.want {
+				t.Errorf("Reported %s, want %s", got, tc.want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s to be reported", tc.want)
+		}
+	}
+
+	// Nothing in the bucket changed
+	if store.Object("bucket", "backup/new.txt") != nil {
+		t.Error("Dry run uploaded new.txt")
+	}
+	if store.Object("bucket", "backup/old.txt") == nil {
+		t.Error("Dry run deleted old.txt")
+	}
+	if au.Uploaded() != 1 {
+		t.Errorf("Expected 1 would-be upload counted, got %d", au.Uploaded())
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAutoUploader_UploadsAndStops(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	dir := t.TempDir()
+	store := memstore.New("bucket")
+
+	opts := DefaultWatcherOptions()
+	opts.DebounceDelay = 10 * time.Millisecond
+	opts.StableTime = 0
+	au, err := NewAutoUploader(store, dir, "bucket", "backup", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := au.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("data "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "3 uploads", func() bool { return au.Uploaded() == 3 })
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		obj := store.Object("bucket", "backup/"+name)
+		if obj == nil {
+			t.Errorf("Expected backup/%s to be uploaded", name)
+		} else if string(obj.Data) != "data "+name {
+			t.Errorf("backup/%s has %q", name, obj.Data)
+		}
+	}
+
+	// Stop ends the workers and the watcher's goroutines
+	au.Stop()
+	cancel()
+	waitFor(t, "goroutines to exit", func() bool { return runtime.NumGoroutine() <= baseline })
+	if au.QueueDepth() != 0 {
+		t.Errorf("Expected an empty queue after Stop, got %d", au.QueueDepth())
+	}
+}
+
+// startBatchUploader starts an AutoUploader on dir that collects bursts for delay
+func startBatchUploader(t *testing.T, store *memstore.Store, dir string, delay time.Duration) *AutoUploader {
+	t.Helper()
+	opts := DefaultWatcherOptions()
+	opts.DebounceDelay = 10 * time.Millisecond
+	opts.StableTime = 0
+	opts.BatchDelay = delay
+	au, err := NewAutoUploader(store, dir, "bucket", "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	t.Cleanup(au.Stop)
+	if err := au.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return au
+}
+
+func TestAutoUploader_BatchUploadsBurst(t *testing.T) {
+	dir := t.TempDir()
+	store := memstore.New("bucket")
+	au := startBatchUploader(t, store, dir, 500*time.Millisecond)
+
+	uploads := make(chan string, 3)
+	au.OnUpload = func(path string, err error) {
+		if err != nil {
+			t.Errorf("Unexpected upload error: %v", err)
+		}
+		uploads <- filepath.Base(path)
+	}
+
+	names := []string{"a.txt", "b.txt", "c.txt"}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The burst is held until the window expires
+	waitFor(t, "the burst to be batched", func() bool { return au.batch.Pending() == len(names) })
+	if n := au.Uploaded(); n != 0 {
+		t.Fatalf("Expected no uploads while the batch is open, got %d", n)
+	}
+
+	// Then every file uploads, each reported on its own
+	got := map[string]bool{}
+	for range names {
+		select {
+		case name := <-uploads:
+			got[name] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for the batch, got %v", got)
+		}
+	}
+	for _, name := range names {
+		if !got[name] || store.Object("bucket", name) == nil {
+			t.Errorf("Expected %s to be uploaded", name)
+		}
+	}
+}
+
+func TestAutoUploader_StopFlushesBatch(t *testing.T) {
+	dir := t.TempDir()
+	store := memstore.New("bucket")
+	// The window never expires within the test
+	au := startBatchUploader(t, store, dir, time.Hour)
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "the burst to be batched", func() bool { return au.batch.Pending() == 2 })
+
+	au.Stop()
+
+	if n := au.Uploaded(); n != 2 {
+		t.Errorf("Expected the pending batch uploaded by Stop, got %d uploads", n)
+	}
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if store.Object("bucket", name) == nil {
+			t.Errorf("Expected %s to be uploaded", name)
+		}
+	}
+}
+
+func TestAutoUploader_InitialScanQueuesOfflineChanges(t *testing.T) {
+	dir := t.TempDir()
+	store := memstore.New("bucket")
+	now := time.Now()
+
+	// changed.txt was edited after its last upload; unchanged.txt wasn't
+	store.Put("bucket", "backup/changed.txt", []byte("old"), now.Add(-time.Hour))
+	store.Put("bucket", "backup/unchanged.txt", []byte("same"), now)
+	for name, mtime := range map[string]time.Time{
+		"changed.txt":   now,
+		"unchanged.txt": now.Add(-2 * time.Hour),
+		"new.txt":       now,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("local "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := DefaultWatcherOptions()
+	opts.StableTime = 0
+	opts.InitialScan = true
+	au, err := NewAutoUploader(store, dir, "bucket", "backup", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(au.Stop)
+	scanned := -1
+	au.OnScan = func(queued int) { scanned = queued }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := au.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if scanned != 2 {
+		t.Errorf("Expected the scan to queue 2 files, got %d", scanned)
+	}
+	waitFor(t, "the scanned files to upload", func() bool { return au.Uploaded() == 2 })
+
+	if obj := store.Object("bucket", "backup/changed.txt"); obj == nil || string(obj.Data) != "local changed.txt" {
+		t.Error("Expected changed.txt to be re-uploaded")
+	}
+	if store.Object("bucket", "backup/new.txt") == nil {
+		t.Error("Expected new.txt to be uploaded")
+	}
+	if obj := store.Object("bucket", "backup/unchanged.txt"); obj == nil || string(obj.Data) != "same" {
+		t.Error("Expected unchanged.txt to be left alone")
+	}
+}
+
+func TestAutoUploader_StartAfterStop(t *testing.T) {
+	au, err := NewAutoUploader(memstore.New("bucket"), t.TempDir(), "bucket", "", DefaultWatcherOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	au.Stop()
+
+	if err := au.Start(context.Background()); err != nil {
+		t.Fatalf("Start after Stop failed: %v", err)
+	}
+	if paths := au.watcher.Paths(); len(paths) != 0 {
+		t.Errorf("Expected nothing watched after Stop, got %v", paths)
+	}
+}
+
+func TestWatcher_IgnoreFilePerRoot(t *testing.T) {
+	photos, docs := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(photos, ".bbignore"), []byte("*.raw\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(docs, ".bbignore"), []byte("*.bak\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWatcher(DefaultWatcherOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(w.Stop)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	for _, dir := range []string{photos, docs} {
+		if err := w.Watch(ctx, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each root keeps its own .bbignore after the other is watched
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(photos, "a.raw"), true},
+		{filepath.Join(photos, "a.bak"), false},
+		{filepath.Join(docs, "a.bak"), true},
+		{filepath.Join(docs, "a.raw"), false},
+	}
+	for _, tt := range tests {
+		if got := w.shouldIgnore(tt.path, false); got != tt.want {
+			t.Errorf("shouldIgnore(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
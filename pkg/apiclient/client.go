@@ -0,0 +1,442 @@
+// Package apiclient talks to a running bb-stream API server over HTTP. Its
+// Client implements b2.Storage, so the CLI and sync engine can work through
+// a shared server instead of holding B2 credentials themselves.
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ryanoboyle/bb-stream/internal/b2"
+	"github.com/ryanoboyle/bb-stream/pkg/checksum"
+	"github.com/ryanoboyle/bb-stream/pkg/errors"
+	"github.com/ryanoboyle/bb-stream/pkg/progress"
+)
+
+// Client calls the bb-stream HTTP API
+type Client struct {
+	baseURL *url.URL
+	apiKey  string
+	http    *http.Client
+}
+
+var _ b2.Storage = (*Client)(nil)
+
+// New creates a client for the server at baseURL (e.g. http://localhost:8080).
+// apiKey is sent as X-API-Key on every request; leave it empty for servers
+// that don't require one.
+func New(baseURL, apiKey string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid server URL %q (expected e.g. http://localhost:8080)", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	return &Client{baseURL: u, apiKey: apiKey, http: &http.Client{}}, nil
+}
+
+// Error is a non-success response from the server
+type Error struct {
+	StatusCode int
+	Message    string // The server's "error" field, or the status text
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Unwrap maps the status code to the matching sentinel, so callers can use
+// errors.IsNotFound and friends as they would with a direct B2 client
+func (e *Error) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return errors.ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errors.ErrUnauthorized
+	case http.StatusBadRequest:
+		return errors.ErrBadRequest
+	}
+	return nil
+}
+
+// unsupported reports an operation or option the API has no endpoint for
+func unsupported(what string) error {
+	return fmt.Errorf("%s is not available through the API server: %w", what, stderrors.ErrUnsupported)
+}
+
+// endpoint builds the URL for an API path. The path is left unescaped so the
+// server's router sees object names exactly as given.
+func (c *Client) endpoint(p string, query url.Values) string {
+	u := *c.baseURL
+	u.Path += p
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// objectPath returns the API path for an object under a route such as /api/download
+func objectPath(route, bucketName, objectName string) string {
+	return route + "/" + bucketName + "/" + objectName
+}
+
+// do sends a request and returns the response, or an *Error for any status
+// of 300 and up. Callers close the body of a successful response.
+func (c *Client) do(ctx context.Context, method, p string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint(p, query), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach API server: %w", err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	var payload struct {
+		Error string `json:"error"`
+	}
+	if data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10)); json.Unmarshal(data, &payload) == nil && payload.Error != "" {
+		apiErr.Message = payload.Error
+	}
+	return nil, fmt.Errorf("%s %s: %w", method, p, apiErr)
+}
+
+// call sends a request with an optional JSON body and decodes a JSON response into out, if given
+func (c *Client) call(ctx context.Context, method, p string, query url.Values, in, out interface{}) error {
+	var body io.Reader
+	header := http.Header{}
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+		header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.do(ctx, method, p, query, body, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", method, p, err)
+	}
+	return nil
+}
+
+// Ping checks that the server is reachable and accepts the API key
+func (c *Client) Ping(ctx context.Context) error {
+	return c.call(ctx, http.MethodGet, "/api/version", nil, nil, nil)
+}
+
+// ListBucketInfo lists the buckets the server's credentials can see
+func (c *Client) ListBucketInfo(ctx context.Context) ([]b2.BucketInfo, error) {
+	var buckets []b2.BucketInfo
+	if err := c.call(ctx, http.MethodGet, "/api/buckets", nil, nil, &buckets); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// CreateBucket creates a bucket of the given type
+func (c *Client) CreateBucket(ctx context.Context, name, bucketType string) (*b2.BucketInfo, error) {
+	req := map[string]string{"name": name, "type": bucketType}
+	var info b2.BucketInfo
+	if err := c.call(ctx, http.MethodPost, "/api/buckets", nil, req, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// DeleteBucket deletes a bucket, emptying it first when force is set
+func (c *Client) DeleteBucket(ctx context.Context, name string, force bool) error {
+	query := url.Values{}
+	if force {
+		query.Set("force", "true")
+	}
+	return c.call(ctx, http.MethodDelete, "/api/buckets/"+name, query, nil, nil)
+}
+
+// ListObjects lists objects in a bucket with an optional prefix
+func (c *Client) ListObjects(ctx context.Context, bucketName, prefix string) ([]b2.ObjectInfo, error) {
+	query := url.Values{}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	var objects []b2.ObjectInfo
+	if err := c.call(ctx, http.MethodGet, "/api/buckets/"+bucketName+"/files", query, nil, &objects); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// SearchObjects runs the search on the server, so only the matches cross the
+// network rather than the whole listing
+func (c *Client) SearchObjects(ctx context.Context, bucketName, prefix, query, cursor string, limit int) (*b2.SearchResult, error) {
+	params := url.Values{"q": {query}}
+	if prefix != "" {
+		params.Set("prefix", prefix)
+	}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	var resp struct {
+		Objects []b2.ObjectInfo `json:"objects"`
+		Cursor  string          `json:"cursor"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/api/buckets/"+bucketName+"/search", params, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &b2.SearchResult{Objects: resp.Objects, Cursor: resp.Cursor}, nil
+}
+
+// GetObjectInfo reads an object's metadata from the headers of a HEAD
+// request. The SHA256 stored at upload time isn't exposed there.
+func (c *Client) GetObjectInfo(ctx context.Context, bucketName, objectName string) (*b2.ObjectInfo, error) {
+	resp, err := c.do(ctx, http.MethodHead, objectPath("/api/download", bucketName, objectName), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	info := &b2.ObjectInfo{
+		Name:        objectName,
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+		SHA1:        strings.Trim(resp.Header.Get("ETag"), `"`),
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.Timestamp = modified.Unix()
+	}
+	return info, nil
+}
+
+// DeleteObject deletes the latest version of an object
+func (c *Client) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	return c.call(ctx, http.MethodDelete, objectPath("/api/delete", bucketName, objectName), nil, nil, nil)
+}
+
+// ListObjectVersions is not exposed by the API
+func (c *Client) ListObjectVersions(ctx context.Context, bucketName, objectName string) ([]b2.ObjectVersion, error) {
+	return nil, unsupported("listing versions")
+}
+
+// DeleteVersion is not exposed by the API
+func (c *Client) DeleteVersion(ctx context.Context, bucketName, objectName, fileID string) error {
+	return unsupported("deleting a single version")
+}
+
+// checkUploadOptions rejects options the server can't apply on our behalf
+func checkUploadOptions(opts *b2.UploadOptions) error {
+	switch {
+	case opts.Compress:
+		return unsupported("gzip compression")
+	case opts.Encrypt:
+		return unsupported("client-side encryption")
+	case len(opts.Info) > 0:
+		return unsupported("custom metadata")
+	case opts.ChecksumAlgorithm == checksum.SHA256:
+		return unsupported("SHA256 checksums")
+	}
+	return nil
+}
+
+// uploadSource applies the rate limit and progress callback to an upload body
+func uploadSource(ctx context.Context, reader io.Reader, size int64, opts *b2.UploadOptions) io.Reader {
+	if opts.MaxBytesPerSec > 0 {
+		reader = progress.NewRateLimitedReader(ctx, reader, opts.MaxBytesPerSec)
+	}
+	if opts.ProgressCallback != nil {
+		reader = progress.NewReader(reader, size, opts.ProgressCallback)
+	}
+	return reader
+}
+
+// Upload uploads an object
+func (c *Client) Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts *b2.UploadOptions) error {
+	_, err := c.UploadWithResult(ctx, bucketName, objectName, reader, size, opts)
+	return err
+}
+
+// UploadWithResult streams the reader to the server as a multipart form,
+// without buffering it. Failed uploads aren't retried, and the content type
+// is chosen by the server.
+func (c *Client) UploadWithResult(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts *b2.UploadOptions) (*b2.UploadResult, error) {
+	if opts == nil {
+		opts = b2.DefaultUploadOptions()
+	}
+	if err := checkUploadOptions(opts); err != nil {
+		return nil, err
+	}
+	src := uploadSource(ctx, reader, size, opts)
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile("file", path.Base(objectName))
+		if err == nil {
+			_, err = io.Copy(part, src)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	defer pr.Close()
+
+	header := http.Header{}
+	header.Set("Content-Type", mw.FormDataContentType())
+	query := url.Values{"bucket": {bucketName}, "path": {objectName}}
+
+	resp, err := c.do(ctx, http.MethodPost, "/api/upload", query, pr, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", objectName, err)
+	}
+	defer resp.Body.Close()
+
+	var result b2.UploadResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode upload response: %w", err)
+	}
+	return &result, nil
+}
+
+// StreamUpload sends an unbounded reader as the raw request body
+func (c *Client) StreamUpload(ctx context.Context, bucketName, objectName string, reader io.Reader, opts *b2.UploadOptions) error {
+	_, err := c.StreamUploadWithResult(ctx, bucketName, objectName, reader, opts)
+	return err
+}
+
+// StreamUploadWithResult streams like StreamUpload and returns the size and
+// SHA1 the server reports for the stored object
+func (c *Client) StreamUploadWithResult(ctx context.Context, bucketName, objectName string, reader io.Reader, opts *b2.UploadOptions) (*b2.UploadResult, error) {
+	if opts == nil {
+		opts = b2.DefaultUploadOptions()
+	}
+	if err := checkUploadOptions(opts); err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	query := url.Values{"bucket": {bucketName}, "path": {objectName}}
+
+	body := uploadSource(ctx, reader, -1, opts)
+	resp, err := c.do(ctx, http.MethodPost, "/api/upload/stream", query, body, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stream upload %s: %w", objectName, err)
+	}
+	defer resp.Body.Close()
+
+	var uploaded struct {
+		Path string `json:"path"`
+		Size int64  `json:"size"`
+		SHA1 string `json:"sha1"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&uploaded); err != nil {
+		return nil, fmt.Errorf("failed to decode upload response: %w", err)
+	}
+	return &b2.UploadResult{Name: uploaded.Path, Size: uploaded.Size, SHA1: uploaded.SHA1}, nil
+}
+
+// download copies an object from one of the download routes to writer. The
+// server sends objects as stored, so gzip-encoded objects aren't decompressed.
+func (c *Client) download(ctx context.Context, route, bucketName, objectName string, writer io.Writer, opts *b2.DownloadOptions) error {
+	if opts == nil {
+		opts = b2.DefaultDownloadOptions()
+	}
+	if opts.Decrypt {
+		return unsupported("client-side decryption")
+	}
+
+	header := http.Header{}
+	if r := opts.Range; r != nil {
+		if r.End > r.Start {
+			header.Set("Range", fmt.Sprintf("bytes=%d-%d", r.Start, r.End-1))
+		} else {
+			header.Set("Range", fmt.Sprintf("bytes=%d-", r.Start))
+		}
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, objectPath(route, bucketName, objectName), nil, nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dest := writer
+	if opts.MaxBytesPerSec > 0 {
+		dest = progress.NewRateLimitedWriter(ctx, dest, opts.MaxBytesPerSec)
+	}
+	if opts.ProgressCallback != nil {
+		dest = progress.NewWriter(dest, resp.ContentLength, opts.ProgressCallback)
+	}
+
+	if _, err := io.Copy(dest, resp.Body); err != nil {
+		return fmt.Errorf("failed to download %s: %w", objectName, err)
+	}
+	return nil
+}
+
+// Download downloads an object, or the byte range in opts, to a writer
+func (c *Client) Download(ctx context.Context, bucketName, objectName string, writer io.Writer, opts *b2.DownloadOptions) error {
+	return c.download(ctx, "/api/download", bucketName, objectName, writer, opts)
+}
+
+// DownloadToFile downloads an object to a temporary file that replaces
+// localPath only once the download completes
+func (c *Client) DownloadToFile(ctx context.Context, bucketName, objectName, localPath string, opts *b2.DownloadOptions) (int64, error) {
+	return b2.WriteFileAtomic(localPath, func(w io.Writer) error {
+		return c.Download(ctx, bucketName, objectName, w, opts)
+	})
+}
+
+// StreamDownload downloads through the server's streaming endpoint, which
+// flushes as it goes. That endpoint has no range support, so ranged requests
+// use the regular download endpoint.
+func (c *Client) StreamDownload(ctx context.Context, bucketName, objectName string, writer io.Writer, opts *b2.DownloadOptions) error {
+	if opts != nil && opts.Range != nil {
+		return c.Download(ctx, bucketName, objectName, writer, opts)
+	}
+	return c.download(ctx, "/api/stream", bucketName, objectName, writer, opts)
+}
+
+// PresignedURL asks the server for a time-limited direct download URL
+func (c *Client) PresignedURL(ctx context.Context, bucketName, objectName string, validSeconds int) (string, error) {
+	query := url.Values{"expires": {strconv.Itoa(validSeconds)}}
+	var resp struct {
+		URL string `json:"url"`
+	}
+	if err := c.call(ctx, http.MethodGet, objectPath("/api/presign", bucketName, objectName), query, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.URL, nil
+}
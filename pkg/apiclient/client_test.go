@@ -0,0 +1,226 @@
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryanoboyle/bb-stream/internal/api"
+	"github.com/ryanoboyle/bb-stream/internal/b2"
+	"github.com/ryanoboyle/bb-stream/internal/memstore"
+	"github.com/ryanoboyle/bb-stream/internal/sync"
+	apperrors "github.com/ryanoboyle/bb-stream/pkg/errors"
+)
+
+// newTestClient starts an API server backed by an in-memory store
+func newTestClient(t *testing.T) (*Client, *memstore.Store) {
+	t.Helper()
+	store := memstore.New("my-bucket")
+	ts := httptest.NewServer(api.NewServer(store, 0).GetRouter())
+	t.Cleanup(ts.Close)
+
+	client, err := New(ts.URL+"/", "")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return client, store
+}
+
+func TestNew_InvalidURL(t *testing.T) {
+	for _, u := range []string{"", "localhost:8080", "ftp://host", "http://"} {
+		if _, err := New(u, ""); err == nil {
+			t.Errorf("New(%q): expected error", u)
+		}
+	}
+}
+
+func TestClient_ObjectRoundTrip(t *testing.T) {
+	client, store := newTestClient(t)
+	ctx := context.Background()
+
+	var reported int64
+	opts := b2.DefaultUploadOptions()
+	opts.ProgressCallback = func(transferred, total int64) { reported = transferred }
+	result, err := client.UploadWithResult(ctx, "my-bucket", "dir/my file.txt", strings.NewReader("hello world"), 11, opts)
+	if err != nil {
+		t.Fatalf("UploadWithResult: %v", err)
+	}
+	if result.Name != "dir/my file.txt" || result.Size != 11 || result.SHA1 == "" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if reported != 11 {
+		t.Errorf("Expected progress to reach 11 bytes, got %d", reported)
+	}
+	if obj := store.Object("my-bucket", "dir/my file.txt"); obj == nil || string(obj.Data) != "hello world" {
+		t.Fatalf("Object not stored: %+v", obj)
+	}
+
+	objects, err := client.ListObjects(ctx, "my-bucket", "dir/")
+	if err != nil || len(objects) != 1 || objects[0].Name != "dir/my file.txt" {
+		t.Fatalf("ListObjects = %v, %v", objects, err)
+	}
+
+	info, err := client.GetObjectInfo(ctx, "my-bucket", "dir/my file.txt")
+	if err != nil {
+		t.Fatalf("GetObjectInfo: %v", err)
+	}
+	if info.Size != 11 || info.SHA1 != result.SHA1 || info.Timestamp == 0 {
+		t.Errorf("Unexpected info %+v", info)
+	}
+
+	var buf bytes.Buffer
+	if err := client.Download(ctx, "my-bucket", "dir/my file.txt", &buf, nil); err != nil || buf.String() != "hello world" {
+		t.Fatalf("Download = %q, %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	ranged := b2.DefaultDownloadOptions()
+	ranged.Range = &b2.ByteRange{Start: 6, End: 11}
+	if err := client.Download(ctx, "my-bucket", "dir/my file.txt", &buf, ranged); err != nil || buf.String() != "world" {
+		t.Fatalf("ranged Download = %q, %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	if err := client.StreamDownload(ctx, "my-bucket", "dir/my file.txt", &buf, nil); err != nil || buf.String() != "hello world" {
+		t.Fatalf("StreamDownload = %q, %v", buf.String(), err)
+	}
+
+	if err := client.DeleteObject(ctx, "my-bucket", "dir/my file.txt"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if _, err := client.GetObjectInfo(ctx, "my-bucket", "dir/my file.txt"); !apperrors.IsNotFound(err) {
+		t.Errorf("Expected not found after delete, got %v", err)
+	}
+}
+
+func TestClient_StreamUploadAndDownloadToFile(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	result, err := client.StreamUploadWithResult(ctx, "my-bucket", "stream.bin", strings.NewReader("streamed"), nil)
+	if err != nil {
+		t.Fatalf("StreamUploadWithResult: %v", err)
+	}
+	if result.Size != 8 || result.SHA1 != "b0c659fe95a68fee99ce1219fb50689c877b2cbd" {
+		t.Errorf("Unexpected upload result %+v", result)
+	}
+
+	dest := filepath.Join(t.TempDir(), "sub", "out.bin")
+	n, err := client.DownloadToFile(ctx, "my-bucket", "stream.bin", dest, nil)
+	if err != nil || n != 8 {
+		t.Fatalf("DownloadToFile = %d, %v", n, err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "streamed" {
+		t.Errorf("Unexpected file contents %q", data)
+	}
+}
+
+func TestClient_Buckets(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	if err := client.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if _, err := client.CreateBucket(ctx, "new-bucket", "allPrivate"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	buckets, err := client.ListBucketInfo(ctx)
+	if err != nil || len(buckets) != 2 {
+		t.Fatalf("ListBucketInfo = %v, %v", buckets, err)
+	}
+	if err := client.DeleteBucket(ctx, "new-bucket", false); err != nil {
+		t.Fatalf("DeleteBucket: %v", err)
+	}
+}
+
+func TestClient_Search(t *testing.T) {
+	client, store := newTestClient(t)
+	for _, name := range []string{"invoice-1.pdf", "invoice-2.pdf", "notes.txt"} {
+		store.Put("my-bucket", name, []byte("x"), time.Now())
+	}
+
+	result, err := b2.SearchObjects(context.Background(), client, "my-bucket", "", "Invoice", "", 1)
+	if err != nil {
+		t.Fatalf("SearchObjects: %v", err)
+	}
+	if len(result.Objects) != 1 || result.Objects[0].Name != "invoice-1.pdf" || result.Cursor != "invoice-1.pdf" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	err := client.Download(ctx, "my-bucket", "missing.txt", &buf, nil)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected a 404 *Error, got %v", err)
+	}
+	if !apperrors.IsNotFound(err) {
+		t.Errorf("Expected IsNotFound for %v", err)
+	}
+
+	if _, err := client.ListObjectVersions(ctx, "my-bucket", "a.txt"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported from ListObjectVersions, got %v", err)
+	}
+	opts := b2.DefaultUploadOptions()
+	opts.Encrypt = true
+	if err := client.Upload(ctx, "my-bucket", "a.txt", strings.NewReader("x"), 1, opts); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported for encrypted upload, got %v", err)
+	}
+}
+
+func TestClient_APIKey(t *testing.T) {
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-API-Key")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"unauthorized"}`))
+	}))
+	defer ts.Close()
+
+	client, err := New(ts.URL, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Ping(context.Background())
+	if got != "secret" {
+		t.Errorf("Expected X-API-Key %q, got %q", "secret", got)
+	}
+	if !errors.Is(err, apperrors.ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestClient_Sync(t *testing.T) {
+	client, store := newTestClient(t)
+	store.Put("my-bucket", "backup/old.txt", []byte("old"), time.Now())
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := sync.DefaultSyncOptions()
+	opts.Direction = sync.ToRemote
+	result, err := sync.NewSyncer(client, opts).Sync(context.Background(), dir, "my-bucket", "backup")
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if result.Uploaded != 1 {
+		t.Errorf("Expected 1 upload, got %+v", result)
+	}
+	if obj := store.Object("my-bucket", "backup/new.txt"); obj == nil || string(obj.Data) != "new" {
+		t.Errorf("Synced object not stored: %+v", obj)
+	}
+}
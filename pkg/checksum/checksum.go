@@ -0,0 +1,62 @@
+// Package checksum selects the content hash used to compare and verify files.
+package checksum
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// Algorithm names a content hash
+type Algorithm string
+
+const (
+	// SHA1 matches the checksum B2 computes for every upload. It's the default.
+	SHA1 Algorithm = "sha1"
+	// SHA256 is a stronger hash for integrity audits. B2 doesn't compute it,
+	// so it's stored as custom file metadata at upload time.
+	SHA256 Algorithm = "sha256"
+)
+
+// Parse returns the algorithm named by s; an empty name selects SHA1
+func Parse(s string) (Algorithm, error) {
+	switch Algorithm(strings.ToLower(strings.TrimSpace(s))) {
+	case "", SHA1:
+		return SHA1, nil
+	case SHA256:
+		return SHA256, nil
+	}
+	return "", fmt.Errorf("unknown checksum algorithm %q (use sha1 or sha256)", s)
+}
+
+// New returns a hash for the algorithm; the zero Algorithm is SHA1
+func (a Algorithm) New() hash.Hash {
+	if a == SHA256 {
+		return sha256.New()
+	}
+	return sha1.New()
+}
+
+// Reader hashes everything read from r and returns the hex digest
+func (a Algorithm) Reader(r io.Reader) (string, error) {
+	h := a.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// File hashes the file at path and returns the hex digest
+func (a Algorithm) File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return a.Reader(f)
+}
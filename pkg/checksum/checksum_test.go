@@ -0,0 +1,56 @@
+package checksum
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Algorithm
+		wantErr bool
+	}{
+		{"", SHA1, false},
+		{"sha1", SHA1, false},
+		{"SHA256", SHA256, false},
+		{"md5", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Parse(%q) = (%q, %v), want %q (err %v)", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		algo Algorithm
+		want string
+	}{
+		{SHA1, "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"},
+		{SHA256, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{"", "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"},
+	}
+	for _, tt := range tests {
+		got, err := tt.algo.File(path)
+		if err != nil {
+			t.Fatalf("%q.File: %v", tt.algo, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q.File() = %s, want %s", tt.algo, got, tt.want)
+		}
+	}
+
+	if _, err := SHA1.File(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
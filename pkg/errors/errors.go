@@ -14,8 +14,12 @@ var (
 	ErrBadRequest     = errors.New("invalid request")
 	ErrInternalError  = errors.New("internal server error")
 	ErrBucketNotFound = errors.New("bucket not found")
+	ErrBucketExists   = errors.New("bucket already exists")
+	ErrBucketNotEmpty = errors.New("bucket is not empty")
 	ErrObjectNotFound = errors.New("object not found")
+	ErrEncrypted      = errors.New("object is encrypted")
 	ErrPathTraversal  = errors.New("path traversal not allowed")
+	ErrSourceChanged  = errors.New("source file changed")
 )
 
 // AppError wraps an error with a user-safe message.
@@ -76,10 +80,18 @@ func Sanitize(err error) string {
 		return "Invalid request"
 	case errors.Is(err, ErrBucketNotFound):
 		return "Bucket not found"
+	case errors.Is(err, ErrBucketExists):
+		return "Bucket already exists"
+	case errors.Is(err, ErrBucketNotEmpty):
+		return "Bucket is not empty"
 	case errors.Is(err, ErrObjectNotFound):
 		return "Object not found"
 	case errors.Is(err, ErrPathTraversal):
 		return "Invalid path"
+	case errors.Is(err, ErrEncrypted):
+		return "Object is encrypted"
+	case errors.Is(err, ErrSourceChanged):
+		return "Source file changed"
 	}
 
 	// Map known error patterns to safe messages
@@ -115,6 +127,25 @@ func Sanitize(err error) string {
 	return "An error occurred"
 }
 
+// Detail returns the full error message for trusted contexts such as the CLI.
+// Unlike Error on an AppError, it includes the wrapped internal error.
+// Never send the result to API clients; use Sanitize there.
+func Detail(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	msg := err.Error()
+	var appErr *AppError
+	if errors.As(err, &appErr) && appErr.Err != nil {
+		inner := Detail(appErr.Err)
+		if !strings.Contains(msg, inner) {
+			msg += ": " + inner
+		}
+	}
+	return msg
+}
+
 // containsAll checks if s contains all of the given substrings.
 func containsAll(s string, substrs ...string) bool {
 	for _, sub := range substrs {
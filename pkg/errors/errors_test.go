@@ -88,6 +88,9 @@ func TestSanitize(t *testing.T) {
 		{"ErrBadRequest", ErrBadRequest, "Invalid request"},
 		{"ErrBucketNotFound", ErrBucketNotFound, "Bucket not found"},
 		{"ErrObjectNotFound", ErrObjectNotFound, "Object not found"},
+		{"ErrBucketExists", ErrBucketExists, "Bucket already exists"},
+		{"ErrBucketNotEmpty", ErrBucketNotEmpty, "Bucket is not empty"},
+		{"ErrEncrypted", ErrEncrypted, "Object is encrypted"},
 		{"ErrPathTraversal", ErrPathTraversal, "Invalid path"},
 		{"wrapped ErrNotFound", fmt.Errorf("context: %w", ErrNotFound), "Resource not found"},
 		{"bucket not found pattern", errors.New("bucket 'test' not found"), "Bucket not found"},
@@ -115,6 +118,32 @@ func TestSanitize(t *testing.T) {
 	}
 }
 
+func TestDetail(t *testing.T) {
+	appErr := &AppError{Err: errors.New("dial tcp: connection refused"), Message: "safe message", StatusCode: 500}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"nil error", nil, ""},
+		{"plain error", errors.New("open /tmp/x: no such file"), "open /tmp/x: no such file"},
+		{"AppError", appErr, "safe message: dial tcp: connection refused"},
+		{"wrapped AppError", fmt.Errorf("upload: %w", appErr), "upload: safe message: dial tcp: connection refused"},
+		{"AppError without cause", &AppError{Message: "safe message"}, "safe message"},
+		{"generic error", errors.New("something unexpected"), "something unexpected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Detail(tt.err)
+			if result != tt.expected {
+				t.Errorf("Detail(%v) = %q, want %q", tt.err, result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestIsNotFound(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,76 @@
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the per-directory ignore file, in gitignore syntax
+const FileName = ".bbignore"
+
+// ReadFile parses an ignore file, skipping blank lines and # comments
+func ReadFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return patterns, nil
+}
+
+// LoadDir builds a matcher from patterns plus every .bbignore file under root.
+// Each file's patterns apply to its own subtree, and directories already
+// ignored are not searched.
+func LoadDir(root string, patterns []string) (*Matcher, error) {
+	m := New(patterns)
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil // Skip errors and files
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		} else if m.Match(rel, true) {
+			return filepath.SkipDir
+		}
+
+		filePatterns, err := ReadFile(filepath.Join(path, FileName))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, p := range filePatterns {
+			m.AddScoped(rel, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
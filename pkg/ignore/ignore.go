@@ -0,0 +1,159 @@
+// Package ignore implements gitignore-style path matching.
+package ignore
+
+import (
+	"path"
+	"strings"
+)
+
+// Matcher matches slash-separated relative paths against a list of patterns.
+//
+// Patterns follow gitignore rules:
+//   - a pattern without a slash matches a name at any depth ("*.log", "build")
+//   - a leading or inner slash anchors the pattern to the root ("/dist", "docs/*.md")
+//   - "**" matches any number of directories ("logs/**/debug.log")
+//   - a trailing slash matches directories only ("build/")
+//   - a leading "!" re-includes a path excluded by an earlier pattern
+//
+// As in git, a path inside an excluded directory cannot be re-included.
+type Matcher struct {
+	rules []rule
+}
+
+type rule struct {
+	base     []string // Directory the pattern is scoped to; empty for the root
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// New compiles patterns into a Matcher. Blank patterns are skipped.
+func New(patterns []string) *Matcher {
+	m := &Matcher{}
+	for _, p := range patterns {
+		m.Add(p)
+	}
+	return m
+}
+
+// Add appends a pattern; later patterns take precedence over earlier ones
+func (m *Matcher) Add(pattern string) {
+	m.AddScoped("", pattern)
+}
+
+// AddScoped appends a pattern that only applies beneath dir, a slash-separated
+// path relative to the root, with anchoring relative to dir
+func (m *Matcher) AddScoped(dir, pattern string) {
+	p := strings.TrimSpace(pattern)
+	if p == "" {
+		return
+	}
+
+	r := rule{}
+	if dir = strings.Trim(path.Clean("/"+dir), "/"); dir != "" {
+		r.base = strings.Split(dir, "/")
+	}
+	if strings.HasPrefix(p, "!") {
+		r.negate = true
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+
+	// Patterns without an inner slash match at any depth
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return
+	}
+
+	r.segments = strings.Split(p, "/")
+	if !anchored {
+		r.segments = append([]string{"**"}, r.segments...)
+	}
+	m.rules = append(m.rules, r)
+}
+
+// Empty reports whether the matcher has no patterns
+func (m *Matcher) Empty() bool {
+	return len(m.rules) == 0
+}
+
+// Match reports whether a relative path is ignored, either directly or
+// because one of its parent directories is
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	relPath = strings.Trim(path.Clean("/"+relPath), "/")
+	if relPath == "" || len(m.rules) == 0 {
+		return false
+	}
+
+	segments := strings.Split(relPath, "/")
+	for i := 1; i < len(segments); i++ {
+		if m.matchPath(segments[:i], true) {
+			return true
+		}
+	}
+	return m.matchPath(segments, isDir)
+}
+
+// matchPath applies every rule to a single path; the last matching rule wins
+func (m *Matcher) matchPath(segments []string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		rest, ok := trimBase(r.base, segments)
+		if !ok {
+			continue
+		}
+		if matchSegments(r.segments, rest) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// trimBase strips a scope directory from the front of a path, reporting
+// whether the path lies strictly beneath it
+func trimBase(base, segments []string) ([]string, bool) {
+	if len(segments) <= len(base) {
+		return nil, len(base) == 0
+	}
+	for i, b := range base {
+		if segments[i] != b {
+			return nil, false
+		}
+	}
+	return segments[len(base):], true
+}
+
+// matchSegments matches pattern segments against path segments, with "**"
+// matching zero or more whole segments
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(rest, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
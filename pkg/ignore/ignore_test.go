@@ -0,0 +1,116 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatcher_Match(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"bare name matches file", []string{"build"}, "build", false, true},
+		{"bare name matches nested dir", []string{"build"}, "src/build/out.o", false, true},
+		{"no substring false positive", []string{"build"}, "rebuild.go", false, false},
+		{"no prefix false positive", []string{"build"}, "src/building/x", false, false},
+		{"glob in nested dir", []string{"*.log"}, "logs/2024/app.log", false, true},
+		{"glob at root", []string{"*.log"}, "app.log", false, true},
+		{"glob no match", []string{"*.log"}, "app.log.txt", false, false},
+		{"anchored matches root", []string{"/dist"}, "dist/app.js", false, true},
+		{"anchored skips nested", []string{"/dist"}, "web/dist/app.js", false, false},
+		{"inner slash anchors", []string{"docs/*.md"}, "docs/readme.md", false, true},
+		{"inner slash anchored nested", []string{"docs/*.md"}, "src/docs/readme.md", false, false},
+		{"double star any depth", []string{"logs/**/debug.log"}, "logs/a/b/debug.log", false, true},
+		{"double star zero dirs", []string{"logs/**/debug.log"}, "logs/debug.log", false, true},
+		{"trailing double star", []string{"tmp/**"}, "tmp/a/b.txt", false, true},
+		{"dir only skips file", []string{"cache/"}, "cache", false, false},
+		{"dir only matches dir", []string{"cache/"}, "cache", true, true},
+		{"dir only matches contents", []string{"cache/"}, "cache/data.bin", false, true},
+		{"negation re-includes", []string{"*.log", "!keep.log"}, "keep.log", false, false},
+		{"negation order matters", []string{"!keep.log", "*.log"}, "keep.log", false, true},
+		{"excluded parent wins", []string{"logs/", "!logs/keep.log"}, "logs/keep.log", false, true},
+		{"hidden dir", []string{".git"}, ".git/config", false, true},
+		{"no patterns", nil, "anything", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(tt.patterns)
+			if got := m.Match(tt.path, tt.isDir); got != tt.expected {
+				t.Errorf("Match(%q) with %v = %v, expected %v", tt.path, tt.patterns, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMatcher_AddScoped(t *testing.T) {
+	m := New(nil)
+	m.AddScoped("web", "*.map")
+	m.AddScoped("web", "/dist")
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"web/app.js.map", true},
+		{"web/src/app.js.map", true},
+		{"app.js.map", false},
+		{"web/dist/app.js", true},
+		{"web/src/dist/app.js", false},
+		{"dist/app.js", false},
+	}
+
+	for _, tt := range tests {
+		if got := m.Match(tt.path, false); got != tt.expected {
+			t.Errorf("Match(%q) = %v, expected %v", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(rel, content string) {
+		t.Helper()
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(".bbignore", "# build output\n\n*.log\n/out\n")
+	writeFile("sub/.bbignore", "*.tmp\n")
+	writeFile("out/.bbignore", "!*.log\n") // Inside an ignored dir, so never read
+
+	m, err := LoadDir(root, []string{".git"})
+	if err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{".git/config", true},
+		{"app.log", true},
+		{"sub/deep/app.log", true},
+		{"out/result.bin", true},
+		{"out/app.log", true},
+		{"sub/x.tmp", true},
+		{"x.tmp", false},
+		{"sub/main.go", false},
+	}
+
+	for _, tt := range tests {
+		if got := m.Match(tt.path, false); got != tt.expected {
+			t.Errorf("Match(%q) = %v, expected %v", tt.path, got, tt.expected)
+		}
+	}
+}
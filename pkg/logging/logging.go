@@ -3,8 +3,11 @@ package logging
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"strings"
 	"sync"
 )
 
@@ -23,6 +26,39 @@ func init() {
 	})
 }
 
+// Configure replaces the default logger with one writing to stderr at level,
+// in either "json" (the default) or human-readable "text" format.
+func Configure(level slog.Level, format string) error {
+	handler, err := newHandler(os.Stderr, level, format)
+	if err != nil {
+		return err
+	}
+	defaultLogger = slog.New(handler)
+	return nil
+}
+
+// ParseLevel parses a level name such as "debug", "info", "warn" or "error".
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q: must be debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// newHandler builds a handler for the given output format
+func newHandler(w io.Writer, level slog.Level, format string) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(format) {
+	case "", "json":
+		return slog.NewJSONHandler(w, opts), nil
+	case "text":
+		return slog.NewTextHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q: must be json or text", format)
+	}
+}
+
 // Logger returns the default logger.
 func Logger() *slog.Logger {
 	return defaultLogger
@@ -33,10 +69,26 @@ func SetLogger(l *slog.Logger) {
 	defaultLogger = l
 }
 
+// requestIDKey is the context key for the request ID
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
 // WithContext returns a logger that includes context values.
-// Can be extended to extract request ID, trace ID, etc.
+// A request ID stored with ContextWithRequestID is added as request_id.
 func WithContext(ctx context.Context) *slog.Logger {
-	// Could extract values from context here (e.g., request ID)
+	if id := RequestIDFromContext(ctx); id != "" {
+		return defaultLogger.With(RequestID(id))
+	}
 	return defaultLogger
 }
 
@@ -67,6 +119,11 @@ func JobID(id string) slog.Attr {
 	return slog.String("job_id", id)
 }
 
+// RequestID creates a request ID attribute.
+func RequestID(id string) slog.Attr {
+	return slog.String("request_id", id)
+}
+
 // Err creates an error attribute.
 func Err(err error) slog.Attr {
 	if err == nil {
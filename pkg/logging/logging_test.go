@@ -110,6 +110,25 @@ func TestWithContext(t *testing.T) {
 	}
 }
 
+func TestWithContext_RequestID(t *testing.T) {
+	original := Logger()
+	defer SetLogger(original)
+
+	var buf bytes.Buffer
+	SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	ctx := ContextWithRequestID(context.Background(), "req-123")
+	WithContext(ctx).Info("handled")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse log output: %v", err)
+	}
+	if entry["request_id"] != "req-123" {
+		t.Errorf("request_id = %v, want req-123", entry["request_id"])
+	}
+}
+
 func TestLoggerOutputFormat(t *testing.T) {
 	// Create a logger that writes to a buffer
 	var buf bytes.Buffer
@@ -144,3 +163,67 @@ func TestLoggerOutputFormat(t *testing.T) {
 		t.Errorf("got status %v, want 200", logEntry["status"])
 	}
 }
+
+func TestConfigure(t *testing.T) {
+	original := Logger()
+	defer SetLogger(original)
+
+	if err := Configure(slog.LevelDebug, "text"); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	if Logger() == original {
+		t.Error("Configure did not replace the default logger")
+	}
+	if !Logger().Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("expected debug level to be enabled")
+	}
+
+	if err := Configure(slog.LevelInfo, "xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestNewHandler_Text(t *testing.T) {
+	var buf bytes.Buffer
+	handler, err := newHandler(&buf, slog.LevelInfo, "text")
+	if err != nil {
+		t.Fatalf("newHandler() error = %v", err)
+	}
+
+	logger := slog.New(handler)
+	logger.Debug("hidden")
+	logger.Info("shown", "key", "value")
+
+	out := buf.String()
+	if bytes.Contains(buf.Bytes(), []byte("hidden")) {
+		t.Error("debug message should be filtered at info level")
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("key=value")) {
+		t.Errorf("expected text output, got %q", out)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"INFO", slog.LevelInfo, false},
+		{"warn", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", slog.LevelInfo, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevel(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
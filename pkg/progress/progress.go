@@ -3,11 +3,17 @@ package progress
 import (
 	"io"
 	"sync"
+	"time"
 )
 
 // Callback is a function that receives progress updates
 type Callback func(bytesTransferred, totalBytes int64)
 
+// DefaultInterval is the minimum time between throttled callbacks: about
+// four a second, enough for a live display without flooding a terminal or
+// WebSocket clients
+const DefaultInterval = 250 * time.Millisecond
+
 // Reader wraps an io.Reader and reports progress
 type Reader struct {
 	reader      io.Reader
@@ -15,6 +21,29 @@ type Reader struct {
 	transferred int64
 	callback    Callback
 	mu          sync.Mutex
+	throttle
+}
+
+// throttle coalesces progress callbacks to at most one per interval
+type throttle struct {
+	interval time.Duration // Zero reports every update
+	last     time.Time
+	reported int64
+}
+
+// due reports whether an update should be delivered. Final updates
+// are always delivered unless that byte count was already reported.
+func (th *throttle) due(transferred int64, final bool) bool {
+	if th.interval <= 0 {
+		return true
+	}
+	now := time.Now()
+	if (final && transferred != th.reported) || now.Sub(th.last) >= th.interval {
+		th.last = now
+		th.reported = transferred
+		return true
+	}
+	return false
 }
 
 // NewReader creates a progress-tracking reader
@@ -26,22 +55,39 @@ func NewReader(r io.Reader, total int64, callback Callback) *Reader {
 	}
 }
 
+// NewReaderThrottled creates a progress-tracking reader that calls back at most
+// once per interval. The final update at EOF or at total is always delivered.
+func NewReaderThrottled(r io.Reader, total int64, callback Callback, interval time.Duration) *Reader {
+	pr := NewReader(r, total, callback)
+	pr.interval = interval
+	return pr
+}
+
 // Read implements io.Reader
 func (pr *Reader) Read(p []byte) (int, error) {
 	n, err := pr.reader.Read(p)
-	if n > 0 {
+	if n > 0 || (err == io.EOF && pr.interval > 0) {
 		pr.mu.Lock()
 		pr.transferred += int64(n)
 		transferred := pr.transferred
+		final := err == io.EOF || (pr.total > 0 && transferred >= pr.total)
+		due := pr.due(transferred, final)
 		pr.mu.Unlock()
 
-		if pr.callback != nil {
+		if pr.callback != nil && due {
 			pr.callback(transferred, pr.total)
 		}
 	}
 	return n, err
 }
 
+// Transferred returns the number of bytes read so far
+func (pr *Reader) Transferred() int64 {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	return pr.transferred
+}
+
 // Writer wraps an io.Writer and reports progress
 type Writer struct {
 	writer      io.Writer
@@ -49,6 +95,7 @@ type Writer struct {
 	transferred int64
 	callback    Callback
 	mu          sync.Mutex
+	throttle
 }
 
 // NewWriter creates a progress-tracking writer
@@ -60,6 +107,14 @@ func NewWriter(w io.Writer, total int64, callback Callback) *Writer {
 	}
 }
 
+// NewWriterThrottled creates a progress-tracking writer that calls back at most
+// once per interval. The update that reaches total is always delivered.
+func NewWriterThrottled(w io.Writer, total int64, callback Callback, interval time.Duration) *Writer {
+	pw := NewWriter(w, total, callback)
+	pw.interval = interval
+	return pw
+}
+
 // Write implements io.Writer
 func (pw *Writer) Write(p []byte) (int, error) {
 	n, err := pw.writer.Write(p)
@@ -67,9 +122,10 @@ func (pw *Writer) Write(p []byte) (int, error) {
 		pw.mu.Lock()
 		pw.transferred += int64(n)
 		transferred := pw.transferred
+		due := pw.due(transferred, pw.total > 0 && transferred >= pw.total)
 		pw.mu.Unlock()
 
-		if pw.callback != nil {
+		if pw.callback != nil && due {
 			pw.callback(transferred, pw.total)
 		}
 	}
@@ -82,6 +138,8 @@ type Tracker struct {
 	Transferred int64
 	mu          sync.Mutex
 	callbacks   []Callback
+	onComplete  func()
+	completed   bool // Whether onComplete has fired for the current transfer
 }
 
 // NewTracker creates a new progress tracker
@@ -98,30 +156,57 @@ func (t *Tracker) Add(callback Callback) {
 	t.callbacks = append(t.callbacks, callback)
 }
 
+// OnComplete registers a callback fired once per transfer when Transferred reaches Total.
+// It replaces any previously registered completion callback.
+func (t *Tracker) OnComplete(fn func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onComplete = fn
+}
+
+// Reset starts a new transfer of total bytes, keeping registered callbacks
+func (t *Tracker) Reset(total int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Total = total
+	t.Transferred = 0
+	t.completed = false
+}
+
 // Update updates the progress and notifies callbacks
 func (t *Tracker) Update(transferred int64) {
 	t.mu.Lock()
 	t.Transferred = transferred
-	callbacks := make([]Callback, len(t.callbacks))
-	copy(callbacks, t.callbacks)
-	t.mu.Unlock()
-
-	for _, cb := range callbacks {
-		cb(transferred, t.Total)
-	}
+	t.notify()
 }
 
 // Increment adds to the transferred count
 func (t *Tracker) Increment(n int64) {
 	t.mu.Lock()
 	t.Transferred += n
-	transferred := t.Transferred
+	t.notify()
+}
+
+// notify releases the lock held by the caller, then runs the progress callbacks
+// and, the first time the transfer is complete, the completion callback
+func (t *Tracker) notify() {
+	transferred, total := t.Transferred, t.Total
 	callbacks := make([]Callback, len(t.callbacks))
 	copy(callbacks, t.callbacks)
+
+	// Decided under the lock so concurrent updates can't both fire it
+	var onComplete func()
+	if !t.completed && total >= 0 && transferred >= total {
+		t.completed = true
+		onComplete = t.onComplete
+	}
 	t.mu.Unlock()
 
 	for _, cb := range callbacks {
-		cb(transferred, t.Total)
+		cb(transferred, total)
+	}
+	if onComplete != nil {
+		onComplete()
 	}
 }
 
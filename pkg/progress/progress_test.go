@@ -0,0 +1,123 @@
+package progress
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestTracker_OnCompleteFiresOnce(t *testing.T) {
+	tr := NewTracker(1000)
+
+	var fired int32
+	tr.OnComplete(func() { atomic.AddInt32(&fired, 1) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// 100 x 20 bytes overshoots the total, so many increments see it complete
+			tr.Increment(20)
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&fired); got != 1 {
+		t.Errorf("OnComplete fired %d times, want 1", got)
+	}
+}
+
+func TestTracker_Reset(t *testing.T) {
+	tr := NewTracker(10)
+
+	var updates, completions int
+	tr.Add(func(transferred, total int64) { updates++ })
+	tr.OnComplete(func() { completions++ })
+
+	tr.Update(10)
+	tr.Reset(5)
+
+	if tr.Transferred != 0 || tr.Total != 5 {
+		t.Errorf("after Reset: transferred=%d total=%d, want 0 and 5", tr.Transferred, tr.Total)
+	}
+
+	tr.Increment(3)
+	tr.Increment(2)
+
+	if updates != 3 {
+		t.Errorf("progress callback ran %d times, want 3", updates)
+	}
+	if completions != 2 {
+		t.Errorf("OnComplete fired %d times across two transfers, want 2", completions)
+	}
+}
+
+func TestReaderThrottled_CoalescesAndReportsFinal(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 10000)
+
+	var calls int
+	var last int64
+	r := NewReaderThrottled(iotest.OneByteReader(bytes.NewReader(data)), -1, func(transferred, total int64) {
+		calls++
+		last = transferred
+	}, time.Hour)
+
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The first read and the EOF update; everything in between is coalesced
+	if calls != 2 {
+		t.Errorf("callback ran %d times, want 2", calls)
+	}
+	if last != int64(len(data)) {
+		t.Errorf("final update reported %d bytes, want %d", last, len(data))
+	}
+}
+
+func TestWriterThrottled_ReportsTotal(t *testing.T) {
+	var calls int
+	var last int64
+	w := NewWriterThrottled(io.Discard, 100, func(transferred, total int64) {
+		calls++
+		last = transferred
+	}, time.Hour)
+
+	for i := 0; i < 10; i++ {
+		if _, err := w.Write(make([]byte, 10)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if calls != 2 || last != 100 {
+		t.Errorf("got %d calls ending at %d, want 2 ending at 100", calls, last)
+	}
+}
+
+func TestReader_UnthrottledReportsEveryRead(t *testing.T) {
+	var calls int
+	r := NewReader(iotest.OneByteReader(bytes.NewReader(make([]byte, 50))), 50, func(int64, int64) { calls++ })
+
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 50 {
+		t.Errorf("callback ran %d times, want 50", calls)
+	}
+}
+
+func TestReader_TransferredWithUnknownTotal(t *testing.T) {
+	r := NewReader(bytes.NewReader(make([]byte, 1234)), -1, nil)
+
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := r.Transferred(); got != 1234 {
+		t.Errorf("Transferred() = %d, want 1234", got)
+	}
+}
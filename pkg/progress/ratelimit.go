@@ -0,0 +1,142 @@
+package progress
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket that caps throughput in bytes per second
+type Limiter struct {
+	rate   float64 // tokens (bytes) added per second
+	burst  int     // maximum tokens that can accumulate
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// NewLimiter creates a token bucket limiter allowing bytesPerSec with a one-second burst
+func NewLimiter(bytesPerSec int64) *Limiter {
+	burst := int(bytesPerSec)
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:   float64(bytesPerSec),
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Burst returns the largest chunk that can be consumed in a single WaitN call
+func (l *Limiter) Burst() int {
+	return l.burst
+}
+
+// WaitN blocks until n bytes may be transferred or ctx is cancelled.
+// n must not exceed Burst.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+		l.last = now
+
+		if l.tokens >= float64(n) {
+			l.tokens -= float64(n)
+			l.mu.Unlock()
+			return nil
+		}
+
+		wait := time.Duration((float64(n) - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// RateLimitedReader wraps an io.Reader and caps its read rate
+type RateLimitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *Limiter
+}
+
+// NewRateLimitedReader creates a reader limited to bytesPerSec.
+// Reads return ctx.Err() promptly once ctx is cancelled.
+func NewRateLimitedReader(ctx context.Context, r io.Reader, bytesPerSec int64) *RateLimitedReader {
+	return &RateLimitedReader{
+		ctx:     ctx,
+		reader:  r,
+		limiter: NewLimiter(bytesPerSec),
+	}
+}
+
+// Read implements io.Reader
+func (rl *RateLimitedReader) Read(p []byte) (int, error) {
+	if err := rl.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if len(p) > rl.limiter.Burst() {
+		p = p[:rl.limiter.Burst()]
+	}
+
+	n, err := rl.reader.Read(p)
+	if n > 0 {
+		if waitErr := rl.limiter.WaitN(rl.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// RateLimitedWriter wraps an io.Writer and caps its write rate
+type RateLimitedWriter struct {
+	ctx     context.Context
+	writer  io.Writer
+	limiter *Limiter
+}
+
+// NewRateLimitedWriter creates a writer limited to bytesPerSec.
+// Writes return ctx.Err() promptly once ctx is cancelled.
+func NewRateLimitedWriter(ctx context.Context, w io.Writer, bytesPerSec int64) *RateLimitedWriter {
+	return &RateLimitedWriter{
+		ctx:     ctx,
+		writer:  w,
+		limiter: NewLimiter(bytesPerSec),
+	}
+}
+
+// Write implements io.Writer
+func (rl *RateLimitedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > rl.limiter.Burst() {
+			chunk = chunk[:rl.limiter.Burst()]
+		}
+
+		if err := rl.limiter.WaitN(rl.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+
+		n, err := rl.writer.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
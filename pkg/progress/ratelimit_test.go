@@ -0,0 +1,49 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedReader_Throttles(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1500)
+	r := NewRateLimitedReader(context.Background(), bytes.NewReader(data), 1000)
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("read %d bytes, want %d", n, len(data))
+	}
+
+	// First 1000 bytes are covered by the initial burst, the remaining 500 take ~0.5s
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("transfer finished in %v, expected throttling to ~0.5s", elapsed)
+	}
+}
+
+func TestRateLimitedWriter_ContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var buf bytes.Buffer
+	w := NewRateLimitedWriter(ctx, &buf, 10)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := w.Write(bytes.Repeat([]byte("x"), 1000))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancellation took %v, expected prompt abort", elapsed)
+	}
+}
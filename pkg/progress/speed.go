@@ -0,0 +1,100 @@
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultSpeedWindow is how much recent history SpeedTracker averages over
+const DefaultSpeedWindow = 5 * time.Second
+
+// sample is the cumulative byte count observed at a point in time
+type sample struct {
+	at    time.Time
+	bytes int64
+}
+
+// SpeedTracker is a Tracker that keeps a rolling window of progress samples
+// to report the current transfer speed and estimated time remaining
+type SpeedTracker struct {
+	*Tracker
+	window  time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	samples []sample
+}
+
+// NewSpeedTracker creates a tracker averaging speed over window.
+// A negative total means the size is unknown, so no ETA is reported.
+func NewSpeedTracker(total int64, window time.Duration) *SpeedTracker {
+	if window <= 0 {
+		window = DefaultSpeedWindow
+	}
+	st := &SpeedTracker{
+		Tracker: NewTracker(total),
+		window:  window,
+		now:     time.Now,
+	}
+	st.Add(st.record)
+	return st
+}
+
+// Reset starts a new transfer of total bytes and forgets the speed history
+func (st *SpeedTracker) Reset(total int64) {
+	st.Tracker.Reset(total)
+	st.mu.Lock()
+	st.samples = nil
+	st.mu.Unlock()
+}
+
+// record appends a sample and drops those that fell out of the window,
+// keeping the newest expired one as the baseline for the delta
+func (st *SpeedTracker) record(transferred, _ int64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := st.now()
+	st.samples = append(st.samples, sample{at: now, bytes: transferred})
+
+	cutoff := now.Add(-st.window)
+	drop := 0
+	for drop < len(st.samples)-2 && !st.samples[drop+1].at.After(cutoff) {
+		drop++
+	}
+	st.samples = st.samples[drop:]
+}
+
+// Speed returns the recent transfer rate in bytes per second
+func (st *SpeedTracker) Speed() float64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if len(st.samples) < 2 {
+		return 0
+	}
+	first, last := st.samples[0], st.samples[len(st.samples)-1]
+	elapsed := last.at.Sub(first.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(last.bytes-first.bytes) / elapsed
+}
+
+// ETA estimates the time until the transfer completes.
+// It returns false when the total is unknown or nothing is moving.
+func (st *SpeedTracker) ETA() (time.Duration, bool) {
+	speed := st.Speed()
+
+	st.Tracker.mu.Lock()
+	remaining := st.Total - st.Transferred
+	unknown := st.Total < 0
+	st.Tracker.mu.Unlock()
+
+	if unknown || speed <= 0 {
+		return 0, false
+	}
+	if remaining <= 0 {
+		return 0, true
+	}
+	return time.Duration(float64(remaining) / speed * float64(time.Second)), true
+}
@@ -0,0 +1,54 @@
+package progress
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpeedTracker_SpeedAndETA(t *testing.T) {
+	clock := time.Unix(1700000000, 0)
+	st := NewSpeedTracker(1000, 2*time.Second)
+	st.now = func() time.Time { return clock }
+
+	if _, ok := st.ETA(); ok {
+		t.Error("expected no ETA before any progress")
+	}
+
+	st.Update(0)
+	clock = clock.Add(time.Second)
+	st.Update(100)
+
+	if speed := st.Speed(); speed != 100 {
+		t.Errorf("speed = %v, want 100", speed)
+	}
+	eta, ok := st.ETA()
+	if !ok || eta != 9*time.Second {
+		t.Errorf("ETA = %v (%v), want 9s", eta, ok)
+	}
+
+	// Old samples fall out of the window, so the speed follows the recent rate
+	for i := 0; i < 4; i++ {
+		clock = clock.Add(time.Second)
+		st.Update(100 + int64(i+1)*50)
+	}
+	if speed := st.Speed(); speed != 50 {
+		t.Errorf("speed = %v, want 50 after slowing down", speed)
+	}
+}
+
+func TestSpeedTracker_UnknownTotal(t *testing.T) {
+	clock := time.Unix(1700000000, 0)
+	st := NewSpeedTracker(-1, time.Second)
+	st.now = func() time.Time { return clock }
+
+	st.Update(0)
+	clock = clock.Add(500 * time.Millisecond)
+	st.Update(500)
+
+	if speed := st.Speed(); speed != 1000 {
+		t.Errorf("speed = %v, want 1000", speed)
+	}
+	if _, ok := st.ETA(); ok {
+		t.Error("expected no ETA for an unknown total")
+	}
+}
@@ -13,6 +13,12 @@ type Config struct {
 	InitialWait time.Duration // Initial wait time before first retry (default 100ms)
 	MaxWait     time.Duration // Maximum wait time between retries (default 5s)
 	Multiplier  float64       // Multiplier for each successive retry (default 2.0)
+	MaxElapsed  time.Duration // Total time budget across all attempts (0 = unlimited)
+
+	// OnRetry, if set, is called before each wait with the failed attempt
+	// number, its error and the upcoming wait. It is not called after the
+	// final attempt.
+	OnRetry func(attempt int, err error, nextWait time.Duration)
 }
 
 // DefaultConfig returns sensible defaults for retry behavior.
@@ -37,6 +43,14 @@ func AlwaysRetry(err error) bool {
 // It retries on errors that pass the isRetryable check.
 // Returns the last error if all attempts fail.
 func Do(ctx context.Context, cfg *Config, isRetryable IsRetryable, operation func() error) error {
+	return DoCtx(ctx, cfg, isRetryable, func(context.Context) error {
+		return operation()
+	})
+}
+
+// DoCtx is like Do but passes ctx to the operation,
+// so an in-flight attempt can observe cancellation and deadlines.
+func DoCtx(ctx context.Context, cfg *Config, isRetryable IsRetryable, operation func(ctx context.Context) error) error {
 	if cfg == nil {
 		cfg = DefaultConfig()
 	}
@@ -46,6 +60,7 @@ func Do(ctx context.Context, cfg *Config, isRetryable IsRetryable, operation fun
 
 	var lastErr error
 	wait := cfg.InitialWait
+	start := time.Now()
 
 	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
 		// Check context before attempting
@@ -55,7 +70,7 @@ func Do(ctx context.Context, cfg *Config, isRetryable IsRetryable, operation fun
 		default:
 		}
 
-		err := operation()
+		err := operation(ctx)
 		if err == nil {
 			return nil
 		}
@@ -81,6 +96,15 @@ func Do(ctx context.Context, cfg *Config, isRetryable IsRetryable, operation fun
 			sleepTime = cfg.MaxWait
 		}
 
+		// Give up if the next wait would exceed the total budget
+		if cfg.MaxElapsed > 0 && time.Since(start)+sleepTime > cfg.MaxElapsed {
+			break
+		}
+
+		if cfg.OnRetry != nil {
+			cfg.OnRetry(attempt, err, sleepTime)
+		}
+
 		// Wait with context cancellation support
 		select {
 		case <-ctx.Done():
@@ -105,3 +129,14 @@ func DoWithResult[T any](ctx context.Context, cfg *Config, isRetryable IsRetryab
 	})
 	return result, err
 }
+
+// DoWithResultCtx is like DoWithResult but passes ctx to the operation.
+func DoWithResultCtx[T any](ctx context.Context, cfg *Config, isRetryable IsRetryable, operation func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := DoCtx(ctx, cfg, isRetryable, func(ctx context.Context) error {
+		var opErr error
+		result, opErr = operation(ctx)
+		return opErr
+	})
+	return result, err
+}
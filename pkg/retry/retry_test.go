@@ -212,3 +212,106 @@ func TestAlwaysRetry(t *testing.T) {
 		t.Error("AlwaysRetry should return false for nil error")
 	}
 }
+
+func TestDoCtx_PassesContext(t *testing.T) {
+	cfg := &Config{
+		MaxAttempts: 3,
+		InitialWait: 1 * time.Millisecond,
+		MaxWait:     10 * time.Millisecond,
+		Multiplier:  2.0,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	err := DoCtx(ctx, cfg, nil, func(opCtx context.Context) error {
+		attempts++
+		<-opCtx.Done()
+		return opCtx.Err()
+	})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+}
+
+func TestDoWithResultCtx_Success(t *testing.T) {
+	cfg := &Config{
+		MaxAttempts: 2,
+		InitialWait: 1 * time.Millisecond,
+		MaxWait:     10 * time.Millisecond,
+		Multiplier:  2.0,
+	}
+
+	result, err := DoWithResultCtx(context.Background(), cfg, nil, func(ctx context.Context) (int, error) {
+		return 7, ctx.Err()
+	})
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if result != 7 {
+		t.Errorf("result = %d, want 7", result)
+	}
+}
+
+func TestDo_MaxElapsed(t *testing.T) {
+	cfg := &Config{
+		MaxAttempts: 5,
+		InitialWait: 200 * time.Millisecond,
+		MaxWait:     time.Second,
+		Multiplier:  2.0,
+		MaxElapsed:  50 * time.Millisecond,
+	}
+
+	expectedErr := errors.New("transient error")
+	attempts := 0
+	start := time.Now()
+	err := Do(context.Background(), cfg, nil, func() error {
+		attempts++
+		return expectedErr
+	})
+	elapsed := time.Since(start)
+
+	if err != expectedErr {
+		t.Errorf("error = %v, want %v", err, expectedErr)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+	if elapsed > 100*time.Millisecond {
+		t.Errorf("elapsed = %v, want early give-up", elapsed)
+	}
+}
+
+func TestDo_OnRetry(t *testing.T) {
+	var calls []int
+	cfg := &Config{
+		MaxAttempts: 3,
+		InitialWait: 1 * time.Millisecond,
+		MaxWait:     10 * time.Millisecond,
+		Multiplier:  2.0,
+		OnRetry: func(attempt int, err error, nextWait time.Duration) {
+			if err == nil {
+				t.Error("OnRetry called with nil error")
+			}
+			if nextWait <= 0 {
+				t.Errorf("nextWait = %v, want > 0", nextWait)
+			}
+			calls = append(calls, attempt)
+		},
+	}
+
+	_ = Do(context.Background(), cfg, nil, func() error {
+		return errors.New("persistent error")
+	})
+
+	// Called before each wait, never after the final attempt
+	if len(calls) != 2 || calls[0] != 1 || calls[1] != 2 {
+		t.Errorf("OnRetry attempts = %v, want [1 2]", calls)
+	}
+}
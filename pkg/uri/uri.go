@@ -0,0 +1,82 @@
+package uri
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scheme prefixes remote locations, as in b2://bucket/path/to/file
+const Scheme = "b2://"
+
+// Location is a parsed command-line argument: either an object or prefix in
+// a B2 bucket, or a local filesystem path
+type Location struct {
+	Remote bool
+	Bucket string
+	Key    string
+	Path   string // Local path when not Remote
+}
+
+// String formats remote locations as b2:// URIs and local ones as their path
+func (l Location) String() string {
+	if l.Remote {
+		return Scheme + l.Bucket + "/" + l.Key
+	}
+	return l.Path
+}
+
+// IsRemote reports whether arg uses the b2:// scheme
+func IsRemote(arg string) bool {
+	return strings.HasPrefix(arg, Scheme)
+}
+
+// Parse classifies arg by its scheme. Arguments starting with b2:// are
+// remote and everything else is a local path, so "mybucket/file" names a
+// local file here. Other schemes (s3://, gs://) are rejected.
+func Parse(arg string) (Location, error) {
+	if IsRemote(arg) {
+		return ParseRemote(arg)
+	}
+	if err := checkScheme(arg, arg); err != nil {
+		return Location{}, err
+	}
+	return Location{Path: arg}, nil
+}
+
+// ParseRemote parses an argument that must name a B2 location. Both
+// b2://bucket/key and the bare bucket/key form are accepted; the key may be
+// empty for commands that take a bucket or prefix.
+func ParseRemote(arg string) (Location, error) {
+	rest := strings.TrimPrefix(arg, Scheme)
+	if err := checkScheme(arg, rest); err != nil {
+		return Location{}, err
+	}
+
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return Location{}, fmt.Errorf("invalid location %q: missing bucket name (expected %sbucket/path)", arg, Scheme)
+	}
+	return Location{Remote: true, Bucket: bucket, Key: key}, nil
+}
+
+// ParseObject parses a remote location that must name a single object, so
+// the key can't be empty
+func ParseObject(arg string) (Location, error) {
+	loc, err := ParseRemote(arg)
+	if err != nil {
+		return Location{}, err
+	}
+	if loc.Key == "" {
+		return Location{}, fmt.Errorf("invalid location %q: missing object path (expected %sbucket/path)", arg, Scheme)
+	}
+	return loc, nil
+}
+
+// checkScheme rejects s, taken from arg, if it starts with a scheme other than b2://
+func checkScheme(arg, s string) error {
+	scheme, _, ok := strings.Cut(s, "://")
+	if !ok || scheme == "" || strings.ContainsAny(scheme, `/\.`) {
+		return nil
+	}
+	return fmt.Errorf("invalid location %q: unsupported scheme %q (expected %sbucket/path)", arg, scheme, Scheme)
+}
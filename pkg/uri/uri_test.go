@@ -0,0 +1,60 @@
+package uri
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Location
+		wantErr bool
+	}{
+		{"b2://bucket/dir/file.txt", Location{Remote: true, Bucket: "bucket", Key: "dir/file.txt"}, false},
+		{"b2://bucket", Location{Remote: true, Bucket: "bucket"}, false},
+		{"b2://bucket/", Location{Remote: true, Bucket: "bucket"}, false},
+		{"bucket/file.txt", Location{Path: "bucket/file.txt"}, false},
+		{"./local", Location{Path: "./local"}, false},
+		{"b2://", Location{}, true},
+		{"b2:///file.txt", Location{}, true},
+		{"s3://bucket/file.txt", Location{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Parse(%q) = (%+v, %v), want %+v (err %v)", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Location
+		wantErr bool
+	}{
+		{"bucket/dir/file.txt", Location{Remote: true, Bucket: "bucket", Key: "dir/file.txt"}, false},
+		{"b2://bucket/dir/", Location{Remote: true, Bucket: "bucket", Key: "dir/"}, false},
+		{"bucket", Location{Remote: true, Bucket: "bucket"}, false},
+		{"", Location{}, true},
+		{"/file.txt", Location{}, true},
+		{"gs://bucket/file.txt", Location{}, true},
+		{"b2://s3://bucket", Location{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRemote(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRemote(%q) = (%+v, %v), want %+v (err %v)", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseObject(t *testing.T) {
+	if _, err := ParseObject("b2://bucket/"); err == nil {
+		t.Error("Expected error for a location without an object path")
+	}
+	loc, err := ParseObject("bucket/a.txt")
+	if err != nil || loc.String() != "b2://bucket/a.txt" {
+		t.Errorf("ParseObject = (%v, %v), want b2://bucket/a.txt", loc, err)
+	}
+}